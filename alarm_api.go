@@ -0,0 +1,126 @@
+package main
+
+import (
+	"encoding/json"
+	"log"
+	"net/http"
+	"time"
+
+	"odysail-boat-viz/integration"
+	"odysail-boat-viz/storage"
+)
+
+// alarmCheckInterval is how often armed alarms are evaluated
+const alarmCheckInterval = time.Second
+
+var (
+	alarmMonitor *integration.AlarmMonitor
+
+	// Raised and cleared alarms for the SSE and WebSocket streams
+	alarmEvents = newEventHub[integration.AlarmEvent]()
+)
+
+// alarmRequest is the POST /api/alarms body. Omitted alarms are left as
+// they are; an anchor without latitude/longitude is set at the current
+// position.
+type alarmRequest struct {
+	Depth *struct {
+		MinDepthM float64 `json:"min_depth_m"`
+	} `json:"depth"`
+	Anchor *struct {
+		Latitude  *float64 `json:"latitude"`
+		Longitude *float64 `json:"longitude"`
+		RadiusM   float64  `json:"radius_m"`
+	} `json:"anchor"`
+}
+
+// startAlarmMonitor evaluates alarms against buffer every
+// alarmCheckInterval, logging and publishing each transition. The returned
+// function stops it.
+func startAlarmMonitor(buffer *storage.RingBuffer) func() {
+	alarmMonitor = integration.NewAlarmMonitor(buffer)
+
+	done := make(chan struct{})
+	go func() {
+		ticker := time.NewTicker(alarmCheckInterval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case now := <-ticker.C:
+				for _, evt := range alarmMonitor.Check(now) {
+					log.Printf("[Alarm] %s %s: %s", evt.Type, evt.State, evt.Message)
+					alarmEvents.Publish(evt)
+				}
+			case <-done:
+				return
+			}
+		}
+	}()
+	return func() { close(done) }
+}
+
+// handleAlarms reports (GET), arms (POST) or clears (DELETE, optional
+// ?type=depth|anchor, default both) the shallow-water and anchor-drag alarms
+func handleAlarms(w http.ResponseWriter, r *http.Request) {
+	if alarmMonitor == nil {
+		http.Error(w, "Alarm monitor not running", http.StatusServiceUnavailable)
+		return
+	}
+
+	switch r.Method {
+	case http.MethodGet:
+
+	case http.MethodPost:
+		var req alarmRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		if req.Depth != nil {
+			if err := alarmMonitor.SetDepthAlarm(req.Depth.MinDepthM); err != nil {
+				http.Error(w, err.Error(), http.StatusBadRequest)
+				return
+			}
+		}
+		if a := req.Anchor; a != nil {
+			var err error
+			switch {
+			case a.Latitude != nil && a.Longitude != nil:
+				err = alarmMonitor.SetAnchor(*a.Latitude, *a.Longitude, a.RadiusM)
+			case a.Latitude == nil && a.Longitude == nil:
+				err = alarmMonitor.SetAnchorHere(a.RadiusM)
+			default:
+				http.Error(w, "anchor needs both latitude and longitude, or neither", http.StatusBadRequest)
+				return
+			}
+			if err != nil {
+				http.Error(w, err.Error(), http.StatusBadRequest)
+				return
+			}
+			anchor := alarmMonitor.Status().Anchor
+			log.Printf("[Alarm] Anchor set at %.6f, %.6f (radius %.0f m)", anchor.Latitude, anchor.Longitude, anchor.RadiusM)
+		}
+
+	case http.MethodDelete:
+		switch r.URL.Query().Get("type") {
+		case integration.AlarmDepth:
+			alarmMonitor.ClearDepthAlarm()
+		case integration.AlarmAnchor:
+			alarmMonitor.ClearAnchor()
+		case "":
+			alarmMonitor.ClearDepthAlarm()
+			alarmMonitor.ClearAnchor()
+		default:
+			http.Error(w, "type must be \"depth\" or \"anchor\"", http.StatusBadRequest)
+			return
+		}
+
+	default:
+		http.Error(w, "GET, POST or DELETE required", http.StatusMethodNotAllowed)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	encodeJSON(w, alarmMonitor.Status())
+}
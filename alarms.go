@@ -0,0 +1,371 @@
+package integration
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"strings"
+	"sync"
+	"time"
+
+	"odysail-boat-viz/storage"
+)
+
+// Alarm types. The no-data alarms are raised when an armed alarm's input
+// goes stale: a silent depth sounder or GPS cannot be trusted to be fine.
+const (
+	AlarmDepth        = "depth"
+	AlarmAnchor       = "anchor"
+	AlarmDepthNoData  = "depth_no_data"
+	AlarmAnchorNoData = "anchor_no_data"
+)
+
+// Alarm event states
+const (
+	AlarmRaised  = "raised"
+	AlarmCleared = "cleared"
+)
+
+// Alarm tuning
+const (
+	// alarmDepthHysteresisM is how far depth must recover above the
+	// threshold before a shallow-water alarm clears, so swell over a sand
+	// bar does not toggle it every second
+	alarmDepthHysteresisM = 0.3
+
+	// alarmAnchorHysteresis is the fraction of the radius the boat must come
+	// back inside before a drag alarm clears; GPS jitter at the edge of the
+	// swing circle would otherwise flap it
+	alarmAnchorHysteresis = 0.1
+
+	// earthRadiusM is the mean Earth radius used for distances
+	earthRadiusM = 6371000.0
+)
+
+// DepthAlarm fires when depth drops below MinDepthM
+type DepthAlarm struct {
+	MinDepthM float64 `json:"min_depth_m"`
+}
+
+// AnchorAlarm fires when the vessel is more than RadiusM from the anchor
+type AnchorAlarm struct {
+	Latitude  float64 `json:"latitude"`
+	Longitude float64 `json:"longitude"`
+	RadiusM   float64 `json:"radius_m"`
+	SetAt     int64   `json:"set_at"` // unix ms
+}
+
+// AlarmEvent is an alarm being raised or cleared
+type AlarmEvent struct {
+	Type      string  `json:"type"`
+	State     string  `json:"state"`
+	Timestamp int64   `json:"timestamp"` // unix ms
+	Value     float64 `json:"value"`     // depth (m), distance from anchor (m) or data age (s)
+	Threshold float64 `json:"threshold"` // min depth, radius (m) or MaxDataAge (s)
+	Message   string  `json:"message"`
+}
+
+// AlarmState is one configured alarm as reported by Status. Value is nil
+// while no fresh reading is available, and NoData is set once that has
+// raised the alarm's no-data alarm.
+type AlarmState struct {
+	Active    bool     `json:"active"`
+	Since     int64    `json:"since,omitempty"` // unix ms the alarm was raised
+	Value     *float64 `json:"value"`
+	Threshold float64  `json:"threshold"`
+	NoData    bool     `json:"no_data"`
+}
+
+// AlarmStatus lists the configured alarms; unset alarms are nil
+type AlarmStatus struct {
+	Depth       *DepthAlarm  `json:"depth"`
+	DepthState  *AlarmState  `json:"depth_state,omitempty"`
+	Anchor      *AnchorAlarm `json:"anchor"`
+	AnchorState *AlarmState  `json:"anchor_state,omitempty"`
+}
+
+// alarmWatch tracks one alarm's last evaluation
+type alarmWatch struct {
+	active bool
+	since  time.Time
+	value  *float64
+}
+
+// AlarmMonitor evaluates shallow-water and anchor-drag alarms against the
+// latest buffered depth (128267) and position (129025, else 129029). It is
+// passive: call Check periodically and publish the events it returns.
+type AlarmMonitor struct {
+	buffer *storage.RingBuffer
+
+	// MaxDataAge is how old a depth or position may be and still be used;
+	// older data leaves the alarm state unchanged and raises the alarm's
+	// no-data alarm instead
+	MaxDataAge time.Duration
+
+	mu          sync.Mutex
+	depth       *DepthAlarm
+	anchor      *AnchorAlarm
+	depthWatch  alarmWatch
+	anchorWatch alarmWatch
+	depthData   alarmWatch // no-data alarm for depth
+	anchorData  alarmWatch // no-data alarm for position
+}
+
+func NewAlarmMonitor(buffer *storage.RingBuffer) *AlarmMonitor {
+	return &AlarmMonitor{
+		buffer:     buffer,
+		MaxDataAge: 30 * time.Second,
+	}
+}
+
+// SetDepthAlarm arms the shallow-water alarm
+func (a *AlarmMonitor) SetDepthAlarm(minDepthM float64) error {
+	if !(minDepthM > 0) || math.IsInf(minDepthM, 0) {
+		return errors.New("min_depth_m must be positive")
+	}
+
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	a.depth = &DepthAlarm{MinDepthM: minDepthM}
+	a.depthWatch = alarmWatch{}
+	a.depthData = alarmWatch{}
+	return nil
+}
+
+// ClearDepthAlarm disarms the shallow-water alarm
+func (a *AlarmMonitor) ClearDepthAlarm() {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	a.depth = nil
+	a.depthWatch = alarmWatch{}
+	a.depthData = alarmWatch{}
+}
+
+// SetAnchor arms the anchor-drag alarm around the given position
+func (a *AlarmMonitor) SetAnchor(lat, lon, radiusM float64) error {
+	if math.IsNaN(lat) || math.Abs(lat) > 90 || math.IsNaN(lon) || math.Abs(lon) > 180 {
+		return errors.New("anchor position out of range")
+	}
+	if !(radiusM > 0) || math.IsInf(radiusM, 0) {
+		return errors.New("radius_m must be positive")
+	}
+
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	a.anchor = &AnchorAlarm{
+		Latitude:  lat,
+		Longitude: lon,
+		RadiusM:   radiusM,
+		SetAt:     time.Now().UnixMilli(),
+	}
+	a.anchorWatch = alarmWatch{}
+	a.anchorData = alarmWatch{}
+	return nil
+}
+
+// SetAnchorHere arms the anchor-drag alarm around the current position
+func (a *AlarmMonitor) SetAnchorHere(radiusM float64) error {
+	lat, lon, ok := a.position(time.Now())
+	if !ok {
+		return errors.New("no current position")
+	}
+	return a.SetAnchor(lat, lon, radiusM)
+}
+
+// ClearAnchor disarms the anchor-drag alarm
+func (a *AlarmMonitor) ClearAnchor() {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	a.anchor = nil
+	a.anchorWatch = alarmWatch{}
+	a.anchorData = alarmWatch{}
+}
+
+// Status returns the configured alarms and their current state
+func (a *AlarmMonitor) Status() AlarmStatus {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	var status AlarmStatus
+	if a.depth != nil {
+		d := *a.depth
+		status.Depth = &d
+		status.DepthState = a.depthWatch.state(d.MinDepthM)
+		status.DepthState.NoData = a.depthData.active
+	}
+	if a.anchor != nil {
+		anchor := *a.anchor
+		status.Anchor = &anchor
+		status.AnchorState = a.anchorWatch.state(anchor.RadiusM)
+		status.AnchorState.NoData = a.anchorData.active
+	}
+	return status
+}
+
+func (w *alarmWatch) state(threshold float64) *AlarmState {
+	s := &AlarmState{Active: w.active, Value: w.value, Threshold: threshold}
+	if w.active {
+		s.Since = w.since.UnixMilli()
+	}
+	return s
+}
+
+// Check evaluates the armed alarms and returns the ones that were raised or
+// cleared since the previous call
+func (a *AlarmMonitor) Check(now time.Time) []AlarmEvent {
+	depth, depthOK := a.depthReading(now)
+	lat, lon, posOK := a.position(now)
+
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	var events []AlarmEvent
+	if !depthOK {
+		a.depthWatch.value = nil
+	}
+	if !posOK {
+		a.anchorWatch.value = nil
+	}
+
+	if a.depth != nil {
+		if evt, ok := a.noDataEvent(&a.depthData, now, !depthOK, "depth", 128267); ok {
+			evt.Type = AlarmDepthNoData
+			events = append(events, evt)
+		}
+	}
+	if a.anchor != nil {
+		if evt, ok := a.noDataEvent(&a.anchorData, now, !posOK, "position", 129025, 129029); ok {
+			evt.Type = AlarmAnchorNoData
+			events = append(events, evt)
+		}
+	}
+
+	if a.depth != nil && depthOK {
+		minDepth := a.depth.MinDepthM
+		a.depthWatch.value = &depth
+		if evt, ok := a.depthWatch.update(now, depth < minDepth, depth >= minDepth+alarmDepthHysteresisM); ok {
+			evt.Type = AlarmDepth
+			evt.Value = depth
+			evt.Threshold = minDepth
+			if evt.State == AlarmRaised {
+				evt.Message = fmt.Sprintf("Shallow water: %.1f m (alarm below %.1f m)", depth, minDepth)
+			} else {
+				evt.Message = fmt.Sprintf("Depth back to %.1f m", depth)
+			}
+			events = append(events, evt)
+		}
+	}
+
+	if a.anchor != nil && posOK {
+		radius := a.anchor.RadiusM
+		dist := DistanceM(a.anchor.Latitude, a.anchor.Longitude, lat, lon)
+		a.anchorWatch.value = &dist
+		if evt, ok := a.anchorWatch.update(now, dist > radius, dist <= radius*(1-alarmAnchorHysteresis)); ok {
+			evt.Type = AlarmAnchor
+			evt.Value = dist
+			evt.Threshold = radius
+			if evt.State == AlarmRaised {
+				evt.Message = fmt.Sprintf("Anchor drag: %.0f m from anchor (radius %.0f m)", dist, radius)
+			} else {
+				evt.Message = fmt.Sprintf("Back within anchor radius: %.0f m", dist)
+			}
+			events = append(events, evt)
+		}
+	}
+
+	return events
+}
+
+// noDataEvent updates a no-data alarm and reports a transition, if any.
+// Value is the age in seconds of the newest of pgns, or -1 when none has
+// been received.
+func (a *AlarmMonitor) noDataEvent(w *alarmWatch, now time.Time, stale bool, what string, pgns ...int) (AlarmEvent, bool) {
+	evt, ok := w.update(now, stale, !stale)
+	if !ok {
+		return evt, false
+	}
+
+	evt.Value = -1
+	for _, pgn := range pgns {
+		if msg := a.buffer.GetLatestByPGN(pgn); msg != nil {
+			if age := now.Sub(msg.Timestamp).Seconds(); evt.Value < 0 || age < evt.Value {
+				evt.Value = age
+			}
+		}
+	}
+	evt.Threshold = a.MaxDataAge.Seconds()
+
+	switch {
+	case evt.State == AlarmCleared:
+		evt.Message = fmt.Sprintf("%s data back", strings.ToUpper(what[:1])+what[1:])
+	case evt.Value < 0:
+		evt.Message = fmt.Sprintf("No %s data received", what)
+	default:
+		evt.Message = fmt.Sprintf("No %s data for %.0f s", what, evt.Value)
+	}
+	return evt, true
+}
+
+// update applies one evaluation and reports a transition, if any
+func (w *alarmWatch) update(now time.Time, raise, reset bool) (AlarmEvent, bool) {
+	switch {
+	case !w.active && raise:
+		w.active = true
+		w.since = now
+		return AlarmEvent{State: AlarmRaised, Timestamp: now.UnixMilli()}, true
+	case w.active && reset:
+		w.active = false
+		w.since = time.Time{}
+		return AlarmEvent{State: AlarmCleared, Timestamp: now.UnixMilli()}, true
+	}
+	return AlarmEvent{}, false
+}
+
+// depthReading returns the latest depth, below the keel when the sounder
+// or configuration supplies the offset
+func (a *AlarmMonitor) depthReading(now time.Time) (float64, bool) {
+	msg := a.latest(128267, now)
+	if msg == nil {
+		return 0, false
+	}
+	for _, key := range []string{"depth_below_keel_m", "depth_transducer_m", "depth_m"} {
+		if v, ok := msg.Fields[key].(float64); ok && !math.IsNaN(v) {
+			return v, true
+		}
+	}
+	return 0, false
+}
+
+// position returns the latest vessel fix
+func (a *AlarmMonitor) position(now time.Time) (float64, float64, bool) {
+	for _, pgn := range []int{129025, 129029} {
+		msg := a.latest(pgn, now)
+		if msg == nil {
+			continue
+		}
+		lat, okLat := msg.Fields["latitude"].(float64)
+		lon, okLon := msg.Fields["longitude"].(float64)
+		if okLat && okLon && math.Abs(lat) <= 90 && math.Abs(lon) <= 180 {
+			return lat, lon, true
+		}
+	}
+	return 0, 0, false
+}
+
+func (a *AlarmMonitor) latest(pgn int, now time.Time) *storage.DecodedMessage {
+	msg := a.buffer.GetLatestByPGN(pgn)
+	if msg == nil || (a.MaxDataAge > 0 && now.Sub(msg.Timestamp) > a.MaxDataAge) {
+		return nil
+	}
+	return msg
+}
+
+// DistanceM is the great-circle distance in metres between two positions
+func DistanceM(lat1, lon1, lat2, lon2 float64) float64 {
+	rad := math.Pi / 180
+	dLat := (lat2 - lat1) * rad
+	dLon := (lon2 - lon1) * rad
+	h := math.Sin(dLat/2)*math.Sin(dLat/2) +
+		math.Cos(lat1*rad)*math.Cos(lat2*rad)*math.Sin(dLon/2)*math.Sin(dLon/2)
+	return 2 * earthRadiusM * math.Asin(math.Min(1, math.Sqrt(h)))
+}
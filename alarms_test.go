@@ -0,0 +1,77 @@
+package integration
+
+import (
+	"testing"
+	"time"
+
+	"odysail-boat-viz/storage"
+)
+
+// TestAlarmNoData checks that an armed alarm whose input goes quiet raises
+// its no-data alarm, and clears it when data returns
+func TestAlarmNoData(t *testing.T) {
+	buf := storage.NewRingBuffer(100)
+	a := NewAlarmMonitor(buf)
+	now := time.Now()
+
+	buf.Push(storage.DecodedMessage{Timestamp: now, PGN: 128267, Fields: map[string]interface{}{"depth_m": 8.0}})
+	buf.Push(storage.DecodedMessage{Timestamp: now, PGN: 129025, Fields: map[string]interface{}{"latitude": 50.0, "longitude": -1.0}})
+	if err := a.SetDepthAlarm(3); err != nil {
+		t.Fatal(err)
+	}
+	if err := a.SetAnchorHere(40); err != nil {
+		t.Fatal(err)
+	}
+	if events := a.Check(now); len(events) != 0 {
+		t.Fatalf("fresh data raised %v", events)
+	}
+
+	later := now.Add(a.MaxDataAge + 15*time.Second)
+	events := a.Check(later)
+	raised := map[string]AlarmEvent{}
+	for _, evt := range events {
+		if evt.State == AlarmRaised {
+			raised[evt.Type] = evt
+		}
+	}
+	for _, typ := range []string{AlarmDepthNoData, AlarmAnchorNoData} {
+		evt, ok := raised[typ]
+		if !ok {
+			t.Errorf("%s not raised by stale data: %v", typ, events)
+			continue
+		}
+		if want := (a.MaxDataAge + 15*time.Second).Seconds(); evt.Value < want-1 || evt.Value > want+1 {
+			t.Errorf("%s age = %.1f s, want %.0f", typ, evt.Value, want)
+		}
+	}
+	if status := a.Status(); !status.DepthState.NoData || !status.AnchorState.NoData {
+		t.Errorf("status does not show no data: %+v %+v", status.DepthState, status.AnchorState)
+	}
+	if events := a.Check(later.Add(time.Second)); len(events) != 0 {
+		t.Errorf("no-data alarm raised again: %v", events)
+	}
+
+	buf.Push(storage.DecodedMessage{Timestamp: later, PGN: 128267, Fields: map[string]interface{}{"depth_m": 8.0}})
+	events = a.Check(later)
+	if len(events) != 1 || events[0].Type != AlarmDepthNoData || events[0].State != AlarmCleared {
+		t.Errorf("depth returning: %v, want depth_no_data cleared", events)
+	}
+
+	// An alarm that is not armed does not care about its input
+	a.ClearAnchor()
+	a.ClearDepthAlarm()
+	if events := a.Check(later.Add(time.Hour)); len(events) != 0 {
+		t.Errorf("disarmed alarms raised %v", events)
+	}
+}
+
+func TestAlarmNoDataNeverReceived(t *testing.T) {
+	a := NewAlarmMonitor(storage.NewRingBuffer(10))
+	if err := a.SetDepthAlarm(3); err != nil {
+		t.Fatal(err)
+	}
+	events := a.Check(time.Now())
+	if len(events) != 1 || events[0].Type != AlarmDepthNoData || events[0].Value != -1 {
+		t.Errorf("armed without a sounder: %v", events)
+	}
+}
@@ -0,0 +1,39 @@
+package main
+
+import (
+	"crypto/subtle"
+	"net/http"
+	"strings"
+)
+
+// requireToken gates a handler behind a bearer token. The token may be sent
+// as "Authorization: Bearer <token>" or, for EventSource and WebSocket
+// clients that cannot set headers, as a "token" query parameter. An empty
+// token disables the check.
+func requireToken(token string, next http.HandlerFunc) http.HandlerFunc {
+	if token == "" {
+		return next
+	}
+
+	return func(w http.ResponseWriter, r *http.Request) {
+		if !tokenMatches(token, requestToken(r)) {
+			w.Header().Set("WWW-Authenticate", `Bearer realm="odysail"`)
+			http.Error(w, "unauthorized", http.StatusUnauthorized)
+			return
+		}
+		next(w, r)
+	}
+}
+
+func requestToken(r *http.Request) string {
+	if auth := r.Header.Get("Authorization"); strings.HasPrefix(auth, "Bearer ") {
+		return strings.TrimSpace(strings.TrimPrefix(auth, "Bearer "))
+	}
+	return r.URL.Query().Get("token")
+}
+
+// tokenMatches compares in constant time so the token cannot be guessed
+// from response timing
+func tokenMatches(want, got string) bool {
+	return subtle.ConstantTimeCompare([]byte(want), []byte(got)) == 1
+}
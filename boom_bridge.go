@@ -0,0 +1,66 @@
+package integration
+
+import (
+	"math"
+	"sync"
+	"time"
+
+	"odysail-boat-viz/boomsense_sensor"
+	"odysail-boat-viz/storage"
+)
+
+// BoomSensePGN is the synthetic PGN BoomSense readings are stored under in
+// the ring buffer. It is above the 18-bit N2K PGN range, so it cannot
+// collide with bus traffic.
+const BoomSensePGN = 0x40000
+
+// BoomBridge stores BoomSense sensor output in the NMEA ring buffer so boom
+// angle sits alongside the bus data in history, streams and the mapper
+type BoomBridge struct {
+	buffer *storage.RingBuffer
+
+	// Interval is the minimum spacing between stored readings; the IMU runs
+	// far faster than the bus and would otherwise crowd N2K data out
+	Interval time.Duration
+
+	mu   sync.Mutex
+	last time.Time
+}
+
+func NewBoomBridge(buffer *storage.RingBuffer) *BoomBridge {
+	return &BoomBridge{
+		buffer:   buffer,
+		Interval: time.Second,
+	}
+}
+
+// Push stores one sensor sample. Pass it to Sensor.AddOutputListener.
+// Uncalibrated samples (NaN boom angle) are skipped.
+func (b *BoomBridge) Push(data boomsense_sensor.FilteredData) {
+	if math.IsNaN(data.BoomRelDegSmooth) || math.IsInf(data.BoomRelDegSmooth, 0) {
+		return
+	}
+
+	b.mu.Lock()
+	if !b.last.IsZero() && data.Timestamp.Sub(b.last) < b.Interval {
+		b.mu.Unlock()
+		return
+	}
+	b.last = data.Timestamp
+	b.mu.Unlock()
+
+	b.buffer.Push(storage.DecodedMessage{
+		Timestamp:   data.Timestamp,
+		PGN:         BoomSensePGN,
+		PGNName:     "BoomSense Boom",
+		Measurement: "boom",
+		Fields: map[string]interface{}{
+			"boom_angle_deg":     data.BoomRelDegSmooth,
+			"boom_angle_raw_deg": data.BoomRelDeg,
+			"boom_norm":          data.BoomNorm,
+			"roll_deg":           data.RollDeg,
+			"pitch_deg":          data.PitchDeg,
+			"clipped":            data.Clipped,
+		},
+	})
+}
@@ -0,0 +1,58 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"odysail-boat-viz/boomsense_sensor"
+)
+
+func postIMU(body string) *httptest.ResponseRecorder {
+	rec := httptest.NewRecorder()
+	handleBoomIMU(rec, httptest.NewRequest(http.MethodPost, "/api/boomsense/imu", strings.NewReader(body)))
+	return rec
+}
+
+func TestHandleBoomIMU(t *testing.T) {
+	if rec := postIMU("{}"); rec.Code != http.StatusServiceUnavailable {
+		t.Fatalf("without a sensor: %d", rec.Code)
+	}
+
+	boomSensor = boomsense_sensor.NewSensor(boomsense_sensor.DefaultConfig())
+	defer func() { boomSensor = nil }()
+
+	rec := postIMU(`{"ts": 1700000000000, "ax": 0, "ay": 0, "az": 1, "gx": 0, "gy": 0, "gz": 0}`)
+	var one map[string]interface{}
+	if rec.Code != http.StatusOK || json.Unmarshal(rec.Body.Bytes(), &one) != nil {
+		t.Fatalf("single reading: %d %s", rec.Code, rec.Body)
+	}
+	if one["timestamp"] != 1700000000000.0 || one["boom_norm"] != nil {
+		t.Errorf("single reading = %v, want the timestamp back and no boom_norm uncalibrated", one)
+	}
+
+	rec = postIMU(`[{"ts": 1700000000020, "az": 1}, {"ts": 1700000000040, "ax": 0.5, "az": 0.86}]`)
+	var many []map[string]interface{}
+	if rec.Code != http.StatusOK || json.Unmarshal(rec.Body.Bytes(), &many) != nil || len(many) != 2 {
+		t.Fatalf("batch: %d %s", rec.Code, rec.Body)
+	}
+
+	// Behind the last processed sample, as live data would be
+	if rec := postIMU(`{"ts": 1700000000030, "az": 1}`); rec.Code != http.StatusConflict {
+		t.Errorf("older reading: %d, want %d", rec.Code, http.StatusConflict)
+	}
+	if rec := postIMU(`[{"ts": 1700000000100, "az": 1}, {"ts": 1700000000050, "az": 1}]`); rec.Code != http.StatusBadRequest {
+		t.Errorf("batch going backwards: %d, want %d", rec.Code, http.StatusBadRequest)
+	}
+
+	if rec := postIMU(`{"ax": "x"}`); rec.Code != http.StatusBadRequest {
+		t.Errorf("bad body: %d", rec.Code)
+	}
+	rec = httptest.NewRecorder()
+	handleBoomIMU(rec, httptest.NewRequest(http.MethodGet, "/api/boomsense/imu", nil))
+	if rec.Code != http.StatusMethodNotAllowed {
+		t.Errorf("GET: %d", rec.Code)
+	}
+}
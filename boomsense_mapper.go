@@ -1,168 +1,628 @@
-package integration
-
-import (
-	"math"
-	"odysail-boat-viz/storage"
-)
-
-type BoomSenseMapper struct {
-	buffer *storage.RingBuffer
-}
-
-func NewBoomSenseMapper(buffer *storage.RingBuffer) *BoomSenseMapper {
-	return &BoomSenseMapper{
-		buffer: buffer,
-	}
-}
-
-// BoomSenseData matches the structure from main.go
-type BoomSenseData struct {
-	BoomAngle     float64 `json:"boom_angle"`
-	RollRate      float64 `json:"roll_rate"`
-	PitchRate     float64 `json:"pitch_rate"`
-	YawRate       float64 `json:"yaw_rate"`
-	MainsheetLoad float64 `json:"mainsheet_load"`
-	VangLoad      float64 `json:"vang_load"`
-	EventType     string  `json:"event_type"`
-	Timestamp     int64   `json:"timestamp"`
-	WindSpeed     float64 `json:"wind_speed"`
-	WindAngle     float64 `json:"wind_angle"`
-	BoatSpeed     float64 `json:"boat_speed"`
-}
-
-func (m *BoomSenseMapper) GetCurrentData() BoomSenseData {
-	data := BoomSenseData{
-		EventType: "normal",
-		Timestamp: 0,
-	}
-
-	// PGN 127257 - Attitude (heel angle, pitch, yaw)
-	if msg := m.buffer.GetLatestByPGN(127257); msg != nil {
-		if heelAngle, ok := msg.Fields["heel_angle"].(float64); ok {
-			// Heel angle is already in degrees
-			data.BoomAngle = heelAngle // Temporary: using heel as rough boom estimate
-		}
-		if pitch, ok := msg.Fields["pitch_deg"].(float64); ok {
-			data.PitchRate = pitch
-		}
-		if yaw, ok := msg.Fields["yaw_deg"].(float64); ok {
-			data.YawRate = yaw
-		}
-		data.Timestamp = msg.Timestamp.UnixMilli()
-	}
-
-	// PGN 127251 - Rate of Turn
-	if msg := m.buffer.GetLatestByPGN(127251); msg != nil {
-		if rot, ok := msg.Fields["rate_of_turn_deg_s"].(float64); ok {
-			data.RollRate = rot
-		}
-	}
-
-	// PGN 130306 - Wind Data
-	if msg := m.buffer.GetLatestByPGN(130306); msg != nil {
-		if ws, ok := msg.Fields["wind_speed_kts"].(float64); ok {
-			data.WindSpeed = ws
-		}
-		if wa, ok := msg.Fields["wind_angle_deg"].(float64); ok {
-			data.WindAngle = wa
-		}
-		if data.Timestamp == 0 {
-			data.Timestamp = msg.Timestamp.UnixMilli()
-		}
-	}
-
-	// PGN 129026 - COG & SOG (boat speed)
-	if msg := m.buffer.GetLatestByPGN(129026); msg != nil {
-		if sog, ok := msg.Fields["sog_kts"].(float64); ok {
-			data.BoatSpeed = sog
-		}
-	}
-
-	// PGN 128259 - Speed Water Referenced (alternative)
-	if data.BoatSpeed == 0 {
-		if msg := m.buffer.GetLatestByPGN(128259); msg != nil {
-			if ws, ok := msg.Fields["water_speed_kts"].(float64); ok {
-				data.BoatSpeed = ws
-			}
-		}
-	}
-
-	return data
-}
-
-// GetHeelAngle returns current heel angle in degrees
-func (m *BoomSenseMapper) GetHeelAngle() float64 {
-	if msg := m.buffer.GetLatestByPGN(127257); msg != nil {
-		if heel, ok := msg.Fields["heel_angle"].(float64); ok {
-			return heel
-		}
-	}
-	return 0.0
-}
-
-// GetWindData returns wind speed (kts) and angle (degrees)
-func (m *BoomSenseMapper) GetWindData() (speed, angle float64) {
-	if msg := m.buffer.GetLatestByPGN(130306); msg != nil {
-		if ws, ok := msg.Fields["wind_speed_kts"].(float64); ok {
-			speed = ws
-		}
-		if wa, ok := msg.Fields["wind_angle_deg"].(float64); ok {
-			angle = wa
-		}
-	}
-	return
-}
-
-// GetBoatSpeed returns current boat speed in knots
-func (m *BoomSenseMapper) GetBoatSpeed() float64 {
-	// Try COG/SOG first
-	if msg := m.buffer.GetLatestByPGN(129026); msg != nil {
-		if sog, ok := msg.Fields["sog_kts"].(float64); ok {
-			return sog
-		}
-	}
-	
-	// Fallback to water speed
-	if msg := m.buffer.GetLatestByPGN(128259); msg != nil {
-		if ws, ok := msg.Fields["water_speed_kts"].(float64); ok {
-			return ws
-		}
-	}
-	
-	return 0.0
-}
-
-// CalculateApparentWind computes apparent wind from true wind + boat speed
-func (m *BoomSenseMapper) CalculateApparentWind() (aws, awa float64) {
-	tws, twa := m.GetWindData()
-	bs := m.GetBoatSpeed()
-	
-	if tws == 0 {
-		return 0, 0
-	}
-	
-	// Convert to radians
-	twaRad := twa * math.Pi / 180.0
-	
-	// Vector calculation
-	// True wind components
-	twx := tws * math.Sin(twaRad)
-	twy := tws * math.Cos(twaRad)
-	
-	// Apparent wind = true wind - boat velocity
-	awx := twx
-	awy := twy - bs
-	
-	// Apparent wind speed
-	aws = math.Sqrt(awx*awx + awy*awy)
-	
-	// Apparent wind angle
-	awa = math.Atan2(awx, awy) * 180.0 / math.Pi
-	
-	// Normalize to 0-180 range
-	if awa < 0 {
-		awa = -awa
-	}
-	
-	return
-}
\ No newline at end of file
+package integration
+
+import (
+	"math"
+	"time"
+
+	"odysail-boat-viz/storage"
+)
+
+// BoomSource supplies a calibrated boom angle in degrees (positive to
+// starboard). ok is false when no calibrated reading is available.
+type BoomSource interface {
+	BoomAngle() (angle float64, ok bool)
+}
+
+type BoomSenseMapper struct {
+	buffer *storage.RingBuffer
+	boom   BoomSource
+
+	// MinTurnRateDegS is the rate of turn below which the boat is treated
+	// as sailing a straight line (no meaningful turning radius)
+	MinTurnRateDegS float64
+
+	// HeelCorrection projects apparent wind measured by a heeled masthead
+	// sensor back to the horizontal plane. Leave off for instruments that
+	// already compensate internally.
+	HeelCorrection bool
+
+	// WindStatsWindow is the rolling window for gust/lull statistics
+	WindStatsWindow time.Duration
+
+	// SeaStateWindow is the attitude history sea state is computed over;
+	// roll or pitch RMS at SeaModerateRMSDeg or SeaRoughRMSDeg (degrees)
+	// moves the class up from flat. Motion slower than SeaStateSmoothing,
+	// such as heeling onto a new tack, is left out.
+	SeaStateWindow    time.Duration
+	SeaStateSmoothing time.Duration
+	SeaModerateRMSDeg float64
+	SeaRoughRMSDeg    float64
+
+	// PressureTrendWindow is the barometer history the pressure tendency
+	// is fitted over
+	PressureTrendWindow time.Duration
+
+	// MaxDataAge is how old a boat speed reading may be before the next
+	// source is used instead
+	MaxDataAge time.Duration
+
+	// LeewayK is the hull coefficient in leeway = K * heel / boatspeed^2
+	// (degrees, knots); typically 8-12 for a keelboat
+	LeewayK float64
+
+	// SensorHeading lets the boom source's magnetic heading stand in for a
+	// compass (PGN 127250) when none is on the bus. Only for a BoomSense
+	// unit mounted on the hull: on the boom its heading swings with the boom.
+	SensorHeading bool
+
+	// LogSuspectFactor is how many times water speed SOG must be before
+	// CheckSpeedLog suspects the paddlewheel; 0 disables the check
+	LogSuspectFactor float64
+
+	// WindCorrection is the upwash calibration applied to true wind angle
+	// for WindAngleCorrected; nil leaves the angle as measured
+	WindCorrection *WindCorrectionTable
+}
+
+func NewBoomSenseMapper(buffer *storage.RingBuffer) *BoomSenseMapper {
+	return &BoomSenseMapper{
+		buffer:          buffer,
+		MinTurnRateDegS: 0.5,
+		LeewayK:         10.0,
+		WindStatsWindow: 60 * time.Second,
+		MaxDataAge:      5 * time.Second,
+
+		PressureTrendWindow: time.Hour,
+		LogSuspectFactor:    2.0,
+
+		SeaStateWindow:    60 * time.Second,
+		SeaStateSmoothing: 10 * time.Second,
+		SeaModerateRMSDeg: 2.0,
+		SeaRoughRMSDeg:    5.0,
+	}
+}
+
+// HeadingSource is implemented by boom sources that also estimate magnetic
+// heading, such as a BoomSense sensor with a magnetometer
+type HeadingSource interface {
+	MagneticHeading() (heading float64, ok bool)
+}
+
+// SetBoomSource attaches the calibrated boom angle provider. Without one,
+// GetCurrentData falls back to readings a BoomBridge stored in the buffer.
+func (m *BoomSenseMapper) SetBoomSource(src BoomSource) {
+	m.boom = src
+}
+
+// BoomSenseData matches the structure from main.go. BoomAngle is nil (and
+// omitted from JSON) when no calibrated boom data is available.
+type BoomSenseData struct {
+	BoomAngle          *float64 `json:"boom_angle,omitempty"`
+	RollRate           float64  `json:"roll_rate"`
+	PitchRate          float64  `json:"pitch_rate"`
+	YawRate            float64  `json:"yaw_rate"`
+	Heading            *float64 `json:"heading,omitempty"` // degrees, from GetHeading
+	MainsheetLoad      float64  `json:"mainsheet_load"`
+	VangLoad           float64  `json:"vang_load"`
+	EventType          string   `json:"event_type"`
+	Timestamp          int64    `json:"timestamp"`
+	WindSpeed          float64  `json:"wind_speed"`
+	WindAngle          float64  `json:"wind_angle"`               // 0-180, folded onto the symmetric polar
+	WindSide           string   `json:"wind_side,omitempty"`      // side the wind comes over: "port" or "starboard"
+	WindAngleCorrected float64  `json:"wind_angle_deg_corrected"` // WindAngle after the upwash correction table
+	BoatSpeed          float64  `json:"boat_speed"`
+	BoatSpeedSource    string   `json:"boat_speed_source,omitempty"` // "sog" or "water"
+}
+
+func (m *BoomSenseMapper) GetCurrentData() BoomSenseData {
+	data := BoomSenseData{
+		EventType: "normal",
+		Timestamp: 0,
+	}
+
+	// Calibrated boom angle from the BoomSense sensor
+	if m.boom != nil {
+		if angle, ok := m.boom.BoomAngle(); ok {
+			data.BoomAngle = &angle
+		}
+	} else if msg := m.fresh(BoomSensePGN); msg != nil {
+		if angle, ok := msg.Fields["boom_angle_deg"].(float64); ok {
+			data.BoomAngle = &angle
+		}
+	}
+
+	// PGN 127257 - Attitude (pitch, yaw)
+	if msg := m.buffer.GetLatestByPGN(127257); msg != nil {
+		if pitch, ok := msg.Fields["pitch_deg"].(float64); ok {
+			data.PitchRate = pitch
+		}
+		if yaw, ok := msg.Fields["yaw_deg"].(float64); ok {
+			data.YawRate = yaw
+		}
+		data.Timestamp = msg.Timestamp.UnixMilli()
+	}
+
+	if heading, ok := m.GetHeading(); ok {
+		data.Heading = &heading
+	}
+
+	// PGN 127251 - Rate of Turn
+	if msg := m.buffer.GetLatestByPGN(127251); msg != nil {
+		if rot, ok := msg.Fields["rate_of_turn_deg_s"].(float64); ok {
+			data.RollRate = rot
+		}
+	}
+
+	// PGN 130306 - Wind Data, resolved to true wind for polar lookups
+	if msg := m.buffer.GetLatestByPGN(130306); msg != nil {
+		data.WindSpeed, data.WindAngle, data.WindSide = m.CalculateTrueWindSide()
+		data.WindAngleCorrected = m.WindCorrection.Correct(data.WindSpeed, data.WindAngle)
+		if data.Timestamp == 0 {
+			data.Timestamp = msg.Timestamp.UnixMilli()
+		}
+	}
+
+	// Boat speed from fresh SOG, else fresh water speed
+	data.BoatSpeed, data.BoatSpeedSource, _ = m.BoatSpeedSource()
+
+	return data
+}
+
+// knots reads a speed field in knots, falling back to the raw SI value when
+// the decoder is configured for a different display unit
+func knots(fields map[string]interface{}, base string) (float64, bool) {
+	if v, ok := fields[base+"_kts"].(float64); ok {
+		return v, true
+	}
+	if v, ok := fields[base+"_ms"].(float64); ok {
+		return v * 1.94384, true
+	}
+	return 0, false
+}
+
+// GetHeelAngle returns current heel angle in degrees
+func (m *BoomSenseMapper) GetHeelAngle() float64 {
+	if msg := m.buffer.GetLatestByPGN(127257); msg != nil {
+		if heel, ok := msg.Fields["heel_angle"].(float64); ok {
+			return heel
+		}
+	}
+	return 0.0
+}
+
+// Wind reference values carried in PGN 130306
+const (
+	WindRefTrueNorth     = 0 // True, ground referenced to North
+	WindRefMagneticNorth = 1 // Magnetic, ground referenced to Magnetic North
+	WindRefApparent      = 2
+	WindRefTrueBoat      = 3 // True, boat referenced
+	WindRefTrueWater     = 4 // True, water referenced
+)
+
+// GetWindData returns wind speed (kts) and angle (degrees) as broadcast,
+// without regard to the wind reference
+func (m *BoomSenseMapper) GetWindData() (speed, angle float64) {
+	speed, angle, _ = m.windReading()
+	return
+}
+
+// windReading returns the latest PGN 130306 speed (kts), angle (degrees)
+// and wind reference. ref is -1 when no wind data has been received.
+func (m *BoomSenseMapper) windReading() (speed, angle float64, ref int) {
+	msg := m.buffer.GetLatestByPGN(130306)
+	if msg == nil {
+		return 0, 0, -1
+	}
+	return m.windFromMessage(msg)
+}
+
+// windFromMessage extracts speed (kts), angle (degrees) and reference from
+// one PGN 130306 message, applying heel correction to apparent wind
+func (m *BoomSenseMapper) windFromMessage(msg *storage.DecodedMessage) (speed, angle float64, ref int) {
+	if ws, ok := knots(msg.Fields, "wind_speed"); ok {
+		speed = ws
+	}
+	if wa, ok := msg.Fields["wind_angle_deg"].(float64); ok {
+		angle = wa
+	}
+
+	// The reference is the low 3 bits; the rest of the byte is reserved
+	ref = WindRefApparent
+	switch v := msg.Fields["wind_reference"].(type) {
+	case uint8:
+		ref = int(v & 0x07)
+	case int:
+		ref = v & 0x07
+	case float64:
+		ref = int(v) & 0x07
+	}
+
+	if ref == WindRefApparent && m.HeelCorrection {
+		speed, angle = correctForHeel(speed, angle, m.GetHeelAngle())
+	}
+	return
+}
+
+// correctForHeel projects apparent wind measured in the heeled mast frame
+// onto the horizontal plane. Only the athwartships component is foreshortened
+// by heel, so tan(AWA_corrected) = tan(AWA) / cos(heel).
+func correctForHeel(speed, angleDeg, heelDeg float64) (float64, float64) {
+	// Beyond ~80° the projection blows up; the reading is meaningless anyway
+	heel := math.Min(math.Abs(heelDeg), 80.0) * math.Pi / 180.0
+	if heel == 0 {
+		return speed, angleDeg
+	}
+
+	a := angleDeg * math.Pi / 180.0
+	across := speed * math.Sin(a) / math.Cos(heel)
+	along := speed * math.Cos(a)
+
+	corrected := math.Atan2(across, along) * 180.0 / math.Pi
+	if corrected < 0 {
+		corrected += 360.0
+	}
+	return math.Sqrt(across*across + along*along), corrected
+}
+
+// GetHeading returns the vessel heading in degrees (PGN 127250), falling
+// back to the boom source's magnetic heading when no compass is on the bus
+// and SensorHeading is set
+func (m *BoomSenseMapper) GetHeading() (float64, bool) {
+	if msg := m.buffer.GetLatestByPGN(127250); msg != nil {
+		if hdg, ok := msg.Fields["heading_deg"].(float64); ok {
+			return hdg, true
+		}
+	}
+	if hs, ok := m.boom.(HeadingSource); ok && m.SensorHeading {
+		return hs.MagneticHeading()
+	}
+	return 0, false
+}
+
+// CalculateTrueWind returns true wind speed (kts) and angle off the bow
+// (0-180 degrees) according to the broadcast wind reference. Apparent wind
+// is converted using boat speed; ground-referenced wind directions are
+// made relative to the bow using heading. Returns zeros when the true wind
+// cannot be determined.
+func (m *BoomSenseMapper) CalculateTrueWind() (tws, twa float64) {
+	return m.trueWind(m.windReading())
+}
+
+// CalculateTrueWindSide is CalculateTrueWind plus the side the wind comes
+// over, which folding onto 0-180 otherwise loses
+func (m *BoomSenseMapper) CalculateTrueWindSide() (tws, twa float64, side string) {
+	tws, signed := m.trueWindSigned(m.windReading())
+	twa, side = FoldWindAngle(signed)
+	return tws, twa, side
+}
+
+// Wind sides reported by FoldWindAngle
+const (
+	WindSidePort      = "port"
+	WindSideStarboard = "starboard"
+)
+
+// FoldWindAngle maps a wind angle off the bow in the N2K 0-360 convention
+// (clockwise from the bow, so 210 is wind from the port quarter) onto the 0-180
+// range polars use, and reports which side the wind is on. Dead ahead and
+// dead astern have no side.
+func FoldWindAngle(angle float64) (float64, string) {
+	a := math.Mod(angle, 360)
+	if a < 0 {
+		a += 360
+	}
+	switch {
+	case a > 180:
+		return 360 - a, WindSidePort
+	case a > 0 && a < 180:
+		return a, WindSideStarboard
+	}
+	return a, ""
+}
+
+// trueWind converts one wind reading to true wind using the current boat
+// speed and heading, folded to 0-180
+func (m *BoomSenseMapper) trueWind(speed, angle float64, ref int) (tws, twa float64) {
+	tws, twa = m.trueWindSigned(speed, angle, ref)
+	twa, _ = FoldWindAngle(twa)
+	return
+}
+
+// trueWindSigned is trueWind with the angle in -180..180, negative to port
+func (m *BoomSenseMapper) trueWindSigned(speed, angle float64, ref int) (tws, twa float64) {
+	if ref < 0 || speed == 0 {
+		return 0, 0
+	}
+
+	switch ref {
+	case WindRefApparent:
+		bs := m.GetBoatSpeed()
+		awaRad := angle * math.Pi / 180.0
+
+		// Apparent wind components
+		awx := speed * math.Sin(awaRad)
+		awy := speed * math.Cos(awaRad)
+
+		// True wind = apparent wind - headwind from boat motion
+		twx := awx
+		twy := awy - bs
+
+		tws = math.Sqrt(twx*twx + twy*twy)
+		twa = math.Atan2(twx, twy) * 180.0 / math.Pi
+
+	case WindRefTrueNorth, WindRefMagneticNorth:
+		hdg, ok := m.GetHeading()
+		if !ok {
+			return 0, 0
+		}
+		tws = speed
+		twa = math.Mod(angle-hdg+540.0, 360.0) - 180.0
+
+	default:
+		tws = speed
+		twa = angle
+		if twa > 180 {
+			twa -= 360
+		}
+	}
+
+	return
+}
+
+// Field states reported by FieldState
+const (
+	FieldMissing = "missing" // PGN not received within MaxDataAge
+	FieldInvalid = "invalid" // received, but the sender marked it not available
+	FieldValid   = "valid"
+)
+
+// FieldState tells a failed sensor from an absent one: a wind instrument
+// sending 0xFFFF gives FieldInvalid for wind_speed_ms, while no PGN 130306
+// at all gives FieldMissing. Use the SI field name. Invalid fields are only
+// kept when the collector runs with mark_invalid_fields, otherwise they
+// read as missing.
+func (m *BoomSenseMapper) FieldState(pgn int, field string) string {
+	msg := m.fresh(pgn)
+	if msg == nil {
+		return FieldMissing
+	}
+	v, present := msg.Fields[field]
+	if !present {
+		return FieldMissing
+	}
+	if v == nil {
+		return FieldInvalid
+	}
+	return FieldValid
+}
+
+// Boat speed sources reported by BoatSpeedSource
+const (
+	BoatSpeedSourceSOG   = "sog"   // PGN 129026, GPS speed over ground
+	BoatSpeedSourceWater = "water" // PGN 128259, paddlewheel
+)
+
+// GetBoatSpeed returns current boat speed in knots, or 0 when no source is
+// fresh
+func (m *BoomSenseMapper) GetBoatSpeed() float64 {
+	speed, _, _ := m.BoatSpeedSource()
+	return speed
+}
+
+// BoatSpeedSource returns boat speed in knots and where it came from: SOG
+// (PGN 129026) first, then water speed (PGN 128259). A source older than
+// MaxDataAge is skipped so a lost GPS fix falls through to the paddlewheel
+// instead of freezing the last SOG. ok is false when neither is fresh.
+func (m *BoomSenseMapper) BoatSpeedSource() (speed float64, source string, ok bool) {
+	if msg := m.fresh(129026); msg != nil {
+		if sog, ok := knots(msg.Fields, "sog"); ok {
+			return sog, BoatSpeedSourceSOG, true
+		}
+	}
+
+	if msg := m.fresh(128259); msg != nil {
+		if ws, ok := knots(msg.Fields, "water_speed"); ok {
+			return ws, BoatSpeedSourceWater, true
+		}
+	}
+
+	return 0, "", false
+}
+
+// fresh returns the latest message for pgn when it is within MaxDataAge
+// (any age when MaxDataAge is zero)
+func (m *BoomSenseMapper) fresh(pgn int) *storage.DecodedMessage {
+	msg := m.buffer.GetLatestByPGN(pgn)
+	if msg == nil {
+		return nil
+	}
+	if m.MaxDataAge > 0 && time.Since(msg.Timestamp) > m.MaxDataAge {
+		return nil
+	}
+	return msg
+}
+
+// CalculateApparentWind computes apparent wind from true wind + boat speed.
+// When the instrument already broadcasts apparent wind it is returned as is.
+// The angle is folded to 0-180; CalculateApparentWindSigned keeps the side.
+func (m *BoomSenseMapper) CalculateApparentWind() (aws, awa float64) {
+	aws, awa = m.CalculateApparentWindSigned()
+	return aws, math.Abs(awa)
+}
+
+// CalculateApparentWindSigned is CalculateApparentWind with the angle in
+// -180..180, negative to port, so a port-tack reach can be told from a
+// starboard one
+func (m *BoomSenseMapper) CalculateApparentWindSigned() (aws, awa float64) {
+	speed, angle, ref := m.windReading()
+	if ref == WindRefApparent {
+		return speed, signedWindAngle(angle)
+	}
+
+	tws, twa := m.trueWindSigned(speed, angle, ref)
+	bs := m.GetBoatSpeed()
+
+	if tws == 0 {
+		return 0, 0
+	}
+
+	// Convert to radians
+	twaRad := twa * math.Pi / 180.0
+
+	// Vector calculation
+	// True wind components
+	twx := tws * math.Sin(twaRad)
+	twy := tws * math.Cos(twaRad)
+
+	// Apparent wind = true wind + headwind from boat motion
+	awx := twx
+	awy := twy + bs
+
+	// Apparent wind speed
+	aws = math.Sqrt(awx*awx + awy*awy)
+
+	// Apparent wind angle, starboard positive
+	awa = math.Atan2(awx, awy) * 180.0 / math.Pi
+
+	return
+}
+
+// signedWindAngle maps a wind angle off the bow in the N2K 0-360 convention
+// onto -180..180, negative to port
+func signedWindAngle(angle float64) float64 {
+	a := math.Mod(angle, 360)
+	if a > 180 {
+		a -= 360
+	} else if a <= -180 {
+		a += 360
+	}
+	return a
+}
+
+// TurningRadius estimates the current turning radius in meters from boat
+// speed and rate of turn (PGN 127251). The result is signed: positive for a
+// turn to starboard, negative for a turn to port. ok is false when there is
+// no rate-of-turn data or the rate is below MinTurnRateDegS.
+func (m *BoomSenseMapper) TurningRadius() (float64, bool) {
+	msg := m.buffer.GetLatestByPGN(127251)
+	if msg == nil {
+		return 0, false
+	}
+
+	rot, ok := msg.Fields["rate_of_turn_rad_s"].(float64)
+	if !ok || math.Abs(rot*180.0/math.Pi) < m.MinTurnRateDegS {
+		return 0, false
+	}
+
+	speedMs := m.GetBoatSpeed() / 1.94384
+	if speedMs <= 0 {
+		return 0, false
+	}
+
+	return speedMs / rot, true
+}
+
+// Leeway estimates are unreliable when the boat is barely moving through
+// the water, where K*heel/bs^2 diverges
+const (
+	minLeewaySpeedKts = 1.0
+	maxLeewayDeg      = 30.0
+)
+
+// GetWaterSpeed returns speed through the water in knots (PGN 128259)
+func (m *BoomSenseMapper) GetWaterSpeed() (float64, bool) {
+	if msg := m.buffer.GetLatestByPGN(128259); msg != nil {
+		return knots(msg.Fields, "water_speed")
+	}
+	return 0, false
+}
+
+// trueHeading returns the heading referenced to true north, applying the
+// variation from PGN 127250 or else PGN 127258 when the compass reports
+// magnetic heading
+func (m *BoomSenseMapper) trueHeading() (float64, bool) {
+	msg := m.buffer.GetLatestByPGN(127250)
+	if msg == nil {
+		return 0, false
+	}
+	hdg, ok := msg.Fields["heading_deg"].(float64)
+	if !ok {
+		return 0, false
+	}
+
+	ref := 0
+	switch v := msg.Fields["heading_reference"].(type) {
+	case uint8:
+		ref = int(v & 0x03)
+	case float64:
+		ref = int(v) & 0x03
+	}
+	if ref == 1 {
+		variation, ok := msg.Fields["variation_deg"].(float64)
+		if !ok {
+			// Compasses often broadcast variation separately
+			if vmsg := m.buffer.GetLatestByPGN(127258); vmsg != nil {
+				variation, ok = vmsg.Fields["variation_deg"].(float64)
+			}
+		}
+		if !ok {
+			return 0, false
+		}
+		hdg += variation
+	}
+	return math.Mod(hdg+360.0, 360.0), true
+}
+
+// EstimateLeeway returns the leeway angle in degrees from heel (PGN 127257)
+// and water speed (PGN 128259) as LeewayK * heel / bs^2. The sign follows
+// heel: positive means the boat is slipping to starboard of its heading.
+// ok is false without both inputs or below minLeewaySpeedKts.
+func (m *BoomSenseMapper) EstimateLeeway() (float64, bool) {
+	msg := m.buffer.GetLatestByPGN(127257)
+	if msg == nil {
+		return 0, false
+	}
+	heel, ok := msg.Fields["heel_angle"].(float64)
+	if !ok {
+		return 0, false
+	}
+
+	bs, ok := m.GetWaterSpeed()
+	if !ok || bs < minLeewaySpeedKts {
+		return 0, false
+	}
+
+	leeway := m.LeewayK * heel / (bs * bs)
+	return math.Max(-maxLeewayDeg, math.Min(maxLeewayDeg, leeway)), true
+}
+
+// EstimateCurrent returns the current set (degrees true, the direction the
+// water flows towards) and drift (kts) as the vector difference between the
+// ground track (COG/SOG, PGN 129026) and the water track (heading plus
+// leeway at water speed). ok is false when any input is missing.
+func (m *BoomSenseMapper) EstimateCurrent() (set, drift float64, ok bool) {
+	msg := m.buffer.GetLatestByPGN(129026)
+	if msg == nil {
+		return 0, 0, false
+	}
+	cog, cogOK := msg.Fields["cog_deg"].(float64)
+	sog, sogOK := knots(msg.Fields, "sog")
+	hdg, hdgOK := m.trueHeading()
+	bs, bsOK := m.GetWaterSpeed()
+	if !cogOK || !sogOK || !hdgOK || !bsOK {
+		return 0, 0, false
+	}
+
+	// Without a usable leeway estimate assume the boat tracks its heading
+	leeway, _ := m.EstimateLeeway()
+	ctw := (hdg + leeway) * math.Pi / 180.0
+	cogRad := cog * math.Pi / 180.0
+
+	// North/east components of ground track minus water track
+	north := sog*math.Cos(cogRad) - bs*math.Cos(ctw)
+	east := sog*math.Sin(cogRad) - bs*math.Sin(ctw)
+
+	drift = math.Sqrt(north*north + east*east)
+	set = math.Mod(math.Atan2(east, north)*180.0/math.Pi+360.0, 360.0)
+	return set, drift, true
+}
@@ -0,0 +1,124 @@
+package integration
+
+import (
+	"math"
+	"testing"
+	"time"
+
+	"odysail-boat-viz/storage"
+)
+
+// fakeBoomSensor is a boom source with a magnetometer heading
+type fakeBoomSensor struct {
+	angle, heading float64
+}
+
+func (s fakeBoomSensor) BoomAngle() (float64, bool)       { return s.angle, true }
+func (s fakeBoomSensor) MagneticHeading() (float64, bool) { return s.heading, true }
+
+// TestHeadingSeparateFromYawRate checks that the heading goes in its own
+// field and the boom sensor heading is only used when opted in
+func TestHeadingSeparateFromYawRate(t *testing.T) {
+	buf := storage.NewRingBuffer(100)
+	m := NewBoomSenseMapper(buf)
+	m.SetBoomSource(fakeBoomSensor{angle: 20, heading: 95})
+
+	buf.Push(storage.DecodedMessage{Timestamp: time.Now(), PGN: 127257, Fields: map[string]interface{}{"pitch_deg": 1.0, "yaw_deg": 3.0}})
+
+	data := m.GetCurrentData()
+	if data.YawRate != 3 {
+		t.Errorf("yaw_rate = %v, want 3 from attitude", data.YawRate)
+	}
+	if data.Heading != nil {
+		t.Errorf("heading = %v without compass or SensorHeading, want none", *data.Heading)
+	}
+	if _, ok := m.GetHeading(); ok {
+		t.Error("GetHeading used the boom sensor without SensorHeading")
+	}
+
+	m.SensorHeading = true
+	data = m.GetCurrentData()
+	if data.Heading == nil || *data.Heading != 95 {
+		t.Errorf("heading = %v, want 95 from hull sensor", data.Heading)
+	}
+	if data.YawRate != 3 {
+		t.Errorf("yaw_rate = %v after heading, want 3", data.YawRate)
+	}
+
+	buf.Push(storage.DecodedMessage{Timestamp: time.Now(), PGN: 127250, Fields: map[string]interface{}{"heading_deg": 180.0}})
+	if hdg, ok := m.GetHeading(); !ok || hdg != 180 {
+		t.Errorf("GetHeading = %v, %v; want compass 180", hdg, ok)
+	}
+}
+
+func TestFoldWindAngle(t *testing.T) {
+	tests := []struct {
+		angle    float64
+		want     float64
+		wantSide string
+	}{
+		{210, 150, WindSidePort},
+		{45, 45, WindSideStarboard},
+		{359, 1, WindSidePort},
+		{-30, 30, WindSidePort},
+		{0, 0, ""},
+		{180, 180, ""},
+		{360, 0, ""},
+		{540, 180, ""},
+	}
+	for _, tt := range tests {
+		got, side := FoldWindAngle(tt.angle)
+		if math.Abs(got-tt.want) > 1e-9 || side != tt.wantSide {
+			t.Errorf("FoldWindAngle(%v) = %v, %q; want %v, %q", tt.angle, got, side, tt.want, tt.wantSide)
+		}
+	}
+}
+
+// TestApparentWindFoldsToPort checks that an apparent wind angle past 180
+// reaches the display folded, with its side
+func TestApparentWindFoldsToPort(t *testing.T) {
+	buf := storage.NewRingBuffer(10)
+	m := NewBoomSenseMapper(buf)
+	buf.Push(storage.DecodedMessage{Timestamp: time.Now(), PGN: 130306, Fields: map[string]interface{}{
+		"wind_speed_kts": 12.0, "wind_speed_ms": 6.17, "wind_angle_deg": 210.0, "wind_reference": uint8(2)}})
+
+	data := m.GetCurrentData()
+	if math.Abs(data.WindAngle-150) > 1e-6 || data.WindSide != WindSidePort {
+		t.Errorf("wind angle %v %q, want 150 %q", data.WindAngle, data.WindSide, WindSidePort)
+	}
+}
+
+func TestTurningRadius(t *testing.T) {
+	tests := []struct {
+		name    string
+		rateDeg float64 // rate of turn, deg/s, positive to starboard
+		want    float64 // metres, signed like the rate
+		wantOK  bool
+	}{
+		// 5 kts is 2.572 m/s; at 3 deg/s (0.05236 rad/s) that is a 49.1 m radius
+		{"starboard", 3, 49.12, true},
+		{"port", -3, -49.12, true},
+		{"tight starboard", 10, 14.74, true},
+		{"straight line", 0.2, 0, false},
+	}
+	for _, tt := range tests {
+		buf := storage.NewRingBuffer(10)
+		m := NewBoomSenseMapper(buf)
+		now := time.Now()
+		buf.Push(storage.DecodedMessage{Timestamp: now, PGN: 128259, Fields: map[string]interface{}{"water_speed_kts": 5.0}})
+		buf.Push(storage.DecodedMessage{Timestamp: now, PGN: 127251, Fields: map[string]interface{}{
+			"rate_of_turn_rad_s": tt.rateDeg * math.Pi / 180}})
+
+		got, ok := m.TurningRadius()
+		if ok != tt.wantOK || math.Abs(got-tt.want) > 0.01 {
+			t.Errorf("%s: TurningRadius = %.2f, %v; want %.2f, %v", tt.name, got, ok, tt.want, tt.wantOK)
+		}
+	}
+
+	// No rate of turn received
+	buf := storage.NewRingBuffer(10)
+	buf.Push(storage.DecodedMessage{Timestamp: time.Now(), PGN: 128259, Fields: map[string]interface{}{"water_speed_kts": 5.0}})
+	if _, ok := NewBoomSenseMapper(buf).TurningRadius(); ok {
+		t.Error("TurningRadius ok without PGN 127251")
+	}
+}
@@ -1,195 +1,363 @@
-package boomsense_sensor
-
-import (
-	"encoding/json"
-	"io/ioutil"
-	"math"
-	"sync"
-)
-
-// BayesianQA implements online Bayesian logistic regression for event quality assessment
-type BayesianQA struct {
-	d     int       // Feature dimension
-	mu    []float64 // Mean weights
-	vr    []float64 // Variance (diagonal)
-	lock  sync.RWMutex
-}
-
-// NewBayesianQA creates a new Bayesian QA model
-func NewBayesianQA(d int, sigma0 float64) *BayesianQA {
-	mu := make([]float64, d)
-	vr := make([]float64, d)
-	for i := 0; i < d; i++ {
-		vr[i] = sigma0 * sigma0
-	}
-	
-	return &BayesianQA{
-		d:  d,
-		mu: mu,
-		vr: vr,
-	}
-}
-
-// PredictProba returns probability that event is correct
-func (bq *BayesianQA) PredictProba(x []float64) float64 {
-	bq.lock.RLock()
-	defer bq.lock.RUnlock()
-
-	if len(x) != bq.d {
-		return 0.5 // Invalid feature vector
-	}
-
-	// Mean prediction: m = mu · x
-	m := 0.0
-	for i := 0; i < bq.d; i++ {
-		m += bq.mu[i] * x[i]
-	}
-
-	// Variance: s² = Σ(var_i * x_i²)
-	s2 := 0.0
-	for i := 0; i < bq.d; i++ {
-		s2 += bq.vr[i] * x[i] * x[i]
-	}
-
-	// Probit approximation for Bayesian logistic regression
-	k := 1.0 / math.Sqrt(1.0+math.Pi*s2/8.0)
-	z := k * m
-
-	// Sigmoid
-	return sigmoid(z)
-}
-
-// Update performs online Bayesian update
-func (bq *BayesianQA) Update(x []float64, y float64, iters int) {
-	bq.lock.Lock()
-	defer bq.lock.Unlock()
-
-	if len(x) != bq.d {
-		return
-	}
-
-	for iter := 0; iter < iters; iter++ {
-		// Forward pass
-		z := 0.0
-		for i := 0; i < bq.d; i++ {
-			z += bq.mu[i] * x[i]
-		}
-		p := sigmoid(z)
-
-		// Gradient and Hessian (diagonal approximation)
-		for i := 0; i < bq.d; i++ {
-			g := (y - p) * x[i]
-			h := p*(1-p)*x[i]*x[i] + 1.0/bq.vr[i]
-			
-			// Update mean
-			step := g / h
-			bq.mu[i] += step
-			
-			// Update variance (diagonal approximation)
-			bq.vr[i] = 1.0 / h
-		}
-	}
-}
-
-// ExtractFeatures converts event to feature vector
-// Feature vector (11 dimensions with wind):
-// [gy_peak, boom_delta, dt, roll_delta, overshoot, 
-//  is_tack, is_gybe_normal, is_gybe_crash,
-//  wind_speed_kn, wind_angle_deg, bias]
-func ExtractFeatures(evt Event) []float64 {
-	// Extract raw features
-	gy := evt.GyroPeak
-	bd := evt.BoomDelta
-	dt := evt.Duration
-	rl := evt.RollDelta
-	os := evt.Overshoot
-	ws := evt.WindSpeed
-	wa := evt.WindAngle
-
-	// One-hot encode event type
-	tTack := 0.0
-	tGN := 0.0
-	tGC := 0.0
-	switch evt.Type {
-	case "tack":
-		tTack = 1.0
-	case "gybe_normal":
-		tGN = 1.0
-	case "gybe_crash":
-		tGC = 1.0
-	}
-
-	// Build feature vector
-	x := []float64{gy, bd, dt, rl, os, tTack, tGN, tGC, ws, wa, 1.0}
-
-	// Scale features (matching Python scales)
-	scales := []float64{150, 1.5, 2.5, 25, 0.4, 1, 1, 1, 40, 180, 1}
-	for i := 0; i < len(x); i++ {
-		x[i] /= scales[i]
-	}
-
-	return x
-}
-
-// sigmoid computes logistic sigmoid
-func sigmoid(z float64) float64 {
-	z = math.Max(-60.0, math.Min(60.0, z)) // Prevent overflow
-	return 1.0 / (1.0 + math.Exp(-z))
-}
-
-// SaveState persists model to JSON
-func (bq *BayesianQA) SaveState(path string) error {
-	bq.lock.RLock()
-	defer bq.lock.RUnlock()
-
-	state := map[string]interface{}{
-		"mu":  bq.mu,
-		"var": bq.vr,
-		"d":   bq.d,
-	}
-
-	data, err := json.MarshalIndent(state, "", "  ")
-	if err != nil {
-		return err
-	}
-
-	return ioutil.WriteFile(path, data, 0644)
-}
-
-// LoadState restores model from JSON
-func (bq *BayesianQA) LoadState(path string) error {
-	data, err := ioutil.ReadFile(path)
-	if err != nil {
-		return err
-	}
-
-	var state map[string]interface{}
-	if err := json.Unmarshal(data, &state); err != nil {
-		return err
-	}
-
-	bq.lock.Lock()
-	defer bq.lock.Unlock()
-
-	// Load mu
-	if muData, ok := state["mu"].([]interface{}); ok {
-		bq.mu = make([]float64, len(muData))
-		for i, v := range muData {
-			if f, ok := v.(float64); ok {
-				bq.mu[i] = f
-			}
-		}
-	}
-
-	// Load var
-	if vrData, ok := state["var"].([]interface{}); ok {
-		bq.vr = make([]float64, len(vrData))
-		for i, v := range vrData {
-			if f, ok := v.(float64); ok {
-				bq.vr[i] = f
-			}
-		}
-	}
-
-	return nil
-}
\ No newline at end of file
+package boomsense_sensor
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"math"
+	"sync"
+)
+
+// FeatureDim is the length of the ExtractFeatures vector
+const FeatureDim = 12
+
+// ModelVersion identifies the feature layout of saved models. Files
+// written before versioning are treated as version 1.
+//
+//	1: 11 features (no is_broach)
+//	2: 12 features, is_broach inserted at index 8
+const ModelVersion = 2
+
+const broachFeatureIndex = 8
+
+// BayesianQA implements online Bayesian logistic regression for event quality assessment
+type BayesianQA struct {
+	d      int       // Feature dimension
+	mu     []float64 // Mean weights
+	vr     []float64 // Variance (diagonal)
+	sigma0 float64   // Prior std, used when migrating saved models
+	lock   sync.RWMutex
+
+	// Full d×d posterior covariance, nil in the default diagonal mode.
+	// vr is kept equal to its diagonal.
+	cov [][]float64
+}
+
+// NewBayesianQA creates a new Bayesian QA model
+func NewBayesianQA(d int, sigma0 float64) *BayesianQA {
+	mu := make([]float64, d)
+	vr := make([]float64, d)
+	for i := 0; i < d; i++ {
+		vr[i] = sigma0 * sigma0
+	}
+	
+	return &BayesianQA{
+		d:      d,
+		mu:     mu,
+		vr:     vr,
+		sigma0: sigma0,
+	}
+}
+
+// EnableFullCovariance switches to a full covariance posterior, which
+// captures correlated features (gyro peak and roll delta move together in
+// gybes) at O(d²) cost per update. The current variances seed its diagonal.
+func (bq *BayesianQA) EnableFullCovariance() {
+	bq.lock.Lock()
+	defer bq.lock.Unlock()
+
+	if bq.cov == nil {
+		bq.cov = diagMatrix(bq.vr)
+	}
+}
+
+// PredictProba returns probability that event is correct
+func (bq *BayesianQA) PredictProba(x []float64) float64 {
+	bq.lock.RLock()
+	defer bq.lock.RUnlock()
+
+	if len(x) != bq.d {
+		return 0.5 // Invalid feature vector
+	}
+
+	// Mean prediction: m = mu · x
+	m := 0.0
+	for i := 0; i < bq.d; i++ {
+		m += bq.mu[i] * x[i]
+	}
+
+	// Variance: s² = xᵀΣx, or Σ(var_i * x_i²) for the diagonal model
+	s2 := 0.0
+	if bq.cov != nil {
+		s2 = dot(x, matVec(bq.cov, x))
+	} else {
+		for i := 0; i < bq.d; i++ {
+			s2 += bq.vr[i] * x[i] * x[i]
+		}
+	}
+
+	// Probit approximation for Bayesian logistic regression
+	k := 1.0 / math.Sqrt(1.0+math.Pi*s2/8.0)
+	z := k * m
+
+	// Sigmoid
+	return sigmoid(z)
+}
+
+// Update performs online Bayesian update
+func (bq *BayesianQA) Update(x []float64, y float64, iters int) {
+	bq.lock.Lock()
+	defer bq.lock.Unlock()
+
+	if len(x) != bq.d {
+		return
+	}
+
+	if bq.cov != nil {
+		bq.updateFull(x, y, iters)
+		return
+	}
+
+	for iter := 0; iter < iters; iter++ {
+		// Forward pass
+		z := 0.0
+		for i := 0; i < bq.d; i++ {
+			z += bq.mu[i] * x[i]
+		}
+		p := sigmoid(z)
+
+		// Gradient and Hessian (diagonal approximation)
+		for i := 0; i < bq.d; i++ {
+			g := (y - p) * x[i]
+			h := p*(1-p)*x[i]*x[i] + 1.0/bq.vr[i]
+			
+			// Update mean
+			step := g / h
+			bq.mu[i] += step
+			
+			// Update variance (diagonal approximation)
+			bq.vr[i] = 1.0 / h
+		}
+	}
+}
+
+// updateFull is the Laplace (ADF) update with a full covariance. With a
+// single observation the posterior mode lies on mu + α·Σx, so Newton runs on
+// the scalar α; the covariance then takes a rank-one Sherman-Morrison
+// update with the curvature p(1-p) at the mode.
+func (bq *BayesianQA) updateFull(x []float64, y float64, iters int) {
+	sx := matVec(bq.cov, x) // Σx
+	v := dot(x, sx)         // xᵀΣx
+	m := dot(bq.mu, x)
+
+	alpha := 0.0
+	for iter := 0; iter < iters; iter++ {
+		p := sigmoid(m + alpha*v)
+		alpha -= (alpha - (y - p)) / (1 + p*(1-p)*v)
+	}
+
+	p := sigmoid(m + alpha*v)
+	lambda := p * (1 - p)
+	scale := lambda / (1 + lambda*v)
+
+	for i := 0; i < bq.d; i++ {
+		bq.mu[i] += alpha * sx[i]
+		for j := 0; j < bq.d; j++ {
+			bq.cov[i][j] -= scale * sx[i] * sx[j]
+		}
+		bq.vr[i] = bq.cov[i][i]
+	}
+}
+
+// ExtractFeatures converts event to feature vector
+// Feature vector (12 dimensions with wind):
+// [gy_peak, boom_delta, dt, roll_delta, overshoot, 
+//  is_tack, is_gybe_normal, is_gybe_crash, is_broach,
+//  wind_speed_kn, wind_angle_deg, bias]
+func ExtractFeatures(evt Event) []float64 {
+	// Extract raw features
+	gy := evt.GyroPeak
+	bd := evt.BoomDelta
+	dt := evt.Duration
+	rl := evt.RollDelta
+	os := evt.Overshoot
+	ws := evt.WindSpeed
+	wa := evt.WindAngle
+
+	// One-hot encode event type
+	tTack := 0.0
+	tGN := 0.0
+	tGC := 0.0
+	tBR := 0.0
+	switch evt.Type {
+	case "tack":
+		tTack = 1.0
+	case "gybe_normal":
+		tGN = 1.0
+	case "gybe_crash":
+		tGC = 1.0
+	case "broach":
+		tBR = 1.0
+	}
+
+	// Build feature vector
+	x := []float64{gy, bd, dt, rl, os, tTack, tGN, tGC, tBR, ws, wa, 1.0}
+
+	// Scale features (matching Python scales)
+	scales := []float64{150, 1.5, 2.5, 25, 0.4, 1, 1, 1, 1, 40, 180, 1}
+	for i := 0; i < len(x); i++ {
+		x[i] /= scales[i]
+	}
+
+	return x
+}
+
+// sigmoid computes logistic sigmoid
+func sigmoid(z float64) float64 {
+	z = math.Max(-60.0, math.Min(60.0, z)) // Prevent overflow
+	return 1.0 / (1.0 + math.Exp(-z))
+}
+
+// SaveState persists model to JSON
+func (bq *BayesianQA) SaveState(path string) error {
+	bq.lock.RLock()
+	defer bq.lock.RUnlock()
+
+	state := map[string]interface{}{
+		"version": ModelVersion,
+		"mu":      bq.mu,
+		"var":     bq.vr,
+		"d":       bq.d,
+	}
+	if bq.cov != nil {
+		state["cov"] = bq.cov
+	}
+
+	data, err := json.MarshalIndent(state, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	return ioutil.WriteFile(path, data, 0644)
+}
+
+// LoadState restores model from JSON. Older model versions are migrated
+// to the current feature layout by inserting prior (zero mean, sigma0²
+// variance) entries for the new features. A file whose dimension still
+// does not match after migration, or that comes from a newer version, is
+// rejected with an error and the current model is left untouched so the
+// caller can keep the fresh prior.
+func (bq *BayesianQA) LoadState(path string) error {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return err
+	}
+
+	var state map[string]interface{}
+	if err := json.Unmarshal(data, &state); err != nil {
+		return err
+	}
+
+	version := 1
+	if v, ok := state["version"].(float64); ok {
+		version = int(v)
+	}
+	if version > ModelVersion {
+		return fmt.Errorf("model version %d is newer than supported version %d", version, ModelVersion)
+	}
+
+	mu := floatSlice(state["mu"])
+	vr := floatSlice(state["var"])
+	if len(mu) != len(vr) {
+		return fmt.Errorf("model has %d means but %d variances", len(mu), len(vr))
+	}
+	if d, ok := state["d"].(float64); ok && int(d) != len(mu) {
+		return fmt.Errorf("model declares d=%d but has %d weights", int(d), len(mu))
+	}
+
+	bq.lock.Lock()
+	defer bq.lock.Unlock()
+
+	if version < 2 && len(mu) == FeatureDim-1 && bq.d == FeatureDim {
+		prior := bq.sigma0 * bq.sigma0
+		mu = insertAt(mu, broachFeatureIndex, 0.0)
+		vr = insertAt(vr, broachFeatureIndex, prior)
+		log.Printf("[BoomSense] Migrated Bayesian QA model %s from v%d (d=%d) to v%d (d=%d)",
+			path, version, len(mu)-1, ModelVersion, len(mu))
+	}
+	if len(mu) != bq.d {
+		return fmt.Errorf("model dimension %d does not match expected %d", len(mu), bq.d)
+	}
+
+	// A saved covariance is only used in full mode; otherwise, or when the
+	// file came from a diagonal model, the variances are all that carry over
+	var cov [][]float64
+	if bq.cov != nil {
+		cov = floatMatrix(state["cov"])
+		if cov == nil {
+			cov = diagMatrix(vr)
+		} else if len(cov) != bq.d {
+			return fmt.Errorf("model covariance is %d×%d, expected %d×%d", len(cov), len(cov), bq.d, bq.d)
+		}
+	}
+
+	bq.mu = mu
+	bq.vr = vr
+	bq.cov = cov
+	return nil
+}
+
+// floatSlice converts a decoded JSON array to []float64
+func floatSlice(v interface{}) []float64 {
+	raw, ok := v.([]interface{})
+	if !ok {
+		return nil
+	}
+	out := make([]float64, len(raw))
+	for i, item := range raw {
+		if f, ok := item.(float64); ok {
+			out[i] = f
+		}
+	}
+	return out
+}
+
+// floatMatrix converts a decoded JSON array of arrays to a square matrix,
+// or nil when absent or not square
+func floatMatrix(v interface{}) [][]float64 {
+	raw, ok := v.([]interface{})
+	if !ok {
+		return nil
+	}
+	out := make([][]float64, len(raw))
+	for i, row := range raw {
+		out[i] = floatSlice(row)
+		if len(out[i]) != len(raw) {
+			return nil
+		}
+	}
+	return out
+}
+
+func diagMatrix(diag []float64) [][]float64 {
+	out := make([][]float64, len(diag))
+	for i := range out {
+		out[i] = make([]float64, len(diag))
+		out[i][i] = diag[i]
+	}
+	return out
+}
+
+func matVec(a [][]float64, x []float64) []float64 {
+	out := make([]float64, len(a))
+	for i, row := range a {
+		out[i] = dot(row, x)
+	}
+	return out
+}
+
+func dot(a, b []float64) float64 {
+	sum := 0.0
+	for i := range a {
+		sum += a[i] * b[i]
+	}
+	return sum
+}
+
+func insertAt(s []float64, i int, v float64) []float64 {
+	out := make([]float64, 0, len(s)+1)
+	out = append(out, s[:i]...)
+	out = append(out, v)
+	return append(out, s[i:]...)
+}
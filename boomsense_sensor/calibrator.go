@@ -1,232 +1,358 @@
-package boomsense_sensor
-
-import (
-	"encoding/json"
-	"fmt"
-	"io/ioutil"
-	"math"
-	"os"
-	"sort"
-	"sync"
-	"time"
-)
-
-// BoomCalibrator handles interactive boom calibration
-type BoomCalibrator struct {
-	boomAxis    string
-	calibration *Calibration
-	mu          sync.RWMutex
-}
-
-func NewBoomCalibrator(boomAxis string) *BoomCalibrator {
-	return &BoomCalibrator{
-		boomAxis: boomAxis,
-	}
-}
-
-// PerformCalibration runs interactive 4-point calibration
-// getAxisValue is a callback to retrieve current axis value
-func (bc *BoomCalibrator) PerformCalibration(getAxisValue func() (float64, bool)) (*Calibration, error) {
-	fmt.Println("\n=== BOOM CALIBRATION SEQUENCE ===")
-	fmt.Printf("Axis for boom mapping: %s\n", bc.boomAxis)
-
-	// Wait for filter to initialize
-	if !bc.waitForFilterReady(getAxisValue, 10*time.Second) {
-		return nil, fmt.Errorf("filter not initialized in time")
-	}
-
-	// Capture 4 points
-	c0 := bc.capturePoint("Place BOOM CENTERED on centerline", getAxisValue)
-	stb := bc.capturePoint("Ease BOOM FULLY OUT to STARBOARD (max)", getAxisValue)
-	port := bc.capturePoint("Ease BOOM FULLY OUT to PORT (max)", getAxisValue)
-	c1 := bc.capturePoint("Return BOOM to CENTER again (validation)", getAxisValue)
-
-	// Calculate calibration parameters
-	midExt := (stb + port) / 2.0       // Bias-resilient from extremes
-	cMid := (c0 + c1) / 2.0             // Operator-defined center
-	noise := math.Abs(c1 - c0)          // Larger → noisier centers
-
-	// Adaptive blending weight
-	wExt := math.Min(0.9, 0.5+noise/10.0)
-	mid := wExt*midExt + (1.0-wExt)*cMid
-
-	// Spans computed around blended mid
-	spanPos := math.Max(1e-3, stb-mid)  // Starboard travel
-	spanNeg := math.Max(1e-3, mid-port) // Port travel
-
-	// Diagnostics
-	off0 := c0 - mid
-	off1 := c1 - mid
-
-	fmt.Println("\n[CAL] ----------------- SUMMARY -----------------")
-	fmt.Printf("[CAL] c0 (center #1): %8.3f deg\n", c0)
-	fmt.Printf("[CAL] stb (max STB) : %8.3f deg\n", stb)
-	fmt.Printf("[CAL] port (max PT) : %8.3f deg\n", port)
-	fmt.Printf("[CAL] c1 (center #2): %8.3f deg\n", c1)
-	fmt.Printf("[CAL] mid_ext (extremes): %8.3f   c_mid (centers): %8.3f\n", midExt, cMid)
-	fmt.Printf("[CAL] noise|c1-c0|     : %8.3f   w_ext (auto): %4.2f\n", noise, wExt)
-	fmt.Printf("[CAL] mid (blended)    : %8.3f\n", mid)
-	fmt.Printf("[CAL] span_pos (STB)   : %.3f deg   span_neg (PORT): %.3f deg\n", spanPos, spanNeg)
-	fmt.Printf("[CAL] center offsets vs blended mid → c0:%+.3f  c1:%+.3f\n", off0, off1)
-
-	if math.Max(math.Abs(off0), math.Abs(off1)) > 3.0 {
-		fmt.Println("\n[CAL] WARNING: Centers are >3° off blended mid. Check sea state / sensor alignment.")
-	}
-
-	fmt.Print("[CAL] Apply this calibration? [Y/n]: ")
-	var ans string
-	fmt.Scanln(&ans)
-	if ans != "" && ans != "y" && ans != "Y" {
-		fmt.Println("[CAL] Calibration aborted by user.")
-		return nil, fmt.Errorf("calibration aborted")
-	}
-
-	cal := &Calibration{
-		Mid:       mid,
-		SpanPos:   spanPos,
-		SpanNeg:   spanNeg,
-		Timestamp: time.Now(),
-	}
-
-	bc.SetCalibration(cal)
-	fmt.Println("[CAL] Calibration committed.")
-	return cal, nil
-}
-
-// capturePoint prompts user and captures median value
-func (bc *BoomCalibrator) capturePoint(instruction string, getAxisValue func() (float64, bool)) float64 {
-	fmt.Println("\n----------------------------------------------------------------")
-	fmt.Printf("[CAL] %s\n", instruction)
-	fmt.Printf("[CAL] Keep the boom steady. Live %s value shown below.\n", bc.boomAxis)
-	fmt.Println("      Press <ENTER> to capture when stable.")
-	fmt.Println("----------------------------------------------------------------")
-
-	// Live preview
-	stopChan := make(chan struct{})
-	go func() {
-		ticker := time.NewTicker(50 * time.Millisecond)
-		defer ticker.Stop()
-		for {
-			select {
-			case <-ticker.C:
-				val, ok := getAxisValue()
-				if ok {
-					fmt.Printf("\r[CAL] %s = %7.3f deg   ", bc.boomAxis, val)
-				}
-			case <-stopChan:
-				return
-			}
-		}
-	}()
-
-	// Wait for user input
-	fmt.Scanln()
-	close(stopChan)
-
-	// Capture samples over 0.5s
-	samples := []float64{}
-	end := time.Now().Add(500 * time.Millisecond)
-	for time.Now().Before(end) {
-		if val, ok := getAxisValue(); ok {
-			samples = append(samples, val)
-		}
-		time.Sleep(20 * time.Millisecond)
-	}
-
-	// Calculate median
-	if len(samples) == 0 {
-		fmt.Println("[CAL] No samples captured!")
-		return 0.0
-	}
-
-	sort.Float64s(samples)
-	median := samples[len(samples)/2]
-	
-	fmt.Printf("\n[CAL] Captured: %.3f deg\n", median)
-	return median
-}
-
-// waitForFilterReady waits for filter initialization
-func (bc *BoomCalibrator) waitForFilterReady(getAxisValue func() (float64, bool), timeout time.Duration) bool {
-	deadline := time.Now().Add(timeout)
-	for time.Now().Before(deadline) {
-		if _, ok := getAxisValue(); ok {
-			return true
-		}
-		time.Sleep(50 * time.Millisecond)
-	}
-	return false
-}
-
-// SetCalibration updates calibration parameters
-func (bc *BoomCalibrator) SetCalibration(cal *Calibration) {
-	bc.mu.Lock()
-	defer bc.mu.Unlock()
-	bc.calibration = cal
-}
-
-// GetCalibration returns current calibration
-func (bc *BoomCalibrator) GetCalibration() *Calibration {
-	bc.mu.RLock()
-	defer bc.mu.RUnlock()
-	return bc.calibration
-}
-
-// ComputeBoom calculates boom metrics from axis value
-func (bc *BoomCalibrator) ComputeBoom(axisValue float64) (relDeg, norm float64, ok bool) {
-	bc.mu.RLock()
-	cal := bc.calibration
-	bc.mu.RUnlock()
-
-	if cal == nil {
-		return 0, 0, false
-	}
-
-	d := axisValue - cal.Mid
-	var n float64
-	if d >= 0 {
-		n = d / cal.SpanPos
-	} else {
-		n = d / cal.SpanNeg
-	}
-	n = math.Max(-1.1, math.Min(1.1, n))
-
-	return d, n, true
-}
-
-// SaveToFile persists calibration to JSON
-func (bc *BoomCalibrator) SaveToFile(path string) error {
-	bc.mu.RLock()
-	cal := bc.calibration
-	bc.mu.RUnlock()
-
-	if cal == nil {
-		return fmt.Errorf("no calibration to save")
-	}
-
-	data, err := json.MarshalIndent(cal, "", "  ")
-	if err != nil {
-		return err
-	}
-
-	return ioutil.WriteFile(path, data, 0644)
-}
-
-// LoadFromFile restores calibration from JSON
-func (bc *BoomCalibrator) LoadFromFile(path string) error {
-	data, err := ioutil.ReadFile(path)
-	if err != nil {
-		if os.IsNotExist(err) {
-			return nil // Not an error if file doesn't exist yet
-		}
-		return err
-	}
-
-	var cal Calibration
-	if err := json.Unmarshal(data, &cal); err != nil {
-		return err
-	}
-
-	bc.SetCalibration(&cal)
-	return nil
+package boomsense_sensor
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"math"
+	"os"
+	"sort"
+	"sync"
+	"time"
+)
+
+// BoomCalibrator handles interactive boom calibration
+type BoomCalibrator struct {
+	boomAxis    string
+	calibration *Calibration
+	mu          sync.RWMutex
+
+	// MinQuality rejects calibrations scoring below it (0-100, 0 accepts
+	// everything)
+	MinQuality float64
+
+	// AxisInvert and MountRotationDeg are the mount correction in force.
+	// They are stamped on new calibrations, and a saved one captured under
+	// other settings is refused on load.
+	AxisInvert       bool
+	MountRotationDeg float64
+}
+
+// Calibration quality ratings, from Calibration.Quality
+const (
+	CalibrationGood = "good" // >= 80
+	CalibrationFair = "fair" // >= 50
+	CalibrationPoor = "poor"
+)
+
+// CalibrationRating labels a quality score for display
+func CalibrationRating(quality float64) string {
+	switch {
+	case quality >= 80:
+		return CalibrationGood
+	case quality >= 50:
+		return CalibrationFair
+	}
+	return CalibrationPoor
+}
+
+func NewBoomCalibrator(boomAxis string) *BoomCalibrator {
+	return &BoomCalibrator{
+		boomAxis: boomAxis,
+	}
+}
+
+// PerformCalibration runs interactive 4-point calibration
+// getAxisValue is a callback to retrieve current axis value
+func (bc *BoomCalibrator) PerformCalibration(getAxisValue func() (float64, bool)) (*Calibration, error) {
+	fmt.Println("\n=== BOOM CALIBRATION SEQUENCE ===")
+	fmt.Printf("Axis for boom mapping: %s\n", bc.boomAxis)
+
+	// Wait for filter to initialize
+	if !bc.waitForFilterReady(getAxisValue, 10*time.Second) {
+		return nil, fmt.Errorf("filter not initialized in time")
+	}
+
+	// Capture 4 points
+	c0 := bc.capturePoint("Place BOOM CENTERED on centerline", getAxisValue)
+	stb := bc.capturePoint("Ease BOOM FULLY OUT to STARBOARD (max)", getAxisValue)
+	port := bc.capturePoint("Ease BOOM FULLY OUT to PORT (max)", getAxisValue)
+	c1 := bc.capturePoint("Return BOOM to CENTER again (validation)", getAxisValue)
+
+	sum, err := computeCalibration(c0, stb, port, c1)
+	if err != nil {
+		return nil, err
+	}
+
+	fmt.Println("\n[CAL] ----------------- SUMMARY -----------------")
+	fmt.Printf("[CAL] c0 (center #1): %8.3f deg\n", c0)
+	fmt.Printf("[CAL] stb (max STB) : %8.3f deg\n", stb)
+	fmt.Printf("[CAL] port (max PT) : %8.3f deg\n", port)
+	fmt.Printf("[CAL] c1 (center #2): %8.3f deg\n", c1)
+	fmt.Printf("[CAL] mid_ext (extremes): %8.3f   c_mid (centers): %8.3f\n", sum.midExt, sum.cMid)
+	fmt.Printf("[CAL] noise|c1-c0|     : %8.3f   w_ext (auto): %4.2f\n", sum.noise, sum.wExt)
+	fmt.Printf("[CAL] mid (blended)    : %8.3f\n", sum.mid)
+	fmt.Printf("[CAL] span_pos (STB)   : %.3f deg   span_neg (PORT): %.3f deg\n", sum.spanPos, sum.spanNeg)
+	fmt.Printf("[CAL] center offsets vs blended mid → c0:%+.3f  c1:%+.3f\n", sum.off0, sum.off1)
+	fmt.Printf("[CAL] quality          : %5.1f / 100 (%s)\n", sum.quality(), CalibrationRating(sum.quality()))
+
+	if sum.centerWarning() {
+		fmt.Println("\n[CAL] WARNING: Centers are >3° off blended mid. Check sea state / sensor alignment.")
+	}
+	if err := bc.checkQuality(sum); err != nil {
+		fmt.Printf("\n[CAL] REJECTED: %v\n", err)
+		return nil, err
+	}
+
+	fmt.Print("[CAL] Apply this calibration? [Y/n]: ")
+	var ans string
+	fmt.Scanln(&ans)
+	if ans != "" && ans != "y" && ans != "Y" {
+		fmt.Println("[CAL] Calibration aborted by user.")
+		return nil, fmt.Errorf("calibration aborted")
+	}
+
+	cal := sum.calibration()
+	cal.AxisInvert, cal.MountRotationDeg = bc.AxisInvert, bc.MountRotationDeg
+	bc.SetCalibration(cal)
+	fmt.Println("[CAL] Calibration committed.")
+	return cal, nil
+}
+
+// SetPoints computes a calibration from the four captured points (center,
+// starboard max, port max, center again) and commits it, without any console
+// I/O. It applies the same blending as the interactive sequence.
+func (bc *BoomCalibrator) SetPoints(center0, stbMax, portMax, center1 float64) (*Calibration, error) {
+	sum, err := computeCalibration(center0, stbMax, portMax, center1)
+	if err != nil {
+		return nil, err
+	}
+	if err := bc.checkQuality(sum); err != nil {
+		return nil, err
+	}
+
+	cal := sum.calibration()
+	cal.AxisInvert, cal.MountRotationDeg = bc.AxisInvert, bc.MountRotationDeg
+	bc.SetCalibration(cal)
+	return cal, nil
+}
+
+// calibrationSummary holds the intermediate values of a 4-point calibration
+type calibrationSummary struct {
+	midExt  float64 // mid from extremes
+	cMid    float64 // mid from operator centers
+	noise   float64 // |c1-c0|
+	wExt    float64 // weight of extremes in the blend
+	mid     float64
+	spanPos float64
+	spanNeg float64
+	off0    float64 // c0 offset from blended mid
+	off1    float64 // c1 offset from blended mid
+}
+
+// computeCalibration runs the blending math shared by the interactive and
+// programmatic calibration paths
+func computeCalibration(c0, stb, port, c1 float64) (calibrationSummary, error) {
+	for _, v := range []float64{c0, stb, port, c1} {
+		if math.IsNaN(v) || math.IsInf(v, 0) {
+			return calibrationSummary{}, fmt.Errorf("calibration points must be finite")
+		}
+	}
+	if stb <= port {
+		return calibrationSummary{}, fmt.Errorf("starboard max (%.3f) must be greater than port max (%.3f)", stb, port)
+	}
+
+	var sum calibrationSummary
+
+	// Calculate calibration parameters
+	sum.midExt = (stb + port) / 2.0 // Bias-resilient from extremes
+	sum.cMid = (c0 + c1) / 2.0      // Operator-defined center
+	sum.noise = math.Abs(c1 - c0)   // Larger → noisier centers
+
+	// Adaptive blending weight
+	sum.wExt = math.Min(0.9, 0.5+sum.noise/10.0)
+	sum.mid = sum.wExt*sum.midExt + (1.0-sum.wExt)*sum.cMid
+
+	// Spans computed around blended mid
+	sum.spanPos = math.Max(1e-3, stb-sum.mid)  // Starboard travel
+	sum.spanNeg = math.Max(1e-3, sum.mid-port) // Port travel
+
+	// Diagnostics
+	sum.off0 = c0 - sum.mid
+	sum.off1 = c1 - sum.mid
+
+	return sum, nil
+}
+
+// quality scores the calibration 0-100 from three checks: repeatability of
+// the two centers (|c1-c0|, 0 at 5°), symmetry of the port and starboard
+// spans (0 when one is half the other) and how far the centers sit from the
+// blended mid (0 at 6°, twice the warning threshold)
+func (s calibrationSummary) quality() float64 {
+	clamp := func(v float64) float64 { return math.Max(0, math.Min(1, v)) }
+
+	noise := clamp(1 - s.noise/5.0)
+	symmetry := clamp((math.Min(s.spanPos, s.spanNeg)/math.Max(s.spanPos, s.spanNeg) - 0.5) / 0.5)
+	offset := clamp(1 - math.Max(math.Abs(s.off0), math.Abs(s.off1))/6.0)
+
+	return 100.0 * (0.4*noise + 0.3*symmetry + 0.3*offset)
+}
+
+// checkQuality rejects a calibration scoring below MinQuality
+func (bc *BoomCalibrator) checkQuality(s calibrationSummary) error {
+	if q := s.quality(); q < bc.MinQuality {
+		return fmt.Errorf("calibration quality %.0f is below the minimum %.0f (center noise %.2f°, spans %.1f°/%.1f°, center offsets %+.2f°/%+.2f°)",
+			q, bc.MinQuality, s.noise, s.spanPos, s.spanNeg, s.off0, s.off1)
+	}
+	return nil
+}
+
+// centerWarning reports whether either center is >3° off the blended mid
+func (s calibrationSummary) centerWarning() bool {
+	return math.Max(math.Abs(s.off0), math.Abs(s.off1)) > 3.0
+}
+
+func (s calibrationSummary) calibration() *Calibration {
+	quality := s.quality()
+	return &Calibration{
+		Mid:       s.mid,
+		SpanPos:   s.spanPos,
+		SpanNeg:   s.spanNeg,
+		Quality:   &quality,
+		Timestamp: time.Now(),
+	}
+}
+
+// capturePoint prompts user and captures median value
+func (bc *BoomCalibrator) capturePoint(instruction string, getAxisValue func() (float64, bool)) float64 {
+	fmt.Println("\n----------------------------------------------------------------")
+	fmt.Printf("[CAL] %s\n", instruction)
+	fmt.Printf("[CAL] Keep the boom steady. Live %s value shown below.\n", bc.boomAxis)
+	fmt.Println("      Press <ENTER> to capture when stable.")
+	fmt.Println("----------------------------------------------------------------")
+
+	// Live preview
+	stopChan := make(chan struct{})
+	go func() {
+		ticker := time.NewTicker(50 * time.Millisecond)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ticker.C:
+				val, ok := getAxisValue()
+				if ok {
+					fmt.Printf("\r[CAL] %s = %7.3f deg   ", bc.boomAxis, val)
+				}
+			case <-stopChan:
+				return
+			}
+		}
+	}()
+
+	// Wait for user input
+	fmt.Scanln()
+	close(stopChan)
+
+	// Capture samples over 0.5s
+	samples := []float64{}
+	end := time.Now().Add(500 * time.Millisecond)
+	for time.Now().Before(end) {
+		if val, ok := getAxisValue(); ok {
+			samples = append(samples, val)
+		}
+		time.Sleep(20 * time.Millisecond)
+	}
+
+	// Calculate median
+	if len(samples) == 0 {
+		fmt.Println("[CAL] No samples captured!")
+		return 0.0
+	}
+
+	sort.Float64s(samples)
+	median := samples[len(samples)/2]
+	
+	fmt.Printf("\n[CAL] Captured: %.3f deg\n", median)
+	return median
+}
+
+// waitForFilterReady waits for filter initialization
+func (bc *BoomCalibrator) waitForFilterReady(getAxisValue func() (float64, bool), timeout time.Duration) bool {
+	deadline := time.Now().Add(timeout)
+	for time.Now().Before(deadline) {
+		if _, ok := getAxisValue(); ok {
+			return true
+		}
+		time.Sleep(50 * time.Millisecond)
+	}
+	return false
+}
+
+// SetCalibration updates calibration parameters
+func (bc *BoomCalibrator) SetCalibration(cal *Calibration) {
+	bc.mu.Lock()
+	defer bc.mu.Unlock()
+	bc.calibration = cal
+}
+
+// GetCalibration returns current calibration
+func (bc *BoomCalibrator) GetCalibration() *Calibration {
+	bc.mu.RLock()
+	defer bc.mu.RUnlock()
+	return bc.calibration
+}
+
+// ComputeBoom calculates boom metrics from axis value
+func (bc *BoomCalibrator) ComputeBoom(axisValue float64) (relDeg, norm float64, ok bool) {
+	bc.mu.RLock()
+	cal := bc.calibration
+	bc.mu.RUnlock()
+
+	if cal == nil {
+		return 0, 0, false
+	}
+
+	d := axisValue - cal.Mid
+	var n float64
+	if d >= 0 {
+		n = d / cal.SpanPos
+	} else {
+		n = d / cal.SpanNeg
+	}
+	n = math.Max(-1.1, math.Min(1.1, n))
+
+	return d, n, true
+}
+
+// SaveToFile persists calibration to JSON
+func (bc *BoomCalibrator) SaveToFile(path string) error {
+	bc.mu.RLock()
+	cal := bc.calibration
+	bc.mu.RUnlock()
+
+	if cal == nil {
+		return fmt.Errorf("no calibration to save")
+	}
+
+	data, err := json.MarshalIndent(cal, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	return ioutil.WriteFile(path, data, 0644)
+}
+
+// LoadFromFile restores calibration from JSON. A calibration captured under a
+// different mount correction measured a different axis, so it is refused
+// and the current calibration is left as it is.
+func (bc *BoomCalibrator) LoadFromFile(path string) error {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil // Not an error if file doesn't exist yet
+		}
+		return err
+	}
+
+	var cal Calibration
+	if err := json.Unmarshal(data, &cal); err != nil {
+		return err
+	}
+	if cal.AxisInvert != bc.AxisInvert || cal.MountRotationDeg != bc.MountRotationDeg {
+		return fmt.Errorf("%s was captured with invert=%v rotation=%.1f deg, sensor is mounted with invert=%v rotation=%.1f deg; recalibrate",
+			path, cal.AxisInvert, cal.MountRotationDeg, bc.AxisInvert, bc.MountRotationDeg)
+	}
+
+	bc.SetCalibration(&cal)
+	return nil
 }
\ No newline at end of file
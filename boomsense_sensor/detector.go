@@ -1,329 +1,532 @@
-package boomsense_sensor
-
-import (
-	"math"
-	"sync"
-	"time"
-)
-
-// EventDetector implements rule-based sailing event detection
-type EventDetector struct {
-	config          Config
-	buffer          []eventSample
-	maxBufferSize   int
-	lastEventTime   float64
-	listeners       []func(Event)
-	mu              sync.RWMutex
-}
-
-type eventSample struct {
-	t        float64
-	gyro     float64
-	boomNorm float64
-	roll     float64
-}
-
-func NewEventDetector(config Config) *EventDetector {
-	return &EventDetector{
-		config:        config,
-		buffer:        make([]eventSample, 0, config.MaxBufferSize),
-		maxBufferSize: config.MaxBufferSize,
-		lastEventTime: -1e9,
-	}
-}
-
-// AddListener registers an event callback
-func (ed *EventDetector) AddListener(fn func(Event)) {
-	ed.mu.Lock()
-	defer ed.mu.Unlock()
-	ed.listeners = append(ed.listeners, fn)
-}
-
-// OnSample processes a new sensor sample
-func (ed *EventDetector) OnSample(t time.Time, gyroY, boomNorm, roll float64) {
-	ed.mu.Lock()
-	defer ed.mu.Unlock()
-
-	ts := float64(t.UnixNano()) / 1e9
-
-	// Add to buffer
-	sample := eventSample{
-		t:        ts,
-		gyro:     gyroY,
-		boomNorm: boomNorm,
-		roll:     roll,
-	}
-
-	ed.buffer = append(ed.buffer, sample)
-	if len(ed.buffer) > ed.maxBufferSize {
-		ed.buffer = ed.buffer[1:]
-	}
-
-	// Check for events
-	ed.maybeEmit(ts)
-}
-
-// maybeEmit checks conditions and emits events
-func (ed *EventDetector) maybeEmit(tNow float64) {
-	// Refractory period
-	if (tNow - ed.lastEventTime) < ed.config.RefractoryPeriod {
-		return
-	}
-
-	// Check crash gybe
-	if evt := ed.checkCrashGybe(tNow); evt != nil {
-		ed.publish(*evt)
-		return
-	}
-
-	// Check normal gybe
-	if evt := ed.checkNormalGybe(tNow); evt != nil {
-		ed.publish(*evt)
-		return
-	}
-
-	// Check tack
-	if evt := ed.checkTack(tNow); evt != nil {
-		ed.publish(*evt)
-		return
-	}
-
-	// Check boom hit
-	if evt := ed.checkBoomHit(tNow); evt != nil {
-		ed.publish(*evt)
-		return
-	}
-}
-
-// checkCrashGybe detects crash gybes
-func (ed *EventDetector) checkCrashGybe(tNow float64) *Event {
-	dt, gyPeak, boomDelta, rollDrop, _, _ := ed.spanIn(tNow, ed.config.CrashDT)
-
-	if gyPeak >= ed.config.CrashGyDPS && boomDelta >= ed.config.BoomStepCrash {
-		return &Event{
-			Type:      "gybe_crash",
-			Timestamp: time.Unix(0, int64(tNow*1e9)),
-			GyroPeak:  gyPeak,
-			BoomDelta: boomDelta,
-			RollDelta: rollDrop,
-			Duration:  dt,
-		}
-	}
-	return nil
-}
-
-// checkNormalGybe detects normal gybes
-func (ed *EventDetector) checkNormalGybe(tNow float64) *Event {
-	dt, gyPeak, boomDelta, rollDrop, _, _ := ed.spanIn(tNow, ed.config.NormalDT)
-
-	if gyPeak >= ed.config.NormalGyMin && 
-	   gyPeak < ed.config.CrashGyDPS && 
-	   boomDelta >= ed.config.BoomStepNormal {
-		return &Event{
-			Type:      "gybe_normal",
-			Timestamp: time.Unix(0, int64(tNow*1e9)),
-			GyroPeak:  gyPeak,
-			BoomDelta: boomDelta,
-			RollDelta: rollDrop,
-			Duration:  dt,
-		}
-	}
-	return nil
-}
-
-// checkTack detects tacks
-func (ed *EventDetector) checkTack(tNow float64) *Event {
-	dt, gyPeak, boomDelta, rollDrop, bnSeries, _ := ed.spanIn(tNow, ed.config.TackDTMax)
-
-	if gyPeak >= ed.config.TackGyMin && 
-	   gyPeak <= ed.config.TackGyMax &&
-	   boomDelta >= ed.config.TackBoomStep &&
-	   rollDrop >= ed.config.TackMinRollDelta {
-
-		direction := ed.tackDirection(bnSeries)
-		overshoot := ed.calculateOvershoot(bnSeries)
-		score := ed.tackQualityScore(dt, gyPeak, rollDrop, overshoot)
-
-		return &Event{
-			Type:      "tack",
-			Timestamp: time.Unix(0, int64(tNow*1e9)),
-			Direction: direction,
-			GyroPeak:  gyPeak,
-			BoomDelta: boomDelta,
-			RollDelta: rollDrop,
-			Duration:  dt,
-			Overshoot: overshoot,
-			Score:     score,
-		}
-	}
-	return nil
-}
-
-// checkBoomHit detects boom hits
-func (ed *EventDetector) checkBoomHit(tNow float64) *Event {
-	dt, gyPeak, _, rollDrop, _, _ := ed.spanIn(tNow, ed.config.RollDT)
-
-	if gyPeak >= (ed.config.CrashGyDPS+20) && rollDrop >= ed.config.RollHit {
-		return &Event{
-			Type:      "boom_hit",
-			Timestamp: time.Unix(0, int64(tNow*1e9)),
-			GyroPeak:  gyPeak,
-			RollDelta: rollDrop,
-			Duration:  dt,
-		}
-	}
-	return nil
-}
-
-// spanIn extracts statistics over a time window
-func (ed *EventDetector) spanIn(tNow, horizon float64) (dt, gyPeak, boomDelta, rollDrop float64, bnSeries, rlSeries []float64) {
-	t0 := math.Max(0, tNow-horizon)
-	
-	var sub []eventSample
-	for _, s := range ed.buffer {
-		if s.t >= t0 {
-			sub = append(sub, s)
-		}
-	}
-
-	if len(sub) == 0 {
-		return 0, 0, 0, 0, nil, nil
-	}
-
-	dt = sub[len(sub)-1].t - sub[0].t
-
-	// Gyro peak (absolute)
-	for _, s := range sub {
-		if abs := math.Abs(s.gyro); abs > gyPeak {
-			gyPeak = abs
-		}
-	}
-
-	// Boom delta
-	var bnValid []float64
-	for _, s := range sub {
-		if math.IsInf(s.boomNorm, 0) || math.IsNaN(s.boomNorm) {
-			continue
-		}
-		bnValid = append(bnValid, s.boomNorm)
-		bnSeries = append(bnSeries, s.boomNorm)
-	}
-	if len(bnValid) >= 2 {
-		minBn, maxBn := bnValid[0], bnValid[0]
-		for _, v := range bnValid {
-			if v < minBn {
-				minBn = v
-			}
-			if v > maxBn {
-				maxBn = v
-			}
-		}
-		boomDelta = maxBn - minBn
-	}
-
-	// Roll drop (max decrease)
-	var rlValid []float64
-	for _, s := range sub {
-		if math.IsInf(s.roll, 0) || math.IsNaN(s.roll) {
-			continue
-		}
-		rlValid = append(rlValid, s.roll)
-		rlSeries = append(rlSeries, s.roll)
-	}
-	if len(rlValid) >= 2 {
-		for i := 0; i < len(rlValid); i++ {
-			for j := i + 1; j < len(rlValid); j++ {
-				drop := rlValid[i] - rlValid[j]
-				if drop > rollDrop {
-					rollDrop = drop
-				}
-			}
-		}
-	}
-
-	return
-}
-
-// tackDirection determines tack direction
-func (ed *EventDetector) tackDirection(bnSeries []float64) string {
-	if len(bnSeries) < 2 {
-		return ""
-	}
-	if bnSeries[0] > 0 && bnSeries[len(bnSeries)-1] < 0 {
-		return "stb_to_port"
-	}
-	if bnSeries[0] < 0 && bnSeries[len(bnSeries)-1] > 0 {
-		return "port_to_stb"
-	}
-	return ""
-}
-
-// calculateOvershoot measures tack overshoot
-func (ed *EventDetector) calculateOvershoot(bnSeries []float64) float64 {
-	if len(bnSeries) < 4 {
-		return 0.0
-	}
-
-	// Head: first quarter average (unused but calculated for consistency)
-	headN := int(math.Max(2, float64(len(bnSeries))/4))
-	headSum := 0.0
-	for i := 0; i < headN; i++ {
-		headSum += bnSeries[i]
-	}
-	_ = headSum / float64(headN) // head unused in current algorithm
-
-	// Tail: last quarter average
-	tailN := int(math.Max(2, float64(len(bnSeries))/4))
-	tailSum := 0.0
-	for i := len(bnSeries) - tailN; i < len(bnSeries); i++ {
-		tailSum += bnSeries[i]
-	}
-	tail := tailSum / float64(tailN)
-
-	// Overshoot: max deviation in last third from target
-	target := tail
-	tailThird := int(math.Max(5, float64(len(bnSeries))/3))
-	maxDev := 0.0
-	for i := len(bnSeries) - tailThird; i < len(bnSeries); i++ {
-		dev := math.Abs(bnSeries[i] - target)
-		if dev > maxDev {
-			maxDev = dev
-		}
-	}
-
-	return math.Max(0.0, maxDev-0.05)
-}
-
-// tackQualityScore calculates tack quality (0-100)
-func (ed *EventDetector) tackQualityScore(dt, gyPeak, rollDrop, overshoot float64) float64 {
-	// Time component (faster is better, target ~1.6s)
-	tComp := math.Max(0, 40*(1.6/math.Max(0.6, dt)))
-
-	// Gyro component (smooth ~55 deg/s is optimal)
-	gyComp := math.Max(0, 30*(1.0-math.Abs(gyPeak-55)/55))
-
-	// Roll component (more heel change is better, up to 25 deg)
-	rlComp := math.Max(0, 20*(math.Min(rollDrop, 25)/25))
-
-	// Overshoot penalty (less is better)
-	osComp := math.Max(0, 10*(1.0-math.Min(overshoot/0.25, 1.0)))
-
-	score := tComp + gyComp + rlComp + osComp
-	return math.Min(100, math.Round(score*10)/10)
-}
-
-// publish notifies all listeners
-func (ed *EventDetector) publish(evt Event) {
-	ed.lastEventTime = float64(evt.Timestamp.UnixNano()) / 1e9
-	
-	for _, fn := range ed.listeners {
-		go func(f func(Event)) {
-			defer func() {
-				if r := recover(); r != nil {
-					// Listener panicked, ignore
-				}
-			}()
-			f(evt)
-		}(fn)
-	}
+package boomsense_sensor
+
+import (
+	"fmt"
+	"math"
+	"sync"
+	"time"
+)
+
+// Event types emitted by the detector
+const (
+	EventTack       = "tack"
+	EventGybeNormal = "gybe_normal"
+	EventGybeCrash  = "gybe_crash"
+	EventBoomHit    = "boom_hit"
+	EventBroach     = "broach"
+)
+
+// severeEvents may bypass the refractory window of a milder event when
+// Config.RefractoryBypassSevere is set
+var severeEvents = map[string]bool{
+	EventGybeCrash: true,
+	EventBroach:    true,
+}
+
+// EventDetector implements rule-based sailing event detection
+type EventDetector struct {
+	config        Config // thresholds in use: base with gyroScale applied
+	base          Config
+	gyroScale     float64
+	buffer        []eventSample
+	maxBufferSize int
+	lastEventTime float64
+	lastEventType string
+	lastByType    map[string]float64 // last emission time of each type
+	listeners     []func(Event)
+	mu            sync.RWMutex
+}
+
+type eventSample struct {
+	t        float64
+	gyro     float64
+	boomNorm float64
+	roll     float64
+	yawRate  float64
+}
+
+func NewEventDetector(config Config) *EventDetector {
+	return &EventDetector{
+		config:        config,
+		base:          config,
+		gyroScale:     1,
+		buffer:        make([]eventSample, 0, config.MaxBufferSize),
+		maxBufferSize: config.MaxBufferSize,
+		lastEventTime: -1e9,
+		lastByType:    make(map[string]float64),
+	}
+}
+
+// Config returns the thresholds the detector was configured with, before
+// any gyro scaling
+func (ed *EventDetector) Config() Config {
+	ed.mu.RLock()
+	defer ed.mu.RUnlock()
+	return ed.base.clone()
+}
+
+// clone copies c, including its map, so a caller decoding JSON over the
+// copy cannot touch the detector's own
+func (c Config) clone() Config {
+	if c.RefractoryByType != nil {
+		byType := make(map[string]float64, len(c.RefractoryByType))
+		for k, v := range c.RefractoryByType {
+			byType[k] = v
+		}
+		c.RefractoryByType = byType
+	}
+	return c
+}
+
+// UpdateConfig swaps in new thresholds, taking effect from the next sample.
+// The sample buffer is kept, so MaxBufferSize stays as constructed. Callers
+// should check config.ValidateThresholds first.
+func (ed *EventDetector) UpdateConfig(config Config) {
+	ed.mu.Lock()
+	defer ed.mu.Unlock()
+	ed.base = config.clone()
+	ed.config = ed.base.scaleGyro(ed.gyroScale)
+}
+
+// SetGyroScale multiplies the gyro rate thresholds by scale (1 = as
+// configured), e.g. raising them in rough water where wave motion alone
+// produces rates near a tack's. Non-positive scales are ignored.
+func (ed *EventDetector) SetGyroScale(scale float64) {
+	if !(scale > 0) {
+		return
+	}
+	ed.mu.Lock()
+	defer ed.mu.Unlock()
+	ed.gyroScale = scale
+	ed.config = ed.base.scaleGyro(scale)
+}
+
+// GyroScale returns the factor set by SetGyroScale
+func (ed *EventDetector) GyroScale() float64 {
+	ed.mu.RLock()
+	defer ed.mu.RUnlock()
+	return ed.gyroScale
+}
+
+// scaleGyro returns a copy of c with its gyro rate thresholds multiplied by
+// scale; the tack band is scaled at both ends so it keeps its shape
+func (c Config) scaleGyro(scale float64) Config {
+	c = c.clone()
+	c.CrashGyDPS *= scale
+	c.NormalGyMin *= scale
+	c.TackGyMin *= scale
+	c.TackGyMax *= scale
+	c.BroachYawDPS *= scale
+	return c
+}
+
+// ValidateThresholds checks the event detection thresholds for values the
+// detector cannot work with: non-positive rates, steps and windows, boom
+// steps beyond the normalised [-1, 1] range, and inverted gyro bands
+func (c Config) ValidateThresholds() error {
+	positive := []struct {
+		name  string
+		value float64
+		max   float64
+	}{
+		{"crash_gy_dps", c.CrashGyDPS, 2000},
+		{"normal_gy_min", c.NormalGyMin, 2000},
+		{"tack_gy_min", c.TackGyMin, 2000},
+		{"tack_gy_max", c.TackGyMax, 2000},
+		{"broach_yaw_dps", c.BroachYawDPS, 2000},
+		{"boom_step_crash", c.BoomStepCrash, 2},
+		{"boom_step_normal", c.BoomStepNormal, 2},
+		{"tack_boom_step", c.TackBoomStep, 2},
+		{"roll_hit", c.RollHit, 90},
+		{"tack_min_roll_delta", c.TackMinRollDelta, 180},
+		{"broach_roll", c.BroachRoll, 90},
+		{"broach_heading_deg", c.BroachHeadingDeg, 180},
+		{"crash_dt", c.CrashDT, 60},
+		{"normal_dt", c.NormalDT, 60},
+		{"roll_dt", c.RollDT, 60},
+		{"tack_dt_max", c.TackDTMax, 60},
+		{"broach_dt", c.BroachDT, 60},
+	}
+	for _, p := range positive {
+		// Written so NaN fails too
+		if !(p.value > 0 && p.value <= p.max) {
+			return fmt.Errorf("%s must be in (0, %g], got %g", p.name, p.max, p.value)
+		}
+	}
+
+	if !(c.RefractoryPeriod >= 0 && c.RefractoryPeriod <= 60) {
+		return fmt.Errorf("refractory_period must be in [0, 60], got %g", c.RefractoryPeriod)
+	}
+	for eventType, period := range c.RefractoryByType {
+		switch eventType {
+		case EventTack, EventGybeNormal, EventGybeCrash, EventBoomHit, EventBroach:
+		default:
+			return fmt.Errorf("refractory_by_type: unknown event type %q", eventType)
+		}
+		if !(period >= 0 && period <= 60) {
+			return fmt.Errorf("refractory_by_type.%s must be in [0, 60], got %g", eventType, period)
+		}
+	}
+	if c.TackGyMin >= c.TackGyMax {
+		return fmt.Errorf("tack_gy_min (%g) must be below tack_gy_max (%g)", c.TackGyMin, c.TackGyMax)
+	}
+	if c.NormalGyMin >= c.CrashGyDPS {
+		return fmt.Errorf("normal_gy_min (%g) must be below crash_gy_dps (%g)", c.NormalGyMin, c.CrashGyDPS)
+	}
+	return nil
+}
+
+// AddListener registers an event callback
+func (ed *EventDetector) AddListener(fn func(Event)) {
+	ed.mu.Lock()
+	defer ed.mu.Unlock()
+	ed.listeners = append(ed.listeners, fn)
+}
+
+// OnSample processes a new sensor sample. yawRate is the rotation rate
+// about the vertical axis (deg/s), used for broach detection.
+func (ed *EventDetector) OnSample(t time.Time, gyroY, boomNorm, roll, yawRate float64) {
+	ed.mu.Lock()
+	defer ed.mu.Unlock()
+
+	ts := float64(t.UnixNano()) / 1e9
+
+	// Add to buffer
+	sample := eventSample{
+		t:        ts,
+		gyro:     gyroY,
+		boomNorm: boomNorm,
+		roll:     roll,
+		yawRate:  yawRate,
+	}
+
+	ed.buffer = append(ed.buffer, sample)
+	if len(ed.buffer) > ed.maxBufferSize {
+		ed.buffer = ed.buffer[1:]
+	}
+
+	// Check for events
+	ed.maybeEmit(ts)
+}
+
+// maybeEmit checks conditions and emits at most one event per sample, the
+// first in priority order that is not held back by its refractory window
+func (ed *EventDetector) maybeEmit(tNow float64) {
+	// Broach first, it is the most dangerous event
+	checks := []func(float64) *Event{
+		ed.checkBroach,
+		ed.checkCrashGybe,
+		ed.checkNormalGybe,
+		ed.checkTack,
+		ed.checkBoomHit,
+	}
+	for _, check := range checks {
+		if evt := check(tNow); evt != nil && !ed.inRefractory(evt.Type, tNow) {
+			ed.publish(*evt)
+			return
+		}
+	}
+}
+
+// inRefractory reports whether a candidate event of eventType is too close
+// to the previous event, or to the previous event of its own type. Only the
+// first can be bypassed by a severe event, so a broach is not re-emitted
+// just because a boom hit was logged in between.
+func (ed *EventDetector) inRefractory(eventType string, tNow float64) bool {
+	period := ed.config.RefractoryPeriod
+	if p, ok := ed.config.RefractoryByType[eventType]; ok {
+		period = p
+	}
+	if last, ok := ed.lastByType[eventType]; ok && tNow-last < period {
+		return true
+	}
+
+	if ed.config.RefractoryBypassSevere && severeEvents[eventType] && !severeEvents[ed.lastEventType] {
+		return false
+	}
+	return tNow-ed.lastEventTime < period
+}
+
+// checkBroach detects broaches: heavy heel together with a fast, sustained
+// turn (the boat rounding up). The heading change is integrated from the
+// yaw rate over the window.
+func (ed *EventDetector) checkBroach(tNow float64) *Event {
+	t0 := tNow - ed.config.BroachDT
+
+	var first, last float64
+	var rollPeak, yawPeak, headingDelta float64
+	var prevT float64
+	n := 0
+	for _, s := range ed.buffer {
+		if s.t < t0 {
+			continue
+		}
+		if n == 0 {
+			first = s.t
+		} else {
+			headingDelta += s.yawRate * (s.t - prevT)
+		}
+		prevT = s.t
+		last = s.t
+		n++
+
+		if abs := math.Abs(s.roll); abs > rollPeak && !math.IsNaN(abs) {
+			rollPeak = abs
+		}
+		if abs := math.Abs(s.yawRate); abs > yawPeak {
+			yawPeak = abs
+		}
+	}
+
+	if n < 2 {
+		return nil
+	}
+
+	if rollPeak >= ed.config.BroachRoll &&
+		yawPeak >= ed.config.BroachYawDPS &&
+		math.Abs(headingDelta) >= ed.config.BroachHeadingDeg {
+		return &Event{
+			Type:      EventBroach,
+			Timestamp: time.Unix(0, int64(tNow*1e9)),
+			GyroPeak:  yawPeak,
+			RollDelta: rollPeak,
+			Duration:  last - first,
+		}
+	}
+	return nil
+}
+
+// checkCrashGybe detects crash gybes
+func (ed *EventDetector) checkCrashGybe(tNow float64) *Event {
+	dt, gyPeak, boomDelta, rollDrop, _, _ := ed.spanIn(tNow, ed.config.CrashDT)
+
+	if gyPeak >= ed.config.CrashGyDPS && boomDelta >= ed.config.BoomStepCrash {
+		return &Event{
+			Type:      EventGybeCrash,
+			Timestamp: time.Unix(0, int64(tNow*1e9)),
+			GyroPeak:  gyPeak,
+			BoomDelta: boomDelta,
+			RollDelta: rollDrop,
+			Duration:  dt,
+		}
+	}
+	return nil
+}
+
+// checkNormalGybe detects normal gybes
+func (ed *EventDetector) checkNormalGybe(tNow float64) *Event {
+	dt, gyPeak, boomDelta, rollDrop, _, _ := ed.spanIn(tNow, ed.config.NormalDT)
+
+	if gyPeak >= ed.config.NormalGyMin && 
+	   gyPeak < ed.config.CrashGyDPS && 
+	   boomDelta >= ed.config.BoomStepNormal {
+		return &Event{
+			Type:      EventGybeNormal,
+			Timestamp: time.Unix(0, int64(tNow*1e9)),
+			GyroPeak:  gyPeak,
+			BoomDelta: boomDelta,
+			RollDelta: rollDrop,
+			Duration:  dt,
+		}
+	}
+	return nil
+}
+
+// checkTack detects tacks
+func (ed *EventDetector) checkTack(tNow float64) *Event {
+	dt, gyPeak, boomDelta, rollDrop, bnSeries, _ := ed.spanIn(tNow, ed.config.TackDTMax)
+
+	if gyPeak >= ed.config.TackGyMin && 
+	   gyPeak <= ed.config.TackGyMax &&
+	   boomDelta >= ed.config.TackBoomStep &&
+	   rollDrop >= ed.config.TackMinRollDelta {
+
+		direction := ed.tackDirection(bnSeries)
+		overshoot := ed.calculateOvershoot(bnSeries)
+		score := ed.tackQualityScore(dt, gyPeak, rollDrop, overshoot)
+
+		return &Event{
+			Type:      EventTack,
+			Timestamp: time.Unix(0, int64(tNow*1e9)),
+			Direction: direction,
+			GyroPeak:  gyPeak,
+			BoomDelta: boomDelta,
+			RollDelta: rollDrop,
+			Duration:  dt,
+			Overshoot: overshoot,
+			Score:     score,
+		}
+	}
+	return nil
+}
+
+// checkBoomHit detects boom hits
+func (ed *EventDetector) checkBoomHit(tNow float64) *Event {
+	dt, gyPeak, _, rollDrop, _, _ := ed.spanIn(tNow, ed.config.RollDT)
+
+	if gyPeak >= (ed.config.CrashGyDPS+20) && rollDrop >= ed.config.RollHit {
+		return &Event{
+			Type:      EventBoomHit,
+			Timestamp: time.Unix(0, int64(tNow*1e9)),
+			GyroPeak:  gyPeak,
+			RollDelta: rollDrop,
+			Duration:  dt,
+		}
+	}
+	return nil
+}
+
+// spanIn extracts statistics over a time window
+func (ed *EventDetector) spanIn(tNow, horizon float64) (dt, gyPeak, boomDelta, rollDrop float64, bnSeries, rlSeries []float64) {
+	t0 := math.Max(0, tNow-horizon)
+	
+	var sub []eventSample
+	for _, s := range ed.buffer {
+		if s.t >= t0 {
+			sub = append(sub, s)
+		}
+	}
+
+	if len(sub) == 0 {
+		return 0, 0, 0, 0, nil, nil
+	}
+
+	dt = sub[len(sub)-1].t - sub[0].t
+
+	// Gyro peak (absolute)
+	for _, s := range sub {
+		if abs := math.Abs(s.gyro); abs > gyPeak {
+			gyPeak = abs
+		}
+	}
+
+	// Boom delta
+	var bnValid []float64
+	for _, s := range sub {
+		if math.IsInf(s.boomNorm, 0) || math.IsNaN(s.boomNorm) {
+			continue
+		}
+		bnValid = append(bnValid, s.boomNorm)
+		bnSeries = append(bnSeries, s.boomNorm)
+	}
+	if len(bnValid) >= 2 {
+		minBn, maxBn := bnValid[0], bnValid[0]
+		for _, v := range bnValid {
+			if v < minBn {
+				minBn = v
+			}
+			if v > maxBn {
+				maxBn = v
+			}
+		}
+		boomDelta = maxBn - minBn
+	}
+
+	// Roll drop (max decrease)
+	var rlValid []float64
+	for _, s := range sub {
+		if math.IsInf(s.roll, 0) || math.IsNaN(s.roll) {
+			continue
+		}
+		rlValid = append(rlValid, s.roll)
+		rlSeries = append(rlSeries, s.roll)
+	}
+	if len(rlValid) >= 2 {
+		for i := 0; i < len(rlValid); i++ {
+			for j := i + 1; j < len(rlValid); j++ {
+				drop := rlValid[i] - rlValid[j]
+				if drop > rollDrop {
+					rollDrop = drop
+				}
+			}
+		}
+	}
+
+	return
+}
+
+// tackDirection determines tack direction
+func (ed *EventDetector) tackDirection(bnSeries []float64) string {
+	if len(bnSeries) < 2 {
+		return ""
+	}
+	if bnSeries[0] > 0 && bnSeries[len(bnSeries)-1] < 0 {
+		return "stb_to_port"
+	}
+	if bnSeries[0] < 0 && bnSeries[len(bnSeries)-1] > 0 {
+		return "port_to_stb"
+	}
+	return ""
+}
+
+// calculateOvershoot measures tack overshoot
+func (ed *EventDetector) calculateOvershoot(bnSeries []float64) float64 {
+	if len(bnSeries) < 4 {
+		return 0.0
+	}
+
+	// Head: first quarter average (unused but calculated for consistency)
+	headN := int(math.Max(2, float64(len(bnSeries))/4))
+	headSum := 0.0
+	for i := 0; i < headN; i++ {
+		headSum += bnSeries[i]
+	}
+	_ = headSum / float64(headN) // head unused in current algorithm
+
+	// Tail: last quarter average
+	tailN := int(math.Max(2, float64(len(bnSeries))/4))
+	tailSum := 0.0
+	for i := len(bnSeries) - tailN; i < len(bnSeries); i++ {
+		tailSum += bnSeries[i]
+	}
+	tail := tailSum / float64(tailN)
+
+	// Overshoot: max deviation in last third from target
+	target := tail
+	tailThird := int(math.Max(5, float64(len(bnSeries))/3))
+	maxDev := 0.0
+	for i := len(bnSeries) - tailThird; i < len(bnSeries); i++ {
+		dev := math.Abs(bnSeries[i] - target)
+		if dev > maxDev {
+			maxDev = dev
+		}
+	}
+
+	return math.Max(0.0, maxDev-0.05)
+}
+
+// tackQualityScore calculates tack quality (0-100)
+func (ed *EventDetector) tackQualityScore(dt, gyPeak, rollDrop, overshoot float64) float64 {
+	// Time component (faster is better, target ~1.6s)
+	tComp := math.Max(0, 40*(1.6/math.Max(0.6, dt)))
+
+	// Gyro component (smooth ~55 deg/s is optimal)
+	gyComp := math.Max(0, 30*(1.0-math.Abs(gyPeak-55)/55))
+
+	// Roll component (more heel change is better, up to 25 deg)
+	rlComp := math.Max(0, 20*(math.Min(rollDrop, 25)/25))
+
+	// Overshoot penalty (less is better)
+	osComp := math.Max(0, 10*(1.0-math.Min(overshoot/0.25, 1.0)))
+
+	score := tComp + gyComp + rlComp + osComp
+	return math.Min(100, math.Round(score*10)/10)
+}
+
+// publish notifies all listeners
+func (ed *EventDetector) publish(evt Event) {
+	ed.lastEventTime = float64(evt.Timestamp.UnixNano()) / 1e9
+	ed.lastEventType = evt.Type
+	ed.lastByType[evt.Type] = ed.lastEventTime
+	
+	for _, fn := range ed.listeners {
+		go func(f func(Event)) {
+			defer func() {
+				if r := recover(); r != nil {
+					// Listener panicked, ignore
+				}
+			}()
+			f(evt)
+		}(fn)
+	}
 }
\ No newline at end of file
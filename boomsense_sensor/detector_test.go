@@ -0,0 +1,136 @@
+package boomsense_sensor
+
+import (
+	"testing"
+	"time"
+)
+
+func TestConfigScaleGyro(t *testing.T) {
+	cfg := DefaultConfig()
+	scaled := cfg.scaleGyro(1.5)
+
+	gyro := []struct {
+		name      string
+		got, base float64
+	}{
+		{"CrashGyDPS", scaled.CrashGyDPS, cfg.CrashGyDPS},
+		{"NormalGyMin", scaled.NormalGyMin, cfg.NormalGyMin},
+		{"TackGyMin", scaled.TackGyMin, cfg.TackGyMin},
+		{"TackGyMax", scaled.TackGyMax, cfg.TackGyMax},
+		{"BroachYawDPS", scaled.BroachYawDPS, cfg.BroachYawDPS},
+	}
+	for _, g := range gyro {
+		if g.got != g.base*1.5 {
+			t.Errorf("%s = %v, want %v", g.name, g.got, g.base*1.5)
+		}
+	}
+	if scaled.RollHit != cfg.RollHit || scaled.BroachRoll != cfg.BroachRoll {
+		t.Errorf("scaleGyro changed non-gyro thresholds: %+v", scaled)
+	}
+	if DefaultConfig().CrashGyDPS != cfg.CrashGyDPS {
+		t.Error("scaleGyro modified its receiver")
+	}
+}
+
+func TestDetectorGyroScaleKeepsBase(t *testing.T) {
+	cfg := DefaultConfig()
+	ed := NewEventDetector(cfg)
+
+	ed.SetGyroScale(1.5)
+	if got := ed.GyroScale(); got != 1.5 {
+		t.Errorf("GyroScale = %v, want 1.5", got)
+	}
+	if got := ed.Config().CrashGyDPS; got != cfg.CrashGyDPS {
+		t.Errorf("Config().CrashGyDPS = %v after scaling, want base %v", got, cfg.CrashGyDPS)
+	}
+
+	// New base thresholds are scaled by the factor already set
+	base := ed.Config()
+	base.CrashGyDPS = 100
+	ed.UpdateConfig(base)
+	if got := ed.Config().CrashGyDPS; got != 100 {
+		t.Errorf("Config().CrashGyDPS = %v after UpdateConfig, want 100", got)
+	}
+	if got := ed.config.CrashGyDPS; got != 150 {
+		t.Errorf("effective CrashGyDPS = %v, want 150", got)
+	}
+
+	// Scaling again starts from the base, not the scaled values
+	ed.SetGyroScale(2)
+	if got := ed.config.CrashGyDPS; got != 200 {
+		t.Errorf("effective CrashGyDPS = %v after rescaling, want 200", got)
+	}
+
+	ed.SetGyroScale(0)
+	if got := ed.GyroScale(); got != 2 {
+		t.Errorf("GyroScale = %v after SetGyroScale(0), want 2", got)
+	}
+}
+
+func TestRefractoryBroachBoomHitBroach(t *testing.T) {
+	ed := NewEventDetector(DefaultConfig())
+	at := func(s float64) time.Time { return time.Unix(1000, 0).Add(time.Duration(s * float64(time.Second))) }
+	tNow := func(s float64) float64 { return float64(at(s).UnixNano()) / 1e9 }
+
+	ed.publish(Event{Type: EventBroach, Timestamp: at(0)})
+	if ed.inRefractory(EventBoomHit, tNow(1.5)) {
+		t.Fatal("boom_hit held back past its 1s window")
+	}
+	ed.publish(Event{Type: EventBoomHit, Timestamp: at(1.5)})
+
+	// The boom hit is not severe, but the broach's own window still holds
+	if !ed.inRefractory(EventBroach, tNow(2)) {
+		t.Error("broach re-emitted 2s after a broach, through a boom_hit")
+	}
+	if ed.inRefractory(EventBroach, tNow(3.5)) {
+		t.Error("broach held back after its own window and bypassing boom_hit's")
+	}
+}
+
+func TestRefractoryBypassSevere(t *testing.T) {
+	ed := NewEventDetector(DefaultConfig())
+	ed.publish(Event{Type: EventTack, Timestamp: time.Unix(10, 0)})
+
+	if !ed.inRefractory(EventTack, 11) {
+		t.Error("tack emitted inside the tack window")
+	}
+	if ed.inRefractory(EventGybeCrash, 10.5) {
+		t.Error("crash gybe held back by a tack with RefractoryBypassSevere")
+	}
+
+	ed.publish(Event{Type: EventBroach, Timestamp: time.Unix(20, 0)})
+	if !ed.inRefractory(EventGybeCrash, 20.5) {
+		t.Error("crash gybe bypassed the window of a broach")
+	}
+}
+
+func TestEventHistoryKeepsQuality(t *testing.T) {
+	s := NewSensor(DefaultConfig())
+	evt := Event{Type: EventBroach, Timestamp: time.Now(), GyroPeak: 40, RollDelta: 30, Duration: 2}
+
+	s.detector.mu.Lock()
+	s.detector.publish(evt)
+	s.detector.mu.Unlock()
+
+	var recent []Event
+	for deadline := time.Now().Add(time.Second); len(recent) == 0 && time.Now().Before(deadline); {
+		time.Sleep(time.Millisecond)
+		recent = s.GetRecentEvents(1)
+	}
+	if len(recent) != 1 || recent[0].Quality == nil {
+		t.Fatalf("history = %+v, want one event with quality", recent)
+	}
+	detected := *recent[0].Quality
+	if want := s.EvaluateEvent(evt); detected != want {
+		t.Errorf("stored quality = %v, want %v", detected, want)
+	}
+
+	// Later feedback moves the posterior but not the stored quality
+	s.bayesian.Update(ExtractFeatures(evt), 0, 10)
+	if s.EvaluateEvent(evt) == detected {
+		t.Fatal("feedback did not change the posterior")
+	}
+	if got := *s.GetRecentEvents(1)[0].Quality; got != detected {
+		t.Errorf("stored quality = %v after feedback, want %v", got, detected)
+	}
+}
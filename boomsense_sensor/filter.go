@@ -1,104 +1,218 @@
-package boomsense_sensor
-
-import (
-	"math"
-	"sync"
-)
-
-// ComplementaryFilter implements Euler angle estimation from IMU
-type ComplementaryFilter struct {
-	tau          float64
-	initialized  bool
-	roll         float64
-	pitch        float64
-	lastTime     float64
-	mu           sync.RWMutex
-}
-
-func NewComplementaryFilter(tau float64) *ComplementaryFilter {
-	return &ComplementaryFilter{
-		tau: tau,
-	}
-}
-
-// Update processes new IMU reading and returns filtered roll and pitch
-func (cf *ComplementaryFilter) Update(reading IMUReading) (roll, pitch float64) {
-	cf.mu.Lock()
-	defer cf.mu.Unlock()
-
-	ts := float64(reading.Timestamp.UnixNano()) / 1e9
-
-	// Remap coordinates to stern-view frame:
-	// Desired frame: +X=starboard, +Y=up, +Z=forward (bow)
-	// Python mapping: ax, ay, az = ay, -az, ax
-	//                 gx, gy, gz = gy, -gz, gx
-	ax := reading.AccelY
-	ay := -reading.AccelZ
-	az := reading.AccelX
-	gx := reading.GyroY
-	gy := -reading.GyroZ
-
-	if !cf.initialized {
-		// Initialize from accelerometer
-		rollAcc, pitchAcc := cf.accTiltDeg(ax, ay, az)
-		cf.roll = rollAcc
-		cf.pitch = pitchAcc
-		cf.lastTime = ts
-		cf.initialized = true
-		return cf.roll, cf.pitch
-	}
-
-	// Calculate time delta
-	dt := ts - cf.lastTime
-	if dt > 0.2 {
-		dt = 0.2 // Cap large gaps
-	}
-	cf.lastTime = ts
-
-	// Integrate gyroscope (prediction step)
-	rollGyro := cf.roll + gx*dt
-	pitchGyro := cf.pitch + gy*dt
-
-	// Get accelerometer angles (measurement step)
-	rollAcc, pitchAcc := cf.accTiltDeg(ax, ay, az)
-
-	// Complementary filter fusion
-	tau := math.Max(1e-3, cf.tau)
-	alpha := tau / (tau + dt)
-	if dt <= 0 {
-		alpha = 1.0
-	}
-
-	cf.roll = alpha*rollGyro + (1.0-alpha)*rollAcc
-	cf.pitch = alpha*pitchGyro + (1.0-alpha)*pitchAcc
-
-	return cf.roll, cf.pitch
-}
-
-// accTiltDeg calculates roll and pitch from accelerometer in stern-view frame
-// Stern-view frame: +X=starboard, +Y=up, +Z=forward (bow)
-// Gravity at rest ≈ (0, -1g, 0)
-// Roll (about +X, starboard axis): φ = atan2(az, -ay)
-// Pitch (about +Y, up axis): θ = atan2(-ax, sqrt(ay² + az²))
-func (cf *ComplementaryFilter) accTiltDeg(ax, ay, az float64) (roll, pitch float64) {
-	roll = math.Atan2(az, -ay) * 180.0 / math.Pi
-	pitch = math.Atan2(-ax, math.Sqrt(ay*ay+az*az)) * 180.0 / math.Pi
-	return
-}
-
-// GetState returns current filtered angles (thread-safe)
-func (cf *ComplementaryFilter) GetState() (roll, pitch float64, initialized bool) {
-	cf.mu.RLock()
-	defer cf.mu.RUnlock()
-	return cf.roll, cf.pitch, cf.initialized
-}
-
-// Reset clears the filter state
-func (cf *ComplementaryFilter) Reset() {
-	cf.mu.Lock()
-	defer cf.mu.Unlock()
-	cf.initialized = false
-	cf.roll = 0.0
-	cf.pitch = 0.0
-	cf.lastTime = 0.0
+package boomsense_sensor
+
+import (
+	"math"
+	"sync"
+)
+
+// AttitudeFilter estimates roll and pitch (degrees, stern-view frame) from
+// IMU readings
+type AttitudeFilter interface {
+	Update(reading IMUReading) (roll, pitch float64)
+	GetState() (roll, pitch float64, initialized bool)
+	Reset()
+}
+
+// NewAttitudeFilter returns the filter selected by config.FilterType,
+// defaulting to the complementary filter
+func NewAttitudeFilter(config Config) AttitudeFilter {
+	switch config.FilterType {
+	case "madgwick":
+		return NewMadgwickFilter(config.MadgwickBeta)
+	case "mahony":
+		return NewMahonyFilter(config.MahonyKp, config.MahonyKi)
+	default:
+		cf := NewComplementaryFilter(config.EulerTau)
+		cf.SetAxisTau(config.RollTau(), config.PitchTau())
+		if config.GyroBiasTracking {
+			cf.EnableBiasTracking(config.BiasAccelTolerance, config.BiasGyroTolerance)
+		}
+		return cf
+	}
+}
+
+// Gyro bias Kalman tuning: random-walk drift of the bias and noise of a
+// single static gyro sample, both in (deg/s)^2
+const (
+	biasProcessNoise     = 1e-5
+	biasMeasurementNoise = 0.25
+	biasInitialVariance  = 1.0
+)
+
+// ComplementaryFilter implements Euler angle estimation from IMU
+type ComplementaryFilter struct {
+	tauRoll     float64
+	tauPitch    float64
+	initialized bool
+	roll        float64
+	pitch       float64
+	lastTime    float64
+	mu          sync.RWMutex
+
+	// Optional gyro bias tracking (stern-view roll/pitch rate axes)
+	biasTracking bool
+	accelTol     float64    // max |accel| deviation from 1g to count as static
+	gyroTol      float64    // max bias-corrected rate (deg/s) to count as static
+	bias         [2]float64 // deg/s
+	biasVar      [2]float64 // Kalman variance of each bias estimate
+}
+
+func NewComplementaryFilter(tau float64) *ComplementaryFilter {
+	return &ComplementaryFilter{
+		tauRoll:  tau,
+		tauPitch: tau,
+	}
+}
+
+// SetAxisTau sets separate time constants (seconds) for roll and pitch; a
+// larger tau trusts the gyro longer before the accelerometer pulls it back
+func (cf *ComplementaryFilter) SetAxisTau(roll, pitch float64) {
+	cf.mu.Lock()
+	defer cf.mu.Unlock()
+	cf.tauRoll = roll
+	cf.tauPitch = pitch
+}
+
+// EnableBiasTracking turns on per-axis gyro bias estimation. The bias is
+// updated by a scalar Kalman filter whenever the IMU looks static (accel
+// magnitude within accelTol g of 1g and corrected rates below gyroTol
+// deg/s) and is subtracted from the rates before integration.
+func (cf *ComplementaryFilter) EnableBiasTracking(accelTol, gyroTol float64) {
+	cf.mu.Lock()
+	defer cf.mu.Unlock()
+	cf.biasTracking = true
+	cf.accelTol = accelTol
+	cf.gyroTol = gyroTol
+	cf.biasVar = [2]float64{biasInitialVariance, biasInitialVariance}
+}
+
+// Update processes new IMU reading and returns filtered roll and pitch
+func (cf *ComplementaryFilter) Update(reading IMUReading) (roll, pitch float64) {
+	cf.mu.Lock()
+	defer cf.mu.Unlock()
+
+	ts := float64(reading.Timestamp.UnixNano()) / 1e9
+
+	// Remap coordinates to stern-view frame:
+	// Desired frame: +X=starboard, +Y=up, +Z=forward (bow)
+	// Python mapping: ax, ay, az = ay, -az, ax
+	//                 gx, gy, gz = gy, -gz, gx
+	ax := reading.AccelY
+	ay := -reading.AccelZ
+	az := reading.AccelX
+	gx := reading.GyroY
+	gy := -reading.GyroZ
+
+	if !cf.initialized {
+		// Initialize from accelerometer
+		rollAcc, pitchAcc := cf.accTiltDeg(ax, ay, az)
+		cf.roll = rollAcc
+		cf.pitch = pitchAcc
+		cf.lastTime = ts
+		cf.initialized = true
+		return cf.roll, cf.pitch
+	}
+
+	// Calculate time delta
+	dt := ts - cf.lastTime
+	if dt > 0.2 {
+		dt = 0.2 // Cap large gaps
+	}
+	cf.lastTime = ts
+
+	if cf.biasTracking && dt > 0 {
+		gx, gy = cf.correctBias(ax, ay, az, gx, gy, dt)
+	}
+
+	// Integrate gyroscope (prediction step)
+	rollGyro := cf.roll + gx*dt
+	pitchGyro := cf.pitch + gy*dt
+
+	// Get accelerometer angles (measurement step)
+	rollAcc, pitchAcc := cf.accTiltDeg(ax, ay, az)
+
+	// Complementary filter fusion
+	alphaRoll := complementaryAlpha(cf.tauRoll, dt)
+	alphaPitch := complementaryAlpha(cf.tauPitch, dt)
+
+	cf.roll = alphaRoll*rollGyro + (1.0-alphaRoll)*rollAcc
+	cf.pitch = alphaPitch*pitchGyro + (1.0-alphaPitch)*pitchAcc
+
+	return cf.roll, cf.pitch
+}
+
+// complementaryAlpha is the gyro weight for time constant tau over dt
+func complementaryAlpha(tau, dt float64) float64 {
+	if dt <= 0 {
+		return 1.0
+	}
+	tau = math.Max(1e-3, tau)
+	return tau / (tau + dt)
+}
+
+// correctBias updates the bias estimates when the IMU is static and returns
+// the bias-corrected rates
+func (cf *ComplementaryFilter) correctBias(ax, ay, az, gx, gy, dt float64) (float64, float64) {
+	rates := [2]float64{gx, gy}
+
+	accelMag := math.Sqrt(ax*ax + ay*ay + az*az)
+	static := math.Abs(accelMag-1.0) < cf.accelTol
+	for i := range rates {
+		if math.Abs(rates[i]-cf.bias[i]) >= cf.gyroTol {
+			static = false
+		}
+	}
+
+	for i := range rates {
+		// Predict: the bias wanders slowly
+		cf.biasVar[i] += biasProcessNoise * dt
+
+		// Update: at rest the raw rate is a direct measurement of the bias
+		if static {
+			k := cf.biasVar[i] / (cf.biasVar[i] + biasMeasurementNoise)
+			cf.bias[i] += k * (rates[i] - cf.bias[i])
+			cf.biasVar[i] *= 1.0 - k
+		}
+
+		rates[i] -= cf.bias[i]
+	}
+
+	return rates[0], rates[1]
+}
+
+// accTiltDeg calculates roll and pitch from accelerometer in stern-view frame
+// Stern-view frame: +X=starboard, +Y=up, +Z=forward (bow)
+// Gravity at rest ≈ (0, -1g, 0)
+// Roll (about +X, starboard axis): φ = atan2(az, -ay)
+// Pitch (about +Y, up axis): θ = atan2(-ax, sqrt(ay² + az²))
+func (cf *ComplementaryFilter) accTiltDeg(ax, ay, az float64) (roll, pitch float64) {
+	roll = math.Atan2(az, -ay) * 180.0 / math.Pi
+	pitch = math.Atan2(-ax, math.Sqrt(ay*ay+az*az)) * 180.0 / math.Pi
+	return
+}
+
+// GetState returns current filtered angles (thread-safe)
+func (cf *ComplementaryFilter) GetState() (roll, pitch float64, initialized bool) {
+	cf.mu.RLock()
+	defer cf.mu.RUnlock()
+	return cf.roll, cf.pitch, cf.initialized
+}
+
+// GetBias returns the current roll- and pitch-rate gyro bias estimates in
+// deg/s (zero unless bias tracking is enabled)
+func (cf *ComplementaryFilter) GetBias() (rollRate, pitchRate float64) {
+	cf.mu.RLock()
+	defer cf.mu.RUnlock()
+	return cf.bias[0], cf.bias[1]
+}
+
+// Reset clears the filter state. Learned gyro bias is kept since it is a
+// property of the sensor rather than of the attitude.
+func (cf *ComplementaryFilter) Reset() {
+	cf.mu.Lock()
+	defer cf.mu.Unlock()
+	cf.initialized = false
+	cf.roll = 0.0
+	cf.pitch = 0.0
+	cf.lastTime = 0.0
 }
\ No newline at end of file
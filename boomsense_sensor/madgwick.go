@@ -0,0 +1,164 @@
+package boomsense_sensor
+
+import (
+	"math"
+	"sync"
+)
+
+// MadgwickFilter implements Madgwick's gradient-descent orientation filter
+// (IMU variant, no magnetometer). The accelerometer only corrects the
+// quaternion through a bounded gradient step, so lateral acceleration during
+// tacks disturbs the attitude far less than in the complementary filter.
+type MadgwickFilter struct {
+	beta        float64
+	q0          float64
+	q1          float64
+	q2          float64
+	q3          float64
+	initialized bool
+	roll        float64
+	pitch       float64
+	lastTime    float64
+	mu          sync.RWMutex
+}
+
+func NewMadgwickFilter(beta float64) *MadgwickFilter {
+	return &MadgwickFilter{
+		beta: beta,
+		q0:   1.0,
+	}
+}
+
+// Update processes new IMU reading and returns filtered roll and pitch
+func (mf *MadgwickFilter) Update(reading IMUReading) (roll, pitch float64) {
+	mf.mu.Lock()
+	defer mf.mu.Unlock()
+
+	ts := float64(reading.Timestamp.UnixNano()) / 1e9
+
+	// Fusion runs in the raw sensor frame; the stern-view remap is applied
+	// when angles are extracted so they match the complementary filter.
+	// Gyro rates are negated: the ComplementaryFilter integrates them with
+	// the opposite handedness to the accelerometer, and both filters must
+	// agree on the sign of roll/pitch rates.
+	ax, ay, az := reading.AccelX, reading.AccelY, reading.AccelZ
+	gx := -reading.GyroX * math.Pi / 180.0
+	gy := -reading.GyroY * math.Pi / 180.0
+	gz := -reading.GyroZ * math.Pi / 180.0
+
+	if !mf.initialized {
+		mf.initFromAccel(ax, ay, az)
+		mf.lastTime = ts
+		mf.initialized = true
+		mf.roll, mf.pitch = mf.eulerDeg()
+		return mf.roll, mf.pitch
+	}
+
+	// Calculate time delta
+	dt := ts - mf.lastTime
+	if dt > 0.2 {
+		dt = 0.2 // Cap large gaps
+	}
+	mf.lastTime = ts
+	if dt <= 0 {
+		return mf.roll, mf.pitch
+	}
+
+	q0, q1, q2, q3 := mf.q0, mf.q1, mf.q2, mf.q3
+
+	// Rate of change of quaternion from gyroscope
+	qDot0 := 0.5 * (-q1*gx - q2*gy - q3*gz)
+	qDot1 := 0.5 * (q0*gx + q2*gz - q3*gy)
+	qDot2 := 0.5 * (q0*gy - q1*gz + q3*gx)
+	qDot3 := 0.5 * (q0*gz + q1*gy - q2*gx)
+
+	// Gradient-descent corrective step (skipped when accel is invalid)
+	if norm := math.Sqrt(ax*ax + ay*ay + az*az); norm > 0 {
+		ax /= norm
+		ay /= norm
+		az /= norm
+
+		s0 := 4*q0*q2*q2 + 2*q2*ax + 4*q0*q1*q1 - 2*q1*ay
+		s1 := 4*q1*q3*q3 - 2*q3*ax + 4*q0*q0*q1 - 2*q0*ay - 4*q1 + 8*q1*q1*q1 + 8*q1*q2*q2 + 4*q1*az
+		s2 := 4*q0*q0*q2 + 2*q0*ax + 4*q2*q3*q3 - 2*q3*ay - 4*q2 + 8*q2*q1*q1 + 8*q2*q2*q2 + 4*q2*az
+		s3 := 4*q1*q1*q3 - 2*q1*ax + 4*q2*q2*q3 - 2*q2*ay
+
+		if sNorm := math.Sqrt(s0*s0 + s1*s1 + s2*s2 + s3*s3); sNorm > 0 {
+			qDot0 -= mf.beta * s0 / sNorm
+			qDot1 -= mf.beta * s1 / sNorm
+			qDot2 -= mf.beta * s2 / sNorm
+			qDot3 -= mf.beta * s3 / sNorm
+		}
+	}
+
+	// Integrate and normalise
+	q0 += qDot0 * dt
+	q1 += qDot1 * dt
+	q2 += qDot2 * dt
+	q3 += qDot3 * dt
+	qNorm := math.Sqrt(q0*q0 + q1*q1 + q2*q2 + q3*q3)
+	mf.q0, mf.q1, mf.q2, mf.q3 = q0/qNorm, q1/qNorm, q2/qNorm, q3/qNorm
+
+	mf.roll, mf.pitch = mf.eulerDeg()
+	return mf.roll, mf.pitch
+}
+
+// initFromAccel seeds the quaternion so its gravity estimate matches the
+// first accelerometer reading (yaw = 0)
+func (mf *MadgwickFilter) initFromAccel(ax, ay, az float64) {
+	mf.q0, mf.q1, mf.q2, mf.q3 = quaternionFromAccel(ax, ay, az)
+}
+
+// eulerDeg converts the quaternion into stern-view roll and pitch
+func (mf *MadgwickFilter) eulerDeg() (roll, pitch float64) {
+	return quaternionTiltDeg(mf.q0, mf.q1, mf.q2, mf.q3)
+}
+
+// quaternionFromAccel returns the orientation, with zero yaw, whose gravity
+// estimate matches an accelerometer reading
+func quaternionFromAccel(ax, ay, az float64) (q0, q1, q2, q3 float64) {
+	phi := math.Atan2(ay, az)
+	theta := math.Atan2(-ax, math.Sqrt(ay*ay+az*az))
+
+	cp, sp := math.Cos(phi/2), math.Sin(phi/2)
+	ct, st := math.Cos(theta/2), math.Sin(theta/2)
+
+	return cp * ct, sp * ct, cp * st, -sp * st
+}
+
+// quaternionTiltDeg converts a quaternion's gravity estimate into roll and
+// pitch using the same stern-view remap and tilt formulas as
+// ComplementaryFilter
+func quaternionTiltDeg(q0, q1, q2, q3 float64) (roll, pitch float64) {
+	// Expected (normalised) accelerometer reading in the sensor frame
+	vx := 2 * (q1*q3 - q0*q2)
+	vy := 2 * (q0*q1 + q2*q3)
+	vz := q0*q0 - q1*q1 - q2*q2 + q3*q3
+
+	// Stern-view frame: ax, ay, az = ay, -az, ax
+	ax := vy
+	ay := -vz
+	az := vx
+
+	roll = math.Atan2(az, -ay) * 180.0 / math.Pi
+	pitch = math.Atan2(-ax, math.Sqrt(ay*ay+az*az)) * 180.0 / math.Pi
+	return
+}
+
+// GetState returns current filtered angles (thread-safe)
+func (mf *MadgwickFilter) GetState() (roll, pitch float64, initialized bool) {
+	mf.mu.RLock()
+	defer mf.mu.RUnlock()
+	return mf.roll, mf.pitch, mf.initialized
+}
+
+// Reset clears the filter state
+func (mf *MadgwickFilter) Reset() {
+	mf.mu.Lock()
+	defer mf.mu.Unlock()
+	mf.initialized = false
+	mf.q0, mf.q1, mf.q2, mf.q3 = 1.0, 0.0, 0.0, 0.0
+	mf.roll = 0.0
+	mf.pitch = 0.0
+	mf.lastTime = 0.0
+}
@@ -0,0 +1,211 @@
+package boomsense_sensor
+
+import (
+	"math"
+	"sync"
+)
+
+// MahonyFilter implements Mahony's nonlinear complementary orientation
+// filter. The accelerometer corrects roll and pitch as in MadgwickFilter;
+// when readings carry magnetometer data it also corrects yaw against
+// magnetic north, giving an absolute heading. Without a magnetometer yaw is
+// integrated gyro only and Heading reports nothing.
+type MahonyFilter struct {
+	kp          float64 // proportional gain
+	ki          float64 // integral gain, tracks gyro bias
+	q0          float64
+	q1          float64
+	q2          float64
+	q3          float64
+	ix          float64 // integral error terms (rad/s)
+	iy          float64
+	iz          float64
+	initialized bool
+	roll        float64
+	pitch       float64
+	heading     float64
+	hasHeading  bool
+	lastTime    float64
+	mu          sync.RWMutex
+}
+
+func NewMahonyFilter(kp, ki float64) *MahonyFilter {
+	return &MahonyFilter{
+		kp: kp,
+		ki: ki,
+		q0: 1.0,
+	}
+}
+
+// hasMag reports whether a reading carries magnetometer data
+func hasMag(reading IMUReading) bool {
+	return reading.MagX != 0 || reading.MagY != 0 || reading.MagZ != 0
+}
+
+// Update processes new IMU reading and returns filtered roll and pitch
+func (mf *MahonyFilter) Update(reading IMUReading) (roll, pitch float64) {
+	mf.mu.Lock()
+	defer mf.mu.Unlock()
+
+	ts := float64(reading.Timestamp.UnixNano()) / 1e9
+
+	// Same sensor frame and gyro handedness as MadgwickFilter
+	ax, ay, az := reading.AccelX, reading.AccelY, reading.AccelZ
+	mx, my, mz := reading.MagX, reading.MagY, reading.MagZ
+	gx := -reading.GyroX * math.Pi / 180.0
+	gy := -reading.GyroY * math.Pi / 180.0
+	gz := -reading.GyroZ * math.Pi / 180.0
+	mag := hasMag(reading)
+
+	if !mf.initialized {
+		mf.q0, mf.q1, mf.q2, mf.q3 = quaternionFromAccel(ax, ay, az)
+		if mag {
+			mf.alignYaw(mx, my, mz)
+		}
+		mf.lastTime = ts
+		mf.initialized = true
+		mf.updateAngles(mag)
+		return mf.roll, mf.pitch
+	}
+
+	// Calculate time delta
+	dt := ts - mf.lastTime
+	if dt > 0.2 {
+		dt = 0.2 // Cap large gaps
+	}
+	mf.lastTime = ts
+	if dt <= 0 {
+		return mf.roll, mf.pitch
+	}
+
+	q0, q1, q2, q3 := mf.q0, mf.q1, mf.q2, mf.q3
+
+	// Error between measured and estimated directions of gravity (and of
+	// the magnetic field), as a rotation rate
+	var ex, ey, ez float64
+	if norm := math.Sqrt(ax*ax + ay*ay + az*az); norm > 0 {
+		ax /= norm
+		ay /= norm
+		az /= norm
+
+		// Estimated gravity direction
+		vx := 2 * (q1*q3 - q0*q2)
+		vy := 2 * (q0*q1 + q2*q3)
+		vz := q0*q0 - q1*q1 - q2*q2 + q3*q3
+
+		ex = ay*vz - az*vy
+		ey = az*vx - ax*vz
+		ez = ax*vy - ay*vx
+
+		if norm := math.Sqrt(mx*mx + my*my + mz*mz); mag && norm > 0 {
+			mx /= norm
+			my /= norm
+			mz /= norm
+
+			// Field in the earth frame, flattened onto north and down
+			hx := 2 * (mx*(0.5-q2*q2-q3*q3) + my*(q1*q2-q0*q3) + mz*(q1*q3+q0*q2))
+			hy := 2 * (mx*(q1*q2+q0*q3) + my*(0.5-q1*q1-q3*q3) + mz*(q2*q3-q0*q1))
+			bx := math.Sqrt(hx*hx + hy*hy)
+			bz := 2 * (mx*(q1*q3-q0*q2) + my*(q2*q3+q0*q1) + mz*(0.5-q1*q1-q2*q2))
+
+			// Estimated field direction in the sensor frame
+			wx := bx*(0.5-q2*q2-q3*q3) + bz*(q1*q3-q0*q2)
+			wy := bx*(q1*q2-q0*q3) + bz*(q0*q1+q2*q3)
+			wz := bx*(q0*q2+q1*q3) + bz*(0.5-q1*q1-q2*q2)
+
+			ex += my*(2*wz) - mz*(2*wy)
+			ey += mz*(2*wx) - mx*(2*wz)
+			ez += mx*(2*wy) - my*(2*wx)
+		}
+
+		if mf.ki > 0 {
+			mf.ix += mf.ki * ex * dt
+			mf.iy += mf.ki * ey * dt
+			mf.iz += mf.ki * ez * dt
+		}
+		gx += mf.kp*ex + mf.ix
+		gy += mf.kp*ey + mf.iy
+		gz += mf.kp*ez + mf.iz
+	}
+
+	// Integrate rate of change of quaternion and normalise
+	qDot0 := 0.5 * (-q1*gx - q2*gy - q3*gz)
+	qDot1 := 0.5 * (q0*gx + q2*gz - q3*gy)
+	qDot2 := 0.5 * (q0*gy - q1*gz + q3*gx)
+	qDot3 := 0.5 * (q0*gz + q1*gy - q2*gx)
+
+	q0 += qDot0 * dt
+	q1 += qDot1 * dt
+	q2 += qDot2 * dt
+	q3 += qDot3 * dt
+	qNorm := math.Sqrt(q0*q0 + q1*q1 + q2*q2 + q3*q3)
+	mf.q0, mf.q1, mf.q2, mf.q3 = q0/qNorm, q1/qNorm, q2/qNorm, q3/qNorm
+
+	mf.updateAngles(mag)
+	return mf.roll, mf.pitch
+}
+
+// alignYaw turns the accelerometer-seeded quaternion about the vertical so
+// its north matches the magnetometer, so the first heading is right
+// instead of converging over several seconds
+func (mf *MahonyFilter) alignYaw(mx, my, mz float64) {
+	q0, q1, q2, q3 := mf.q0, mf.q1, mf.q2, mf.q3
+	hx := mx*(1-2*(q2*q2+q3*q3)) + my*2*(q1*q2-q0*q3) + mz*2*(q1*q3+q0*q2)
+	hy := mx*2*(q1*q2+q0*q3) + my*(1-2*(q1*q1+q3*q3)) + mz*2*(q2*q3-q0*q1)
+
+	// Rotate by -yaw about the earth vertical: q = (cos, 0, 0, -sin) ⊗ q
+	half := math.Atan2(hy, hx) / 2
+	c, s := math.Cos(half), math.Sin(half)
+	mf.q0 = c*q0 + s*q3
+	mf.q1 = c*q1 + s*q2
+	mf.q2 = c*q2 - s*q1
+	mf.q3 = c*q3 - s*q0
+}
+
+// updateAngles refreshes roll, pitch and, with a magnetometer, heading
+func (mf *MahonyFilter) updateAngles(mag bool) {
+	q0, q1, q2, q3 := mf.q0, mf.q1, mf.q2, mf.q3
+	mf.roll, mf.pitch = quaternionTiltDeg(q0, q1, q2, q3)
+
+	mf.hasHeading = mag
+	if !mag {
+		return
+	}
+
+	// Sensor Y, the stern-view fore-aft axis, in the earth frame (x magnetic
+	// north, z up, so y is west); heading is clockwise from north
+	north := 2 * (q1*q2 - q0*q3)
+	west := 1 - 2*(q1*q1+q3*q3)
+	heading := math.Atan2(-west, north) * 180.0 / math.Pi
+	mf.heading = math.Mod(heading+360.0, 360.0)
+}
+
+// Heading returns the magnetic heading of the sensor's fore-aft axis in
+// degrees (0-360); ok is false until a reading with magnetometer data has
+// been fused
+func (mf *MahonyFilter) Heading() (float64, bool) {
+	mf.mu.RLock()
+	defer mf.mu.RUnlock()
+	return mf.heading, mf.initialized && mf.hasHeading
+}
+
+// GetState returns current filtered angles (thread-safe)
+func (mf *MahonyFilter) GetState() (roll, pitch float64, initialized bool) {
+	mf.mu.RLock()
+	defer mf.mu.RUnlock()
+	return mf.roll, mf.pitch, mf.initialized
+}
+
+// Reset clears the filter state
+func (mf *MahonyFilter) Reset() {
+	mf.mu.Lock()
+	defer mf.mu.Unlock()
+	mf.initialized = false
+	mf.q0, mf.q1, mf.q2, mf.q3 = 1.0, 0.0, 0.0, 0.0
+	mf.ix, mf.iy, mf.iz = 0.0, 0.0, 0.0
+	mf.roll = 0.0
+	mf.pitch = 0.0
+	mf.heading = 0.0
+	mf.hasHeading = false
+	mf.lastTime = 0.0
+}
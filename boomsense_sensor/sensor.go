@@ -1,337 +1,607 @@
-package boomsense_sensor
-
-import (
-	"encoding/csv"
-	"fmt"
-	"log"
-	"math"
-	"os"
-	"sync"
-	"time"
-)
-
-// Sensor is the main BoomSense coordinator
-type Sensor struct {
-	config     Config
-	filter     *ComplementaryFilter
-	calibrator *BoomCalibrator
-	detector   *EventDetector
-	bayesian   *BayesianQA
-	buffers    *TelemetryBuffers
-	csvWriter  *csv.Writer
-	csvFile    *os.File
-	startTime  time.Time
-	mu         sync.RWMutex
-}
-
-// NewSensor creates a new BoomSense sensor
-func NewSensor(config Config) *Sensor {
-	s := &Sensor{
-		config:     config,
-		filter:     NewComplementaryFilter(config.EulerTau),
-		calibrator: NewBoomCalibrator(config.BoomAxis),
-		detector:   NewEventDetector(config),
-		bayesian:   NewBayesianQA(11, config.BayesSigma0), // 11 features with wind
-		buffers:    NewTelemetryBuffers(config.MaxBufferSize),
-		startTime:  time.Now(),
-	}
-
-	return s
-}
-
-// Start initializes the sensor
-func (s *Sensor) Start() error {
-	log.Printf("[BoomSense] Starting sensor...")
-	log.Printf("[BoomSense] Config: EulerTau=%.2f BoomAxis=%s", s.config.EulerTau, s.config.BoomAxis)
-
-	// Try to load existing calibration
-	if err := s.calibrator.LoadFromFile("boom_calibration.json"); err == nil {
-		cal := s.calibrator.GetCalibration()
-		if cal != nil {
-			log.Printf("[BoomSense] Loaded calibration: mid=%.2f span_pos=%.2f span_neg=%.2f", 
-				cal.Mid, cal.SpanPos, cal.SpanNeg)
-		}
-	}
-
-	// Try to load Bayesian model
-	if err := s.bayesian.LoadState("boom_bayes_posterior.json"); err == nil {
-		log.Printf("[BoomSense] Loaded Bayesian QA model")
-	}
-
-	log.Printf("[BoomSense] Sensor started successfully")
-	return nil
-}
-
-// Stop gracefully shuts down the sensor
-func (s *Sensor) Stop() {
-	log.Printf("[BoomSense] Stopping sensor...")
-
-	// Save calibration
-	if err := s.calibrator.SaveToFile("boom_calibration.json"); err == nil {
-		log.Printf("[BoomSense] Saved calibration")
-	}
-
-	// Save Bayesian model
-	if err := s.bayesian.SaveState("boom_bayes_posterior.json"); err == nil {
-		log.Printf("[BoomSense] Saved Bayesian QA model")
-	}
-
-	// Close CSV
-	if s.csvWriter != nil {
-		s.csvWriter.Flush()
-		s.csvFile.Close()
-	}
-
-	log.Printf("[BoomSense] Sensor stopped")
-}
-
-// EnableCSVLogging starts CSV output
-func (s *Sensor) EnableCSVLogging(path string) error {
-	file, err := os.OpenFile(path, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0644)
-	if err != nil {
-		return err
-	}
-
-	s.csvFile = file
-	s.csvWriter = csv.NewWriter(file)
-
-	// Write header if file is new
-	info, _ := file.Stat()
-	if info.Size() == 0 {
-		header := []string{
-			"iso8601", "ts", "ax_g", "ay_g", "az_g",
-			"gx_dps", "gy_dps", "gz_dps",
-			"roll_f_deg", "pitch_f_deg",
-			"boom_rel_deg", "boom_norm",
-			"temp_c", "press_hpa", "rh_pct",
-			"wind_speed_kn", "wind_angle_deg",
-		}
-		s.csvWriter.Write(header)
-		s.csvWriter.Flush()
-	}
-
-	return nil
-}
-
-// ProcessIMU processes an IMU reading
-func (s *Sensor) ProcessIMU(reading IMUReading) FilteredData {
-	// Apply complementary filter
-	roll, pitch := s.filter.Update(reading)
-
-	// Get axis value based on config
-	axisValue := roll
-	if s.config.BoomAxis == "pitch" {
-		axisValue = pitch
-	}
-
-	// Compute boom metrics
-	boomRelDeg, boomNorm, hasCal := s.calibrator.ComputeBoom(axisValue)
-
-	// Build filtered data
-	filtered := FilteredData{
-		Timestamp:  reading.Timestamp,
-		RollDeg:    roll,
-		PitchDeg:   pitch,
-		BoomRelDeg: boomRelDeg,
-		BoomNorm:   boomNorm,
-		AccelX:     reading.AccelX,
-		AccelY:     reading.AccelY,
-		AccelZ:     reading.AccelZ,
-		GyroX:      reading.GyroX,
-		GyroY:      reading.GyroY,
-		GyroZ:      reading.GyroZ,
-	}
-
-	// Handle NaN for uncalibrated
-	if !hasCal {
-		filtered.BoomRelDeg = math.NaN()
-		filtered.BoomNorm = math.NaN()
-	}
-
-	// Store in buffer
-	s.buffers.PushFiltered(filtered)
-
-	// Feed to event detector
-	if hasCal && !math.IsNaN(filtered.BoomNorm) && !math.IsInf(filtered.BoomNorm, 0) {
-		s.detector.OnSample(reading.Timestamp, reading.GyroY, filtered.BoomNorm, roll)
-	}
-
-	// Write to CSV
-	s.writeCSVRow(filtered)
-
-	return filtered
-}
-
-// ProcessMeteo processes a meteo reading
-func (s *Sensor) ProcessMeteo(reading MeteoReading) {
-	s.buffers.PushMeteo(reading)
-}
-
-// ProcessWind processes a wind reading
-func (s *Sensor) ProcessWind(reading WindReading) {
-	s.buffers.PushWind(reading)
-}
-
-// writeCSVRow writes filtered data to CSV
-func (s *Sensor) writeCSVRow(data FilteredData) {
-	if s.csvWriter == nil {
-		return
-	}
-
-	// Get latest wind
-	wind, _ := s.buffers.GetLatestWind()
-
-	// Get latest meteo
-	meteo := s.buffers.GetRecentMeteo(1)
-	var tempC, pressHpa, rhPct float64
-	if len(meteo) > 0 {
-		tempC = meteo[0].TempC
-		pressHpa = meteo[0].PressureHpa
-		rhPct = meteo[0].HumidityPct
-	}
-
-	row := []string{
-		data.Timestamp.UTC().Format(time.RFC3339),
-		fmt.Sprintf("%.3f", float64(data.Timestamp.UnixNano())/1e9),
-		fmt.Sprintf("%.6f", data.AccelX),
-		fmt.Sprintf("%.6f", data.AccelY),
-		fmt.Sprintf("%.6f", data.AccelZ),
-		fmt.Sprintf("%.6f", data.GyroX),
-		fmt.Sprintf("%.6f", data.GyroY),
-		fmt.Sprintf("%.6f", data.GyroZ),
-		fmt.Sprintf("%.3f", data.RollDeg),
-		fmt.Sprintf("%.3f", data.PitchDeg),
-		fmt.Sprintf("%.3f", data.BoomRelDeg),
-		fmt.Sprintf("%.3f", data.BoomNorm),
-		fmt.Sprintf("%.2f", tempC),
-		fmt.Sprintf("%.2f", pressHpa),
-		fmt.Sprintf("%.2f", rhPct),
-		fmt.Sprintf("%.2f", wind.SpeedKts),
-		fmt.Sprintf("%.2f", wind.AngleDeg),
-	}
-
-	s.csvWriter.Write(row)
-	s.csvWriter.Flush()
-}
-
-// GetCurrentState returns latest sensor state
-func (s *Sensor) GetCurrentState() map[string]interface{} {
-	filtered := s.buffers.GetRecentFiltered(1)
-	wind, _ := s.buffers.GetLatestWind()
-	cal := s.calibrator.GetCalibration()
-
-	state := map[string]interface{}{
-		"has_calibration": cal != nil,
-		"wind_speed_kts":  wind.SpeedKts,
-		"wind_angle_deg":  wind.AngleDeg,
-	}
-
-	if len(filtered) > 0 {
-		f := filtered[0]
-		state["roll_deg"] = f.RollDeg
-		state["pitch_deg"] = f.PitchDeg
-		state["boom_rel_deg"] = f.BoomRelDeg
-		state["boom_norm"] = f.BoomNorm
-		state["timestamp"] = f.Timestamp.Format(time.RFC3339)
-	}
-
-	if cal != nil {
-		state["calibration"] = map[string]interface{}{
-			"mid":       cal.Mid,
-			"span_pos":  cal.SpanPos,
-			"span_neg":  cal.SpanNeg,
-			"timestamp": cal.Timestamp.Format(time.RFC3339),
-		}
-	}
-
-	return state
-}
-
-// GetAxisValue returns current axis value (for calibration)
-func (s *Sensor) GetAxisValue() (float64, bool) {
-	roll, pitch, ok := s.filter.GetState()
-	if !ok {
-		return 0, false
-	}
-
-	if s.config.BoomAxis == "roll" {
-		return roll, true
-	}
-	return pitch, true
-}
-
-// RunCalibration performs interactive calibration
-func (s *Sensor) RunCalibration() error {
-	cal, err := s.calibrator.PerformCalibration(s.GetAxisValue)
-	if err != nil {
-		return err
-	}
-
-	// Save immediately
-	if err := s.calibrator.SaveToFile("boom_calibration.json"); err != nil {
-		log.Printf("[BoomSense] Warning: failed to save calibration: %v", err)
-	}
-
-	log.Printf("[BoomSense] Calibration complete: mid=%.2f span_pos=%.2f span_neg=%.2f",
-		cal.Mid, cal.SpanPos, cal.SpanNeg)
-
-	return nil
-}
-
-// AddEventListener registers an event callback
-func (s *Sensor) AddEventListener(fn func(Event)) {
-	// Wrap to add wind data enrichment
-	enriched := func(evt Event) {
-		// Enrich with latest wind
-		if wind, ok := s.buffers.GetLatestWind(); ok {
-			evt.WindSpeed = wind.SpeedKts
-			evt.WindAngle = wind.AngleDeg
-		}
-
-		// Pass to original listener
-		fn(evt)
-	}
-
-	s.detector.AddListener(enriched)
-}
-
-// ProcessEventFeedback performs Bayesian QA update
-func (s *Sensor) ProcessEventFeedback(evt Event, isCorrect bool) {
-	features := ExtractFeatures(evt)
-	y := 0.0
-	if isCorrect {
-		y = 1.0
-	}
-	s.bayesian.Update(features, y, 1)
-
-	// Save model after feedback
-	if err := s.bayesian.SaveState("boom_bayes_posterior.json"); err != nil {
-		log.Printf("[BoomSense] Warning: failed to save Bayesian model: %v", err)
-	}
-}
-
-// EvaluateEvent returns quality probability for an event
-func (s *Sensor) EvaluateEvent(evt Event) float64 {
-	features := ExtractFeatures(evt)
-	return s.bayesian.PredictProba(features)
-}
-
-// GetBuffers returns telemetry buffers (for visualization)
-func (s *Sensor) GetBuffers() *TelemetryBuffers {
-	return s.buffers
-}
-
-// GetStats returns sensor statistics
-func (s *Sensor) GetStats() map[string]interface{} {
-	roll, pitch, initialized := s.filter.GetState()
-	cal := s.calibrator.GetCalibration()
-
-	return map[string]interface{}{
-		"filter_initialized": initialized,
-		"current_roll_deg":   roll,
-		"current_pitch_deg":  pitch,
-		"has_calibration":    cal != nil,
-		"uptime_seconds":     time.Since(s.startTime).Seconds(),
-		"buffers":            s.buffers.Stats(),
-	}
+package boomsense_sensor
+
+import (
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"log"
+	"math"
+	"os"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// Sensor is the main BoomSense coordinator
+type Sensor struct {
+	config     Config
+	filter     AttitudeFilter
+	calibrator *BoomCalibrator
+	detector   *EventDetector
+	bayesian   *BayesianQA
+	buffers    *TelemetryBuffers
+	csvWriter  *csv.Writer
+	csvFile    *os.File
+	startTime  time.Time
+	lastOutput time.Time      // last sample passed downstream under TargetHz
+	lastSample time.Time      // last sample through the filter
+	smoother   *SavitzkyGolay // nil when BoomSmoothWindow is off
+	clipped    atomic.Int64   // samples flagged by AccelFullScaleG
+	outputs    []func(FilteredData)
+	events     *RingBuffer  // recent detected events, enriched with wind and quality
+	mu         sync.RWMutex // held by ProcessIMU, which MQTT and HTTP both call
+}
+
+// NewSensor creates a new BoomSense sensor
+func NewSensor(config Config) *Sensor {
+	s := &Sensor{
+		config:     config,
+		filter:     NewAttitudeFilter(config),
+		calibrator: NewBoomCalibrator(config.BoomAxis),
+		detector:   NewEventDetector(config),
+		bayesian:   NewBayesianQA(FeatureDim, config.BayesSigma0),
+		buffers:    NewTelemetryBuffers(config.MaxBufferSize),
+		startTime:  time.Now(),
+	}
+	if config.EventHistory > 0 {
+		s.events = NewRingBuffer(config.EventHistory)
+		s.AddEventListener(func(evt Event) { s.events.Push(evt) })
+	}
+	s.calibrator.MinQuality = config.CalibrationMinQuality
+	s.calibrator.AxisInvert = config.AxisInvert
+	s.calibrator.MountRotationDeg = config.MountRotationDeg
+	if config.BayesFullCovariance {
+		s.bayesian.EnableFullCovariance()
+	}
+	if config.BoomSmoothWindow >= 3 {
+		s.smoother = NewSavitzkyGolay(config.BoomSmoothWindow)
+	}
+
+	return s
+}
+
+// Start initializes the sensor
+func (s *Sensor) Start() error {
+	log.Printf("[BoomSense] Starting sensor...")
+	log.Printf("[BoomSense] Config: Filter=%s TauRoll=%.2f TauPitch=%.2f BoomAxis=%s",
+		s.config.FilterType, s.config.RollTau(), s.config.PitchTau(), s.config.BoomAxis)
+	if s.config.AxisInvert || s.config.MountRotationDeg != 0 {
+		log.Printf("[BoomSense] Mount correction: invert=%v rotation=%.1f deg",
+			s.config.AxisInvert, s.config.MountRotationDeg)
+	}
+
+	// Try to load existing calibration
+	if err := s.calibrator.LoadFromFile("boom_calibration.json"); err == nil {
+		cal := s.calibrator.GetCalibration()
+		if cal != nil {
+			log.Printf("[BoomSense] Loaded calibration: mid=%.2f span_pos=%.2f span_neg=%.2f", 
+				cal.Mid, cal.SpanPos, cal.SpanNeg)
+		}
+	} else {
+		log.Printf("[BoomSense] Calibration not loaded: %v", err)
+	}
+
+	// Try to load Bayesian model
+	if err := s.bayesian.LoadState("boom_bayes_posterior.json"); err == nil {
+		log.Printf("[BoomSense] Loaded Bayesian QA model")
+	} else if !os.IsNotExist(err) {
+		log.Printf("[BoomSense] Bayesian QA model not loaded, starting from prior: %v", err)
+	}
+
+	log.Printf("[BoomSense] Sensor started successfully")
+	return nil
+}
+
+// Stop gracefully shuts down the sensor
+func (s *Sensor) Stop() {
+	log.Printf("[BoomSense] Stopping sensor...")
+
+	// Save calibration
+	if err := s.calibrator.SaveToFile("boom_calibration.json"); err == nil {
+		log.Printf("[BoomSense] Saved calibration")
+	}
+
+	// Save Bayesian model
+	if err := s.bayesian.SaveState("boom_bayes_posterior.json"); err == nil {
+		log.Printf("[BoomSense] Saved Bayesian QA model")
+	}
+
+	// Close CSV, after any reading still being processed
+	s.mu.Lock()
+	if s.csvWriter != nil {
+		s.csvWriter.Flush()
+		s.csvFile.Close()
+		s.csvWriter = nil
+	}
+	s.mu.Unlock()
+
+	log.Printf("[BoomSense] Sensor stopped")
+}
+
+// EnableCSVLogging starts CSV output
+func (s *Sensor) EnableCSVLogging(path string) error {
+	file, err := os.OpenFile(path, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0644)
+	if err != nil {
+		return err
+	}
+
+	s.csvFile = file
+	s.csvWriter = csv.NewWriter(file)
+
+	// Write header if file is new
+	info, _ := file.Stat()
+	if info.Size() == 0 {
+		header := []string{
+			"iso8601", "ts", "ax_g", "ay_g", "az_g",
+			"gx_dps", "gy_dps", "gz_dps",
+			"roll_f_deg", "pitch_f_deg",
+			"boom_rel_deg", "boom_norm",
+			"temp_c", "press_hpa", "rh_pct",
+			"wind_speed_kn", "wind_angle_deg",
+			"boom_rel_deg_smooth", "clipped",
+		}
+		s.csvWriter.Write(header)
+		s.csvWriter.Flush()
+	}
+
+	return nil
+}
+
+// ErrOutOfOrder is returned by ProcessIMUInOrder for a batch that would take
+// the filter back in time
+var ErrOutOfOrder = errors.New("reading is older than the last processed sample")
+
+// ProcessIMU processes an IMU reading. Calls are serialized: the filter,
+// smoother and detector each take one sample at a time, in order.
+func (s *Sensor) ProcessIMU(reading IMUReading) FilteredData {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.processIMULocked(reading)
+}
+
+// ProcessIMUInOrder is ProcessIMU for a batch of readings injected alongside
+// the live feed. The whole batch is checked before any of it is processed:
+// if a reading is timestamped before the one before it, or the first before
+// the last sample processed, nothing is applied and ErrOutOfOrder is returned
+// rather than giving the filter a negative time step. The lock is held for
+// the batch, so live samples cannot land in the middle of it.
+func (s *Sensor) ProcessIMUInOrder(readings []IMUReading) ([]FilteredData, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	last := s.lastSample
+	for i, reading := range readings {
+		if reading.Timestamp.Before(last) {
+			return nil, fmt.Errorf("reading %d: %w", i, ErrOutOfOrder)
+		}
+		last = reading.Timestamp
+	}
+	results := make([]FilteredData, len(readings))
+	for i, reading := range readings {
+		results[i] = s.processIMULocked(reading)
+	}
+	return results, nil
+}
+
+// processIMULocked runs one reading through the pipeline; s.mu must be held
+func (s *Sensor) processIMULocked(reading IMUReading) FilteredData {
+	s.lastSample = reading.Timestamp
+
+	// Apply complementary filter
+	roll, pitch := s.filter.Update(reading)
+
+	// Get axis value based on config and mounting
+	axisValue := s.boomAxisValue(roll, pitch)
+
+	// Compute boom metrics
+	boomRelDeg, boomNorm, hasCal := s.calibrator.ComputeBoom(axisValue)
+
+	// Build filtered data
+	filtered := FilteredData{
+		Timestamp:  reading.Timestamp,
+		RollDeg:    roll,
+		PitchDeg:   pitch,
+		BoomRelDeg: boomRelDeg,
+		BoomNorm:   boomNorm,
+		AccelX:     reading.AccelX,
+		AccelY:     reading.AccelY,
+		AccelZ:     reading.AccelZ,
+		GyroX:      reading.GyroX,
+		GyroY:      reading.GyroY,
+		GyroZ:      reading.GyroZ,
+		Clipped:    s.isClipped(reading),
+	}
+
+	// Handle NaN for uncalibrated
+	if !hasCal {
+		filtered.BoomRelDeg = math.NaN()
+		filtered.BoomNorm = math.NaN()
+	}
+
+	// Count clipping on every sample, including those decimated away below
+	if filtered.Clipped {
+		s.clipped.Add(1)
+	}
+
+	// The filter has integrated this sample; downstream work is decimated.
+	// A decimated sample never reaches the smoother, so it has no smoothed
+	// angle rather than a centred one.
+	if !s.shouldOutput(reading.Timestamp) {
+		filtered.BoomRelDegSmooth = math.NaN()
+		return filtered
+	}
+
+	// Smooth the displayed/logged angle only
+	filtered.BoomRelDegSmooth = filtered.BoomRelDeg
+	if s.smoother != nil {
+		filtered.BoomRelDegSmooth = s.smoother.Update(filtered.BoomRelDeg)
+	}
+
+	// Store in buffer
+	s.buffers.PushFiltered(filtered)
+
+	// Feed to event detector; clipped samples would register as false hits
+	if !filtered.Clipped && hasCal && !math.IsNaN(filtered.BoomNorm) && !math.IsInf(filtered.BoomNorm, 0) {
+		// Yaw is about the stern-view up axis (gy = -GyroZ in the filter remap)
+		s.detector.OnSample(reading.Timestamp, reading.GyroY, filtered.BoomNorm, roll, -reading.GyroZ)
+	}
+
+	// Write to CSV
+	s.writeCSVRow(filtered)
+
+	for _, fn := range s.outputs {
+		fn(filtered)
+	}
+
+	return filtered
+}
+
+// AddOutputListener registers fn to receive every sample that passes the
+// TargetHz decimation. It runs on the IMU path, with ProcessIMU's lock
+// held, and must not block or call back into the sensor.
+func (s *Sensor) AddOutputListener(fn func(FilteredData)) {
+	s.mu.Lock()
+	s.outputs = append(s.outputs, fn)
+	s.mu.Unlock()
+}
+
+// isClipped reports whether any accelerometer axis is at the configured
+// full-scale rail
+func (s *Sensor) isClipped(reading IMUReading) bool {
+	if s.config.AccelFullScaleG <= 0 {
+		return false
+	}
+	limit := s.config.AccelFullScaleG - s.config.ClipEpsilonG
+	return math.Abs(reading.AccelX) >= limit ||
+		math.Abs(reading.AccelY) >= limit ||
+		math.Abs(reading.AccelZ) >= limit
+}
+
+// shouldOutput applies Config.TargetHz decimation by sample time, so bursty
+// delivery does not change the output rate
+func (s *Sensor) shouldOutput(t time.Time) bool {
+	if s.config.TargetHz <= 0 {
+		return true
+	}
+
+	period := time.Duration(float64(time.Second) / s.config.TargetHz)
+	// A clock step backwards (e.g. a restarted replay) restarts decimation
+	if !s.lastOutput.IsZero() && t.After(s.lastOutput) && t.Sub(s.lastOutput) < period {
+		return false
+	}
+	s.lastOutput = t
+	return true
+}
+
+// ProcessMeteo processes a meteo reading
+func (s *Sensor) ProcessMeteo(reading MeteoReading) {
+	s.buffers.PushMeteo(reading)
+}
+
+// ProcessWind processes a wind reading
+func (s *Sensor) ProcessWind(reading WindReading) {
+	s.buffers.PushWind(reading)
+}
+
+// writeCSVRow writes filtered data to CSV
+func (s *Sensor) writeCSVRow(data FilteredData) {
+	if s.csvWriter == nil {
+		return
+	}
+
+	// Get latest wind
+	wind, _ := s.buffers.GetLatestWind()
+
+	// Get latest meteo
+	meteo := s.buffers.GetRecentMeteo(1)
+	var tempC, pressHpa, rhPct float64
+	if len(meteo) > 0 {
+		tempC = meteo[0].TempC
+		pressHpa = meteo[0].PressureHpa
+		rhPct = meteo[0].HumidityPct
+	}
+
+	row := []string{
+		data.Timestamp.UTC().Format(time.RFC3339),
+		fmt.Sprintf("%.3f", float64(data.Timestamp.UnixNano())/1e9),
+		fmt.Sprintf("%.6f", data.AccelX),
+		fmt.Sprintf("%.6f", data.AccelY),
+		fmt.Sprintf("%.6f", data.AccelZ),
+		fmt.Sprintf("%.6f", data.GyroX),
+		fmt.Sprintf("%.6f", data.GyroY),
+		fmt.Sprintf("%.6f", data.GyroZ),
+		fmt.Sprintf("%.3f", data.RollDeg),
+		fmt.Sprintf("%.3f", data.PitchDeg),
+		fmt.Sprintf("%.3f", data.BoomRelDeg),
+		fmt.Sprintf("%.3f", data.BoomNorm),
+		fmt.Sprintf("%.2f", tempC),
+		fmt.Sprintf("%.2f", pressHpa),
+		fmt.Sprintf("%.2f", rhPct),
+		fmt.Sprintf("%.2f", wind.SpeedKts),
+		fmt.Sprintf("%.2f", wind.AngleDeg),
+		fmt.Sprintf("%.3f", data.BoomRelDegSmooth),
+		strconv.FormatBool(data.Clipped),
+	}
+
+	s.csvWriter.Write(row)
+	s.csvWriter.Flush()
+}
+
+// GetCurrentState returns latest sensor state
+func (s *Sensor) GetCurrentState() map[string]interface{} {
+	filtered := s.buffers.GetRecentFiltered(1)
+	wind, _ := s.buffers.GetLatestWind()
+	cal := s.calibrator.GetCalibration()
+
+	state := map[string]interface{}{
+		"has_calibration": cal != nil,
+		"wind_speed_kts":  wind.SpeedKts,
+		"wind_angle_deg":  wind.AngleDeg,
+	}
+
+	if len(filtered) > 0 {
+		f := filtered[0]
+		state["roll_deg"] = f.RollDeg
+		state["pitch_deg"] = f.PitchDeg
+		state["boom_rel_deg"] = f.BoomRelDeg
+		state["boom_rel_deg_smooth"] = f.BoomRelDegSmooth
+		state["boom_norm"] = f.BoomNorm
+		state["timestamp"] = f.Timestamp.Format(time.RFC3339)
+	}
+
+	if heading, ok := s.MagneticHeading(); ok {
+		state["magnetic_heading_deg"] = heading
+	}
+
+	if bf, ok := s.filter.(interface{ GetBias() (float64, float64) }); ok {
+		rollBias, pitchBias := bf.GetBias()
+		state["gyro_bias_dps"] = map[string]interface{}{
+			"roll":  rollBias,
+			"pitch": pitchBias,
+		}
+	}
+
+	if cal != nil {
+		calState := map[string]interface{}{
+			"mid":       cal.Mid,
+			"span_pos":  cal.SpanPos,
+			"span_neg":  cal.SpanNeg,
+			"timestamp": cal.Timestamp.Format(time.RFC3339),
+		}
+		if cal.Quality != nil {
+			calState["quality"] = *cal.Quality
+			calState["rating"] = CalibrationRating(*cal.Quality)
+		}
+		state["calibration"] = calState
+	}
+
+	return state
+}
+
+// MagneticHeading returns the magnetic heading in degrees (0-360) from a
+// Mahony filter fusing magnetometer data, corrected by
+// MagHeadingOffsetDeg. ok is false with other filters or without a
+// magnetometer.
+func (s *Sensor) MagneticHeading() (float64, bool) {
+	hf, ok := s.filter.(interface{ Heading() (float64, bool) })
+	if !ok {
+		return 0, false
+	}
+	heading, ok := hf.Heading()
+	if !ok {
+		return 0, false
+	}
+	return math.Mod(heading+s.config.MagHeadingOffsetDeg+720.0, 360.0), true
+}
+
+// GetAxisValue returns current axis value (for calibration)
+func (s *Sensor) GetAxisValue() (float64, bool) {
+	roll, pitch, ok := s.filter.GetState()
+	if !ok {
+		return 0, false
+	}
+
+	return s.boomAxisValue(roll, pitch), true
+}
+
+// boomAxisValue picks the boom axis out of the filter attitude, corrected
+// for how the sensor is mounted: MountRotationDeg turns the sensor about its
+// vertical axis, mixing roll into pitch, and AxisInvert flips the sign so
+// that starboard reads positive. Calibration captures this same value, so
+// both the stored calibration and tack direction follow the correction.
+func (s *Sensor) boomAxisValue(roll, pitch float64) float64 {
+	rot := s.config.MountRotationDeg * math.Pi / 180.0
+	value := roll*math.Cos(rot) + pitch*math.Sin(rot)
+	if s.config.BoomAxis == "pitch" {
+		value = pitch*math.Cos(rot) - roll*math.Sin(rot)
+	}
+	if s.config.AxisInvert {
+		value = -value
+	}
+	return value
+}
+
+// BoomAngle returns the latest calibrated boom angle in degrees relative to
+// the centreline, smoothed when BoomSmoothWindow is set. ok is false until a
+// calibration exists and a finite angle has been computed.
+func (s *Sensor) BoomAngle() (float64, bool) {
+	if s.calibrator.GetCalibration() == nil {
+		return 0, false
+	}
+
+	filtered := s.buffers.GetRecentFiltered(1)
+	if len(filtered) == 0 {
+		return 0, false
+	}
+
+	angle := filtered[0].BoomRelDegSmooth
+	if math.IsNaN(angle) || math.IsInf(angle, 0) {
+		return 0, false
+	}
+	return angle, true
+}
+
+// RunCalibration performs interactive calibration
+func (s *Sensor) RunCalibration() error {
+	cal, err := s.calibrator.PerformCalibration(s.GetAxisValue)
+	if err != nil {
+		return err
+	}
+
+	// Save immediately
+	if err := s.calibrator.SaveToFile("boom_calibration.json"); err != nil {
+		log.Printf("[BoomSense] Warning: failed to save calibration: %v", err)
+	}
+
+	log.Printf("[BoomSense] Calibration complete: mid=%.2f span_pos=%.2f span_neg=%.2f",
+		cal.Mid, cal.SpanPos, cal.SpanNeg)
+
+	return nil
+}
+
+// CalibrateFromPoints commits a calibration from four captured axis values
+// (center, starboard max, port max, center again) without console I/O, e.g.
+// when the points are collected through the web UI
+func (s *Sensor) CalibrateFromPoints(center0, stbMax, portMax, center1 float64) (*Calibration, error) {
+	cal, err := s.calibrator.SetPoints(center0, stbMax, portMax, center1)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := s.calibrator.SaveToFile("boom_calibration.json"); err != nil {
+		log.Printf("[BoomSense] Warning: failed to save calibration: %v", err)
+	}
+
+	log.Printf("[BoomSense] Calibration set: mid=%.2f span_pos=%.2f span_neg=%.2f quality=%.0f",
+		cal.Mid, cal.SpanPos, cal.SpanNeg, *cal.Quality)
+
+	return cal, nil
+}
+
+// AddEventListener registers an event callback. Events arrive with the
+// latest wind and their QA probability at detection, which stays with the
+// event in the history however the posterior is updated afterwards.
+func (s *Sensor) AddEventListener(fn func(Event)) {
+	// Wrap to add wind data enrichment
+	enriched := func(evt Event) {
+		// Enrich with latest wind
+		if wind, ok := s.buffers.GetLatestWind(); ok {
+			evt.WindSpeed = wind.SpeedKts
+			evt.WindAngle = wind.AngleDeg
+		}
+		quality := s.EvaluateEvent(evt)
+		evt.Quality = &quality
+
+		// Pass to original listener
+		fn(evt)
+	}
+
+	s.detector.AddListener(enriched)
+}
+
+// GetRecentEvents returns up to n of the most recently detected events,
+// oldest first (n <= 0 returns the whole history)
+func (s *Sensor) GetRecentEvents(n int) []Event {
+	if s.events == nil {
+		return nil
+	}
+	if n <= 0 {
+		n = s.events.Size()
+	}
+
+	recent := s.events.GetRecent(n)
+	events := make([]Event, len(recent))
+	for i, item := range recent {
+		events[len(recent)-1-i] = item.(Event)
+	}
+	return events
+}
+
+// DetectorConfig returns the event detector's current thresholds
+func (s *Sensor) DetectorConfig() Config {
+	return s.detector.Config()
+}
+
+// UpdateDetectorConfig validates and applies new event detection thresholds
+// while running. Only the detection fields of config are used; filter,
+// calibration and QA settings keep their startup values.
+func (s *Sensor) UpdateDetectorConfig(config Config) error {
+	if err := config.ValidateThresholds(); err != nil {
+		return err
+	}
+	s.detector.UpdateConfig(config)
+	return nil
+}
+
+// SetDetectorGyroScale scales the event detector's gyro thresholds; see
+// EventDetector.SetGyroScale
+func (s *Sensor) SetDetectorGyroScale(scale float64) {
+	s.detector.SetGyroScale(scale)
+}
+
+// DetectorGyroScale returns the scale the detector's gyro thresholds run at
+func (s *Sensor) DetectorGyroScale() float64 {
+	return s.detector.GyroScale()
+}
+
+// ProcessEventFeedback performs Bayesian QA update
+func (s *Sensor) ProcessEventFeedback(evt Event, isCorrect bool) {
+	features := ExtractFeatures(evt)
+	y := 0.0
+	if isCorrect {
+		y = 1.0
+	}
+	s.bayesian.Update(features, y, 1)
+
+	// Save model after feedback
+	if err := s.bayesian.SaveState("boom_bayes_posterior.json"); err != nil {
+		log.Printf("[BoomSense] Warning: failed to save Bayesian model: %v", err)
+	}
+}
+
+// EvaluateEvent returns quality probability for an event
+func (s *Sensor) EvaluateEvent(evt Event) float64 {
+	features := ExtractFeatures(evt)
+	return s.bayesian.PredictProba(features)
+}
+
+// GetBuffers returns telemetry buffers (for visualization)
+func (s *Sensor) GetBuffers() *TelemetryBuffers {
+	return s.buffers
+}
+
+// GetStats returns sensor statistics
+func (s *Sensor) GetStats() map[string]interface{} {
+	roll, pitch, initialized := s.filter.GetState()
+	cal := s.calibrator.GetCalibration()
+
+	return map[string]interface{}{
+		"filter_initialized": initialized,
+		"current_roll_deg":   roll,
+		"current_pitch_deg":  pitch,
+		"has_calibration":    cal != nil,
+		"uptime_seconds":     time.Since(s.startTime).Seconds(),
+		"buffers":            s.buffers.Stats(),
+		"clipped_samples":    s.clipped.Load(),
+	}
 }
\ No newline at end of file
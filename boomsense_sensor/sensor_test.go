@@ -0,0 +1,128 @@
+package boomsense_sensor
+
+import (
+	"errors"
+	"math"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestClippingCountedBeforeDecimation(t *testing.T) {
+	cfg := DefaultConfig()
+	cfg.TargetHz = 10
+	s := NewSensor(cfg)
+
+	// 100 Hz input decimated to 10 Hz; every sample is at the rail
+	t0 := time.Now()
+	outputs := 0
+	s.AddOutputListener(func(FilteredData) { outputs++ })
+	for i := 0; i < 10; i++ {
+		s.ProcessIMU(IMUReading{
+			Timestamp: t0.Add(time.Duration(i) * 10 * time.Millisecond),
+			AccelZ:    cfg.AccelFullScaleG,
+		})
+	}
+
+	if outputs != 1 {
+		t.Fatalf("%d samples passed decimation, want 1", outputs)
+	}
+	if got := s.GetStats()["clipped_samples"].(int64); got != 10 {
+		t.Errorf("clipped_samples = %d, want 10", got)
+	}
+}
+
+func TestDecimatedSampleHasNoSmoothedAngle(t *testing.T) {
+	cfg := DefaultConfig()
+	cfg.TargetHz = 10
+	s := NewSensor(cfg)
+	if _, err := s.calibrator.SetPoints(0, 40, -40, 0); err != nil {
+		t.Fatal(err)
+	}
+
+	t0 := time.Now()
+	for i := 0; i < 20; i++ {
+		filtered := s.ProcessIMU(IMUReading{
+			Timestamp: t0.Add(time.Duration(i) * 10 * time.Millisecond),
+			AccelZ:    1,
+		})
+		if math.IsNaN(filtered.BoomRelDeg) {
+			t.Fatalf("sample %d: uncalibrated boom angle", i)
+		}
+		// 100 Hz decimated to 10 Hz: samples 0 and 10 pass
+		if passed := i%10 == 0; passed == math.IsNaN(filtered.BoomRelDegSmooth) {
+			t.Errorf("sample %d: smoothed angle %v, want a value only when it passes decimation",
+				i, filtered.BoomRelDegSmooth)
+		}
+	}
+}
+
+func TestProcessIMUInOrderAppliesNothingOnConflict(t *testing.T) {
+	cfg := DefaultConfig()
+	cfg.TargetHz = 0
+	s := NewSensor(cfg)
+	outputs := 0
+	s.AddOutputListener(func(FilteredData) { outputs++ })
+	t0 := time.Now()
+	if _, err := s.ProcessIMUInOrder([]IMUReading{{Timestamp: t0.Add(time.Second), AccelZ: 1}}); err != nil {
+		t.Fatal(err)
+	}
+
+	// The first reading is fine on its own terms, the second predates the live feed
+	stale := []IMUReading{
+		{Timestamp: t0.Add(2 * time.Second), AccelZ: 1},
+		{Timestamp: t0, AccelZ: 1},
+	}
+	if _, err := s.ProcessIMUInOrder(stale); !errors.Is(err, ErrOutOfOrder) {
+		t.Fatalf("err = %v, want ErrOutOfOrder", err)
+	}
+	if outputs != 1 {
+		t.Errorf("%d samples processed after refused batch, want 1", outputs)
+	}
+
+	results, err := s.ProcessIMUInOrder([]IMUReading{
+		{Timestamp: t0.Add(1500 * time.Millisecond), AccelZ: 1},
+		{Timestamp: t0.Add(2 * time.Second), AccelZ: 1},
+	})
+	if err != nil {
+		t.Fatalf("in-order batch refused: %v", err)
+	}
+	if len(results) != 2 {
+		t.Errorf("%d results, want 2", len(results))
+	}
+}
+
+func TestCalibrationRefusedUnderOtherMount(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "boom_calibration.json")
+
+	cfg := DefaultConfig()
+	cfg.MountRotationDeg = 90
+	s := NewSensor(cfg)
+	if _, err := s.calibrator.SetPoints(0, 40, -40, 0); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.calibrator.SaveToFile(path); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name     string
+		invert   bool
+		rotation float64
+		loaded   bool
+	}{
+		{"same mount", false, 90, true},
+		{"rotated", false, 0, false},
+		{"inverted", true, 90, false},
+	}
+	for _, tt := range tests {
+		cfg := DefaultConfig()
+		cfg.AxisInvert = tt.invert
+		cfg.MountRotationDeg = tt.rotation
+		s := NewSensor(cfg)
+		err := s.calibrator.LoadFromFile(path)
+		if loaded := s.calibrator.GetCalibration() != nil; loaded != tt.loaded || (err == nil) != tt.loaded {
+			t.Errorf("%s: loaded = %v, err = %v; want loaded %v", tt.name, loaded, err, tt.loaded)
+		}
+	}
+}
@@ -0,0 +1,77 @@
+package boomsense_sensor
+
+import "math"
+
+// SavitzkyGolay smooths a signal by fitting a quadratic to the last N
+// samples by least squares and evaluating it at the newest one. Fitting at
+// the window end rather than its centre adds no delay, at the cost of less
+// noise reduction than a centred window of the same length.
+type SavitzkyGolay struct {
+	weights []float64 // applied oldest first
+	samples []float64 // ring of the last len(weights) samples
+	next    int
+	count   int
+}
+
+// NewSavitzkyGolay creates a smoother over window samples (at least 3)
+func NewSavitzkyGolay(window int) *SavitzkyGolay {
+	return &SavitzkyGolay{
+		weights: savitzkyGolayWeights(window),
+		samples: make([]float64, window),
+	}
+}
+
+// savitzkyGolayWeights returns the quadratic end-point weights for sample
+// positions x = -(n-1)..0: the first row of the inverse normal matrix
+// applied to (1, x, x²)
+func savitzkyGolayWeights(n int) []float64 {
+	var s [5]float64 // sums of x^0..x^4
+	for i := 0; i < n; i++ {
+		x := float64(i - (n - 1))
+		p := 1.0
+		for k := range s {
+			s[k] += p
+			p *= x
+		}
+	}
+
+	// Cofactors of the symmetric matrix [[s0 s1 s2] [s1 s2 s3] [s2 s3 s4]]
+	c00 := s[2]*s[4] - s[3]*s[3]
+	c01 := -(s[1]*s[4] - s[2]*s[3])
+	c02 := s[1]*s[3] - s[2]*s[2]
+	det := s[0]*c00 + s[1]*c01 + s[2]*c02
+
+	weights := make([]float64, n)
+	for i := range weights {
+		x := float64(i - (n - 1))
+		weights[i] = (c00 + c01*x + c02*x*x) / det
+	}
+	return weights
+}
+
+// Update adds a sample and returns the smoothed value. Until the window has
+// filled the sample is returned unchanged. A NaN/Inf sample (boom not
+// calibrated) restarts the window and is passed through.
+func (sg *SavitzkyGolay) Update(v float64) float64 {
+	if math.IsNaN(v) || math.IsInf(v, 0) {
+		sg.count = 0
+		sg.next = 0
+		return v
+	}
+
+	sg.samples[sg.next] = v
+	sg.next = (sg.next + 1) % len(sg.samples)
+	if sg.count < len(sg.samples) {
+		sg.count++
+		if sg.count < len(sg.samples) {
+			return v
+		}
+	}
+
+	// sg.next now indexes the oldest sample
+	var out float64
+	for i, w := range sg.weights {
+		out += w * sg.samples[(sg.next+i)%len(sg.samples)]
+	}
+	return out
+}
@@ -1,185 +1,300 @@
-package boomsense_sensor
-
-import (
-	"sync"
-	"time"
-)
-
-// IMUReading represents raw IMU sensor data
-type IMUReading struct {
-	Timestamp time.Time
-	AccelX    float64 // g
-	AccelY    float64 // g
-	AccelZ    float64 // g
-	GyroX     float64 // deg/s
-	GyroY     float64 // deg/s
-	GyroZ     float64 // deg/s
-}
-
-// MeteoReading represents meteorological sensor data
-type MeteoReading struct {
-	Timestamp   time.Time
-	TempC       float64
-	PressureHpa float64
-	HumidityPct float64
-}
-
-// WindReading represents wind sensor data
-type WindReading struct {
-	Timestamp time.Time
-	SpeedKts  float64
-	AngleDeg  float64
-}
-
-// FilteredData represents processed IMU data with filtered angles
-type FilteredData struct {
-	Timestamp    time.Time
-	RollDeg      float64
-	PitchDeg     float64
-	BoomRelDeg   float64 // Relative to calibrated center
-	BoomNorm     float64 // Normalized [-1, 1]
-	AccelX       float64
-	AccelY       float64
-	AccelZ       float64
-	GyroX        float64
-	GyroY        float64
-	GyroZ        float64
-}
-
-// Calibration holds boom calibration parameters
-type Calibration struct {
-	Mid      float64 // Center angle (degrees)
-	SpanPos  float64 // Starboard span (degrees)
-	SpanNeg  float64 // Port span (degrees)
-	Timestamp time.Time
-}
-
-// Event represents a detected sailing event
-type Event struct {
-	Type      string    // "tack", "gybe_normal", "gybe_crash", "boom_hit"
-	Timestamp time.Time
-	GyroPeak  float64
-	BoomDelta float64
-	RollDelta float64
-	Duration  float64
-	Direction string  // For tacks: "stb_to_port", "port_to_stb"
-	Overshoot float64 // For tacks
-	Score     float64 // Tack quality score (0-100)
-	WindSpeed float64
-	WindAngle float64
-}
-
-// RingBuffer is a generic circular buffer
-type RingBuffer struct {
-	data     []interface{}
-	head     int
-	size     int
-	capacity int
-	mu       sync.RWMutex
-}
-
-func NewRingBuffer(capacity int) *RingBuffer {
-	return &RingBuffer{
-		data:     make([]interface{}, capacity),
-		capacity: capacity,
-	}
-}
-
-func (rb *RingBuffer) Push(item interface{}) {
-	rb.mu.Lock()
-	defer rb.mu.Unlock()
-
-	rb.data[rb.head] = item
-	rb.head = (rb.head + 1) % rb.capacity
-	if rb.size < rb.capacity {
-		rb.size++
-	}
-}
-
-func (rb *RingBuffer) GetRecent(n int) []interface{} {
-	rb.mu.RLock()
-	defer rb.mu.RUnlock()
-
-	if n > rb.size {
-		n = rb.size
-	}
-
-	result := make([]interface{}, n)
-	for i := 0; i < n; i++ {
-		idx := (rb.head - 1 - i + rb.capacity) % rb.capacity
-		result[i] = rb.data[idx]
-	}
-	return result
-}
-
-func (rb *RingBuffer) Size() int {
-	rb.mu.RLock()
-	defer rb.mu.RUnlock()
-	return rb.size
-}
-
-func (rb *RingBuffer) GetAll() []interface{} {
-	rb.mu.RLock()
-	defer rb.mu.RUnlock()
-
-	result := make([]interface{}, rb.size)
-	for i := 0; i < rb.size; i++ {
-		idx := (rb.head - rb.size + i + rb.capacity) % rb.capacity
-		result[i] = rb.data[idx]
-	}
-	return result
-}
-
-// Config holds sensor configuration
-type Config struct {
-	MaxBufferSize int
-	EulerTau      float64
-	BoomAxis      string // "roll" or "pitch"
-	
-	// Event detection thresholds
-	CrashGyDPS        float64
-	NormalGyMin       float64
-	BoomStepCrash     float64
-	BoomStepNormal    float64
-	CrashDT           float64
-	NormalDT          float64
-	RollHit           float64
-	RollDT            float64
-	TackGyMin         float64
-	TackGyMax         float64
-	TackBoomStep      float64
-	TackDTMax         float64
-	TackMinRollDelta  float64
-	
-	// Bayesian QA
-	BayesSigma0       float64
-	QALowThreshold    float64
-	QAHighThreshold   float64
-	
-	RefractoryPeriod  float64 // seconds between events
-}
-
-func DefaultConfig() Config {
-	return Config{
-		MaxBufferSize:    600,
-		EulerTau:         0.7,
-		BoomAxis:         "roll",
-		CrashGyDPS:       120.0,
-		NormalGyMin:      20.0,
-		BoomStepCrash:    1.2,
-		BoomStepNormal:   1.0,
-		CrashDT:          0.6,
-		NormalDT:         2.5,
-		RollHit:          8.0,
-		RollDT:           0.4,
-		TackGyMin:        15.0,
-		TackGyMax:        110.0,
-		TackBoomStep:     1.0,
-		TackDTMax:        3.0,
-		TackMinRollDelta: 12.0,
-		BayesSigma0:      10.0,
-		QALowThreshold:   0.02,
-		QAHighThreshold:  0.85,
-		RefractoryPeriod: 3.0,
-	}
+package boomsense_sensor
+
+import (
+	"sync"
+	"time"
+)
+
+// IMUReading represents raw IMU sensor data
+type IMUReading struct {
+	Timestamp time.Time
+	AccelX    float64 // g
+	AccelY    float64 // g
+	AccelZ    float64 // g
+	GyroX     float64 // deg/s
+	GyroY     float64 // deg/s
+	GyroZ     float64 // deg/s
+
+	// Magnetometer, any unit (only the direction is used); all zero when
+	// the IMU has none
+	MagX float64
+	MagY float64
+	MagZ float64
+}
+
+// MeteoReading represents meteorological sensor data
+type MeteoReading struct {
+	Timestamp   time.Time
+	TempC       float64
+	PressureHpa float64
+	HumidityPct float64
+}
+
+// WindReading represents wind sensor data
+type WindReading struct {
+	Timestamp time.Time
+	SpeedKts  float64
+	AngleDeg  float64
+}
+
+// FilteredData represents processed IMU data with filtered angles
+type FilteredData struct {
+	Timestamp        time.Time
+	RollDeg          float64
+	PitchDeg         float64
+	BoomRelDeg       float64 // Relative to calibrated center
+	BoomNorm         float64 // Normalized [-1, 1]
+	BoomRelDegSmooth float64 // BoomRelDeg after Config.BoomSmoothWindow (equal to it when off); NaN on samples TargetHz decimates
+	Clipped          bool    // an accelerometer axis was at full scale
+	AccelX           float64
+	AccelY           float64
+	AccelZ           float64
+	GyroX            float64
+	GyroY            float64
+	GyroZ            float64
+}
+
+// Calibration holds boom calibration parameters
+type Calibration struct {
+	Mid      float64 // Center angle (degrees)
+	SpanPos  float64 // Starboard span (degrees)
+	SpanNeg  float64 // Port span (degrees)
+	Quality  *float64 // 0-100 score; nil for calibrations saved before scoring
+	Timestamp time.Time
+
+	// Mount correction the calibration was captured under
+	AxisInvert       bool
+	MountRotationDeg float64
+}
+
+// Event represents a detected sailing event
+type Event struct {
+	Type      string    // "tack", "gybe_normal", "gybe_crash", "boom_hit", "broach"
+	Timestamp time.Time
+	GyroPeak  float64
+	BoomDelta float64
+	RollDelta float64
+	Duration  float64
+	Direction string  // For tacks: "stb_to_port", "port_to_stb"
+	Overshoot float64 // For tacks
+	Score     float64 // Tack quality score (0-100)
+	WindSpeed float64
+	WindAngle float64
+	Quality   *float64 // Bayesian QA probability when detected; nil from the bare detector
+}
+
+// RingBuffer is a generic circular buffer
+type RingBuffer struct {
+	data     []interface{}
+	head     int
+	size     int
+	capacity int
+	mu       sync.RWMutex
+}
+
+func NewRingBuffer(capacity int) *RingBuffer {
+	return &RingBuffer{
+		data:     make([]interface{}, capacity),
+		capacity: capacity,
+	}
+}
+
+func (rb *RingBuffer) Push(item interface{}) {
+	rb.mu.Lock()
+	defer rb.mu.Unlock()
+
+	rb.data[rb.head] = item
+	rb.head = (rb.head + 1) % rb.capacity
+	if rb.size < rb.capacity {
+		rb.size++
+	}
+}
+
+func (rb *RingBuffer) GetRecent(n int) []interface{} {
+	rb.mu.RLock()
+	defer rb.mu.RUnlock()
+
+	if n > rb.size {
+		n = rb.size
+	}
+
+	result := make([]interface{}, n)
+	for i := 0; i < n; i++ {
+		idx := (rb.head - 1 - i + rb.capacity) % rb.capacity
+		result[i] = rb.data[idx]
+	}
+	return result
+}
+
+func (rb *RingBuffer) Size() int {
+	rb.mu.RLock()
+	defer rb.mu.RUnlock()
+	return rb.size
+}
+
+func (rb *RingBuffer) GetAll() []interface{} {
+	rb.mu.RLock()
+	defer rb.mu.RUnlock()
+
+	result := make([]interface{}, rb.size)
+	for i := 0; i < rb.size; i++ {
+		idx := (rb.head - rb.size + i + rb.capacity) % rb.capacity
+		result[i] = rb.data[idx]
+	}
+	return result
+}
+
+// Config holds sensor configuration
+type Config struct {
+	MaxBufferSize int     `json:"max_buffer_size"`
+	EventHistory  int     `json:"event_history"` // detected events kept for GetRecentEvents
+	EulerTau      float64 `json:"euler_tau"`
+	BoomAxis      string  `json:"boom_axis"`     // "roll" or "pitch"
+	FilterType    string  `json:"filter_type"`   // "complementary", "madgwick" or "mahony"
+	MadgwickBeta  float64 `json:"madgwick_beta"` // gradient-descent gain (rad/s)
+
+	// Mahony filter gains. With magnetometer data it also estimates
+	// magnetic heading; MagHeadingOffsetDeg is added to align the sensor's
+	// fore-aft axis with the bow (assumes the sensor is fixed to the hull).
+	MahonyKp            float64 `json:"mahony_kp"`
+	MahonyKi            float64 `json:"mahony_ki"`
+	MagHeadingOffsetDeg float64 `json:"mag_heading_offset_deg"`
+
+	// Per-axis complementary filter time constants (seconds); 0 uses
+	// EulerTau. A noisy pitch axis from wave slamming can take a larger tau
+	// to trust the gyro more without slowing the roll response.
+	TauRoll  float64 `json:"tau_roll"`
+	TauPitch float64 `json:"tau_pitch"`
+
+	// Mounting correction for the boom axis: AxisInvert flips a sensor that
+	// reads port as positive, MountRotationDeg is how far the sensor is
+	// turned about its vertical axis from square to the boom
+	AxisInvert       bool    `json:"axis_invert"`
+	MountRotationDeg float64 `json:"mount_rotation_deg"`
+
+	// TargetHz decimates the IMU stream after the attitude filter: the
+	// filter sees every sample, while buffers, event detection and CSV run
+	// at most this often (0 = every sample). Event thresholds were tuned at
+	// the full sensor rate and may need retuning below about 50 Hz, since
+	// short gyro peaks can fall between retained samples.
+	TargetHz float64 `json:"target_hz"`
+
+	// Accelerometer saturation: a sample with any axis within ClipEpsilonG
+	// of AccelFullScaleG is flagged as clipped and kept from the event
+	// detector, since slamming at the rail makes the attitude spike
+	// (AccelFullScaleG 0 = off)
+	AccelFullScaleG float64 `json:"accel_full_scale_g"`
+	ClipEpsilonG    float64 `json:"clip_epsilon_g"`
+
+	// BoomSmoothWindow smooths the displayed and logged boom angle with a
+	// Savitzky-Golay filter over this many output samples (0 = off, else at
+	// least 3). The event detector always sees the unsmoothed signal.
+	BoomSmoothWindow int `json:"boom_smooth_window"`
+
+	// CalibrationMinQuality rejects boom calibrations whose quality score
+	// (0-100) is below it instead of committing them (0 = accept all)
+	CalibrationMinQuality float64 `json:"calibration_min_quality"`
+
+	// Gyro bias tracking (complementary filter only)
+	GyroBiasTracking   bool    `json:"gyro_bias_tracking"`
+	BiasAccelTolerance float64 `json:"bias_accel_tolerance"` // g from 1g to treat as static
+	BiasGyroTolerance  float64 `json:"bias_gyro_tolerance"`  // deg/s to treat as static
+
+	// Event detection thresholds
+	CrashGyDPS       float64 `json:"crash_gy_dps"`
+	NormalGyMin      float64 `json:"normal_gy_min"`
+	BoomStepCrash    float64 `json:"boom_step_crash"`
+	BoomStepNormal   float64 `json:"boom_step_normal"`
+	CrashDT          float64 `json:"crash_dt"`
+	NormalDT         float64 `json:"normal_dt"`
+	RollHit          float64 `json:"roll_hit"`
+	RollDT           float64 `json:"roll_dt"`
+	TackGyMin        float64 `json:"tack_gy_min"`
+	TackGyMax        float64 `json:"tack_gy_max"`
+	TackBoomStep     float64 `json:"tack_boom_step"`
+	TackDTMax        float64 `json:"tack_dt_max"`
+	TackMinRollDelta float64 `json:"tack_min_roll_delta"`
+	BroachRoll       float64 `json:"broach_roll"`        // heel (deg) that must be exceeded
+	BroachYawDPS     float64 `json:"broach_yaw_dps"`     // peak yaw rate (deg/s)
+	BroachHeadingDeg float64 `json:"broach_heading_deg"` // heading change within BroachDT (deg)
+	BroachDT         float64 `json:"broach_dt"`
+
+	// Bayesian QA
+	BayesSigma0     float64 `json:"bayes_sigma0"`
+	QALowThreshold  float64 `json:"qa_low_threshold"`
+	QAHighThreshold float64 `json:"qa_high_threshold"`
+
+	// BayesFullCovariance keeps a full posterior covariance instead of the
+	// diagonal approximation, so correlated features are not double counted
+	BayesFullCovariance bool `json:"bayes_full_covariance"`
+
+	RefractoryPeriod float64 `json:"refractory_period"` // seconds between events
+
+	// RefractoryByType overrides RefractoryPeriod for a candidate event of
+	// the given type, e.g. a short boom_hit window so the hit that follows
+	// a crash gybe is logged, or a long tack window against false tacks in
+	// chop. RefractoryBypassSevere lets a broach or crash gybe through the
+	// window of an earlier, less severe event, but never through the window
+	// of the last event of its own type.
+	RefractoryByType       map[string]float64 `json:"refractory_by_type"`
+	RefractoryBypassSevere bool               `json:"refractory_bypass_severe"`
+}
+
+// RollTau is the complementary filter time constant for roll
+func (c Config) RollTau() float64 {
+	if c.TauRoll > 0 {
+		return c.TauRoll
+	}
+	return c.EulerTau
+}
+
+// PitchTau is the complementary filter time constant for pitch
+func (c Config) PitchTau() float64 {
+	if c.TauPitch > 0 {
+		return c.TauPitch
+	}
+	return c.EulerTau
+}
+
+func DefaultConfig() Config {
+	return Config{
+		MaxBufferSize:    600,
+		EventHistory:     200,
+		EulerTau:         0.7,
+		BoomAxis:         "roll",
+		FilterType:       "complementary",
+		MadgwickBeta:     0.1,
+		MahonyKp:         1.0,
+		CrashGyDPS:       120.0,
+		NormalGyMin:      20.0,
+		BoomStepCrash:    1.2,
+		BoomStepNormal:   1.0,
+		CrashDT:          0.6,
+		NormalDT:         2.5,
+		RollHit:          8.0,
+		RollDT:           0.4,
+		TackGyMin:        15.0,
+		TackGyMax:        110.0,
+		TackBoomStep:     1.0,
+		TackDTMax:        3.0,
+		TackMinRollDelta: 12.0,
+		BroachRoll:       35.0,
+		BroachYawDPS:     25.0,
+		BroachHeadingDeg: 30.0,
+		BroachDT:         3.0,
+		BayesSigma0:      10.0,
+		QALowThreshold:   0.02,
+		QAHighThreshold:  0.85,
+		RefractoryPeriod: 3.0,
+
+		RefractoryByType:       map[string]float64{EventBoomHit: 1.0},
+		RefractoryBypassSevere: true,
+
+		AccelFullScaleG: 16.0,
+		ClipEpsilonG:    0.05,
+
+		GyroBiasTracking:   false,
+		BiasAccelTolerance: 0.03,
+		BiasGyroTolerance:  2.0,
+	}
 }
\ No newline at end of file
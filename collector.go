@@ -1,375 +1,954 @@
-package nmea
-
-import (
-	"crypto/tls"
-	"encoding/hex"
-	"encoding/json"
-	"fmt"
-	"log"
-	"strings"
-	"time"
-
-	mqtt "github.com/eclipse/paho.mqtt.golang"
-	"odysail-boat-viz/storage"
-)
-
-type Collector struct {
-	config      Config
-	client      mqtt.Client
-	decoder     *Decoder
-	buffer      BufferInterface
-	csvWriter   CSVWriterInterface
-	stats       *Statistics
-	rawFrames   chan RawFrame
-	decodedData chan DecodedMessage
-	done        chan struct{}
-}
-
-// Interfaces for dependency injection (testing)
-type BufferInterface interface {
-	Push(msg storage.DecodedMessage)
-	GetLatestByPGN(pgn int) *storage.DecodedMessage
-	Size() int
-	GetStats() map[string]interface{}
-}
-
-type CSVWriterInterface interface {
-	WriteDecoded(msg storage.DecodedMessage)
-	Close()
-}
-
-func NewCollector(config Config, buffer BufferInterface, csvWriter CSVWriterInterface) *Collector {
-	return &Collector{
-		config:      config,
-		decoder:     NewDecoder(),
-		buffer:      buffer,
-		csvWriter:   csvWriter,
-		stats:       NewStatistics(),
-		rawFrames:   make(chan RawFrame, config.QueueSize),
-		decodedData: make(chan DecodedMessage, config.QueueSize),
-		done:        make(chan struct{}),
-	}
-}
-
-func (c *Collector) Start() error {
-	log.Printf("[NMEA] Starting collector...")
-	log.Printf("[NMEA] Config: Broker=%s:%d Topic=%s", c.config.MQTTBroker, c.config.MQTTPort, c.config.MQTTTopic)
-
-	// Setup MQTT client options
-	opts := mqtt.NewClientOptions()
-
-	// Broker URL
-	protocol := "tcp"
-	if c.config.UseTLS {
-		protocol = "tls"
-	}
-	brokerURL := fmt.Sprintf("%s://%s:%d", protocol, c.config.MQTTBroker, c.config.MQTTPort)
-	opts.AddBroker(brokerURL)
-
-	// Client ID
-	clientID := fmt.Sprintf("odysail-collector-%d", time.Now().Unix())
-	opts.SetClientID(clientID)
-
-	// Credentials
-	if c.config.MQTTUsername != "" {
-		opts.SetUsername(c.config.MQTTUsername)
-		opts.SetPassword(c.config.MQTTPassword)
-	}
-
-	// TLS config
-	if c.config.UseTLS {
-		tlsConfig := &tls.Config{
-			InsecureSkipVerify: c.config.InsecureSkipTLS,
-		}
-		opts.SetTLSConfig(tlsConfig)
-	}
-
-	// Connection settings
-	opts.SetKeepAlive(60 * time.Second)
-	opts.SetPingTimeout(10 * time.Second)
-	opts.SetConnectTimeout(10 * time.Second)
-	opts.SetAutoReconnect(true)
-	opts.SetMaxReconnectInterval(30 * time.Second)
-
-	// Callbacks
-	opts.OnConnect = c.onConnect
-	opts.OnConnectionLost = c.onConnectionLost
-	opts.OnReconnecting = c.onReconnecting
-
-	// Create and connect client
-	c.client = mqtt.NewClient(opts)
-
-	log.Printf("[MQTT] Connecting to %s as %s...", brokerURL, clientID)
-
-	token := c.client.Connect()
-	if !token.WaitTimeout(10 * time.Second) {
-		return fmt.Errorf("MQTT connect timeout")
-	}
-	if token.Error() != nil {
-		return fmt.Errorf("MQTT connect failed: %w", token.Error())
-	}
-
-	// Start worker goroutines
-	log.Printf("[NMEA] Starting %d decoder workers", c.config.DecoderWorkers)
-	for i := 0; i < c.config.DecoderWorkers; i++ {
-		go c.decodeWorker(i)
-	}
-	go c.storageWorker()
-	go c.statsReporter()
-
-	log.Printf("[NMEA] Collector started successfully")
-	return nil
-}
-
-func (c *Collector) Stop() {
-	log.Printf("[NMEA] Stopping collector...")
-	close(c.done)
-
-	if c.client != nil && c.client.IsConnected() {
-		c.client.Disconnect(1000)
-	}
-
-	if c.csvWriter != nil {
-		c.csvWriter.Close()
-	}
-
-	successRate := 0.0
-	if c.stats.MessagesProcessed > 0 {
-		successRate = float64(c.stats.DecodeSuccesses) / float64(c.stats.MessagesProcessed) * 100.0
-	}
-
-	log.Printf("[NMEA] Collector stopped - processed %d messages (%.1f%% decode success)",
-		c.stats.MessagesProcessed, successRate)
-}
-
-func (c *Collector) onConnect(client mqtt.Client) {
-	log.Printf("[MQTT] Connected successfully")
-
-	token := client.Subscribe(c.config.MQTTTopic, 0, c.onMessage)
-	if !token.WaitTimeout(5 * time.Second) {
-		log.Printf("[MQTT] Subscribe timeout for %s", c.config.MQTTTopic)
-		return
-	}
-	if token.Error() != nil {
-		log.Printf("[MQTT] Subscribe error: %v", token.Error())
-		return
-	}
-
-	log.Printf("[MQTT] Subscribed to %s", c.config.MQTTTopic)
-}
-
-func (c *Collector) onConnectionLost(client mqtt.Client, err error) {
-	log.Printf("[MQTT] Connection lost: %v (will auto-reconnect)", err)
-}
-
-func (c *Collector) onReconnecting(client mqtt.Client, opts *mqtt.ClientOptions) {
-	log.Printf("[MQTT] Reconnecting...")
-}
-
-func (c *Collector) onMessage(client mqtt.Client, msg mqtt.Message) {
-	// Parse JSON payload
-	var payload map[string]interface{}
-	if err := json.Unmarshal(msg.Payload(), &payload); err != nil {
-		// Not JSON, skip
-		return
-	}
-
-	// Parse raw frame
-	frame := c.parseRawFrame(msg.Topic(), payload)
-	if frame == nil {
-		return
-	}
-
-	// Send to decoder workers
-	select {
-	case c.rawFrames <- *frame:
-		// Success
-	case <-c.done:
-		return
-	default:
-		// Queue full, drop message (prioritize latest data)
-	}
-}
-
-func (c *Collector) parseRawFrame(topic string, payload map[string]interface{}) *RawFrame {
-	frame := &RawFrame{
-		Timestamp: time.Now(),
-		Topic:     topic,
-	}
-
-	// Extract timestamp
-	if ts, ok := payload["ts"].(float64); ok {
-		frame.Timestamp = time.Unix(0, int64(ts)*1e6)
-	} else if ts, ok := payload["timestamp"].(float64); ok {
-		frame.Timestamp = time.Unix(0, int64(ts)*1e6)
-	}
-
-	// Extract PGN
-	if pgn, ok := payload["pgn"].(float64); ok {
-		frame.PGN = int(pgn)
-	} else {
-		// Try to compute from CAN ID components
-		dp, _ := payload["dp"].(float64)
-		pf, _ := payload["pf"].(float64)
-		ps, _ := payload["ps"].(float64)
-		frame.PGN = PGNFromParts(uint8(dp), uint8(pf), uint8(ps))
-	}
-
-	// Extract source address
-	if src, ok := payload["src"].(float64); ok {
-		frame.Source = uint8(src)
-	} else if id, ok := payload["id"].(float64); ok {
-		frame.Source = uint8(int(id) & 0xFF)
-	}
-
-	// Extract data
-	if dataStr, ok := payload["data"].(string); ok {
-		frame.Data = c.parseHexData(dataStr)
-	} else if dataArr, ok := payload["data"].([]interface{}); ok {
-		frame.Data = c.parseArrayData(dataArr)
-	} else {
-		return nil // No data, invalid frame
-	}
-
-	if len(frame.Data) == 0 {
-		return nil
-	}
-
-	frame.Length = len(frame.Data)
-
-	return frame
-}
-
-func (c *Collector) parseHexData(dataStr string) []byte {
-	// Remove common separators
-	cleaned := strings.ReplaceAll(dataStr, " ", "")
-	cleaned = strings.ReplaceAll(cleaned, ",", "")
-	cleaned = strings.ReplaceAll(cleaned, ":", "")
-	cleaned = strings.ReplaceAll(cleaned, "-", "")
-	cleaned = strings.ToLower(cleaned)
-
-	// Decode hex
-	data, err := hex.DecodeString(cleaned)
-	if err != nil {
-		return nil
-	}
-
-	return data
-}
-
-func (c *Collector) parseArrayData(dataArr []interface{}) []byte {
-	data := make([]byte, 0, len(dataArr))
-	for _, v := range dataArr {
-		if num, ok := v.(float64); ok {
-			data = append(data, byte(num))
-		}
-	}
-	return data
-}
-
-func (c *Collector) decodeWorker(id int) {
-	log.Printf("[NMEA] Decoder worker %d started", id)
-
-	for {
-		select {
-		case frame := <-c.rawFrames:
-			// Decode the frame
-			fields, err := c.decoder.Decode(frame.PGN, frame.Data)
-
-			// Build decoded message
-			decoded := DecodedMessage{
-				Timestamp:   frame.Timestamp,
-				PGN:         frame.PGN,
-				PGNName:     GetPGNName(frame.PGN),
-				Source:      frame.Source,
-				Measurement: GetMeasurementType(frame.PGN),
-				Fields:      fields,
-				Raw:         frame.Data,
-			}
-
-			// Record statistics
-			success := err == nil && fields != nil && len(fields) > 0
-			c.stats.RecordMessage(frame.PGN, decoded.Measurement, success)
-
-			// Send to storage
-			select {
-			case c.decodedData <- decoded:
-				// Success
-			case <-c.done:
-				return
-			default:
-				// Storage queue full, drop
-			}
-
-		case <-c.done:
-			log.Printf("[NMEA] Decoder worker %d stopped", id)
-			return
-		}
-	}
-}
-
-func (c *Collector) storageWorker() {
-	log.Printf("[NMEA] Storage worker started")
-
-	for {
-		select {
-		case msg := <-c.decodedData:
-			// Convert to storage.DecodedMessage
-			storageMsg := storage.DecodedMessage{
-				Timestamp:   msg.Timestamp,
-				PGN:         msg.PGN,
-				PGNName:     msg.PGNName,
-				Source:      msg.Source,
-				Measurement: msg.Measurement,
-				Fields:      msg.Fields,
-				Raw:         msg.Raw,
-			}
-
-			// Store in ring buffer
-			if c.buffer != nil {
-				c.buffer.Push(storageMsg)
-			}
-
-			// Write to CSV if enabled
-			if c.csvWriter != nil {
-				c.csvWriter.WriteDecoded(storageMsg)
-			}
-
-		case <-c.done:
-			log.Printf("[NMEA] Storage worker stopped")
-			return
-		}
-	}
-}
-
-func (c *Collector) statsReporter() {
-	ticker := time.NewTicker(30 * time.Second)
-	defer ticker.Stop()
-
-	for {
-		select {
-		case <-ticker.C:
-			stats := c.stats.GetSnapshot()
-			log.Printf("[NMEA] Stats: %d msgs, %.1f msg/s, %.1f%% success, buffer: %d",
-				stats["messages_processed"],
-				stats["messages_per_sec"],
-				stats["success_rate"],
-				c.buffer.Size())
-
-		case <-c.done:
-			return
-		}
-	}
-}
-
-func (c *Collector) Buffer() BufferInterface {
-	return c.buffer
-}
-
-func (c *Collector) Stats() *Statistics {
-	return c.stats
-}
-
-func (c *Collector) IsConnected() bool {
-	return c.client != nil && c.client.IsConnected()
+package nmea
+
+import (
+	"crypto/tls"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"log"
+	"math/rand"
+	"runtime"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	mqtt "github.com/eclipse/paho.mqtt.golang"
+	"odysail-boat-viz/storage"
+)
+
+type Collector struct {
+	config      Config
+	client      mqtt.Client
+	decoder     *Decoder
+	buffer      BufferInterface
+	csvWriter   CSVWriterInterface
+	stats       *Statistics
+	devices     *DeviceRegistry
+	gate        queueGate
+	fastPackets *fastPacketAssembler // YDWG fragments in reassembly
+	rawFrames   *priorityQueue[RawFrame]
+	decodedData *priorityQueue[DecodedMessage]
+	done        chan struct{} // closed by Stop: intake ends, queues drain
+	workers     sync.WaitGroup
+
+	// Shutdown runs stage by stage so each queue is drained into the next
+	// before that stage stops: dispatcher, then decoders, then storage
+	dispatcher sync.WaitGroup
+	decoders   sync.WaitGroup
+	decodeDone chan struct{} // closed once nothing more reaches the decoders
+	storeDone  chan struct{} // closed once the decoders have drained
+
+	// Per-worker queues when DecoderPinSources is set, fed from rawFrames
+	// by dispatchFrames; nil when the workers share rawFrames
+	workerFrames []*priorityQueue[RawFrame]
+
+	// Subscription health, guarded by healthMu
+	healthMu          sync.Mutex
+	subscribed        bool
+	subscribeFailures int
+	lastMessage       time.Time
+	replaying         bool
+
+	// BoomSense readings published alongside the N2K frames
+	sinkMu sync.RWMutex
+	sink   SensorSink
+
+	// Session capture for later replay
+	rec recorder
+
+	// Set while reconnectLoop runs so only one loop retries at a time
+	reconnecting atomic.Bool
+}
+
+// Interfaces for dependency injection (testing)
+type BufferInterface interface {
+	Push(msg storage.DecodedMessage)
+	GetLatestByPGN(pgn int) *storage.DecodedMessage
+	GetByPGNTimeRange(pgn int, start, end time.Time) []storage.DecodedMessage
+	Size() int
+	GetStats() map[string]interface{}
+}
+
+type CSVWriterInterface interface {
+	WriteFrame(frame storage.RawFrame)
+	WriteDecoded(msg storage.DecodedMessage)
+	WriteStats(snapshot map[string]interface{})
+	Close()
+}
+
+// multiWriter fans every log call out to several writers
+type multiWriter []CSVWriterInterface
+
+// MultiWriter combines writers, e.g. the CSV logs and an InfluxDB sink, into
+// the single writer a Collector takes
+func MultiWriter(writers ...CSVWriterInterface) CSVWriterInterface {
+	return multiWriter(writers)
+}
+
+func (m multiWriter) WriteFrame(frame storage.RawFrame) {
+	for _, w := range m {
+		w.WriteFrame(frame)
+	}
+}
+
+func (m multiWriter) WriteDecoded(msg storage.DecodedMessage) {
+	for _, w := range m {
+		w.WriteDecoded(msg)
+	}
+}
+
+func (m multiWriter) WriteStats(snapshot map[string]interface{}) {
+	for _, w := range m {
+		w.WriteStats(snapshot)
+	}
+}
+
+func (m multiWriter) Close() {
+	for _, w := range m {
+		w.Close()
+	}
+}
+
+func NewCollector(config Config, buffer BufferInterface, csvWriter CSVWriterInterface) *Collector {
+	decoder := NewDecoderWithUnits(config.Units)
+	decoder.MarkInvalid = config.MarkInvalidFields
+	decoder.DepthOffset = config.DepthOffsetM
+
+	c := &Collector{
+		config:      config,
+		decoder:     decoder,
+		buffer:      buffer,
+		csvWriter:   csvWriter,
+		stats:       NewStatistics(),
+		devices:     NewDeviceRegistry(),
+		gate:        newQueueGate(config),
+		fastPackets: newFastPacketAssembler(),
+		rawFrames:   newPriorityQueue[RawFrame](config.QueueSize),
+		decodedData: newPriorityQueue[DecodedMessage](config.QueueSize),
+		done:        make(chan struct{}),
+		decodeDone:  make(chan struct{}),
+		storeDone:   make(chan struct{}),
+	}
+
+	if workers := config.DecoderWorkerCount(); config.DecoderPinSources && workers > 1 {
+		size := config.QueueSize / workers
+		if size < 1 {
+			size = 1
+		}
+		c.workerFrames = make([]*priorityQueue[RawFrame], workers)
+		for i := range c.workerFrames {
+			c.workerFrames[i] = newPriorityQueue[RawFrame](size)
+		}
+	}
+	return c
+}
+
+func (c *Collector) Start() error {
+	log.Printf("[NMEA] Starting collector...")
+
+	if c.config.Source == SourceReplay {
+		return c.startReplay()
+	}
+
+	log.Printf("[NMEA] Config: Broker=%s:%d Topic=%s", c.config.MQTTBroker, c.config.MQTTPort, c.config.MQTTTopic)
+
+	// Setup MQTT client options
+	opts := mqtt.NewClientOptions()
+
+	// Broker URL
+	protocol := "tcp"
+	if c.config.UseTLS {
+		protocol = "tls"
+	}
+	brokerURL := fmt.Sprintf("%s://%s:%d", protocol, c.config.MQTTBroker, c.config.MQTTPort)
+	opts.AddBroker(brokerURL)
+
+	// Client ID
+	clientID := fmt.Sprintf("odysail-collector-%d", time.Now().Unix())
+	opts.SetClientID(clientID)
+
+	// Credentials
+	if c.config.MQTTUsername != "" {
+		opts.SetUsername(c.config.MQTTUsername)
+		opts.SetPassword(c.config.MQTTPassword)
+	}
+
+	// TLS config
+	if c.config.UseTLS {
+		tlsConfig := &tls.Config{
+			InsecureSkipVerify: c.config.InsecureSkipTLS,
+		}
+		opts.SetTLSConfig(tlsConfig)
+	}
+
+	// Connection settings
+	opts.SetKeepAlive(60 * time.Second)
+	opts.SetPingTimeout(10 * time.Second)
+	opts.SetConnectTimeout(10 * time.Second)
+	// Reconnects go through reconnectLoop, which paces them with
+	// ReconnectMin/MaxInterval and jitter; paho's own backoff has neither
+	opts.SetAutoReconnect(false)
+
+	// Callbacks
+	opts.OnConnect = c.onConnect
+	opts.OnConnectionLost = c.onConnectionLost
+
+	// Create and connect client
+	c.client = mqtt.NewClient(opts)
+
+	log.Printf("[MQTT] Connecting to %s as %s...", brokerURL, clientID)
+
+	var err error
+	token := c.client.Connect()
+	if !token.WaitTimeout(10 * time.Second) {
+		err = fmt.Errorf("MQTT connect timeout")
+	} else if token.Error() != nil {
+		err = fmt.Errorf("MQTT connect failed: %w", token.Error())
+	}
+	if err != nil {
+		if !c.config.ConnectRetryForever {
+			return err
+		}
+		log.Printf("[MQTT] %v, retrying in the background", err)
+		c.startReconnect()
+	}
+
+	c.startWorkers()
+	c.startNMEA0183()
+	go c.watchdog()
+
+	log.Printf("[NMEA] Collector started successfully")
+	return nil
+}
+
+// startWorkers launches the decode, storage and stats goroutines shared by
+// every frame source
+func (c *Collector) startWorkers() {
+	workers := c.config.DecoderWorkerCount()
+	mode := "shared queue"
+	if c.workerFrames != nil {
+		mode = "pinned by source"
+	}
+	if c.config.DecoderWorkers == 0 {
+		log.Printf("[NMEA] Starting %d decoder workers (auto, %d CPUs, %s)", workers, runtime.NumCPU(), mode)
+	} else {
+		log.Printf("[NMEA] Starting %d decoder workers (%s)", workers, mode)
+	}
+
+	c.decoders.Add(workers)
+	for i := 0; i < workers; i++ {
+		frames := c.rawFrames
+		if c.workerFrames != nil {
+			frames = c.workerFrames[i]
+		}
+		go c.decodeWorker(i, frames)
+	}
+	if c.workerFrames != nil {
+		c.dispatcher.Add(1)
+		go c.dispatchFrames()
+	}
+	c.workers.Add(1)
+	go c.storageWorker()
+	go c.statsReporter()
+}
+
+// startReplay feeds frames from Config.ReplayPath instead of MQTT
+func (c *Collector) startReplay() error {
+	replay, err := OpenReplaySource(c.config.ReplayPath, c.config.ReplaySpeed, c.config.ReplayLoop)
+	if err != nil {
+		return err
+	}
+
+	c.startWorkers()
+	c.startNMEA0183()
+
+	c.healthMu.Lock()
+	c.replaying = true
+	c.subscribed = true
+	c.lastMessage = time.Now()
+	c.healthMu.Unlock()
+
+	replay.Sensor = func(topic string, payload map[string]interface{}) {
+		c.handleSensorPayload(topic, payload)
+	}
+
+	go func() {
+		// Replay waits for room rather than dropping, so its frames need
+		// no priority
+		if err := replay.Run(c.rawFrames.normal, c.done); err != nil {
+			log.Printf("[REPLAY] %v", err)
+		}
+		c.healthMu.Lock()
+		c.replaying = false
+		c.healthMu.Unlock()
+	}()
+
+	log.Printf("[NMEA] Collector started in replay mode")
+	return nil
+}
+
+func (c *Collector) Stop() {
+	log.Printf("[NMEA] Stopping collector...")
+
+	// Stop intake first so the storage worker can drain what is queued
+	if c.client != nil && c.client.IsConnected() {
+		c.client.Disconnect(1000)
+	}
+
+	close(c.done)
+	if !c.drain(stopDrainTimeout) {
+		raw, decoded := c.QueueDepth()
+		log.Printf("[WARN] NMEA queues not drained after %v (%d frames, %d messages left)", stopDrainTimeout, raw, decoded)
+	}
+
+	if c.Recording().Active {
+		if status, err := c.StopRecording(); err != nil {
+			log.Printf("[NMEA] Failed to close recording %s: %v", status.Path, err)
+		} else {
+			log.Printf("[NMEA] Closed recording %s (%d frames, %d readings)", status.Path, status.Frames, status.Readings)
+		}
+	}
+
+	if c.csvWriter != nil {
+		c.csvWriter.Close()
+	}
+
+	successRate := 0.0
+	if c.stats.MessagesProcessed > 0 {
+		successRate = float64(c.stats.DecodeSuccesses) / float64(c.stats.MessagesProcessed) * 100.0
+	}
+
+	log.Printf("[NMEA] Collector stopped - processed %d messages (%.1f%% decode success)",
+		c.stats.MessagesProcessed, successRate)
+}
+
+// stopDrainTimeout bounds how long Stop waits for queued frames to be
+// decoded and stored
+const stopDrainTimeout = 5 * time.Second
+
+// drain lets each stage of the pipeline empty its queue into the next and
+// stop, in order, reporting false if that takes longer than timeout
+func (c *Collector) drain(timeout time.Duration) bool {
+	finished := make(chan struct{})
+	go func() {
+		c.dispatcher.Wait()
+		close(c.decodeDone)
+		c.decoders.Wait()
+		close(c.storeDone)
+		c.workers.Wait()
+		close(finished)
+	}()
+
+	select {
+	case <-finished:
+		return true
+	case <-time.After(timeout):
+		return false
+	}
+}
+
+// SetSensorSink routes BoomSense sensor payloads to sink; nil drops them
+func (c *Collector) SetSensorSink(sink SensorSink) {
+	c.sinkMu.Lock()
+	c.sink = sink
+	c.sinkMu.Unlock()
+}
+
+func (c *Collector) onConnect(client mqtt.Client) {
+	log.Printf("[MQTT] Connected successfully")
+
+	// Give a fresh connection a full DataTimeout before it counts as stalled
+	c.healthMu.Lock()
+	c.lastMessage = time.Now()
+	c.healthMu.Unlock()
+
+	if err := c.subscribe(client); err != nil {
+		log.Printf("[MQTT] %v (watchdog will retry)", err)
+		return
+	}
+
+	log.Printf("[MQTT] Subscribed to %s", c.config.MQTTTopic)
+}
+
+// subscribe subscribes to every configured topic and records whether the
+// broker acknowledged it, so a failed re-subscribe after reconnect shows up
+// as unhealthy instead of a silent stall
+func (c *Collector) subscribe(client mqtt.Client) error {
+	filters := make(map[string]byte, len(c.config.MQTTTopic))
+	for _, topic := range c.config.MQTTTopic {
+		filters[topic] = 0
+	}
+
+	var err error
+	token := client.SubscribeMultiple(filters, c.onMessage)
+	if !token.WaitTimeout(5 * time.Second) {
+		err = fmt.Errorf("subscribe timeout for %s", c.config.MQTTTopic)
+	} else if token.Error() != nil {
+		err = fmt.Errorf("subscribe error: %w", token.Error())
+	}
+
+	c.healthMu.Lock()
+	defer c.healthMu.Unlock()
+
+	if err != nil {
+		c.subscribed = false
+		c.subscribeFailures++
+		return err
+	}
+
+	c.subscribed = true
+	c.subscribeFailures = 0
+	return nil
+}
+
+func (c *Collector) onConnectionLost(client mqtt.Client, err error) {
+	log.Printf("[MQTT] Connection lost: %v (will reconnect)", err)
+
+	c.healthMu.Lock()
+	c.subscribed = false
+	c.healthMu.Unlock()
+
+	c.startReconnect()
+}
+
+// startReconnect launches reconnectLoop unless one is already running
+func (c *Collector) startReconnect() {
+	if c.reconnecting.CompareAndSwap(false, true) {
+		go c.reconnectLoop()
+	}
+}
+
+// reconnectLoop retries the broker with exponential backoff until it
+// connects or the collector stops. onConnect re-subscribes on success.
+func (c *Collector) reconnectLoop() {
+	defer c.reconnecting.Store(false)
+
+	for attempt := 1; ; attempt++ {
+		delay := c.reconnectDelay(attempt)
+		log.Printf("[MQTT] Reconnect attempt %d in %s", attempt, delay.Round(time.Millisecond))
+
+		select {
+		case <-time.After(delay):
+		case <-c.done:
+			return
+		}
+
+		// Bounded by the client's ConnectTimeout
+		token := c.client.Connect()
+		token.Wait()
+		if err := token.Error(); err != nil {
+			log.Printf("[MQTT] Reconnect attempt %d failed: %v", attempt, err)
+			continue
+		}
+
+		select {
+		case <-c.done:
+			// Stopped while connecting
+			c.client.Disconnect(0)
+		default:
+			log.Printf("[MQTT] Reconnected after %d attempt(s)", attempt)
+		}
+		return
+	}
+}
+
+// reconnectDelay is ReconnectMinInterval doubled per failed attempt, capped
+// at ReconnectMaxInterval, then spread by ±ReconnectJitter
+func (c *Collector) reconnectDelay(attempt int) time.Duration {
+	delay := c.config.ReconnectMinInterval
+	for i := 1; i < attempt && delay < c.config.ReconnectMaxInterval; i++ {
+		delay *= 2
+	}
+	if delay > c.config.ReconnectMaxInterval {
+		delay = c.config.ReconnectMaxInterval
+	}
+
+	if c.config.ReconnectJitter > 0 {
+		spread := c.config.ReconnectJitter * (2*rand.Float64() - 1)
+		delay += time.Duration(float64(delay) * spread)
+	}
+	if delay < 0 {
+		delay = 0
+	}
+	return delay
+}
+
+func (c *Collector) onMessage(client mqtt.Client, msg mqtt.Message) {
+	c.healthMu.Lock()
+	c.lastMessage = time.Now()
+	c.healthMu.Unlock()
+
+	c.handlePayload(msg.Topic(), msg.Payload())
+}
+
+// handlePayload routes one MQTT payload by its frame format
+func (c *Collector) handlePayload(topic string, data []byte) {
+	format := c.config.FrameFormat
+	if format == "" || format == FrameFormatAuto {
+		format = detectFrameFormat(data)
+	} else if isJSONPayload(data) {
+		format = FrameFormatJSON
+	}
+
+	// Line-based gateway formats may batch several frames per message
+	if format != FrameFormatJSON {
+		frames, failed, _ := parseTextFrames(format, topic, data, time.Now())
+		if failed > 0 {
+			c.stats.RecordTextFrameErrors(failed)
+		}
+		for _, frame := range frames {
+			if format == FrameFormatYDWG {
+				msg, complete, dropped := c.fastPackets.add(frame)
+				if dropped {
+					c.stats.RecordFastPacketDropped()
+				}
+				if !complete {
+					continue
+				}
+				frame = msg
+			}
+			c.enqueue(frame)
+		}
+		return
+	}
+
+	// Parse JSON payload
+	var payload map[string]interface{}
+	if err := json.Unmarshal(data, &payload); err != nil {
+		// Not JSON, skip
+		return
+	}
+
+	// BoomSense IMU/meteo/wind readings bypass the N2K decode path
+	if c.handleSensorPayload(topic, payload) {
+		return
+	}
+
+	// Parse raw frame
+	frame := c.parseRawFrame(topic, payload)
+	if frame == nil {
+		return
+	}
+
+	c.enqueue(*frame)
+}
+
+// handleSensorPayload routes a BoomSense reading to the sink, reporting
+// false when the payload is not a sensor reading
+func (c *Collector) handleSensorPayload(topic string, payload map[string]interface{}) bool {
+	kind := sensorPayloadKind(topic, payload)
+	if kind == sensorPayloadNone {
+		return false
+	}
+
+	c.recordSensor(topic, payload)
+
+	c.sinkMu.RLock()
+	sink := c.sink
+	c.sinkMu.RUnlock()
+	if sink != nil && !routeSensorPayload(sink, kind, payload) {
+		c.stats.RecordSensorRejected()
+	}
+	return true
+}
+
+// enqueue hands a frame to the decoder workers
+func (c *Collector) enqueue(frame RawFrame) {
+	c.recordFrame(frame)
+
+	// Under load, frames of critical PGNs take the place of the rest
+	critical := c.gate.isCritical(frame.PGN, frame.ID, frame.Priority)
+	c.countOffer(offer(c.gate, c.rawFrames, frame, critical), c.stats.RecordFrameDropped)
+}
+
+func (c *Collector) parseRawFrame(topic string, payload map[string]interface{}) *RawFrame {
+	frame := &RawFrame{
+		Timestamp: payloadTime(payload),
+		Topic:     topic,
+	}
+
+	// The 29-bit CAN ID, when present, gives the full header; explicit
+	// pgn and src fields still take precedence over it
+	frame.Dest = 0xFF
+	if id, ok := payload["id"].(float64); ok && id >= 0 && id <= 0x1FFFFFFF {
+		frame.ID = uint32(id)
+		frame.Priority, frame.DP, frame.PF, frame.PS, frame.Source, frame.Dest, frame.PGN = ParseCANID(frame.ID)
+	} else {
+		// Try to compute from CAN ID components
+		dp, _ := payload["dp"].(float64)
+		pf, _ := payload["pf"].(float64)
+		ps, _ := payload["ps"].(float64)
+		frame.DP, frame.PF, frame.PS = uint8(dp), uint8(pf), uint8(ps)
+		frame.PGN = PGNFromParts(frame.DP, frame.PF, frame.PS)
+		if _, ok := payload["ps"]; ok && frame.PF < 240 {
+			frame.Dest = frame.PS
+		}
+	}
+
+	// Extract PGN
+	if pgn, ok := payload["pgn"].(float64); ok {
+		frame.PGN = int(pgn)
+	}
+
+	// Extract source address
+	if src, ok := payload["src"].(float64); ok {
+		frame.Source = uint8(src)
+	}
+
+	// Extract data
+	if dataStr, ok := payload["data"].(string); ok {
+		frame.Data = c.parseHexData(dataStr)
+	} else if dataArr, ok := payload["data"].([]interface{}); ok {
+		frame.Data = c.parseArrayData(dataArr)
+	} else {
+		return nil // No data, invalid frame
+	}
+
+	if len(frame.Data) == 0 {
+		return nil
+	}
+
+	frame.Length = len(frame.Data)
+
+	return frame
+}
+
+func (c *Collector) parseHexData(dataStr string) []byte {
+	// Remove common separators
+	cleaned := strings.ReplaceAll(dataStr, " ", "")
+	cleaned = strings.ReplaceAll(cleaned, ",", "")
+	cleaned = strings.ReplaceAll(cleaned, ":", "")
+	cleaned = strings.ReplaceAll(cleaned, "-", "")
+	cleaned = strings.ToLower(cleaned)
+
+	// Decode hex
+	data, err := hex.DecodeString(cleaned)
+	if err != nil {
+		return nil
+	}
+
+	return data
+}
+
+func (c *Collector) parseArrayData(dataArr []interface{}) []byte {
+	data := make([]byte, 0, len(dataArr))
+	for _, v := range dataArr {
+		if num, ok := v.(float64); ok {
+			data = append(data, byte(num))
+		}
+	}
+	return data
+}
+
+// dispatchFrames hands each frame from rawFrames to the worker owning its
+// source address. A full worker queue holds up the dispatcher, and so the
+// intake, rather than reordering frames. On shutdown it passes on what is
+// still queued before returning; the workers are still running then.
+func (c *Collector) dispatchFrames() {
+	defer c.dispatcher.Done()
+
+	for {
+		frame, ok := c.rawFrames.receive(c.done)
+		if !ok {
+			break
+		}
+		c.dispatch(frame)
+	}
+	for {
+		frame, ok := c.rawFrames.tryReceive()
+		if !ok {
+			return
+		}
+		c.dispatch(frame)
+	}
+}
+
+// dispatch queues a frame for the worker owning its source address
+func (c *Collector) dispatch(frame RawFrame) {
+	critical := c.gate.isCritical(frame.PGN, frame.ID, frame.Priority)
+	c.workerFrames[int(frame.Source)%len(c.workerFrames)].send(frame, critical, nil)
+}
+
+func (c *Collector) decodeWorker(id int, frames *priorityQueue[RawFrame]) {
+	defer c.decoders.Done()
+	log.Printf("[NMEA] Decoder worker %d started", id)
+
+	for {
+		frame, ok := frames.receive(c.decodeDone)
+		if !ok {
+			break
+		}
+		c.decodeFrame(frame)
+	}
+
+	// Decode what is still queued so it reaches storage
+	drained := 0
+	for {
+		frame, ok := frames.tryReceive()
+		if !ok {
+			log.Printf("[NMEA] Decoder worker %d stopped (drained %d frames)", id, drained)
+			return
+		}
+		c.decodeFrame(frame)
+		drained++
+	}
+}
+
+// decodeFrame decodes one raw frame and queues the result for storage
+func (c *Collector) decodeFrame(frame RawFrame) {
+	// Drop keep-alive frames before they reach stats or storage
+	if c.shouldDropEmpty(frame.PGN) && IsEmptyFrame(frame.Data) {
+		c.stats.RecordEmptyFrame()
+		return
+	}
+
+	// Decode the frame
+	fields, err := c.decoder.Decode(frame.PGN, frame.Data)
+
+	// Build decoded message
+	decoded := DecodedMessage{
+		Timestamp:   frame.Timestamp,
+		PGN:         frame.PGN,
+		PGNName:     GetPGNName(frame.PGN),
+		Source:      frame.Source,
+		Measurement: GetMeasurementType(frame.PGN),
+		Fields:      fields,
+		Raw:         frame.Data,
+		CANID:       frame.ID,
+		Priority:    frame.Priority,
+		Dest:        frame.Dest,
+	}
+
+	// Record statistics
+	success := err == nil && fields != nil && len(fields) > 0
+	c.stats.RecordMessage(frame.PGN, decoded.Measurement, success)
+
+	// Send to storage
+	c.sendDecoded(decoded)
+}
+
+// shouldDropEmpty reports whether empty-frame rejection is enabled for a PGN,
+// either globally or through the per-PGN opt-in list.
+func (c *Collector) shouldDropEmpty(pgn int) bool {
+	if c.config.DropEmptyFrames {
+		return true
+	}
+	for _, p := range c.config.DropEmptyPGNs {
+		if p == pgn {
+			return true
+		}
+	}
+	return false
+}
+
+func (c *Collector) storageWorker() {
+	defer c.workers.Done()
+	log.Printf("[NMEA] Storage worker started")
+
+	for {
+		msg, ok := c.decodedData.receive(c.storeDone)
+		if !ok {
+			break
+		}
+		c.store(msg)
+	}
+
+	// Drain decoded messages still queued so they reach the CSV files
+	drained := 0
+	for {
+		msg, ok := c.decodedData.tryReceive()
+		if !ok {
+			log.Printf("[NMEA] Storage worker stopped (drained %d messages)", drained)
+			return
+		}
+		c.store(msg)
+		drained++
+	}
+}
+
+// store pushes a decoded message to the ring buffer and CSV outputs
+func (c *Collector) store(msg DecodedMessage) {
+	// Convert to storage.DecodedMessage
+	storageMsg := storage.DecodedMessage{
+		Timestamp:   msg.Timestamp,
+		PGN:         msg.PGN,
+		PGNName:     msg.PGNName,
+		Source:      msg.Source,
+		Measurement: msg.Measurement,
+		Fields:      msg.Fields,
+		Raw:         msg.Raw,
+	}
+
+	// Store in ring buffer
+	if c.buffer != nil {
+		c.buffer.Push(storageMsg)
+	}
+
+	// NMEA 0183 messages carry a placeholder source, not a bus address
+	if msg.Raw != nil {
+		c.devices.Observe(msg)
+	}
+
+	// Write to CSV if enabled. NMEA 0183 messages have no CAN frame.
+	if c.csvWriter != nil {
+		if msg.Raw == nil {
+			c.csvWriter.WriteDecoded(storageMsg)
+			return
+		}
+		c.csvWriter.WriteFrame(storage.RawFrame{
+			Timestamp: msg.Timestamp,
+			ID:        msg.CANID,
+			PGN:       msg.PGN,
+			Priority:  msg.Priority,
+			Source:    msg.Source,
+			Dest:      msg.Dest,
+			Data:      msg.Raw,
+		})
+		c.csvWriter.WriteDecoded(storageMsg)
+	}
+}
+
+func (c *Collector) statsReporter() {
+	ticker := time.NewTicker(30 * time.Second)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			stats := c.stats.GetSnapshot()
+			log.Printf("[NMEA] Stats: %d msgs, %.1f msg/s, %.1f%% success, buffer: %d",
+				stats["messages_processed"],
+				stats["messages_per_sec"],
+				stats["success_rate"],
+				c.buffer.Size())
+
+			if c.csvWriter != nil {
+				c.csvWriter.WriteStats(stats)
+			}
+
+		case <-c.done:
+			return
+		}
+	}
+}
+
+// watchdog verifies the subscription is live: when connected but not
+// subscribed, or when no data has arrived for DataTimeout, it re-subscribes
+func (c *Collector) watchdog() {
+	if c.config.DataTimeout <= 0 {
+		return
+	}
+
+	ticker := time.NewTicker(c.config.DataTimeout / 2)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			if !c.IsConnected() {
+				continue
+			}
+
+			c.healthMu.Lock()
+			subscribed := c.subscribed
+			idle := time.Since(c.lastMessage)
+			c.healthMu.Unlock()
+
+			if subscribed && idle < c.config.DataTimeout {
+				continue
+			}
+
+			if subscribed {
+				log.Printf("[NMEA] No data for %s, verifying subscription", idle.Round(time.Second))
+			}
+
+			if err := c.subscribe(c.client); err != nil {
+				c.healthMu.Lock()
+				failures := c.subscribeFailures
+				c.healthMu.Unlock()
+				log.Printf("[MQTT] Re-subscribe failed (%d/%d): %v",
+					failures, c.config.MaxSubscribeFailures, err)
+			}
+
+		case <-c.done:
+			return
+		}
+	}
+}
+
+// Health reports connection, subscription and data-flow status
+func (c *Collector) Health() map[string]interface{} {
+	connected := c.IsConnected()
+
+	c.healthMu.Lock()
+	defer c.healthMu.Unlock()
+
+	idle := time.Since(c.lastMessage)
+	// Recordings may contain legitimate gaps, so replay never counts as stalled
+	stalled := connected && c.config.Source != SourceReplay &&
+		c.config.DataTimeout > 0 && idle > c.config.DataTimeout
+	// A single failed subscribe is retried by the watchdog; only a run of
+	// MaxSubscribeFailures in a row marks the collector unhealthy
+	healthy := connected && !stalled &&
+		c.subscribeFailures < c.config.MaxSubscribeFailures
+
+	return map[string]interface{}{
+		"healthy":            healthy,
+		"connected":          connected,
+		"subscribed":         c.subscribed,
+		"subscribe_failures": c.subscribeFailures,
+		"stalled":            stalled,
+		"idle_seconds":       idle.Seconds(),
+	}
+}
+
+// IsHealthy reports whether the collector is connected, subscribed and
+// receiving data
+func (c *Collector) IsHealthy() bool {
+	healthy, _ := c.Health()["healthy"].(bool)
+	return healthy
+}
+
+func (c *Collector) Buffer() BufferInterface {
+	return c.buffer
+}
+
+// PGNCoverage lists every PGN received with its activity and whether it is
+// decoded, so PGNs flowing on the bus without a decoder stand out
+func (c *Collector) PGNCoverage() []PGNInfo {
+	infos := c.stats.PGNActivity()
+	for i := range infos {
+		infos[i].Decoded = c.decoder.HasHandler(infos[i].PGN)
+	}
+	return infos
+}
+
+// Devices lists the source addresses seen on the bus with their product
+// information
+func (c *Collector) Devices() []DeviceInfo {
+	return c.devices.Devices()
+}
+
+// QueueDepth reports how many frames are waiting to be decoded and how many
+// decoded messages are waiting to be stored
+func (c *Collector) QueueDepth() (raw, decoded int) {
+	raw = c.rawFrames.Len()
+	for _, frames := range c.workerFrames {
+		raw += frames.Len()
+	}
+	return raw, c.decodedData.Len()
+}
+
+func (c *Collector) Stats() *Statistics {
+	return c.stats
+}
+
+func (c *Collector) IsConnected() bool {
+	if c.config.Source == SourceReplay {
+		c.healthMu.Lock()
+		defer c.healthMu.Unlock()
+		return c.replaying
+	}
+	return c.client != nil && c.client.IsConnected()
 }
\ No newline at end of file
@@ -0,0 +1,154 @@
+package nmea
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	mqtt "github.com/eclipse/paho.mqtt.golang"
+
+	"odysail-boat-viz/storage"
+)
+
+// TestCollectorStopDrains queues frames faster than the workers start and
+// checks Stop still decodes and stores every one of them
+func TestCollectorStopDrains(t *testing.T) {
+	for _, pinned := range []bool{false, true} {
+		cfg := DefaultConfig()
+		cfg.DecoderWorkers = 3
+		cfg.DecoderPinSources = pinned
+		cfg.QueueSize = 600
+		cfg.QueueHighWater = 0
+
+		buf := storage.NewRingBuffer(1000)
+		c := NewCollector(cfg, buf, nil)
+
+		base := time.Now()
+		const n = 500
+		for i := 0; i < n; i++ {
+			c.enqueue(RawFrame{
+				Timestamp: base.Add(time.Duration(i) * time.Millisecond),
+				PGN:       127257,
+				Source:    uint8(i % 5),
+				Data:      []byte{byte(i), 0, 0, 0, 0, 0, 0, 0xFF},
+			})
+		}
+
+		c.startWorkers()
+		start := time.Now()
+		c.Stop()
+
+		if got := buf.Size(); got != n {
+			t.Errorf("pinned=%v: %d of %d queued frames stored", pinned, got, n)
+		}
+		if raw, decoded := c.QueueDepth(); raw != 0 || decoded != 0 {
+			t.Errorf("pinned=%v: queues left at %d raw, %d decoded", pinned, raw, decoded)
+		}
+		if elapsed := time.Since(start); elapsed > stopDrainTimeout {
+			t.Errorf("pinned=%v: Stop took %v", pinned, elapsed)
+		}
+	}
+}
+
+// fakeToken completes at once with err
+type fakeToken struct {
+	mqtt.Token
+	err error
+}
+
+func (t fakeToken) WaitTimeout(time.Duration) bool { return true }
+func (t fakeToken) Error() error                   { return t.err }
+
+// fakeBroker is a connected client whose subscribes succeed until fail is set
+type fakeBroker struct {
+	mqtt.Client
+	fail bool
+}
+
+func (b *fakeBroker) IsConnected() bool { return true }
+func (b *fakeBroker) SubscribeMultiple(map[string]byte, mqtt.MessageHandler) mqtt.Token {
+	if b.fail {
+		return fakeToken{err: errors.New("not authorized")}
+	}
+	return fakeToken{}
+}
+
+func TestHealthSubscribeFailures(t *testing.T) {
+	cfg := DefaultConfig()
+	cfg.MaxSubscribeFailures = 3
+	c := NewCollector(cfg, nil, nil)
+	broker := &fakeBroker{}
+	c.client = broker
+
+	c.onConnect(broker)
+	if !c.IsHealthy() {
+		t.Fatalf("after subscribing: %v", c.Health())
+	}
+
+	// Re-subscribes on each reconnect fail; health holds until the limit
+	broker.fail = true
+	for i := 1; i <= cfg.MaxSubscribeFailures; i++ {
+		c.onConnect(broker)
+		health := c.Health()
+		if got := health["subscribe_failures"].(int); got != i {
+			t.Errorf("subscribe_failures = %d, want %d", got, i)
+		}
+		if want := i < cfg.MaxSubscribeFailures; health["healthy"] != want {
+			t.Errorf("after %d failures healthy = %v, want %v", i, health["healthy"], want)
+		}
+	}
+
+	// One successful subscribe resets the count
+	broker.fail = false
+	if err := c.subscribe(broker); err != nil {
+		t.Fatal(err)
+	}
+	if health := c.Health(); health["healthy"] != true || health["subscribe_failures"].(int) != 0 {
+		t.Errorf("after recovering: %v", health)
+	}
+}
+
+func TestIsEmptyFrame(t *testing.T) {
+	tests := []struct {
+		data []byte
+		want bool
+	}{
+		{nil, true},
+		{[]byte{0xFF, 0xFF, 0xFF, 0xFF, 0xFF, 0xFF, 0xFF, 0xFF}, true},
+		{[]byte{0xFF, 0xFF, 0xFF, 0xFF, 0xFF, 0xFF, 0xFF, 0xFE}, false},
+		{[]byte{0x00, 0x10, 0x27, 0x00, 0x00, 0xFA, 0xFF, 0xFF}, false},
+	}
+	for _, tt := range tests {
+		if got := IsEmptyFrame(tt.data); got != tt.want {
+			t.Errorf("IsEmptyFrame(% X) = %v, want %v", tt.data, got, tt.want)
+		}
+	}
+}
+
+func TestDropEmptyWindFrame(t *testing.T) {
+	keepAlive := RawFrame{PGN: 130306, Data: []byte{0xFF, 0xFF, 0xFF, 0xFF, 0xFF, 0xFF, 0xFF, 0xFF}}
+	tests := []struct {
+		name    string
+		all     bool
+		pgns    []int
+		dropped bool
+	}{
+		{"off", false, nil, false},
+		{"all PGNs", true, nil, true},
+		{"wind PGN", false, []int{130306}, true},
+		{"other PGN", false, []int{128267}, false},
+	}
+	for _, tt := range tests {
+		cfg := DefaultConfig()
+		cfg.DropEmptyFrames = tt.all
+		cfg.DropEmptyPGNs = tt.pgns
+		c := NewCollector(cfg, nil, nil)
+
+		c.decodeFrame(keepAlive)
+		_, stored := c.decodedData.tryReceive()
+		empty := c.stats.GetSnapshot()["empty_frames"].(int64)
+		if stored == tt.dropped || (empty == 1) != tt.dropped {
+			t.Errorf("%s: stored = %v, empty_frames = %d; want dropped = %v", tt.name, stored, empty, tt.dropped)
+		}
+	}
+}
@@ -0,0 +1,365 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"math"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"odysail-boat-viz/boomsense_sensor"
+	"odysail-boat-viz/nmea"
+)
+
+// AppConfig is the single application configuration. It starts from the
+// subsystem defaults, is overlaid by an optional JSON file, then by
+// environment variables.
+type AppConfig struct {
+	HTTPAddr string `json:"http_addr"`
+	DBPath   string `json:"db_path"`
+
+	// APIToken, when set, is required as a bearer token by the control and
+	// live-data endpoints
+	APIToken string `json:"api_token"`
+
+	// CORSOrigins lists the cross-origin frontends allowed to call /api/*,
+	// e.g. ["http://localhost:3000"]; empty means same-origin only and "*"
+	// allows any origin
+	CORSOrigins []string `json:"cors_origins"`
+
+	// MetricsEnabled serves Prometheus metrics on /metrics
+	MetricsEnabled bool `json:"metrics_enabled"`
+
+	// HealthRequireMQTT makes /healthz fail while the NMEA collector is
+	// disconnected; turn off when running offline on purpose
+	HealthRequireMQTT bool `json:"health_require_mqtt"`
+
+	NMEA nmea.Config `json:"nmea"`
+
+	// WindHeelCorrection projects masthead apparent wind to the horizontal
+	// plane using heel; disable for instruments that compensate themselves
+	WindHeelCorrection bool `json:"wind_heel_correction"`
+
+	// LeewayK is the hull coefficient for leeway = K * heel / boatspeed^2
+	LeewayK float64 `json:"leeway_k"`
+
+	// WindStatsWindow is the rolling window for gust/lull statistics
+	WindStatsWindow time.Duration `json:"wind_stats_window_ns"`
+
+	// SeaStateWindow is the attitude history sea state is classified over:
+	// flat below SeaModerateRMSDeg of roll or pitch RMS, rough from
+	// SeaRoughRMSDeg. Roll and pitch slower than SeaStateSmoothing (a tack)
+	// are not counted.
+	SeaStateWindow    time.Duration `json:"sea_state_window_ns"`
+	SeaStateSmoothing time.Duration `json:"sea_state_smoothing_ns"`
+	SeaModerateRMSDeg float64       `json:"sea_moderate_rms_deg"`
+	SeaRoughRMSDeg    float64       `json:"sea_rough_rms_deg"`
+
+	// SeaModerateGyroScale and SeaRoughGyroScale raise the sensor's gyro
+	// event thresholds in moderate and rough water, against wave motion
+	// passing for tacks and gybes; 1 leaves them as configured
+	SeaModerateGyroScale float64 `json:"sea_moderate_gyro_scale"`
+	SeaRoughGyroScale    float64 `json:"sea_rough_gyro_scale"`
+
+	// WindCorrectionPath is an optional JSON upwash correction table (TWA
+	// by TWS) applied to true wind angle; empty leaves it uncorrected
+	WindCorrectionPath string `json:"wind_correction_path"`
+
+	// SensorHeadingFallback uses the BoomSense magnetometer heading when no
+	// compass is on the bus. Enable only for a sensor mounted on the hull;
+	// one on the boom swings with it.
+	SensorHeadingFallback bool `json:"sensor_heading_fallback"`
+
+	// LogSuspectFactor flags the water speed log as suspect (fouled
+	// paddlewheel) when SOG exceeds it by this factor; 0 disables the check
+	LogSuspectFactor float64 `json:"log_suspect_factor"`
+
+	// BoatSpeedMaxAge is how old SOG may be before falling back to water
+	// speed (and water speed before reporting no boat speed); 0 disables the
+	// check
+	BoatSpeedMaxAge time.Duration `json:"boat_speed_max_age_ns"`
+
+	// PolarStreakPct is the polar percentage the achievement streak counts
+	// time at or above; dips below it last up to PolarStreakGrace before
+	// the streak resets
+	PolarStreakPct   float64       `json:"polar_streak_pct"`
+	PolarStreakGrace time.Duration `json:"polar_streak_grace_ns"`
+
+	SensorEnabled bool                    `json:"sensor_enabled"`
+	Sensor        boomsense_sensor.Config `json:"sensor"`
+}
+
+// DefaultAppConfigPath is read when present; a missing default file is not
+// an error
+const DefaultAppConfigPath = "odysail.json"
+
+func DefaultAppConfig() AppConfig {
+	return AppConfig{
+		HTTPAddr: ":8080",
+		DBPath:   "orc_boat_db.json",
+		LeewayK:  10.0,
+		NMEA:     nmea.DefaultConfig(),
+		Sensor:   boomsense_sensor.DefaultConfig(),
+
+		WindStatsWindow: 60 * time.Second,
+		BoatSpeedMaxAge: 5 * time.Second,
+
+		LogSuspectFactor: 2.0,
+
+		PolarStreakPct:   95.0,
+		PolarStreakGrace: 10 * time.Second,
+
+		SeaStateWindow:       60 * time.Second,
+		SeaStateSmoothing:    10 * time.Second,
+		SeaModerateRMSDeg:    2.0,
+		SeaRoughRMSDeg:       5.0,
+		SeaModerateGyroScale: 1.15,
+		SeaRoughGyroScale:    1.3,
+
+		HealthRequireMQTT: true,
+	}
+}
+
+// LoadAppConfig builds the configuration from defaults, the JSON file at
+// path (if any), environment overrides and finally the given overrides
+// (command-line flags), then validates it. An empty path falls back to
+// ODYSAIL_CONFIG and then DefaultAppConfigPath.
+func LoadAppConfig(path string, overrides ...func(*AppConfig)) (AppConfig, error) {
+	cfg := DefaultAppConfig()
+
+	explicit := path != ""
+	if !explicit {
+		if env := os.Getenv("ODYSAIL_CONFIG"); env != "" {
+			path = env
+			explicit = true
+		} else {
+			path = DefaultAppConfigPath
+		}
+	}
+
+	data, err := ioutil.ReadFile(path)
+	switch {
+	case err == nil:
+		// Decoding onto the defaults only overrides keys present in the file
+		if err := json.Unmarshal(data, &cfg); err != nil {
+			return cfg, fmt.Errorf("failed to parse config %s: %w", path, err)
+		}
+	case os.IsNotExist(err) && !explicit:
+		// No config file, run on defaults
+	default:
+		return cfg, fmt.Errorf("failed to read config %s: %w", path, err)
+	}
+
+	if err := cfg.applyEnv(); err != nil {
+		return cfg, err
+	}
+
+	for _, override := range overrides {
+		override(&cfg)
+	}
+
+	if err := cfg.Validate(); err != nil {
+		return cfg, err
+	}
+
+	return cfg, nil
+}
+
+// applyEnv overrides settings from ODYSAIL_* environment variables
+func (c *AppConfig) applyEnv() error {
+	str := func(name string, dst *string) {
+		if v, ok := os.LookupEnv(name); ok {
+			*dst = v
+		}
+	}
+
+	str("ODYSAIL_HTTP_ADDR", &c.HTTPAddr)
+	str("ODYSAIL_DB_PATH", &c.DBPath)
+	str("ODYSAIL_API_TOKEN", &c.APIToken)
+	str("ODYSAIL_WIND_CORRECTION_PATH", &c.WindCorrectionPath)
+	str("ODYSAIL_SOURCE", &c.NMEA.Source)
+	str("ODYSAIL_REPLAY_PATH", &c.NMEA.ReplayPath)
+	str("ODYSAIL_NMEA0183_INPUT", &c.NMEA.NMEA0183Input)
+	str("ODYSAIL_MQTT_BROKER", &c.NMEA.MQTTBroker)
+	str("ODYSAIL_FRAME_FORMAT", &c.NMEA.FrameFormat)
+	str("MQTT_USERNAME", &c.NMEA.MQTTUsername)
+	str("MQTT_PASSWORD", &c.NMEA.MQTTPassword)
+	str("ODYSAIL_MQTT_USERNAME", &c.NMEA.MQTTUsername)
+	str("ODYSAIL_MQTT_PASSWORD", &c.NMEA.MQTTPassword)
+	str("ODYSAIL_CSV_FRAMES_PATH", &c.NMEA.CSVFramesPath)
+	str("ODYSAIL_CSV_DECODED_PATH", &c.NMEA.CSVDecodedPath)
+	str("ODYSAIL_OUTPUT_FORMAT", &c.NMEA.OutputFormat)
+	str("ODYSAIL_JSONL_DECODED_PATH", &c.NMEA.JSONLDecodedPath)
+	str("ODYSAIL_CSV_STATS_PATH", &c.NMEA.CSVStatsPath)
+	str("ODYSAIL_BUFFER_SNAPSHOT_PATH", &c.NMEA.BufferSnapshotPath)
+	str("ODYSAIL_RECORD_DIR", &c.NMEA.RecordDir)
+	str("ODYSAIL_INFLUX_URL", &c.NMEA.InfluxURL)
+	str("ODYSAIL_INFLUX_ORG", &c.NMEA.InfluxOrg)
+	str("ODYSAIL_INFLUX_BUCKET", &c.NMEA.InfluxBucket)
+	str("ODYSAIL_INFLUX_TOKEN", &c.NMEA.InfluxToken)
+
+	if v, ok := os.LookupEnv("ODYSAIL_MQTT_TOPIC"); ok {
+		c.NMEA.MQTTTopic = nmea.ParseTopicList(v)
+	}
+
+	if v, ok := os.LookupEnv("ODYSAIL_CORS_ORIGINS"); ok {
+		c.CORSOrigins = strings.Split(v, ",")
+	}
+
+	if v, ok := os.LookupEnv("ODYSAIL_MQTT_PORT"); ok {
+		port, err := strconv.Atoi(v)
+		if err != nil {
+			return fmt.Errorf("invalid ODYSAIL_MQTT_PORT: %q", v)
+		}
+		c.NMEA.MQTTPort = port
+	}
+
+	if v, ok := os.LookupEnv("ODYSAIL_DEPTH_OFFSET_M"); ok {
+		offset, err := strconv.ParseFloat(v, 64)
+		if err != nil {
+			return fmt.Errorf("invalid ODYSAIL_DEPTH_OFFSET_M: %q", v)
+		}
+		c.NMEA.DepthOffsetM = offset
+	}
+
+	for name, dst := range map[string]*bool{
+		"ODYSAIL_MQTT_TLS":             &c.NMEA.UseTLS,
+		"ODYSAIL_MQTT_RETRY_FOREVER":   &c.NMEA.ConnectRetryForever,
+		"ODYSAIL_CSV_ENABLED":          &c.NMEA.EnableCSV,
+		"ODYSAIL_SENSOR_ENABLED":       &c.SensorEnabled,
+		"ODYSAIL_METRICS_ENABLED":      &c.MetricsEnabled,
+		"ODYSAIL_HEALTH_REQUIRE_MQTT":  &c.HealthRequireMQTT,
+		"ODYSAIL_MARK_INVALID_FIELDS":  &c.NMEA.MarkInvalidFields,
+		"ODYSAIL_WIND_HEEL_CORRECTION": &c.WindHeelCorrection,
+	} {
+		if v, ok := os.LookupEnv(name); ok {
+			b, err := strconv.ParseBool(v)
+			if err != nil {
+				return fmt.Errorf("invalid %s: %q", name, v)
+			}
+			*dst = b
+		}
+	}
+
+	return nil
+}
+
+// Validate checks every subsystem and reports all problems at once
+func (c AppConfig) Validate() error {
+	var problems []string
+	check := func(ok bool, msg string) {
+		if !ok {
+			problems = append(problems, msg)
+		}
+	}
+
+	check(c.HTTPAddr != "", "http_addr is required")
+	check(c.DBPath != "", "db_path is required")
+	check(c.LeewayK > 0, "leeway_k must be positive")
+	check(c.WindStatsWindow > 0, "wind_stats_window_ns must be positive")
+	check(c.BoatSpeedMaxAge >= 0, "boat_speed_max_age_ns must not be negative")
+	check(c.SeaStateWindow > 0, "sea_state_window_ns must be positive")
+	check(c.SeaStateSmoothing > 0 && c.SeaStateSmoothing < c.SeaStateWindow,
+		"sea_state_smoothing_ns must be positive and below sea_state_window_ns")
+	check(c.SeaModerateRMSDeg > 0 && c.SeaRoughRMSDeg > c.SeaModerateRMSDeg,
+		"sea_moderate_rms_deg must be positive and below sea_rough_rms_deg")
+	check(c.SeaModerateGyroScale >= 1 && c.SeaRoughGyroScale >= 1,
+		"sea_moderate_gyro_scale and sea_rough_gyro_scale must be at least 1")
+	check(c.LogSuspectFactor == 0 || c.LogSuspectFactor > 1, "log_suspect_factor must be 0 (off) or greater than 1")
+	check(c.PolarStreakPct > 0 && c.PolarStreakPct <= 150, "polar_streak_pct must be above 0 and at most 150")
+	check(c.PolarStreakGrace >= 0, "polar_streak_grace_ns must not be negative")
+
+	n := c.NMEA
+	switch n.Source {
+	case nmea.SourceMQTT:
+		check(n.MQTTBroker != "", "nmea.mqtt_broker is required")
+		check(n.MQTTPort > 0 && n.MQTTPort < 65536, fmt.Sprintf("nmea.mqtt_port out of range: %d", n.MQTTPort))
+		check(len(n.MQTTTopic) > 0, "nmea.mqtt_topic is required")
+		switch n.FrameFormat {
+		case nmea.FrameFormatAuto, nmea.FrameFormatJSON, nmea.FrameFormatActisense, nmea.FrameFormatYDWG:
+		default:
+			check(false, fmt.Sprintf("nmea.frame_format must be \"auto\", \"json\", \"actisense\" or \"ydwg\", got %q", n.FrameFormat))
+		}
+		check(n.ReconnectMinInterval > 0 && n.ReconnectMaxInterval >= n.ReconnectMinInterval,
+			"nmea.reconnect_min_interval_ns must be positive and no more than nmea.reconnect_max_interval_ns")
+		check(n.ReconnectJitter >= 0 && n.ReconnectJitter <= 1, "nmea.reconnect_jitter must be between 0 and 1")
+	case nmea.SourceReplay:
+		check(n.ReplayPath != "", "nmea.replay_path is required when source is \"replay\"")
+		check(n.ReplaySpeed >= 0, "nmea.replay_speed must not be negative")
+	default:
+		check(false, fmt.Sprintf("nmea.source must be \"mqtt\" or \"replay\", got %q", n.Source))
+	}
+	if n.InfluxURL != "" {
+		check(n.InfluxOrg != "" && n.InfluxBucket != "",
+			"nmea.influx_org and nmea.influx_bucket are required when influx_url is set")
+	}
+	check(n.BufferSize > 0, "nmea.buffer_size must be positive")
+	check(n.BufferDuration >= 0, "nmea.buffer_duration_ns must not be negative")
+	if n.BufferDuration > 0 {
+		check(n.BufferRateHz > 0, "nmea.buffer_rate_hz must be positive when buffer_duration_ns is set")
+	}
+	check(n.DataTimeout == 0 || n.DataTimeout >= time.Second,
+		"nmea.data_timeout_ns must be 0 (off) or at least 1s")
+	check(n.MaxSubscribeFailures > 0, "nmea.max_subscribe_failures must be positive")
+	check(n.DecoderWorkers >= 0, "nmea.decoder_workers must not be negative (0 = one per CPU)")
+	check(n.QueueSize > 0, "nmea.queue_size must be positive")
+	check(n.QueueHighWater >= 0 && n.QueueHighWater <= 1, "nmea.queue_high_water must be between 0 and 1")
+	check(math.Abs(n.DepthOffsetM) <= 30, "nmea.depth_offset_m must be within ±30 m")
+	if n.EnableCSV {
+		check(n.CSVFramesPath != "" && n.CSVDecodedPath != "" && n.CSVStatsPath != "",
+			"nmea csv paths are required when enable_csv is set")
+		check(n.CSVMaxSizeBytes >= 0, "nmea.csv_max_size_bytes must not be negative")
+		check(n.CSVPrecision >= -1 && n.CSVPrecision <= 15, "nmea.csv_precision must be between -1 (shortest) and 15")
+		for measurement, p := range n.CSVPrecisionByMeasurement {
+			check(p >= -1 && p <= 15,
+				fmt.Sprintf("nmea.csv_precision_by_measurement.%s must be between -1 (shortest) and 15", measurement))
+		}
+		switch n.OutputFormat {
+		case nmea.OutputFormatCSV:
+		case nmea.OutputFormatJSONL:
+			check(n.JSONLDecodedPath != "", "nmea.jsonl_decoded_path is required when output_format is \"jsonl\"")
+		default:
+			check(false, fmt.Sprintf("nmea.output_format must be \"csv\" or \"jsonl\", got %q", n.OutputFormat))
+		}
+	}
+
+	s := c.Sensor
+	check(s.BoomAxis == "roll" || s.BoomAxis == "pitch",
+		fmt.Sprintf("sensor.boom_axis must be \"roll\" or \"pitch\", got %q", s.BoomAxis))
+	check(s.FilterType == "complementary" || s.FilterType == "madgwick" || s.FilterType == "mahony",
+		fmt.Sprintf("sensor.filter_type must be \"complementary\", \"madgwick\" or \"mahony\", got %q", s.FilterType))
+	if s.FilterType == "mahony" {
+		check(s.MahonyKp > 0 && s.MahonyKi >= 0, "sensor.mahony_kp must be positive and sensor.mahony_ki not negative")
+	}
+	check(s.EulerTau > 0, "sensor.euler_tau must be positive")
+	check(s.TauRoll >= 0 && s.TauPitch >= 0, "sensor.tau_roll and sensor.tau_pitch must not be negative (0 = euler_tau)")
+	check(s.MountRotationDeg >= -180 && s.MountRotationDeg <= 180,
+		"sensor.mount_rotation_deg must be between -180 and 180")
+	check(s.TargetHz >= 0, "sensor.target_hz must not be negative")
+	check(s.CalibrationMinQuality >= 0 && s.CalibrationMinQuality <= 100,
+		"sensor.calibration_min_quality must be between 0 and 100")
+	check(s.AccelFullScaleG >= 0 && s.ClipEpsilonG >= 0,
+		"sensor.accel_full_scale_g and sensor.clip_epsilon_g must not be negative")
+	if s.AccelFullScaleG > 0 {
+		check(s.ClipEpsilonG < s.AccelFullScaleG, "sensor.clip_epsilon_g must be less than sensor.accel_full_scale_g")
+	}
+	check(s.BoomSmoothWindow == 0 || s.BoomSmoothWindow >= 3,
+		"sensor.boom_smooth_window must be 0 (off) or at least 3")
+	if s.GyroBiasTracking {
+		check(s.BiasAccelTolerance > 0 && s.BiasGyroTolerance > 0,
+			"sensor bias tolerances must be positive when gyro_bias_tracking is set")
+	}
+	check(s.MaxBufferSize > 0, "sensor.max_buffer_size must be positive")
+	check(s.EventHistory >= 0, "sensor.event_history must not be negative")
+	if err := s.ValidateThresholds(); err != nil {
+		check(false, "sensor."+err.Error())
+	}
+
+	if len(problems) > 0 {
+		return fmt.Errorf("invalid config: %s", strings.Join(problems, "; "))
+	}
+	return nil
+}
@@ -0,0 +1,119 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func writeConfig(t *testing.T, body string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "odysail.json")
+	if err := os.WriteFile(path, []byte(body), 0644); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestLoadAppConfigFull(t *testing.T) {
+	path := writeConfig(t, `{
+		"http_addr": ":9090",
+		"db_path": "boats.json",
+		"leeway_k": 9,
+		"polar_streak_pct": 90,
+		"polar_streak_grace_ns": 5000000000,
+		"nmea": {
+			"mqtt_broker": "broker.local",
+			"mqtt_port": 8883,
+			"mqtt_topic": ["n2k/a", "n2k/b"],
+			"csv_frames_path": "log/frames.csv",
+			"csv_decoded_path": "log/decoded.csv",
+			"csv_stats_path": "log/stats.csv",
+			"data_timeout_ns": 10000000000,
+			"units": {"depth": "ft"}
+		},
+		"sensor": {"crash_gy_dps": 150, "boom_axis": "pitch"}
+	}`)
+	t.Setenv("ODYSAIL_MQTT_PASSWORD", "secret")
+
+	cfg, err := LoadAppConfig(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if cfg.HTTPAddr != ":9090" || cfg.DBPath != "boats.json" || cfg.LeewayK != 9 {
+		t.Errorf("top level = %q %q %g", cfg.HTTPAddr, cfg.DBPath, cfg.LeewayK)
+	}
+	if cfg.PolarStreakPct != 90 || cfg.PolarStreakGrace != 5*time.Second {
+		t.Errorf("polar streak = %g%% grace %v", cfg.PolarStreakPct, cfg.PolarStreakGrace)
+	}
+
+	n := cfg.NMEA
+	if n.MQTTBroker != "broker.local" || n.MQTTPort != 8883 || len(n.MQTTTopic) != 2 || n.MQTTPassword != "secret" {
+		t.Errorf("mqtt = %s:%d %v password %q", n.MQTTBroker, n.MQTTPort, n.MQTTTopic, n.MQTTPassword)
+	}
+	if n.CSVFramesPath != "log/frames.csv" || n.CSVDecodedPath != "log/decoded.csv" || n.CSVStatsPath != "log/stats.csv" {
+		t.Errorf("csv paths = %q %q %q", n.CSVFramesPath, n.CSVDecodedPath, n.CSVStatsPath)
+	}
+	if n.DataTimeout != 10*time.Second {
+		t.Errorf("data timeout = %v", n.DataTimeout)
+	}
+	// Keys absent from the file keep their defaults, also inside sections
+	if n.QueueSize != 1000 || n.Units.Depth != "ft" || n.Units.Speed != "kts" {
+		t.Errorf("defaults lost: queue %d units %+v", n.QueueSize, n.Units)
+	}
+
+	if cfg.Sensor.CrashGyDPS != 150 || cfg.Sensor.BoomAxis != "pitch" || cfg.Sensor.TackGyMin != 15 {
+		t.Errorf("sensor = crash %g axis %q tack min %g", cfg.Sensor.CrashGyDPS, cfg.Sensor.BoomAxis, cfg.Sensor.TackGyMin)
+	}
+}
+
+func TestAppConfigValidate(t *testing.T) {
+	tests := []struct {
+		name string
+		edit func(*AppConfig)
+		want string // substring of the error, "" for valid
+	}{
+		{"defaults", func(*AppConfig) {}, ""},
+		{"data timeout off", func(c *AppConfig) { c.NMEA.DataTimeout = 0 }, ""},
+		{"data timeout 1s", func(c *AppConfig) { c.NMEA.DataTimeout = time.Second }, ""},
+		{"data timeout 1ns", func(c *AppConfig) { c.NMEA.DataTimeout = 1 }, "nmea.data_timeout_ns"},
+		{"data timeout 500ms", func(c *AppConfig) { c.NMEA.DataTimeout = 500 * time.Millisecond }, "nmea.data_timeout_ns"},
+		{"data timeout negative", func(c *AppConfig) { c.NMEA.DataTimeout = -time.Second }, "nmea.data_timeout_ns"},
+		{"mqtt port", func(c *AppConfig) { c.NMEA.MQTTPort = 0 }, "nmea.mqtt_port"},
+		{"max subscribe failures", func(c *AppConfig) { c.NMEA.MaxSubscribeFailures = 0 }, "nmea.max_subscribe_failures"},
+		{"polar streak pct zero", func(c *AppConfig) { c.PolarStreakPct = 0 }, "polar_streak_pct"},
+		{"polar streak pct 200", func(c *AppConfig) { c.PolarStreakPct = 200 }, "polar_streak_pct"},
+		{"polar streak no grace", func(c *AppConfig) { c.PolarStreakGrace = 0 }, ""},
+		{"polar streak grace negative", func(c *AppConfig) { c.PolarStreakGrace = -time.Second }, "polar_streak_grace_ns"},
+		{"clip epsilon at full scale", func(c *AppConfig) { c.Sensor.ClipEpsilonG = c.Sensor.AccelFullScaleG }, "sensor.clip_epsilon_g"},
+		{"clip epsilon, clipping off", func(c *AppConfig) { c.Sensor.AccelFullScaleG, c.Sensor.ClipEpsilonG = 0, 1 }, ""},
+		{"boom axis", func(c *AppConfig) { c.Sensor.BoomAxis = "yaw" }, "sensor.boom_axis"},
+	}
+	for _, tt := range tests {
+		cfg := DefaultAppConfig()
+		tt.edit(&cfg)
+		err := cfg.Validate()
+		switch {
+		case tt.want == "" && err != nil:
+			t.Errorf("%s: %v", tt.name, err)
+		case tt.want != "" && (err == nil || !strings.Contains(err.Error(), tt.want)):
+			t.Errorf("%s: error %v, want one naming %s", tt.name, err, tt.want)
+		}
+	}
+}
+
+func TestLoadAppConfigErrors(t *testing.T) {
+	if _, err := LoadAppConfig(writeConfig(t, `{"nmea": {"mqtt_port": 0}, "sensor": {"boom_axis": "yaw"}}`)); err == nil ||
+		!strings.Contains(err.Error(), "mqtt_port") || !strings.Contains(err.Error(), "boom_axis") {
+		t.Errorf("every problem should be reported at once, got %v", err)
+	}
+	if _, err := LoadAppConfig(writeConfig(t, `{"http_addr": `)); err == nil {
+		t.Error("malformed file accepted")
+	}
+	if _, err := LoadAppConfig(filepath.Join(t.TempDir(), "missing.json")); err == nil {
+		t.Error("missing explicit file accepted")
+	}
+}
@@ -0,0 +1,82 @@
+package main
+
+import (
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+// corsPolicy decides which cross-origin frontends may call the API. With
+// no origins configured only same-origin pages can read responses; "*"
+// allows any origin.
+type corsPolicy struct {
+	any     bool
+	origins map[string]bool
+}
+
+func newCORSPolicy(origins []string) *corsPolicy {
+	p := &corsPolicy{origins: make(map[string]bool)}
+	for _, o := range origins {
+		o = strings.TrimRight(strings.TrimSpace(o), "/")
+		switch o {
+		case "":
+		case "*":
+			p.any = true
+		default:
+			p.origins[o] = true
+		}
+	}
+	return p
+}
+
+func (p *corsPolicy) allowed(origin string) bool {
+	return p.any || p.origins[origin]
+}
+
+// checkOrigin is the WebSocket upgrade check: same-origin pages and
+// non-browser clients (no Origin header) are always accepted
+func (p *corsPolicy) checkOrigin(r *http.Request) bool {
+	origin := r.Header.Get("Origin")
+	if origin == "" {
+		return true
+	}
+	if u, err := url.Parse(origin); err == nil && strings.EqualFold(u.Host, r.Host) {
+		return true
+	}
+	return p.allowed(origin)
+}
+
+// corsHandler adds CORS headers to /api/* responses for allowed origins and
+// answers preflight requests itself, before routing, so a preflight never
+// needs the API token
+func corsHandler(p *corsPolicy, next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		origin := r.Header.Get("Origin")
+		if origin == "" || !strings.HasPrefix(r.URL.Path, "/api/") {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		w.Header().Add("Vary", "Origin")
+		allowed := p.allowed(origin)
+		if allowed {
+			if p.any {
+				w.Header().Set("Access-Control-Allow-Origin", "*")
+			} else {
+				w.Header().Set("Access-Control-Allow-Origin", origin)
+			}
+		}
+
+		if r.Method == http.MethodOptions && r.Header.Get("Access-Control-Request-Method") != "" {
+			if allowed {
+				w.Header().Set("Access-Control-Allow-Methods", "GET, POST, OPTIONS")
+				w.Header().Set("Access-Control-Allow-Headers", "Authorization, Content-Type")
+				w.Header().Set("Access-Control-Max-Age", "600")
+			}
+			w.WriteHeader(http.StatusNoContent)
+			return
+		}
+
+		next.ServeHTTP(w, r)
+	})
+}
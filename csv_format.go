@@ -0,0 +1,69 @@
+package storage
+
+import (
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"strconv"
+)
+
+// decodedFieldAllowed reports whether field belongs in the decoded log
+// under the Fields allowlist
+func (w *CSVWriter) decodedFieldAllowed(field string) bool {
+	if len(w.Fields) == 0 {
+		return true
+	}
+	for _, name := range w.Fields {
+		if name == field {
+			return true
+		}
+	}
+	return false
+}
+
+// precisionFor returns the float precision for a measurement
+func (w *CSVWriter) precisionFor(measurement string) int {
+	if p, ok := w.PrecisionByMeasurement[measurement]; ok {
+		return p
+	}
+	return w.Precision
+}
+
+// formatDecodedValue renders a decoded field value for the value column.
+// Floats are written with precision decimals (-1 = shortest exact form);
+// lists and records (satellites, route waypoints) are JSON encoded, with
+// NaN and Inf written as null, or skipped when skipComposite is set. ok is
+// false for a skipped value.
+func formatDecodedValue(value interface{}, precision int, skipComposite bool) (string, bool) {
+	switch v := value.(type) {
+	case nil:
+		// Sent as "not available"; an empty value rather than "<nil>"
+		return "", true
+	case float64:
+		return formatFloat(v, precision, 64), true
+	case float32:
+		return formatFloat(float64(v), precision, 32), true
+	case string:
+		return v, true
+	}
+
+	switch reflect.ValueOf(value).Kind() {
+	case reflect.Map, reflect.Slice, reflect.Array, reflect.Struct:
+		if skipComposite {
+			return "", false
+		}
+		data, err := json.Marshal(finiteJSON(value))
+		if err != nil {
+			return "", false
+		}
+		return string(data), true
+	}
+	return fmt.Sprintf("%v", value), true
+}
+
+func formatFloat(v float64, precision, bitSize int) string {
+	if precision < 0 {
+		return strconv.FormatFloat(v, 'g', -1, bitSize)
+	}
+	return strconv.FormatFloat(v, 'f', precision, bitSize)
+}
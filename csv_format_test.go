@@ -0,0 +1,92 @@
+package storage
+
+import (
+	"encoding/csv"
+	"math"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestFormatDecodedValue(t *testing.T) {
+	satellites := []map[string]interface{}{
+		{"prn": 3, "snr": 40.5},
+		{"prn": 7, "snr": math.NaN()},
+	}
+	tests := []struct {
+		name          string
+		value         interface{}
+		precision     int
+		skipComposite bool
+		want          string
+		wantOK        bool
+	}{
+		{"shortest float", 1.23456789, -1, false, "1.23456789", true},
+		{"fixed precision", 1.23456789, 2, false, "1.23", true},
+		{"precision pads", 12.5, 3, false, "12.500", true},
+		{"float32", float32(0.1), -1, false, "0.1", true},
+		{"integer", uint8(2), 2, false, "2", true},
+		{"string", "gnss fix", -1, false, "gnss fix", true},
+		{"not available", nil, -1, false, "", true},
+		{"list of records", satellites[:1], -1, false, `[{"prn":3,"snr":40.5}]`, true},
+		{"NaN in a record", satellites, -1, false, `[{"prn":3,"snr":40.5},{"prn":7,"snr":null}]`, true},
+		{"Inf in a list", []float64{1, math.Inf(1)}, -1, false, `[1,null]`, true},
+		{"composite skipped", satellites, -1, true, "", false},
+	}
+	for _, tt := range tests {
+		got, ok := formatDecodedValue(tt.value, tt.precision, tt.skipComposite)
+		if got != tt.want || ok != tt.wantOK {
+			t.Errorf("%s: formatDecodedValue = %q, %v; want %q, %v", tt.name, got, ok, tt.want, tt.wantOK)
+		}
+	}
+}
+
+func TestWriteDecodedFormatting(t *testing.T) {
+	dir := t.TempDir()
+	decodedPath := filepath.Join(dir, "decoded.csv")
+	w := NewCSVWriter(filepath.Join(dir, "frames.csv"), decodedPath, filepath.Join(dir, "stats.csv"))
+	w.Precision = 2
+	w.PrecisionByMeasurement = map[string]int{"position": 7}
+	w.Fields = []string{"hdop", "latitude", "satellites"}
+
+	now := time.Now()
+	w.WriteDecoded(DecodedMessage{Timestamp: now, PGN: 129029, Measurement: "gnss", Fields: map[string]interface{}{
+		"hdop":       1.23456789,
+		"integrity":  uint8(2),
+		"satellites": []map[string]interface{}{{"prn": 3, "snr": math.NaN()}},
+	}})
+	w.WriteDecoded(DecodedMessage{Timestamp: now, PGN: 129025, Measurement: "position", Fields: map[string]interface{}{
+		"latitude":  50.795012345,
+		"longitude": -1.107212345,
+	}})
+	w.Close()
+
+	f, err := os.Open(decodedPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	records, err := csv.NewReader(f).ReadAll()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	got := map[string]string{}
+	for _, record := range records[1:] {
+		got[record[6]] = record[7]
+	}
+	want := map[string]string{
+		"hdop":       "1.23",
+		"satellites": `[{"prn":3,"snr":null}]`,
+		"latitude":   "50.7950123",
+	}
+	if len(got) != len(want) {
+		t.Errorf("logged fields %v, want only %v", got, want)
+	}
+	for field, value := range want {
+		if got[field] != value {
+			t.Errorf("%s = %q, want %q", field, got[field], value)
+		}
+	}
+}
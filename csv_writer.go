@@ -1,95 +1,334 @@
-package storage
-
-import (
-	"encoding/csv"
-	"fmt"
-	"os"
-	"path/filepath"
-	"time"
-)
-
-// DecodedMessage is a local copy to avoid circular import
-type DecodedMessage struct {
-	Timestamp   time.Time
-	PGN         int
-	PGNName     string
-	Source      uint8
-	Measurement string
-	Fields      map[string]interface{}
-	Raw         []byte
-}
-
-type CSVWriter struct {
-	framesFile  *os.File
-	decodedFile *os.File
-	statsFile   *os.File
-
-	framesWriter  *csv.Writer
-	decodedWriter *csv.Writer
-	statsWriter   *csv.Writer
-}
-
-func NewCSVWriter(framesPath, decodedPath, statsPath string) *CSVWriter {
-	// Create data directory if needed
-	os.MkdirAll(filepath.Dir(framesPath), 0755)
-
-	w := &CSVWriter{}
-
-	// Open files
-	w.framesFile, _ = os.OpenFile(framesPath, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0644)
-	w.decodedFile, _ = os.OpenFile(decodedPath, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0644)
-	w.statsFile, _ = os.OpenFile(statsPath, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0644)
-
-	w.framesWriter = csv.NewWriter(w.framesFile)
-	w.decodedWriter = csv.NewWriter(w.decodedFile)
-	w.statsWriter = csv.NewWriter(w.statsFile)
-
-	// Write headers if files are new
-	w.writeHeaders()
-
-	return w
-}
-
-func (w *CSVWriter) writeHeaders() {
-	// Check file size, if 0 write headers
-	info, _ := w.decodedFile.Stat()
-	if info.Size() == 0 {
-		w.decodedWriter.Write([]string{
-			"iso8601", "ts_ms", "measurement", "pgn", "pgn_name",
-			"source", "field", "value",
-		})
-		w.decodedWriter.Flush()
-	}
-}
-
-func (w *CSVWriter) WriteDecoded(msg DecodedMessage) {
-	for field, value := range msg.Fields {
-		row := []string{
-			msg.Timestamp.Format(time.RFC3339),
-			fmt.Sprintf("%d", msg.Timestamp.UnixMilli()),
-			msg.Measurement,
-			fmt.Sprintf("%d", msg.PGN),
-			msg.PGNName,
-			fmt.Sprintf("%d", msg.Source),
-			field,
-			fmt.Sprintf("%v", value),
-		}
-		w.decodedWriter.Write(row)
-	}
-	w.decodedWriter.Flush()
-}
-
-func (w *CSVWriter) Close() {
-	if w.framesWriter != nil {
-		w.framesWriter.Flush()
-		w.framesFile.Close()
-	}
-	if w.decodedWriter != nil {
-		w.decodedWriter.Flush()
-		w.decodedFile.Close()
-	}
-	if w.statsWriter != nil {
-		w.statsWriter.Flush()
-		w.statsFile.Close()
-	}
-}
\ No newline at end of file
+package storage
+
+import (
+	"bytes"
+	"encoding/csv"
+	"encoding/hex"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// DecodedMessage is a local copy to avoid circular import
+type DecodedMessage struct {
+	Timestamp   time.Time
+	PGN         int
+	PGNName     string
+	Source      uint8
+	Measurement string
+	Fields      map[string]interface{}
+	Raw         []byte
+}
+
+// RawFrame is a local copy of the CAN frame header and payload for the
+// frames log (avoids circular import)
+type RawFrame struct {
+	Timestamp time.Time
+	ID        uint32
+	PGN       int
+	Priority  uint8
+	Source    uint8
+	Dest      uint8
+	Data      []byte
+}
+
+type CSVWriter struct {
+	// MaxSizeBytes rotates a file once the next write would take it past
+	// this size (0 disables size rotation)
+	MaxSizeBytes int64
+	// RotateDaily rotates a file when the UTC date changes
+	RotateDaily bool
+
+	// Precision is the number of decimals float values are written with in
+	// the decoded log (-1 = shortest exact form); PrecisionByMeasurement
+	// overrides it for a measurement, e.g. {"position": 7}
+	Precision              int
+	PrecisionByMeasurement map[string]int
+	// SkipComposite leaves out list and record values (satellites, route
+	// waypoints) instead of writing them as JSON
+	SkipComposite bool
+	// Fields, when not empty, limits the decoded log to these field names
+	Fields []string
+
+	frames  *csvFile
+	decoded *csvFile
+	stats   *csvFile
+
+	mu sync.Mutex
+}
+
+// csvFile is a single append-only CSV output with rotation bookkeeping
+type csvFile struct {
+	path   string
+	header []string
+	file   *os.File
+	size   int64
+	day    string
+}
+
+var (
+	framesHeader = []string{
+		"iso8601", "ts_ms", "can_id", "pgn", "priority",
+		"source", "dest", "length", "data_hex",
+	}
+	decodedHeader = []string{
+		"iso8601", "ts_ms", "measurement", "pgn", "pgn_name",
+		"source", "field", "value",
+	}
+	statsHeader = []string{
+		"iso8601", "ts_ms", "metric", "value",
+	}
+)
+
+func NewCSVWriter(framesPath, decodedPath, statsPath string) *CSVWriter {
+	// Create data directory if needed
+	os.MkdirAll(filepath.Dir(framesPath), 0755)
+
+	// Open files
+	return &CSVWriter{
+		frames:  openCSVFile(framesPath, framesHeader),
+		decoded: openCSVFile(decodedPath, decodedHeader),
+		stats:   openCSVFile(statsPath, statsHeader),
+
+		Precision: -1,
+	}
+}
+
+// openCSVFile opens path for appending and writes the header if the file
+// is new. Returns nil if the file cannot be opened.
+func openCSVFile(path string, header []string) *csvFile {
+	f := &csvFile{path: path, header: header}
+	if err := f.open(); err != nil {
+		log.Printf("[CSV] Failed to open %s: %v", path, err)
+		return nil
+	}
+	return f
+}
+
+func (f *csvFile) open() error {
+	file, err := os.OpenFile(f.path, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0644)
+	if err != nil {
+		return err
+	}
+	info, err := file.Stat()
+	if err != nil {
+		file.Close()
+		return err
+	}
+
+	f.file = file
+	f.size = info.Size()
+	f.day = info.ModTime().UTC().Format("2006-01-02")
+
+	// Write headers if file is new
+	if f.size == 0 {
+		f.day = time.Now().UTC().Format("2006-01-02")
+		if len(f.header) > 0 {
+			return f.append(encodeCSV([][]string{f.header}))
+		}
+	}
+	return nil
+}
+
+func (f *csvFile) append(data []byte) error {
+	n, err := f.file.Write(data)
+	f.size += int64(n)
+	return err
+}
+
+// write appends rows, rotating first if the size limit or date requires it
+func (f *csvFile) write(rows [][]string, maxSize int64, daily bool) {
+	f.writeData(encodeCSV(rows), maxSize, daily)
+}
+
+// writeData appends already encoded data with the same rotation rules
+func (f *csvFile) writeData(data []byte, maxSize int64, daily bool) {
+	now := time.Now().UTC()
+
+	rotate := daily && f.day != now.Format("2006-01-02")
+	if maxSize > 0 && f.size > 0 && f.size+int64(len(data)) > maxSize {
+		rotate = true
+	}
+	if rotate {
+		if err := f.rotate(now); err != nil {
+			log.Printf("[CSV] Failed to rotate %s: %v", f.path, err)
+			if f.file == nil {
+				return
+			}
+		}
+	}
+
+	if err := f.append(data); err != nil {
+		log.Printf("[CSV] Write to %s failed: %v", f.path, err)
+	}
+}
+
+// rotate closes the current file, renames it with a timestamp suffix and
+// reopens a fresh one at the original path
+func (f *csvFile) rotate(now time.Time) error {
+	if err := f.file.Close(); err != nil {
+		return err
+	}
+	f.file = nil
+
+	ext := filepath.Ext(f.path)
+	base := strings.TrimSuffix(f.path, ext)
+	stamp := now.Format("20060102T150405Z")
+	rotated := fmt.Sprintf("%s_%s%s", base, stamp, ext)
+	for i := 1; ; i++ {
+		if _, err := os.Stat(rotated); os.IsNotExist(err) {
+			break
+		}
+		rotated = fmt.Sprintf("%s_%s_%d%s", base, stamp, i, ext)
+	}
+
+	if err := os.Rename(f.path, rotated); err != nil {
+		// Keep writing to the existing file rather than losing data
+		if openErr := f.open(); openErr != nil {
+			return openErr
+		}
+		return err
+	}
+
+	log.Printf("[CSV] Rotated %s -> %s", f.path, rotated)
+	return f.open()
+}
+
+func (f *csvFile) close() {
+	if f != nil && f.file != nil {
+		f.file.Close()
+		f.file = nil
+	}
+}
+
+func encodeCSV(rows [][]string) []byte {
+	var buf bytes.Buffer
+	cw := csv.NewWriter(&buf)
+	cw.WriteAll(rows)
+	return buf.Bytes()
+}
+
+// WriteFrame logs a raw CAN frame with its payload hex-encoded, suitable
+// for offline replay
+func (w *CSVWriter) WriteFrame(frame RawFrame) {
+	if w.frames == nil {
+		return
+	}
+
+	row := []string{
+		frame.Timestamp.Format(time.RFC3339Nano),
+		fmt.Sprintf("%d", frame.Timestamp.UnixMilli()),
+		fmt.Sprintf("0x%08X", frame.ID),
+		fmt.Sprintf("%d", frame.PGN),
+		fmt.Sprintf("%d", frame.Priority),
+		fmt.Sprintf("%d", frame.Source),
+		fmt.Sprintf("%d", frame.Dest),
+		fmt.Sprintf("%d", len(frame.Data)),
+		hex.EncodeToString(frame.Data),
+	}
+
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.frames.write([][]string{row}, w.MaxSizeBytes, w.RotateDaily)
+}
+
+// WriteStats logs a statistics snapshot, one row per metric
+func (w *CSVWriter) WriteStats(snapshot map[string]interface{}) {
+	if w.stats == nil || len(snapshot) == 0 {
+		return
+	}
+
+	now := time.Now()
+	flat := make(map[string]interface{}, len(snapshot))
+	flattenStats("", snapshot, flat)
+
+	keys := make([]string, 0, len(flat))
+	for k := range flat {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	rows := make([][]string, 0, len(keys))
+	for _, k := range keys {
+		value := flat[k]
+		if t, ok := value.(time.Time); ok {
+			value = t.Format(time.RFC3339)
+		}
+		rows = append(rows, []string{
+			now.Format(time.RFC3339),
+			fmt.Sprintf("%d", now.UnixMilli()),
+			k,
+			fmt.Sprintf("%v", value),
+		})
+	}
+
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.stats.write(rows, w.MaxSizeBytes, w.RotateDaily)
+}
+
+// flattenStats expands nested snapshot maps into dotted metric names,
+// e.g. pgns.130306.rate_per_sec
+func flattenStats(prefix string, value interface{}, out map[string]interface{}) {
+	switch v := value.(type) {
+	case map[string]interface{}:
+		for k, sub := range v {
+			flattenStats(prefix+k+".", sub, out)
+		}
+	case map[int]interface{}:
+		for k, sub := range v {
+			flattenStats(fmt.Sprintf("%s%d.", prefix, k), sub, out)
+		}
+	default:
+		out[strings.TrimSuffix(prefix, ".")] = v
+	}
+}
+
+func (w *CSVWriter) WriteDecoded(msg DecodedMessage) {
+	if w.decoded == nil || len(msg.Fields) == 0 {
+		return
+	}
+
+	precision := w.precisionFor(msg.Measurement)
+	rows := make([][]string, 0, len(msg.Fields))
+	for field, value := range msg.Fields {
+		if !w.decodedFieldAllowed(field) {
+			continue
+		}
+		text, ok := formatDecodedValue(value, precision, w.SkipComposite)
+		if !ok {
+			continue
+		}
+		row := []string{
+			msg.Timestamp.Format(time.RFC3339),
+			fmt.Sprintf("%d", msg.Timestamp.UnixMilli()),
+			msg.Measurement,
+			fmt.Sprintf("%d", msg.PGN),
+			msg.PGNName,
+			fmt.Sprintf("%d", msg.Source),
+			field,
+			text,
+		}
+		rows = append(rows, row)
+	}
+	if len(rows) == 0 {
+		return
+	}
+
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.decoded.write(rows, w.MaxSizeBytes, w.RotateDaily)
+}
+
+func (w *CSVWriter) Close() {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.frames.close()
+	w.decoded.close()
+	w.stats.close()
+}
@@ -0,0 +1,114 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strings"
+
+	"odysail-boat-viz/nmea"
+)
+
+// decodeSampleFields caps how many fields of each sample are printed
+const decodeSampleFields = 8
+
+// runDecodeCommand implements `odysail decode [-samples N] <frames.csv>`: a
+// dry run of a captured frames file (a recording or replay CSV) through the
+// decoder, for checking a new instrument's PGNs without a boat or a server.
+// It returns the process exit code.
+func runDecodeCommand(args []string) int {
+	fs := flag.NewFlagSet("decode", flag.ContinueOnError)
+	samples := fs.Int("samples", 1, "decoded samples to print per PGN")
+	fs.Usage = func() {
+		fmt.Fprintln(fs.Output(), "usage: odysail decode [-samples N] <frames.csv>")
+		fs.PrintDefaults()
+	}
+	if err := fs.Parse(args); err != nil {
+		return 2
+	}
+	if fs.NArg() != 1 {
+		fs.Usage()
+		return 2
+	}
+
+	report, err := nmea.DecodeFile(fs.Arg(0), nmea.NewDecoder(), *samples)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "decode: %v\n", err)
+		return 1
+	}
+	printDecodeReport(os.Stdout, report)
+	return 0
+}
+
+// printDecodeReport writes the per-PGN table followed by the samples and
+// errors
+func printDecodeReport(w io.Writer, report *nmea.DecodeReport) {
+	fmt.Fprintf(w, "%s: %d frames, %d PGNs, %d decoded\n\n",
+		report.Path, report.Frames, len(report.PGNs), report.Decoded)
+	if len(report.PGNs) == 0 {
+		return
+	}
+
+	fmt.Fprintf(w, "%-7s %-36s %8s %8s %8s  %s\n", "PGN", "NAME", "FRAMES", "OK", "FAILED", "SOURCES")
+	for _, s := range report.PGNs {
+		ok, failed := fmt.Sprint(s.Decoded), fmt.Sprint(s.Failed)
+		if !s.HasDecoder {
+			ok, failed = "-", "-"
+		}
+		sources := make([]string, len(s.Sources))
+		for i, src := range s.Sources {
+			sources[i] = fmt.Sprint(src)
+		}
+		fmt.Fprintf(w, "%-7d %-36s %8d %8s %8s  %s\n",
+			s.PGN, s.Name, s.Frames, ok, failed, strings.Join(sources, ","))
+	}
+
+	for _, s := range report.PGNs {
+		if !s.HasDecoder || (len(s.Samples) == 0 && s.Failed == 0) {
+			continue
+		}
+		fmt.Fprintf(w, "\n%d %s\n", s.PGN, s.Name)
+		for _, fields := range s.Samples {
+			fmt.Fprintf(w, "  %s\n", formatDecodeSample(fields))
+		}
+		if s.Failed > 0 {
+			fmt.Fprintf(w, "  last error: %s\n", s.LastError)
+		}
+	}
+
+	var undecoded []string
+	for _, s := range report.PGNs {
+		if !s.HasDecoder {
+			undecoded = append(undecoded, fmt.Sprint(s.PGN))
+		}
+	}
+	if len(undecoded) > 0 {
+		fmt.Fprintf(w, "\nNo decoder: %s\n", strings.Join(undecoded, ", "))
+	}
+}
+
+// formatDecodeSample renders decoded fields as sorted key=value pairs
+func formatDecodeSample(fields map[string]interface{}) string {
+	keys := make([]string, 0, len(fields))
+	for k := range fields {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	parts := make([]string, 0, decodeSampleFields+1)
+	for i, k := range keys {
+		if i == decodeSampleFields {
+			parts = append(parts, fmt.Sprintf("... (+%d)", len(keys)-i))
+			break
+		}
+		v := fields[k]
+		if f, ok := v.(float64); ok {
+			parts = append(parts, fmt.Sprintf("%s=%.4g", k, f))
+		} else {
+			parts = append(parts, fmt.Sprintf("%s=%v", k, v))
+		}
+	}
+	return strings.Join(parts, " ")
+}
@@ -0,0 +1,97 @@
+package nmea
+
+import (
+	"fmt"
+	"sort"
+)
+
+// PGNDecodeSummary is how one PGN fared in DecodeFile
+type PGNDecodeSummary struct {
+	PGN        int
+	Name       string
+	HasDecoder bool
+	Frames     int
+	Decoded    int // decoded to at least one field
+	Failed     int // decoder error, or no fields (short or all-invalid payload)
+	Sources    []uint8
+	Samples    []map[string]interface{} // first decoded field sets
+	LastError  string
+}
+
+// DecodeReport summarises a decode run over a frames file
+type DecodeReport struct {
+	Path    string
+	Frames  int
+	Decoded int
+	PGNs    []PGNDecodeSummary // ordered by PGN
+}
+
+// DecodeFile runs every frame of a recorded frames CSV (the format
+// ReplaySource reads) through decoder as fast as possible and summarises
+// the result per PGN, keeping up to samples decoded field sets of each.
+// Sensor rows of session recordings are skipped.
+func DecodeFile(path string, decoder *Decoder, samples int) (*DecodeReport, error) {
+	rs, err := OpenReplaySource(path, 0, false)
+	if err != nil {
+		return nil, err
+	}
+
+	frames := make(chan RawFrame, 64)
+	runErr := make(chan error, 1)
+	go func() {
+		runErr <- rs.Run(frames, nil)
+		close(frames)
+	}()
+
+	report := &DecodeReport{Path: path}
+	byPGN := make(map[int]*PGNDecodeSummary)
+	sources := make(map[int]map[uint8]bool)
+
+	for frame := range frames {
+		s, ok := byPGN[frame.PGN]
+		if !ok {
+			s = &PGNDecodeSummary{
+				PGN:        frame.PGN,
+				Name:       GetPGNName(frame.PGN),
+				HasDecoder: decoder.HasHandler(frame.PGN),
+			}
+			byPGN[frame.PGN] = s
+			sources[frame.PGN] = make(map[uint8]bool)
+		}
+		s.Frames++
+		report.Frames++
+		sources[frame.PGN][frame.Source] = true
+
+		if !s.HasDecoder {
+			continue
+		}
+		fields, err := decoder.Decode(frame.PGN, frame.Data)
+		switch {
+		case err != nil:
+			s.Failed++
+			s.LastError = err.Error()
+		case len(fields) == 0:
+			s.Failed++
+			s.LastError = fmt.Sprintf("no fields from %d byte payload", len(frame.Data))
+		default:
+			s.Decoded++
+			report.Decoded++
+			if len(s.Samples) < samples {
+				s.Samples = append(s.Samples, fields)
+			}
+		}
+	}
+	if err := <-runErr; err != nil {
+		return nil, err
+	}
+
+	for pgn, s := range byPGN {
+		for src := range sources[pgn] {
+			s.Sources = append(s.Sources, src)
+		}
+		sort.Slice(s.Sources, func(i, j int) bool { return s.Sources[i] < s.Sources[j] })
+		report.PGNs = append(report.PGNs, *s)
+	}
+	sort.Slice(report.PGNs, func(i, j int) bool { return report.PGNs[i].PGN < report.PGNs[j].PGN })
+	return report, nil
+}
@@ -1,362 +1,626 @@
-package nmea
-
-import (
-	"encoding/binary"
-	"math"
-)
-
-// Decoder handles PGN decoding
-type Decoder struct {
-	handlers map[int]DecoderFunc
-}
-
-type DecoderFunc func(data []byte) (map[string]interface{}, error)
-
-func NewDecoder() *Decoder {
-	d := &Decoder{
-		handlers: make(map[int]DecoderFunc),
-	}
-	d.registerDefaultHandlers()
-	return d
-}
-
-func (d *Decoder) Decode(pgn int, data []byte) (map[string]interface{}, error) {
-	if handler, ok := d.handlers[pgn]; ok {
-		return handler(data)
-	}
-	return nil, nil // No handler for this PGN
-}
-
-func (d *Decoder) registerDefaultHandlers() {
-	// Critical PGNs for sailing/BoomSense
-	d.handlers[127257] = decodePGN127257 // Attitude (CRITICAL for heel angle)
-	d.handlers[127251] = decodePGN127251 // Rate of Turn
-	d.handlers[130306] = decodePGN130306 // Wind Data (CRITICAL)
-	d.handlers[127250] = decodePGN127250 // Vessel Heading
-	d.handlers[129026] = decodePGN129026 // COG & SOG (CRITICAL for boat speed)
-	d.handlers[129025] = decodePGN129025 // Position Rapid Update
-	d.handlers[129029] = decodePGN129029 // GNSS Position Data
-	d.handlers[128267] = decodePGN128267 // Water Depth
-	d.handlers[128259] = decodePGN128259 // Speed Water Referenced
-	d.handlers[128275] = decodePGN128275 // Distance Log
-	d.handlers[127245] = decodePGN127245 // Rudder
-	d.handlers[127237] = decodePGN127237 // Heading/Track Control
-	d.handlers[129284] = decodePGN129284 // Navigation Data
-	d.handlers[129540] = decodePGN129540 // GNSS Satellites
-	d.handlers[126992] = decodePGN126992 // System Time
-	d.handlers[127508] = decodePGN127508 // Battery Status
-	d.handlers[127489] = decodePGN127489 // Engine Parameters
-	d.handlers[130310] = decodePGN130310 // Environmental Parameters
-	d.handlers[130312] = decodePGN130312 // Temperature
-	d.handlers[130313] = decodePGN130313 // Humidity
-}
-
-// Helper functions for reading multi-byte values
-func u8(data []byte, offset int) uint8 {
-	if offset >= len(data) {
-		return 0xFF
-	}
-	return data[offset]
-}
-
-func u16le(data []byte, offset int) uint16 {
-	if offset+1 >= len(data) {
-		return 0xFFFF
-	}
-	return binary.LittleEndian.Uint16(data[offset : offset+2])
-}
-
-func u32le(data []byte, offset int) uint32 {
-	if offset+3 >= len(data) {
-		return 0xFFFFFFFF
-	}
-	return binary.LittleEndian.Uint32(data[offset : offset+4])
-}
-
-func i8(data []byte, offset int) int8 {
-	if offset >= len(data) {
-		return 0x7F
-	}
-	return int8(data[offset])
-}
-
-func i16le(data []byte, offset int) int16 {
-	if offset+1 >= len(data) {
-		return 0x7FFF
-	}
-	return int16(binary.LittleEndian.Uint16(data[offset : offset+2]))
-}
-
-func i32le(data []byte, offset int) int32 {
-	if offset+3 >= len(data) {
-		return 0x7FFFFFFF
-	}
-	return int32(binary.LittleEndian.Uint32(data[offset : offset+4]))
-}
-
-func i64le(data []byte, offset int) int64 {
-	if offset+7 >= len(data) {
-		return 0x7FFFFFFFFFFFFFFF
-	}
-	return int64(binary.LittleEndian.Uint64(data[offset : offset+8]))
-}
-
-// === CRITICAL: PGN 127257 - Attitude (Yaw, Pitch, Roll) ===
-// This provides heel angle for BoomSense!
-func decodePGN127257(data []byte) (map[string]interface{}, error) {
-	if len(data) < 7 {
-		return nil, nil
-	}
-
-	result := make(map[string]interface{})
-	sid := u8(data, 0)
-	yawRaw := i16le(data, 1)
-	pitchRaw := i16le(data, 3)
-	rollRaw := i16le(data, 5)
-
-	result["sid"] = sid
-
-	if yawRaw != 0x7FFF {
-		yaw := float64(yawRaw) * 0.0001 // radians
-		result["yaw_rad"] = yaw
-		result["yaw_deg"] = yaw * 180.0 / math.Pi
-	}
-
-	if pitchRaw != 0x7FFF {
-		pitch := float64(pitchRaw) * 0.0001 // radians
-		result["pitch_rad"] = pitch
-		result["pitch_deg"] = pitch * 180.0 / math.Pi
-	}
-
-	if rollRaw != 0x7FFF {
-		roll := float64(rollRaw) * 0.0001 // radians (heel angle)
-		result["roll_rad"] = roll
-		result["roll_deg"] = roll * 180.0 / math.Pi
-		result["heel_angle"] = roll * 180.0 / math.Pi // Alias for clarity
-	}
-
-	return result, nil
-}
-
-// === CRITICAL: PGN 130306 - Wind Data ===
-func decodePGN130306(data []byte) (map[string]interface{}, error) {
-	if len(data) < 6 {
-		return nil, nil
-	}
-
-	result := make(map[string]interface{})
-	sid := u8(data, 0)
-	wsRaw := u16le(data, 1)
-	waRaw := u16le(data, 3)
-	ref := u8(data, 5)
-
-	result["sid"] = sid
-	result["wind_reference"] = ref
-
-	if wsRaw != 0xFFFF {
-		windSpeed := float64(wsRaw) * 0.01 // m/s
-		result["wind_speed_ms"] = windSpeed
-		result["wind_speed_kts"] = windSpeed * 1.94384 // Convert to knots
-	}
-
-	if waRaw != 0xFFFF {
-		windAngle := float64(waRaw) * 0.0001 // radians
-		result["wind_angle_rad"] = windAngle
-		result["wind_angle_deg"] = windAngle * 180.0 / math.Pi
-	}
-
-	return result, nil
-}
-
-// === CRITICAL: PGN 129026 - COG & SOG Rapid Update ===
-func decodePGN129026(data []byte) (map[string]interface{}, error) {
-	if len(data) < 8 {
-		return nil, nil
-	}
-
-	result := make(map[string]interface{})
-	sid := u8(data, 0)
-	cogRaw := u16le(data, 1)
-	sogRaw := u16le(data, 3)
-
-	result["sid"] = sid
-
-	if cogRaw != 0xFFFF {
-		cog := float64(cogRaw) * 0.0001 // radians
-		result["cog_rad"] = cog
-		result["cog_deg"] = cog * 180.0 / math.Pi
-	}
-
-	if sogRaw != 0xFFFF {
-		sog := float64(sogRaw) * 0.01 // m/s
-		result["sog_ms"] = sog
-		result["sog_kts"] = sog * 1.94384
-	}
-
-	return result, nil
-}
-
-// === PGN 127250 - Vessel Heading ===
-func decodePGN127250(data []byte) (map[string]interface{}, error) {
-	if len(data) < 8 {
-		return nil, nil
-	}
-
-	result := make(map[string]interface{})
-	sid := u8(data, 0)
-	headingRaw := u16le(data, 1)
-	deviationRaw := i16le(data, 3)
-	variationRaw := i16le(data, 5)
-	ref := u8(data, 7)
-
-	result["sid"] = sid
-	result["heading_reference"] = ref
-
-	if headingRaw != 0xFFFF {
-		heading := float64(headingRaw) * 0.0001
-		result["heading_rad"] = heading
-		result["heading_deg"] = heading * 180.0 / math.Pi
-	}
-
-	if deviationRaw != 0x7FFF {
-		deviation := float64(deviationRaw) * 0.0001
-		result["deviation_rad"] = deviation
-		result["deviation_deg"] = deviation * 180.0 / math.Pi
-	}
-
-	if variationRaw != 0x7FFF {
-		variation := float64(variationRaw) * 0.0001
-		result["variation_rad"] = variation
-		result["variation_deg"] = variation * 180.0 / math.Pi
-	}
-
-	return result, nil
-}
-
-// === PGN 127251 - Rate of Turn ===
-func decodePGN127251(data []byte) (map[string]interface{}, error) {
-	result := make(map[string]interface{})
-
-	// 8-byte variant
-	if len(data) >= 8 {
-		sid := u8(data, 0)
-		rotRaw := i32le(data, 1)
-		result["sid"] = sid
-
-		if rotRaw != 0x7FFFFFFF {
-			rot := float64(rotRaw) * 3.125e-8 // rad/s
-			result["rate_of_turn_rad_s"] = rot
-			result["rate_of_turn_deg_s"] = rot * 180.0 / math.Pi
-		}
-		return result, nil
-	}
-
-	// 3-byte variant
-	if len(data) >= 3 {
-		sid := u8(data, 0)
-		rotRaw := i16le(data, 1)
-		result["sid"] = sid
-
-		if rotRaw != 0x7FFF {
-			rot := float64(rotRaw) * 0.0001
-			result["rate_of_turn_rad_s"] = rot
-			result["rate_of_turn_deg_s"] = rot * 180.0 / math.Pi
-		}
-		return result, nil
-	}
-
-	return nil, nil
-}
-
-// === PGN 129025 - Position Rapid Update ===
-func decodePGN129025(data []byte) (map[string]interface{}, error) {
-	if len(data) < 8 {
-		return nil, nil
-	}
-
-	result := make(map[string]interface{})
-	latRaw := u32le(data, 0)
-	lonRaw := u32le(data, 4)
-
-	if latRaw != 0xFFFFFFFF {
-		lat := (float64(latRaw) - 0x80000000) * 1e-7
-		result["latitude"] = lat
-	}
-
-	if lonRaw != 0xFFFFFFFF {
-		lon := (float64(lonRaw) - 0x80000000) * 1e-7
-		result["longitude"] = lon
-	}
-
-	return result, nil
-}
-
-// === PGN 128267 - Water Depth ===
-func decodePGN128267(data []byte) (map[string]interface{}, error) {
-	if len(data) < 5 {
-		return nil, nil
-	}
-
-	result := make(map[string]interface{})
-	sid := u8(data, 0)
-	depthRaw := u32le(data, 1)
-
-	result["sid"] = sid
-
-	if depthRaw != 0xFFFFFFFF {
-		result["depth_m"] = float64(depthRaw) * 0.01
-	}
-
-	return result, nil
-}
-
-// === PGN 128259 - Speed Water Referenced ===
-func decodePGN128259(data []byte) (map[string]interface{}, error) {
-	if len(data) < 7 {
-		return nil, nil
-	}
-
-	result := make(map[string]interface{})
-	sid := u8(data, 0)
-	waterRaw := u16le(data, 1)
-	groundRaw := u16le(data, 3)
-
-	result["sid"] = sid
-
-	if waterRaw != 0xFFFF {
-		ws := float64(waterRaw) * 0.01
-		result["water_speed_ms"] = ws
-		result["water_speed_kts"] = ws * 1.94384
-	}
-
-	if groundRaw != 0xFFFF {
-		gs := float64(groundRaw) * 0.01
-		result["ground_speed_ms"] = gs
-		result["ground_speed_kts"] = gs * 1.94384
-	}
-
-	return result, nil
-}
-
-// === PGN 128275 - Distance Log ===
-func decodePGN128275(data []byte) (map[string]interface{}, error) {
-	if len(data) < 8 {
-		return nil, nil
-	}
-
-	result := make(map[string]interface{})
-	logRaw := u32le(data, 0)
-	tripRaw := u32le(data, 4)
-
-	if logRaw != 0xFFFFFFFF {
-		result["log_distance_m"] = float64(logRaw) * 185.2 // 0.1 nm to meters
-	}
-
-	if tripRaw != 0xFFFFFFFF {
-		result["trip_distance_m"] = float64(tripRaw) * 185.2
-	}
-
-	return result, nil
-}
-
+package nmea
+
+import (
+	"encoding/binary"
+	"math"
+	"strings"
+)
+
+// Decoder handles PGN decoding
+type Decoder struct {
+	handlers map[int]DecoderFunc
+	units    UnitConfig
+
+	// MarkInvalid keeps fields the sender flagged "not available" as nil
+	// values instead of dropping them, so consumers can tell a failed sensor
+	// from a PGN that was never sent
+	MarkInvalid bool
+
+	// DepthOffset is the transducer offset in metres used for 128267 when
+	// the sender reports none (offset missing or zero): positive is the
+	// distance down from the waterline, negative up from the keel
+	DepthOffset float64
+}
+
+type DecoderFunc func(data []byte) (map[string]interface{}, error)
+
+// UnitConfig selects the display unit emitted next to each raw SI field.
+// Decoders always emit SI (`*_ms`, `depth*_m`, `*_c`); the configured unit
+// adds one converted companion field with a unit-specific suffix.
+type UnitConfig struct {
+	Speed       string `json:"speed"`       // "kts" (default), "kmh" or "ms"
+	Depth       string `json:"depth"`       // "m" (default), "ft" or "fathoms"
+	Temperature string `json:"temperature"` // "c" (default) or "f"
+}
+
+func DefaultUnitConfig() UnitConfig {
+	return UnitConfig{
+		Speed:       "kts",
+		Depth:       "m",
+		Temperature: "c",
+	}
+}
+
+func NewDecoder() *Decoder {
+	return NewDecoderWithUnits(DefaultUnitConfig())
+}
+
+// NewDecoderWithUnits creates a decoder emitting the given display units.
+// Empty or unknown unit names fall back to the defaults.
+func NewDecoderWithUnits(cfg UnitConfig) *Decoder {
+	d := &Decoder{
+		handlers: make(map[int]DecoderFunc),
+		units:    normalizeUnits(cfg),
+	}
+	d.registerDefaultHandlers()
+	return d
+}
+
+func (d *Decoder) Decode(pgn int, data []byte) (map[string]interface{}, error) {
+	if handler, ok := d.handlers[pgn]; ok {
+		result, err := handler(data)
+		if result != nil {
+			d.finish(pgn, result)
+		}
+		return result, err
+	}
+	return nil, nil // No handler for this PGN
+}
+
+// finish applies the decoder settings to a handler's raw result: the
+// manual depth offset, dropping "not available" fields and unit companions
+func (d *Decoder) finish(pgn int, result map[string]interface{}) {
+	if pgn == 128267 {
+		d.applyManualDepthOffset(result)
+	}
+	if !d.MarkInvalid {
+		dropInvalid(result)
+	}
+	d.applyUnits(result)
+}
+
+// HasHandler reports whether a decoder is registered for pgn
+func (d *Decoder) HasHandler(pgn int) bool {
+	return d.handlers[pgn] != nil
+}
+
+// applyManualDepthOffset fills in the configured DepthOffset for depth
+// senders whose own offset is not set
+func (d *Decoder) applyManualDepthOffset(result map[string]interface{}) {
+	if d.DepthOffset == 0 {
+		return
+	}
+	if reported, ok := result["transducer_offset_m"].(float64); ok && reported != 0 {
+		return
+	}
+	result["transducer_offset_m"] = d.DepthOffset
+	applyDepthOffset(result, d.DepthOffset)
+}
+
+func normalizeUnits(cfg UnitConfig) UnitConfig {
+	def := DefaultUnitConfig()
+	switch cfg.Speed {
+	case "kts", "kmh", "ms":
+	default:
+		cfg.Speed = def.Speed
+	}
+	switch cfg.Depth {
+	case "m", "ft", "fathoms":
+	default:
+		cfg.Depth = def.Depth
+	}
+	switch cfg.Temperature {
+	case "c", "f":
+	default:
+		cfg.Temperature = def.Temperature
+	}
+	return cfg
+}
+
+// applyUnits adds the configured display-unit companion for every SI speed,
+// depth and temperature field. Field names depend only on the unit, so
+// `depth_ft` always means feet regardless of which decoder produced it.
+func (d *Decoder) applyUnits(result map[string]interface{}) {
+	keys := make([]string, 0, len(result))
+	for k := range result {
+		keys = append(keys, k)
+	}
+
+	for _, k := range keys {
+		v, ok := result[k].(float64)
+		if !ok {
+			continue
+		}
+
+		switch {
+		case strings.HasSuffix(k, "_ms"):
+			base := strings.TrimSuffix(k, "_ms")
+			switch d.units.Speed {
+			case "kts":
+				result[base+"_kts"] = v * 1.94384
+			case "kmh":
+				result[base+"_kmh"] = v * 3.6
+			}
+
+		case strings.HasPrefix(k, "depth") && strings.HasSuffix(k, "_m"):
+			base := strings.TrimSuffix(k, "_m")
+			switch d.units.Depth {
+			case "ft":
+				result[base+"_ft"] = v * 3.28084
+			case "fathoms":
+				result[base+"_fathoms"] = v / 1.8288
+			}
+
+		case strings.HasSuffix(k, "_c"):
+			base := strings.TrimSuffix(k, "_c")
+			if d.units.Temperature == "f" {
+				result[base+"_f"] = v*9.0/5.0 + 32.0
+			}
+		}
+	}
+}
+
+func (d *Decoder) registerDefaultHandlers() {
+	// Critical PGNs for sailing/BoomSense
+	d.handlers[127257] = decodePGN127257 // Attitude (CRITICAL for heel angle)
+	d.handlers[127252] = decodePGN127252 // Heave (sea state)
+	d.handlers[127251] = decodePGN127251 // Rate of Turn
+	d.handlers[130306] = decodePGN130306 // Wind Data (CRITICAL)
+	d.handlers[127250] = decodePGN127250 // Vessel Heading
+	d.handlers[127258] = decodePGN127258 // Magnetic Variation
+	d.handlers[129026] = decodePGN129026 // COG & SOG (CRITICAL for boat speed)
+	d.handlers[129025] = decodePGN129025 // Position Rapid Update
+	d.handlers[129029] = decodePGN129029 // GNSS Position Data
+	d.handlers[128267] = decodePGN128267 // Water Depth
+	d.handlers[128259] = decodePGN128259 // Speed Water Referenced
+	d.handlers[128275] = decodePGN128275 // Distance Log
+	d.handlers[127245] = decodePGN127245 // Rudder
+	d.handlers[127237] = decodePGN127237 // Heading/Track Control
+	d.handlers[129284] = decodePGN129284 // Navigation Data
+	d.handlers[129285] = decodePGN129285 // Route/WP Information
+	d.handlers[129540] = decodePGN129540 // GNSS Satellites
+	d.handlers[126992] = decodePGN126992 // System Time
+	d.handlers[126996] = decodePGN126996 // Product Information
+	d.handlers[126998] = decodePGN126998 // Configuration Information
+	d.handlers[127508] = decodePGN127508 // Battery Status
+	d.handlers[127489] = decodePGN127489 // Engine Parameters
+	d.handlers[127493] = decodePGN127493 // Transmission Parameters
+	d.handlers[127505] = decodePGN127505 // Fluid Level
+	d.handlers[130310] = decodePGN130310 // Environmental Parameters
+	d.handlers[130312] = decodePGN130312 // Temperature
+	d.handlers[130313] = decodePGN130313 // Humidity
+}
+
+// IsEmptyFrame reports whether a payload carries no meaningful data: either
+// zero-length or every byte set to the 0xFF "not available" sentinel, as sent
+// by some gateways as keep-alives.
+func IsEmptyFrame(data []byte) bool {
+	for _, b := range data {
+		if b != 0xFF {
+			return false
+		}
+	}
+	return true
+}
+
+// invalid records fields whose raw value was the "not available" sentinel.
+// They are kept as nil only when Decoder.MarkInvalid is set.
+func invalid(result map[string]interface{}, fields ...string) {
+	for _, field := range fields {
+		result[field] = nil
+	}
+}
+
+func dropInvalid(result map[string]interface{}) {
+	for k, v := range result {
+		if v == nil {
+			delete(result, k)
+		}
+	}
+}
+
+// Helper functions for reading multi-byte values
+func u8(data []byte, offset int) uint8 {
+	if offset >= len(data) {
+		return 0xFF
+	}
+	return data[offset]
+}
+
+func u16le(data []byte, offset int) uint16 {
+	if offset+1 >= len(data) {
+		return 0xFFFF
+	}
+	return binary.LittleEndian.Uint16(data[offset : offset+2])
+}
+
+func u32le(data []byte, offset int) uint32 {
+	if offset+3 >= len(data) {
+		return 0xFFFFFFFF
+	}
+	return binary.LittleEndian.Uint32(data[offset : offset+4])
+}
+
+func i8(data []byte, offset int) int8 {
+	if offset >= len(data) {
+		return 0x7F
+	}
+	return int8(data[offset])
+}
+
+func i16le(data []byte, offset int) int16 {
+	if offset+1 >= len(data) {
+		return 0x7FFF
+	}
+	return int16(binary.LittleEndian.Uint16(data[offset : offset+2]))
+}
+
+func i32le(data []byte, offset int) int32 {
+	if offset+3 >= len(data) {
+		return 0x7FFFFFFF
+	}
+	return int32(binary.LittleEndian.Uint32(data[offset : offset+4]))
+}
+
+func i64le(data []byte, offset int) int64 {
+	if offset+7 >= len(data) {
+		return 0x7FFFFFFFFFFFFFFF
+	}
+	return int64(binary.LittleEndian.Uint64(data[offset : offset+8]))
+}
+
+// === CRITICAL: PGN 127257 - Attitude (Yaw, Pitch, Roll) ===
+// This provides heel angle for BoomSense!
+func decodePGN127257(data []byte) (map[string]interface{}, error) {
+	if len(data) < 7 {
+		return nil, nil
+	}
+
+	result := make(map[string]interface{})
+	sid := u8(data, 0)
+	yawRaw := i16le(data, 1)
+	pitchRaw := i16le(data, 3)
+	rollRaw := i16le(data, 5)
+
+	result["sid"] = sid
+
+	if yawRaw != 0x7FFF {
+		yaw := float64(yawRaw) * 0.0001 // radians
+		result["yaw_rad"] = yaw
+		result["yaw_deg"] = yaw * 180.0 / math.Pi
+	} else {
+		invalid(result, "yaw_rad", "yaw_deg")
+	}
+
+	if pitchRaw != 0x7FFF {
+		pitch := float64(pitchRaw) * 0.0001 // radians
+		result["pitch_rad"] = pitch
+		result["pitch_deg"] = pitch * 180.0 / math.Pi
+	} else {
+		invalid(result, "pitch_rad", "pitch_deg")
+	}
+
+	if rollRaw != 0x7FFF {
+		roll := float64(rollRaw) * 0.0001 // radians (heel angle)
+		result["roll_rad"] = roll
+		result["roll_deg"] = roll * 180.0 / math.Pi
+		result["heel_angle"] = roll * 180.0 / math.Pi // Alias for clarity
+	} else {
+		invalid(result, "roll_rad", "roll_deg", "heel_angle")
+	}
+
+	return result, nil
+}
+
+// PGN 127252 - Heave: vertical displacement of the sensor. Its variance
+// over a short window is a usable sea-state proxy.
+func decodePGN127252(data []byte) (map[string]interface{}, error) {
+	if len(data) < 3 {
+		return nil, nil
+	}
+
+	result := make(map[string]interface{})
+	result["sid"] = u8(data, 0)
+
+	if heaveRaw := i16le(data, 1); heaveRaw != 0x7FFF {
+		result["heave_m"] = float64(heaveRaw) * 0.01
+	} else {
+		invalid(result, "heave_m")
+	}
+
+	// Optional measurement delay, absent on older senders
+	if len(data) >= 5 {
+		if delayRaw := u16le(data, 3); delayRaw != 0xFFFF {
+			result["heave_delay_s"] = float64(delayRaw) * 0.01
+		} else {
+			invalid(result, "heave_delay_s")
+		}
+	}
+
+	return result, nil
+}
+
+// === CRITICAL: PGN 130306 - Wind Data ===
+func decodePGN130306(data []byte) (map[string]interface{}, error) {
+	if len(data) < 6 {
+		return nil, nil
+	}
+
+	result := make(map[string]interface{})
+	sid := u8(data, 0)
+	wsRaw := u16le(data, 1)
+	waRaw := u16le(data, 3)
+	ref := u8(data, 5)
+
+	result["sid"] = sid
+	result["wind_reference"] = ref
+	result["wind_reference_str"] = EnumString(WindReferenceNames, ref&0x07)
+
+	if wsRaw != 0xFFFF {
+		windSpeed := float64(wsRaw) * 0.01 // m/s
+		result["wind_speed_ms"] = windSpeed
+	} else {
+		invalid(result, "wind_speed_ms")
+	}
+
+	if waRaw != 0xFFFF {
+		windAngle := float64(waRaw) * 0.0001 // radians
+		result["wind_angle_rad"] = windAngle
+		result["wind_angle_deg"] = windAngle * 180.0 / math.Pi
+	} else {
+		invalid(result, "wind_angle_rad", "wind_angle_deg")
+	}
+
+	return result, nil
+}
+
+// === CRITICAL: PGN 129026 - COG & SOG Rapid Update ===
+func decodePGN129026(data []byte) (map[string]interface{}, error) {
+	if len(data) < 8 {
+		return nil, nil
+	}
+
+	result := make(map[string]interface{})
+	sid := u8(data, 0)
+	ref := u8(data, 1)
+	cogRaw := u16le(data, 2)
+	sogRaw := u16le(data, 4)
+
+	result["sid"] = sid
+	result["cog_reference"] = ref
+	result["cog_reference_str"] = EnumString(HeadingReferenceNames, ref&0x03)
+
+	if cogRaw != 0xFFFF {
+		cog := float64(cogRaw) * 0.0001 // radians
+		result["cog_rad"] = cog
+		result["cog_deg"] = cog * 180.0 / math.Pi
+	} else {
+		invalid(result, "cog_rad", "cog_deg")
+	}
+
+	if sogRaw != 0xFFFF {
+		sog := float64(sogRaw) * 0.01 // m/s
+		result["sog_ms"] = sog
+	} else {
+		invalid(result, "sog_ms")
+	}
+
+	return result, nil
+}
+
+// === PGN 127250 - Vessel Heading ===
+func decodePGN127250(data []byte) (map[string]interface{}, error) {
+	if len(data) < 8 {
+		return nil, nil
+	}
+
+	result := make(map[string]interface{})
+	sid := u8(data, 0)
+	headingRaw := u16le(data, 1)
+	deviationRaw := i16le(data, 3)
+	variationRaw := i16le(data, 5)
+	ref := u8(data, 7)
+
+	result["sid"] = sid
+	result["heading_reference"] = ref
+	result["heading_reference_str"] = EnumString(HeadingReferenceNames, ref&0x03)
+
+	if headingRaw != 0xFFFF {
+		heading := float64(headingRaw) * 0.0001
+		result["heading_rad"] = heading
+		result["heading_deg"] = heading * 180.0 / math.Pi
+	} else {
+		invalid(result, "heading_rad", "heading_deg")
+	}
+
+	if deviationRaw != 0x7FFF {
+		deviation := float64(deviationRaw) * 0.0001
+		result["deviation_rad"] = deviation
+		result["deviation_deg"] = deviation * 180.0 / math.Pi
+	} else {
+		invalid(result, "deviation_rad", "deviation_deg")
+	}
+
+	if variationRaw != 0x7FFF {
+		variation := float64(variationRaw) * 0.0001
+		result["variation_rad"] = variation
+		result["variation_deg"] = variation * 180.0 / math.Pi
+	} else {
+		invalid(result, "variation_rad", "variation_deg")
+	}
+
+	return result, nil
+}
+
+// === PGN 127251 - Rate of Turn ===
+func decodePGN127251(data []byte) (map[string]interface{}, error) {
+	result := make(map[string]interface{})
+
+	// 8-byte variant
+	if len(data) >= 8 {
+		sid := u8(data, 0)
+		rotRaw := i32le(data, 1)
+		result["sid"] = sid
+
+		if rotRaw != 0x7FFFFFFF {
+			rot := float64(rotRaw) * 3.125e-8 // rad/s
+			result["rate_of_turn_rad_s"] = rot
+			result["rate_of_turn_deg_s"] = rot * 180.0 / math.Pi
+		} else {
+			invalid(result, "rate_of_turn_rad_s", "rate_of_turn_deg_s")
+		}
+		return result, nil
+	}
+
+	// 3-byte variant
+	if len(data) >= 3 {
+		sid := u8(data, 0)
+		rotRaw := i16le(data, 1)
+		result["sid"] = sid
+
+		if rotRaw != 0x7FFF {
+			rot := float64(rotRaw) * 0.0001
+			result["rate_of_turn_rad_s"] = rot
+			result["rate_of_turn_deg_s"] = rot * 180.0 / math.Pi
+		} else {
+			invalid(result, "rate_of_turn_rad_s", "rate_of_turn_deg_s")
+		}
+		return result, nil
+	}
+
+	return nil, nil
+}
+
+// === PGN 129025 - Position Rapid Update ===
+func decodePGN129025(data []byte) (map[string]interface{}, error) {
+	if len(data) < 8 {
+		return nil, nil
+	}
+
+	result := make(map[string]interface{})
+	latRaw := i32le(data, 0)
+	lonRaw := i32le(data, 4)
+
+	if latRaw != 0x7FFFFFFF {
+		lat := float64(latRaw) * 1e-7
+		result["latitude"] = lat
+	} else {
+		invalid(result, "latitude")
+	}
+
+	if lonRaw != 0x7FFFFFFF {
+		lon := float64(lonRaw) * 1e-7
+		result["longitude"] = lon
+	} else {
+		invalid(result, "longitude")
+	}
+
+	return result, nil
+}
+
+// === PGN 128267 - Water Depth ===
+// The offset field is the transducer's distance from the waterline
+// (positive) or from the keel (negative), so depth plus offset gives depth
+// below surface or below keel respectively.
+func decodePGN128267(data []byte) (map[string]interface{}, error) {
+	if len(data) < 5 {
+		return nil, nil
+	}
+
+	result := make(map[string]interface{})
+	sid := u8(data, 0)
+	depthRaw := u32le(data, 1)
+	offsetRaw := i16le(data, 5)
+
+	result["sid"] = sid
+
+	if depthRaw != 0xFFFFFFFF {
+		result["depth_m"] = float64(depthRaw) * 0.01
+		result["depth_transducer_m"] = result["depth_m"]
+	} else {
+		invalid(result, "depth_m", "depth_transducer_m")
+	}
+
+	if offsetRaw != 0x7FFF {
+		offset := float64(offsetRaw) * 0.001
+		result["transducer_offset_m"] = offset
+		applyDepthOffset(result, offset)
+	} else {
+		invalid(result, "transducer_offset_m")
+	}
+
+	return result, nil
+}
+
+// applyDepthOffset adds depth_below_surface_m or depth_below_keel_m to a
+// 128267 result from the transducer depth and an offset in the PGN's sign
+// convention. A zero offset derives nothing.
+func applyDepthOffset(result map[string]interface{}, offset float64) {
+	depth, ok := result["depth_transducer_m"].(float64)
+	if !ok {
+		return
+	}
+	switch {
+	case offset > 0:
+		result["depth_below_surface_m"] = depth + offset
+	case offset < 0:
+		result["depth_below_keel_m"] = depth + offset
+	}
+}
+
+// === PGN 128259 - Speed Water Referenced ===
+func decodePGN128259(data []byte) (map[string]interface{}, error) {
+	if len(data) < 7 {
+		return nil, nil
+	}
+
+	result := make(map[string]interface{})
+	sid := u8(data, 0)
+	waterRaw := u16le(data, 1)
+	groundRaw := u16le(data, 3)
+
+	result["sid"] = sid
+
+	if waterRaw != 0xFFFF {
+		ws := float64(waterRaw) * 0.01
+		result["water_speed_ms"] = ws
+	} else {
+		invalid(result, "water_speed_ms")
+	}
+
+	if groundRaw != 0xFFFF {
+		gs := float64(groundRaw) * 0.01
+		result["ground_speed_ms"] = gs
+	} else {
+		invalid(result, "ground_speed_ms")
+	}
+
+	return result, nil
+}
+
+// === PGN 128275 - Distance Log ===
+func decodePGN128275(data []byte) (map[string]interface{}, error) {
+	if len(data) < 8 {
+		return nil, nil
+	}
+
+	result := make(map[string]interface{})
+	logRaw := u32le(data, 0)
+	tripRaw := u32le(data, 4)
+
+	if logRaw != 0xFFFFFFFF {
+		result["log_distance_m"] = float64(logRaw) * 185.2 // 0.1 nm to meters
+	} else {
+		invalid(result, "log_distance_m")
+	}
+
+	if tripRaw != 0xFFFFFFFF {
+		result["trip_distance_m"] = float64(tripRaw) * 185.2
+	} else {
+		invalid(result, "trip_distance_m")
+	}
+
+	return result, nil
+}
+
 // More decoders continued in Part 2...
\ No newline at end of file
@@ -1,437 +1,878 @@
-package nmea
-
-import (
-	"math"
-	"time"
-)
-
-// === PGN 129029 - GNSS Position Data ===
-func decodePGN129029(data []byte) (map[string]interface{}, error) {
-	if len(data) < 43 {
-		return nil, nil
-	}
-
-	result := make(map[string]interface{})
-	sid := u8(data, 0)
-	dateDays := u16le(data, 1)
-	timeRaw := u32le(data, 3)
-	latRaw := i64le(data, 7)
-	lonRaw := i64le(data, 15)
-	altRaw := i64le(data, 23)
-
-	pack1 := u8(data, 31)
-	gnssType := pack1 & 0x0F
-	method := (pack1 >> 4) & 0x0F
-
-	pack2 := u8(data, 32)
-	integrity := pack2 & 0b11
-
-	svs := u8(data, 33)
-	hdopRaw := i16le(data, 34)
-	pdopRaw := i16le(data, 36)
-	geoidRaw := i32le(data, 38)
-	refStations := u8(data, 42)
-
-	result["sid"] = sid
-
-	// Build UTC timestamp
-	if dateDays != 0xFFFF && timeRaw != 0xFFFFFFFF {
-		midnight := time.Date(1970, 1, 1, 0, 0, 0, 0, time.UTC).AddDate(0, 0, int(dateDays))
-		fixTime := midnight.Add(time.Duration(float64(timeRaw)*0.0001) * time.Second)
-		result["fix_time_utc"] = fixTime.Format(time.RFC3339)
-	}
-
-	if latRaw != 0x7FFFFFFFFFFFFFFF {
-		result["latitude"] = float64(latRaw) * 1e-16
-	}
-
-	if lonRaw != 0x7FFFFFFFFFFFFFFF {
-		result["longitude"] = float64(lonRaw) * 1e-16
-	}
-
-	if altRaw != 0x7FFFFFFFFFFFFFFF {
-		result["altitude_m"] = float64(altRaw) * 1e-6
-	}
-
-	result["gnss_type"] = gnssType
-	result["method"] = method
-	result["integrity"] = integrity
-	result["satellites"] = svs
-
-	if hdopRaw != 0x7FFF {
-		result["hdop"] = float64(hdopRaw) * 0.01
-	}
-
-	if pdopRaw != 0x7FFF {
-		result["pdop"] = float64(pdopRaw) * 0.01
-	}
-
-	if geoidRaw != 0x7FFFFFFF {
-		result["geoidal_separation_m"] = float64(geoidRaw) * 0.01
-	}
-
-	result["reference_stations"] = refStations
-
-	return result, nil
-}
-
-// === PGN 127245 - Rudder ===
-func decodePGN127245(data []byte) (map[string]interface{}, error) {
-	if len(data) < 6 {
-		return nil, nil
-	}
-
-	result := make(map[string]interface{})
-	instance := u8(data, 0)
-	directionOrder := u8(data, 1)
-	angleOrderRaw := i16le(data, 2)
-	positionRaw := i16le(data, 4)
-
-	result["rudder_instance"] = instance
-	result["direction_order"] = directionOrder
-
-	if angleOrderRaw != 0x7FFF {
-		angle := float64(angleOrderRaw) * 0.0001
-		result["rudder_angle_order_rad"] = angle
-		result["rudder_angle_order_deg"] = angle * 180.0 / math.Pi
-	}
-
-	if positionRaw != 0x7FFF {
-		pos := float64(positionRaw) * 0.0001
-		result["rudder_position_rad"] = pos
-		result["rudder_position_deg"] = pos * 180.0 / math.Pi
-	}
-
-	return result, nil
-}
-
-// === PGN 127237 - Heading/Track Control (Autopilot) ===
-func decodePGN127237(data []byte) (map[string]interface{}, error) {
-	if len(data) < 8 {
-		return nil, nil
-	}
-
-	result := make(map[string]interface{})
-
-	b0 := u8(data, 0)
-	b1 := u8(data, 1)
-	b2 := u8(data, 2)
-
-	result["rudder_limit_exceeded"] = (b0 >> 6) & 0b11
-	result["off_heading_exceeded"] = (b0 >> 4) & 0b11
-	result["off_track_exceeded"] = (b0 >> 2) & 0b11
-	result["override"] = b0 & 0b11
-	result["steering_mode"] = (b1 >> 5) & 0b111
-	result["turn_mode"] = (b1 >> 2) & 0b111
-	result["heading_reference"] = ((b1 & 0b11) | ((b2 >> 7) & 0b1) << 2)
-	result["commanded_rudder_direction"] = b2 & 0b111
-
-	offset := 3
-	cmdRudderAngleRaw := i16le(data, offset)
-	offset += 2
-	headingToSteerRaw := u16le(data, offset)
-	offset += 2
-	trackRaw := u16le(data, offset)
-	offset += 2
-
-	if cmdRudderAngleRaw != 0x7FFF {
-		result["commanded_rudder_angle_rad"] = float64(cmdRudderAngleRaw) * 0.0001
-	}
-
-	if headingToSteerRaw != 0xFFFF {
-		result["heading_to_steer_rad"] = float64(headingToSteerRaw) * 0.0001
-	}
-
-	if trackRaw != 0xFFFF {
-		result["track_rad"] = float64(trackRaw) * 0.0001
-	}
-
-	return result, nil
-}
-
-// === PGN 129284 - Navigation Data ===
-func decodePGN129284(data []byte) (map[string]interface{}, error) {
-	if len(data) < 8 {
-		return nil, nil
-	}
-
-	result := make(map[string]interface{})
-	offset := 0
-
-	sid := u8(data, offset)
-	offset++
-	distCm := u32le(data, offset)
-	offset += 4
-	flags := u8(data, offset)
-	offset++
-
-	result["sid"] = sid
-
-	if distCm != 0xFFFFFFFF {
-		result["distance_to_waypoint_m"] = float64(distCm) / 100.0
-	}
-
-	result["bearing_reference"] = (flags >> 6) & 0b11
-	result["perpendicular_crossed"] = (flags >> 4) & 0b11
-	result["arrival_circle_entered"] = (flags >> 2) & 0b11
-	result["calculation_type"] = flags & 0b11
-
-	if offset+4 <= len(data) {
-		etaTimeRaw := u32le(data, offset)
-		offset += 4
-		etaDateRaw := u16le(data, offset)
-		offset += 2
-
-		if etaDateRaw != 0xFFFF && etaTimeRaw != 0xFFFFFFFF {
-			midnight := time.Date(1970, 1, 1, 0, 0, 0, 0, time.UTC).AddDate(0, 0, int(etaDateRaw))
-			eta := midnight.Add(time.Duration(float64(etaTimeRaw)*0.0001) * time.Second)
-			result["eta_utc"] = eta.Format(time.RFC3339)
-		}
-	}
-
-	return result, nil
-}
-
-// === PGN 129540 - GNSS Satellites in View ===
-func decodePGN129540(data []byte) (map[string]interface{}, error) {
-	if len(data) < 3 {
-		return nil, nil
-	}
-
-	result := make(map[string]interface{})
-	sid := u8(data, 0)
-	hdr := u8(data, 1)
-	satsInView := u8(data, 2)
-
-	result["sid"] = sid
-	result["range_residual_mode"] = (hdr >> 6) & 0b11
-	result["sats_in_view"] = satsInView
-
-	offset := 3
-	for i := 1; i <= int(satsInView) && offset+9 <= len(data); i++ {
-		prn := u8(data, offset)
-		offset++
-		elevRaw := i16le(data, offset)
-		offset += 2
-		azimRaw := u16le(data, offset)
-		offset += 2
-		snrRaw := i16le(data, offset)
-		offset += 2
-		rngRaw := u32le(data, offset)
-		offset += 4
-		status := u8(data, offset)
-		offset++
-
-		if prn != 0xFF {
-			result[formatSatField("prn", i)] = prn
-		}
-		if elevRaw != 0x7FFF {
-			result[formatSatField("elevation_rad", i)] = float64(elevRaw) * 0.0001
-		}
-		if azimRaw != 0xFFFF {
-			result[formatSatField("azimuth_rad", i)] = float64(azimRaw) * 0.0001
-		}
-		if snrRaw != 0x7FFF {
-			result[formatSatField("snr_dbhz", i)] = float64(snrRaw) * 0.1
-		}
-		if rngRaw != 0xFFFFFFFF {
-			result[formatSatField("range_residual_m", i)] = float64(rngRaw) * 0.001
-		}
-		result[formatSatField("status", i)] = (status >> 4) & 0x0F
-	}
-
-	return result, nil
-}
-
-func formatSatField(field string, index int) string {
-	return "sv_" + string(rune('0'+index)) + "_" + field
-}
-
-// === PGN 126992 - System Time ===
-func decodePGN126992(data []byte) (map[string]interface{}, error) {
-	if len(data) < 8 {
-		return nil, nil
-	}
-
-	result := make(map[string]interface{})
-	sid := u8(data, 0)
-	timeSource := u8(data, 1)
-	days := u16le(data, 2)
-	ms := u32le(data, 4)
-
-	result["sid"] = sid
-	result["time_source"] = timeSource
-
-	if days != 0xFFFF {
-		date := time.Date(1970, 1, 1, 0, 0, 0, 0, time.UTC).AddDate(0, 0, int(days))
-		result["date"] = date.Format("2006-01-02")
-	}
-
-	if ms != 0xFFFFFFFF {
-		seconds := float64(ms) * 0.0001
-		hours := int(seconds / 3600)
-		minutes := int((seconds - float64(hours*3600)) / 60)
-		secs := seconds - float64(hours*3600) - float64(minutes*60)
-		result["time_of_day"] = formatTime(hours, minutes, secs)
-	}
-
-	return result, nil
-}
-
-func formatTime(h, m int, s float64) string {
-	return time.Date(0, 1, 1, h, m, int(s), int((s-float64(int(s)))*1e9), time.UTC).Format("15:04:05.000")
-}
-
-// === PGN 127508 - Battery Status ===
-func decodePGN127508(data []byte) (map[string]interface{}, error) {
-	if len(data) < 8 {
-		return nil, nil
-	}
-
-	result := make(map[string]interface{})
-	instance := u8(data, 0)
-	voltageRaw := u16le(data, 1)
-	currentRaw := i16le(data, 3)
-	tempRaw := u16le(data, 5)
-	sid := u8(data, 7)
-
-	result["battery_instance"] = instance
-	result["sid"] = sid
-
-	if voltageRaw != 0xFFFF {
-		result["battery_voltage_v"] = float64(voltageRaw) * 0.01
-	}
-
-	if currentRaw != 0x7FFF {
-		result["battery_current_a"] = float64(currentRaw) * 0.1
-	}
-
-	if tempRaw != 0xFFFF {
-		result["battery_temperature_c"] = float64(tempRaw)*0.01 - 273.15
-	}
-
-	return result, nil
-}
-
-// === PGN 127489 - Engine Parameters Dynamic ===
-func decodePGN127489(data []byte) (map[string]interface{}, error) {
-	if len(data) < 8 {
-		return nil, nil
-	}
-
-	result := make(map[string]interface{})
-	instance := u8(data, 0)
-	oilPressureRaw := u16le(data, 1)
-	oilTempRaw := u16le(data, 3)
-	engineTempRaw := u16le(data, 5)
-
-	result["engine_instance"] = instance
-
-	if oilPressureRaw != 0xFFFF {
-		result["oil_pressure_pa"] = float64(oilPressureRaw) * 100
-	}
-
-	if oilTempRaw != 0xFFFF {
-		result["oil_temperature_c"] = float64(oilTempRaw)*0.1 - 273.15
-	}
-
-	if engineTempRaw != 0xFFFF {
-		result["engine_temperature_c"] = float64(engineTempRaw)*0.01 - 273.15
-	}
-
-	if len(data) >= 9 {
-		altVoltageRaw := u16le(data, 7)
-		if altVoltageRaw != 0xFFFF {
-			result["alternator_voltage_v"] = float64(altVoltageRaw) * 0.01
-		}
-	}
-
-	return result, nil
-}
-
-// === PGN 130310 - Environmental Parameters ===
-func decodePGN130310(data []byte) (map[string]interface{}, error) {
-	if len(data) < 12 {
-		return nil, nil
-	}
-
-	result := make(map[string]interface{})
-	sid := u8(data, 0)
-	airTempRaw := u16le(data, 4)
-	waterTempRaw := u16le(data, 6)
-	humidityRaw := u16le(data, 8)
-	pressureRaw := u16le(data, 10)
-
-	result["sid"] = sid
-
-	if airTempRaw != 0xFFFF {
-		result["air_temperature_c"] = float64(airTempRaw)*0.01 - 273.15
-	}
-
-	if waterTempRaw != 0xFFFF {
-		result["water_temperature_c"] = float64(waterTempRaw)*0.01 - 273.15
-	}
-
-	if humidityRaw != 0xFFFF {
-		result["relative_humidity_pct"] = float64(humidityRaw) * 0.004
-	}
-
-	if pressureRaw != 0xFFFF {
-		result["atmospheric_pressure_hpa"] = float64(pressureRaw) * 0.1
-	}
-
-	return result, nil
-}
-
-// === PGN 130312 - Temperature ===
-func decodePGN130312(data []byte) (map[string]interface{}, error) {
-	if len(data) < 6 {
-		return nil, nil
-	}
-
-	result := make(map[string]interface{})
-	sid := u8(data, 0)
-	instance := u8(data, 1)
-	source := u8(data, 2)
-	actualTempRaw := u16le(data, 3)
-
-	result["sid"] = sid
-	result["temperature_instance"] = instance
-	result["temperature_source"] = source
-
-	if actualTempRaw != 0xFFFF {
-		result["actual_temperature_c"] = float64(actualTempRaw)*0.01 - 273.15
-	}
-
-	if len(data) >= 7 {
-		setTempRaw := u16le(data, 5)
-		if setTempRaw != 0xFFFF {
-			result["set_temperature_c"] = float64(setTempRaw)*0.01 - 273.15
-		}
-	}
-
-	return result, nil
-}
-
-// === PGN 130313 - Humidity ===
-func decodePGN130313(data []byte) (map[string]interface{}, error) {
-	if len(data) < 6 {
-		return nil, nil
-	}
-
-	result := make(map[string]interface{})
-	sid := u8(data, 0)
-	instance := u8(data, 1)
-	source := u8(data, 2)
-	actualHumidityRaw := u16le(data, 3)
-
-	result["sid"] = sid
-	result["humidity_instance"] = instance
-	result["humidity_source"] = source
-
-	if actualHumidityRaw != 0xFFFF {
-		result["actual_humidity_pct"] = float64(actualHumidityRaw) * 0.004
-	}
-
-	return result, nil
-}
\ No newline at end of file
+package nmea
+
+import (
+	"math"
+	"strings"
+	"time"
+)
+
+// === PGN 129029 - GNSS Position Data ===
+func decodePGN129029(data []byte) (map[string]interface{}, error) {
+	if len(data) < 43 {
+		return nil, nil
+	}
+
+	result := make(map[string]interface{})
+	sid := u8(data, 0)
+	dateDays := u16le(data, 1)
+	timeRaw := u32le(data, 3)
+	latRaw := i64le(data, 7)
+	lonRaw := i64le(data, 15)
+	altRaw := i64le(data, 23)
+
+	pack1 := u8(data, 31)
+	gnssType := pack1 & 0x0F
+	method := (pack1 >> 4) & 0x0F
+
+	pack2 := u8(data, 32)
+	integrity := pack2 & 0b11
+
+	svs := u8(data, 33)
+	hdopRaw := i16le(data, 34)
+	pdopRaw := i16le(data, 36)
+	geoidRaw := i32le(data, 38)
+	refStations := u8(data, 42)
+
+	result["sid"] = sid
+
+	// Build UTC timestamp
+	if dateDays != 0xFFFF && timeRaw != 0xFFFFFFFF {
+		midnight := time.Date(1970, 1, 1, 0, 0, 0, 0, time.UTC).AddDate(0, 0, int(dateDays))
+		fixTime := midnight.Add(time.Duration(float64(timeRaw)*0.0001) * time.Second)
+		result["fix_time_utc"] = fixTime.Format(time.RFC3339)
+	} else {
+		invalid(result, "fix_time_utc")
+	}
+
+	if latRaw != 0x7FFFFFFFFFFFFFFF {
+		result["latitude"] = float64(latRaw) * 1e-16
+	} else {
+		invalid(result, "latitude")
+	}
+
+	if lonRaw != 0x7FFFFFFFFFFFFFFF {
+		result["longitude"] = float64(lonRaw) * 1e-16
+	} else {
+		invalid(result, "longitude")
+	}
+
+	if altRaw != 0x7FFFFFFFFFFFFFFF {
+		result["altitude_m"] = float64(altRaw) * 1e-6
+	} else {
+		invalid(result, "altitude_m")
+	}
+
+	result["gnss_type"] = gnssType
+	result["gnss_type_str"] = EnumString(GNSSTypeNames, gnssType)
+	result["method"] = method
+	result["method_str"] = EnumString(GNSSMethodNames, method)
+	result["integrity"] = integrity
+	result["satellites"] = svs
+
+	if hdopRaw != 0x7FFF {
+		result["hdop"] = float64(hdopRaw) * 0.01
+	} else {
+		invalid(result, "hdop")
+	}
+
+	if pdopRaw != 0x7FFF {
+		result["pdop"] = float64(pdopRaw) * 0.01
+	} else {
+		invalid(result, "pdop")
+	}
+
+	if geoidRaw != 0x7FFFFFFF {
+		result["geoidal_separation_m"] = float64(geoidRaw) * 0.01
+	} else {
+		invalid(result, "geoidal_separation_m")
+	}
+
+	result["reference_stations"] = refStations
+
+	return result, nil
+}
+
+// === PGN 127245 - Rudder ===
+func decodePGN127245(data []byte) (map[string]interface{}, error) {
+	if len(data) < 6 {
+		return nil, nil
+	}
+
+	result := make(map[string]interface{})
+	instance := u8(data, 0)
+	directionOrder := u8(data, 1)
+	angleOrderRaw := i16le(data, 2)
+	positionRaw := i16le(data, 4)
+
+	result["rudder_instance"] = instance
+	result["direction_order"] = directionOrder
+
+	if angleOrderRaw != 0x7FFF {
+		angle := float64(angleOrderRaw) * 0.0001
+		result["rudder_angle_order_rad"] = angle
+		result["rudder_angle_order_deg"] = angle * 180.0 / math.Pi
+	} else {
+		invalid(result, "rudder_angle_order_rad", "rudder_angle_order_deg")
+	}
+
+	if positionRaw != 0x7FFF {
+		pos := float64(positionRaw) * 0.0001
+		result["rudder_position_rad"] = pos
+		result["rudder_position_deg"] = pos * 180.0 / math.Pi
+	} else {
+		invalid(result, "rudder_position_rad", "rudder_position_deg")
+	}
+
+	return result, nil
+}
+
+// === PGN 127237 - Heading/Track Control (Autopilot) ===
+func decodePGN127237(data []byte) (map[string]interface{}, error) {
+	if len(data) < 8 {
+		return nil, nil
+	}
+
+	result := make(map[string]interface{})
+
+	b0 := u8(data, 0)
+	b1 := u8(data, 1)
+	b2 := u8(data, 2)
+
+	result["rudder_limit_exceeded"] = (b0 >> 6) & 0b11
+	result["off_heading_exceeded"] = (b0 >> 4) & 0b11
+	result["off_track_exceeded"] = (b0 >> 2) & 0b11
+	result["override"] = b0 & 0b11
+	result["steering_mode"] = (b1 >> 5) & 0b111
+	result["turn_mode"] = (b1 >> 2) & 0b111
+	result["heading_reference"] = ((b1 & 0b11) | ((b2 >> 7) & 0b1) << 2)
+	result["heading_reference_str"] = EnumString(HeadingReferenceNames, b1&0b11)
+	result["commanded_rudder_direction"] = b2 & 0b111
+
+	offset := 3
+	cmdRudderAngleRaw := i16le(data, offset)
+	offset += 2
+	headingToSteerRaw := u16le(data, offset)
+	offset += 2
+	trackRaw := u16le(data, offset)
+	offset += 2
+
+	if cmdRudderAngleRaw != 0x7FFF {
+		result["commanded_rudder_angle_rad"] = float64(cmdRudderAngleRaw) * 0.0001
+	} else {
+		invalid(result, "commanded_rudder_angle_rad")
+	}
+
+	if headingToSteerRaw != 0xFFFF {
+		result["heading_to_steer_rad"] = float64(headingToSteerRaw) * 0.0001
+	} else {
+		invalid(result, "heading_to_steer_rad")
+	}
+
+	if trackRaw != 0xFFFF {
+		result["track_rad"] = float64(trackRaw) * 0.0001
+	} else {
+		invalid(result, "track_rad")
+	}
+
+	return result, nil
+}
+
+// === PGN 129284 - Navigation Data ===
+func decodePGN129284(data []byte) (map[string]interface{}, error) {
+	if len(data) < 8 {
+		return nil, nil
+	}
+
+	result := make(map[string]interface{})
+	offset := 0
+
+	sid := u8(data, offset)
+	offset++
+	distCm := u32le(data, offset)
+	offset += 4
+	flags := u8(data, offset)
+	offset++
+
+	result["sid"] = sid
+
+	if distCm != 0xFFFFFFFF {
+		result["distance_to_waypoint_m"] = float64(distCm) / 100.0
+	} else {
+		invalid(result, "distance_to_waypoint_m")
+	}
+
+	result["bearing_reference"] = (flags >> 6) & 0b11
+	result["perpendicular_crossed"] = (flags >> 4) & 0b11
+	result["arrival_circle_entered"] = (flags >> 2) & 0b11
+	result["calculation_type"] = flags & 0b11
+
+	if offset+4 <= len(data) {
+		etaTimeRaw := u32le(data, offset)
+		offset += 4
+		etaDateRaw := u16le(data, offset)
+		offset += 2
+
+		if etaDateRaw != 0xFFFF && etaTimeRaw != 0xFFFFFFFF {
+			midnight := time.Date(1970, 1, 1, 0, 0, 0, 0, time.UTC).AddDate(0, 0, int(etaDateRaw))
+			eta := midnight.Add(time.Duration(float64(etaTimeRaw)*0.0001) * time.Second)
+			result["eta_utc"] = eta.Format(time.RFC3339)
+		} else {
+			invalid(result, "eta_utc")
+		}
+	}
+
+	return result, nil
+}
+
+// === PGN 129285 - Route/WP Information ===
+func decodePGN129285(data []byte) (map[string]interface{}, error) {
+	if len(data) < 10 {
+		return nil, nil
+	}
+
+	result := make(map[string]interface{})
+	offset := 0
+
+	startRPS := u16le(data, offset)
+	offset += 2
+	nItems := u16le(data, offset)
+	offset += 2
+	databaseID := u16le(data, offset)
+	offset += 2
+	routeID := u16le(data, offset)
+	offset += 2
+	flags := u8(data, offset)
+	offset++
+
+	result["start_rps"] = startRPS
+	result["items"] = nItems
+	result["database_id"] = databaseID
+	result["route_id"] = routeID
+	result["navigation_direction"] = flags & 0b111
+	result["supplementary_data"] = (flags >> 3) & 0b11
+
+	routeName, offset := stringLAU(data, offset)
+	result["route_name"] = routeName
+	offset++ // reserved
+
+	waypoints := make([]map[string]interface{}, 0, int(nItems))
+	for i := 0; i < int(nItems) && offset+2 <= len(data); i++ {
+		wpID := u16le(data, offset)
+		offset += 2
+
+		var wpName string
+		wpName, offset = stringLAU(data, offset)
+
+		latRaw := i32le(data, offset)
+		offset += 4
+		lonRaw := i32le(data, offset)
+		offset += 4
+
+		wp := map[string]interface{}{
+			"wp_id":   wpID,
+			"wp_name": wpName,
+		}
+		if latRaw != 0x7FFFFFFF {
+			wp["latitude"] = float64(latRaw) * 1e-7
+		}
+		if lonRaw != 0x7FFFFFFF {
+			wp["longitude"] = float64(lonRaw) * 1e-7
+		}
+		waypoints = append(waypoints, wp)
+	}
+
+	result["waypoints"] = waypoints
+
+	return result, nil
+}
+
+// stringLAU reads a variable-length NMEA2000 string (length byte including
+// the two header bytes, encoding byte, then characters) and returns the
+// trimmed text and the offset just past it
+func stringLAU(data []byte, offset int) (string, int) {
+	length := int(u8(data, offset))
+	if length < 2 || length == 0xFF {
+		return "", offset + 2
+	}
+
+	start := offset + 2
+	end := offset + length
+	if end > len(data) {
+		end = len(data)
+	}
+
+	// Strip padding (NUL, 0xFF, '@' and trailing spaces)
+	for end > start {
+		c := data[end-1]
+		if c != 0x00 && c != 0xFF && c != '@' && c != ' ' {
+			break
+		}
+		end--
+	}
+	if start >= end {
+		return "", offset + length
+	}
+
+	return string(data[start:end]), offset + length
+}
+
+// === PGN 129540 - GNSS Satellites in View ===
+func decodePGN129540(data []byte) (map[string]interface{}, error) {
+	if len(data) < 3 {
+		return nil, nil
+	}
+
+	result := make(map[string]interface{})
+	sid := u8(data, 0)
+	hdr := u8(data, 1)
+	satsInView := u8(data, 2)
+
+	result["sid"] = sid
+	result["range_residual_mode"] = (hdr >> 6) & 0b11
+	result["sats_in_view"] = satsInView
+
+	offset := 3
+	for i := 1; i <= int(satsInView) && offset+9 <= len(data); i++ {
+		prn := u8(data, offset)
+		offset++
+		elevRaw := i16le(data, offset)
+		offset += 2
+		azimRaw := u16le(data, offset)
+		offset += 2
+		snrRaw := i16le(data, offset)
+		offset += 2
+		rngRaw := u32le(data, offset)
+		offset += 4
+		status := u8(data, offset)
+		offset++
+
+		if prn != 0xFF {
+			result[formatSatField("prn", i)] = prn
+		}
+		if elevRaw != 0x7FFF {
+			result[formatSatField("elevation_rad", i)] = float64(elevRaw) * 0.0001
+		}
+		if azimRaw != 0xFFFF {
+			result[formatSatField("azimuth_rad", i)] = float64(azimRaw) * 0.0001
+		}
+		if snrRaw != 0x7FFF {
+			result[formatSatField("snr_dbhz", i)] = float64(snrRaw) * 0.1
+		}
+		if rngRaw != 0xFFFFFFFF {
+			result[formatSatField("range_residual_m", i)] = float64(rngRaw) * 0.001
+		}
+		result[formatSatField("status", i)] = (status >> 4) & 0x0F
+	}
+
+	return result, nil
+}
+
+func formatSatField(field string, index int) string {
+	return "sv_" + string(rune('0'+index)) + "_" + field
+}
+
+// === PGN 126992 - System Time ===
+func decodePGN126992(data []byte) (map[string]interface{}, error) {
+	if len(data) < 8 {
+		return nil, nil
+	}
+
+	result := make(map[string]interface{})
+	sid := u8(data, 0)
+	timeSource := u8(data, 1)
+	days := u16le(data, 2)
+	ms := u32le(data, 4)
+
+	result["sid"] = sid
+	result["time_source"] = timeSource
+
+	if days != 0xFFFF {
+		date := time.Date(1970, 1, 1, 0, 0, 0, 0, time.UTC).AddDate(0, 0, int(days))
+		result["date"] = date.Format("2006-01-02")
+	} else {
+		invalid(result, "date")
+	}
+
+	if ms != 0xFFFFFFFF {
+		seconds := float64(ms) * 0.0001
+		hours := int(seconds / 3600)
+		minutes := int((seconds - float64(hours*3600)) / 60)
+		secs := seconds - float64(hours*3600) - float64(minutes*60)
+		result["time_of_day"] = formatTime(hours, minutes, secs)
+	} else {
+		invalid(result, "time_of_day")
+	}
+
+	return result, nil
+}
+
+func formatTime(h, m int, s float64) string {
+	return time.Date(0, 1, 1, h, m, int(s), int((s-float64(int(s)))*1e9), time.UTC).Format("15:04:05.000")
+}
+
+// === PGN 127508 - Battery Status ===
+func decodePGN127508(data []byte) (map[string]interface{}, error) {
+	if len(data) < 8 {
+		return nil, nil
+	}
+
+	result := make(map[string]interface{})
+	instance := u8(data, 0)
+	voltageRaw := u16le(data, 1)
+	currentRaw := i16le(data, 3)
+	tempRaw := u16le(data, 5)
+	sid := u8(data, 7)
+
+	result["battery_instance"] = instance
+	result["sid"] = sid
+
+	if voltageRaw != 0xFFFF {
+		result["battery_voltage_v"] = float64(voltageRaw) * 0.01
+	} else {
+		invalid(result, "battery_voltage_v")
+	}
+
+	if currentRaw != 0x7FFF {
+		result["battery_current_a"] = float64(currentRaw) * 0.1
+	} else {
+		invalid(result, "battery_current_a")
+	}
+
+	if tempRaw != 0xFFFF {
+		result["battery_temperature_c"] = float64(tempRaw)*0.01 - 273.15
+	} else {
+		invalid(result, "battery_temperature_c")
+	}
+
+	return result, nil
+}
+
+// === PGN 127489 - Engine Parameters Dynamic ===
+func decodePGN127489(data []byte) (map[string]interface{}, error) {
+	if len(data) < 8 {
+		return nil, nil
+	}
+
+	result := make(map[string]interface{})
+	instance := u8(data, 0)
+	oilPressureRaw := u16le(data, 1)
+	oilTempRaw := u16le(data, 3)
+	engineTempRaw := u16le(data, 5)
+
+	result["engine_instance"] = instance
+
+	if oilPressureRaw != 0xFFFF {
+		result["oil_pressure_pa"] = float64(oilPressureRaw) * 100
+	} else {
+		invalid(result, "oil_pressure_pa")
+	}
+
+	if oilTempRaw != 0xFFFF {
+		result["oil_temperature_c"] = float64(oilTempRaw)*0.1 - 273.15
+	} else {
+		invalid(result, "oil_temperature_c")
+	}
+
+	if engineTempRaw != 0xFFFF {
+		result["engine_temperature_c"] = float64(engineTempRaw)*0.01 - 273.15
+	} else {
+		invalid(result, "engine_temperature_c")
+	}
+
+	if len(data) >= 9 {
+		altVoltageRaw := u16le(data, 7)
+		if altVoltageRaw != 0xFFFF {
+			result["alternator_voltage_v"] = float64(altVoltageRaw) * 0.01
+		} else {
+			invalid(result, "alternator_voltage_v")
+		}
+	}
+
+	// The remaining fields only arrive in the full fast-packet message; the
+	// read helpers return "not available" past the end of the data
+	fuelRateRaw := i16le(data, 9)
+	engineHoursRaw := u32le(data, 11)
+	coolantPressureRaw := u16le(data, 15)
+	fuelPressureRaw := u16le(data, 17)
+	status1 := u16le(data, 20)
+	status2 := u16le(data, 22)
+	loadRaw := i8(data, 24)
+	torqueRaw := i8(data, 25)
+
+	if fuelRateRaw != 0x7FFF {
+		result["fuel_rate_lph"] = float64(fuelRateRaw) * 0.1
+	} else {
+		invalid(result, "fuel_rate_lph")
+	}
+
+	if engineHoursRaw != 0xFFFFFFFF {
+		result["engine_hours"] = float64(engineHoursRaw) / 3600
+	} else {
+		invalid(result, "engine_hours")
+	}
+
+	if coolantPressureRaw != 0xFFFF {
+		result["coolant_pressure_pa"] = float64(coolantPressureRaw) * 100
+	} else {
+		invalid(result, "coolant_pressure_pa")
+	}
+
+	if fuelPressureRaw != 0xFFFF {
+		result["fuel_pressure_pa"] = float64(fuelPressureRaw) * 1000
+	} else {
+		invalid(result, "fuel_pressure_pa")
+	}
+
+	if status1 != 0xFFFF {
+		engineAlarms(result, EngineStatus1Alarms, status1)
+	}
+
+	if status2 != 0xFFFF {
+		engineAlarms(result, EngineStatus2Alarms, status2)
+	}
+
+	if loadRaw != 0x7F {
+		result["engine_load_pct"] = float64(loadRaw)
+	} else {
+		invalid(result, "engine_load_pct")
+	}
+
+	if torqueRaw != 0x7F {
+		result["engine_torque_pct"] = float64(torqueRaw)
+	} else {
+		invalid(result, "engine_torque_pct")
+	}
+
+	return result, nil
+}
+
+// EngineStatus1Alarms names the PGN 127489 discrete status 1 bits, from bit 0
+var EngineStatus1Alarms = []string{
+	"check_engine",
+	"over_temperature",
+	"low_oil_pressure",
+	"low_oil_level",
+	"low_fuel_pressure",
+	"low_system_voltage",
+	"low_coolant_level",
+	"water_flow",
+	"water_in_fuel",
+	"charge_indicator",
+	"preheat_indicator",
+	"high_boost_pressure",
+	"rev_limit_exceeded",
+	"egr_system",
+	"throttle_position_sensor",
+	"emergency_stop",
+}
+
+// EngineStatus2Alarms names the PGN 127489 discrete status 2 bits, from bit 0
+var EngineStatus2Alarms = []string{
+	"warning_level_1",
+	"warning_level_2",
+	"power_reduction",
+	"maintenance_needed",
+	"engine_comm_error",
+	"sub_or_secondary_throttle",
+	"neutral_start_protect",
+	"engine_shutting_down",
+}
+
+// engineAlarms sets an alarm_<name> boolean for every named status bit
+func engineAlarms(result map[string]interface{}, names []string, status uint16) {
+	for bit, name := range names {
+		result["alarm_"+name] = status&(1<<bit) != 0
+	}
+}
+
+// === PGN 127493 - Transmission Parameters Dynamic ===
+func decodePGN127493(data []byte) (map[string]interface{}, error) {
+	if len(data) < 6 {
+		return nil, nil
+	}
+
+	result := make(map[string]interface{})
+	instance := u8(data, 0)
+	gear := u8(data, 1) & 0x03
+	oilPressureRaw := u16le(data, 2)
+	oilTempRaw := u16le(data, 4)
+
+	result["transmission_instance"] = instance
+	result["transmission_gear"] = gear
+	result["transmission_gear_str"] = EnumString(TransmissionGearNames, gear)
+
+	if oilPressureRaw != 0xFFFF {
+		result["transmission_oil_pressure_pa"] = float64(oilPressureRaw) * 100
+	} else {
+		invalid(result, "transmission_oil_pressure_pa")
+	}
+
+	if oilTempRaw != 0xFFFF {
+		result["transmission_oil_temperature_c"] = float64(oilTempRaw)*0.1 - 273.15
+	} else {
+		invalid(result, "transmission_oil_temperature_c")
+	}
+
+	return result, nil
+}
+
+// FluidTypes maps the PGN 127505 fluid type nibble to a name
+var FluidTypes = map[uint8]string{
+	0: "fuel",
+	1: "water",
+	2: "gray_water",
+	3: "live_well",
+	4: "oil",
+	5: "black_water",
+}
+
+// === PGN 127505 - Fluid Level ===
+func decodePGN127505(data []byte) (map[string]interface{}, error) {
+	if len(data) < 7 {
+		return nil, nil
+	}
+
+	result := make(map[string]interface{})
+	b0 := u8(data, 0)
+	instance := b0 & 0x0F
+	fluidType := (b0 >> 4) & 0x0F
+	levelRaw := i16le(data, 1)
+	capacityRaw := u32le(data, 3)
+
+	result["fluid_instance"] = instance
+	result["fluid_type"] = fluidType
+	if name, ok := FluidTypes[fluidType]; ok {
+		result["fluid_type_name"] = name
+	} else {
+		result["fluid_type_name"] = "unknown"
+	}
+
+	if levelRaw != 0x7FFF {
+		result["fluid_level_pct"] = float64(levelRaw) * 0.004
+	} else {
+		invalid(result, "fluid_level_pct")
+	}
+
+	if capacityRaw != 0xFFFFFFFF {
+		result["tank_capacity_l"] = float64(capacityRaw) * 0.1
+	} else {
+		invalid(result, "tank_capacity_l")
+	}
+
+	return result, nil
+}
+
+// === PGN 130310 - Environmental Parameters ===
+func decodePGN130310(data []byte) (map[string]interface{}, error) {
+	if len(data) < 12 {
+		return nil, nil
+	}
+
+	result := make(map[string]interface{})
+	sid := u8(data, 0)
+	airTempRaw := u16le(data, 4)
+	waterTempRaw := u16le(data, 6)
+	humidityRaw := u16le(data, 8)
+	pressureRaw := u16le(data, 10)
+
+	result["sid"] = sid
+
+	if airTempRaw != 0xFFFF {
+		result["air_temperature_c"] = float64(airTempRaw)*0.01 - 273.15
+	} else {
+		invalid(result, "air_temperature_c")
+	}
+
+	if waterTempRaw != 0xFFFF {
+		result["water_temperature_c"] = float64(waterTempRaw)*0.01 - 273.15
+	} else {
+		invalid(result, "water_temperature_c")
+	}
+
+	if humidityRaw != 0xFFFF {
+		result["relative_humidity_pct"] = float64(humidityRaw) * 0.004
+	} else {
+		invalid(result, "relative_humidity_pct")
+	}
+
+	if pressureRaw != 0xFFFF {
+		result["atmospheric_pressure_hpa"] = float64(pressureRaw) * 0.1
+	} else {
+		invalid(result, "atmospheric_pressure_hpa")
+	}
+
+	return result, nil
+}
+
+// === PGN 130312 - Temperature ===
+func decodePGN130312(data []byte) (map[string]interface{}, error) {
+	if len(data) < 6 {
+		return nil, nil
+	}
+
+	result := make(map[string]interface{})
+	sid := u8(data, 0)
+	instance := u8(data, 1)
+	source := u8(data, 2)
+	actualTempRaw := u16le(data, 3)
+
+	result["sid"] = sid
+	result["temperature_instance"] = instance
+	result["temperature_source"] = source
+
+	if actualTempRaw != 0xFFFF {
+		result["actual_temperature_c"] = float64(actualTempRaw)*0.01 - 273.15
+	} else {
+		invalid(result, "actual_temperature_c")
+	}
+
+	if len(data) >= 7 {
+		setTempRaw := u16le(data, 5)
+		if setTempRaw != 0xFFFF {
+			result["set_temperature_c"] = float64(setTempRaw)*0.01 - 273.15
+		} else {
+			invalid(result, "set_temperature_c")
+		}
+	}
+
+	return result, nil
+}
+
+// === PGN 130313 - Humidity ===
+func decodePGN130313(data []byte) (map[string]interface{}, error) {
+	if len(data) < 6 {
+		return nil, nil
+	}
+
+	result := make(map[string]interface{})
+	sid := u8(data, 0)
+	instance := u8(data, 1)
+	source := u8(data, 2)
+	actualHumidityRaw := u16le(data, 3)
+
+	result["sid"] = sid
+	result["humidity_instance"] = instance
+	result["humidity_source"] = source
+
+	if actualHumidityRaw != 0xFFFF {
+		result["actual_humidity_pct"] = float64(actualHumidityRaw) * 0.004
+	} else {
+		invalid(result, "actual_humidity_pct")
+	}
+
+	return result, nil
+}
+
+// === PGN 127258 - Magnetic Variation ===
+func decodePGN127258(data []byte) (map[string]interface{}, error) {
+	if len(data) < 6 {
+		return nil, nil
+	}
+
+	result := make(map[string]interface{})
+	sid := u8(data, 0)
+	source := u8(data, 1) & 0x0F
+	ageDays := u16le(data, 2)
+	variationRaw := i16le(data, 4)
+
+	result["sid"] = sid
+	result["variation_source"] = source
+	result["variation_source_str"] = EnumString(VariationSourceNames, source)
+
+	if ageDays != 0xFFFF {
+		result["age_of_service_days"] = int(ageDays)
+		result["age_of_service_date"] = time.Unix(0, 0).UTC().AddDate(0, 0, int(ageDays)).Format("2006-01-02")
+	} else {
+		invalid(result, "age_of_service_days", "age_of_service_date")
+	}
+
+	if variationRaw != 0x7FFF {
+		variation := float64(variationRaw) * 0.0001
+		result["variation_rad"] = variation
+		result["variation_deg"] = variation * 180.0 / math.Pi
+	} else {
+		invalid(result, "variation_rad", "variation_deg")
+	}
+
+	return result, nil
+}
+
+// === PGN 126996 - Product Information ===
+func decodePGN126996(data []byte) (map[string]interface{}, error) {
+	if len(data) < 36 {
+		return nil, nil
+	}
+
+	result := make(map[string]interface{})
+	versionRaw := u16le(data, 0)
+	productCode := u16le(data, 2)
+
+	if versionRaw != 0xFFFF {
+		result["nmea2000_version"] = float64(versionRaw) * 0.001
+	} else {
+		invalid(result, "nmea2000_version")
+	}
+	if productCode != 0xFFFF {
+		result["product_code"] = productCode
+	} else {
+		invalid(result, "product_code")
+	}
+
+	result["model_id"] = stringFix(data, 4, 32)
+	result["software_version"] = stringFix(data, 36, 32)
+	result["model_version"] = stringFix(data, 68, 32)
+	result["model_serial"] = stringFix(data, 100, 32)
+	if level := u8(data, 132); level != 0xFF {
+		result["certification_level"] = level
+	} else {
+		invalid(result, "certification_level")
+	}
+
+	if load := u8(data, 133); load != 0xFF {
+		result["load_equivalency"] = load // multiples of 50 mA
+	} else {
+		invalid(result, "load_equivalency")
+	}
+
+	return result, nil
+}
+
+// === PGN 126998 - Configuration Information ===
+func decodePGN126998(data []byte) (map[string]interface{}, error) {
+	if len(data) < 6 {
+		return nil, nil
+	}
+
+	result := make(map[string]interface{})
+	offset := 0
+
+	var text string
+	text, offset = stringLAU(data, offset)
+	result["installation_description_1"] = text
+	text, offset = stringLAU(data, offset)
+	result["installation_description_2"] = text
+	text, _ = stringLAU(data, offset)
+	result["manufacturer_information"] = text
+
+	return result, nil
+}
+
+// stringFix reads a fixed-width NMEA2000 text field, which ends at the
+// first NUL or 0xFF and may be padded with '@' or spaces
+func stringFix(data []byte, offset, width int) string {
+	if offset >= len(data) {
+		return ""
+	}
+	end := offset + width
+	if end > len(data) {
+		end = len(data)
+	}
+	field := data[offset:end]
+	for i, c := range field {
+		if c == 0x00 || c == 0xFF {
+			field = field[:i]
+			break
+		}
+	}
+	return strings.TrimRight(string(field), "@ ")
+}
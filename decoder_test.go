@@ -0,0 +1,236 @@
+package nmea
+
+import (
+	"encoding/binary"
+	"encoding/hex"
+	"encoding/json"
+	"math"
+	"os"
+	"sort"
+	"testing"
+)
+
+// goldenFrame is one entry of testdata/decoder_golden.json: a payload and
+// the complete set of fields it must decode to
+type goldenFrame struct {
+	PGN       int                    `json:"pgn"`
+	Name      string                 `json:"name"`
+	DataHex   string                 `json:"data_hex"`
+	Tolerance float64                `json:"tolerance"` // for float fields
+	Fields    map[string]interface{} `json:"fields"`
+	Invalid   []string               `json:"invalid"` // "not available" fields
+	Empty     bool                   `json:"empty"`   // decodes to nothing
+}
+
+func loadGoldenFrames(t *testing.T) []goldenFrame {
+	t.Helper()
+	data, err := os.ReadFile("testdata/decoder_golden.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var frames []goldenFrame
+	if err := json.Unmarshal(data, &frames); err != nil {
+		t.Fatal(err)
+	}
+	return frames
+}
+
+func TestDecoderGolden(t *testing.T) {
+	d := NewDecoder()
+	d.MarkInvalid = true
+
+	for _, g := range loadGoldenFrames(t) {
+		g := g
+		t.Run(g.Name, func(t *testing.T) {
+			data, err := hex.DecodeString(g.DataHex)
+			if err != nil {
+				t.Fatalf("bad data_hex: %v", err)
+			}
+			result, err := d.Decode(g.PGN, data)
+			if err != nil {
+				t.Fatalf("Decode(%d): %v", g.PGN, err)
+			}
+			if g.Empty {
+				if len(result) != 0 {
+					t.Fatalf("Decode(%d) = %v, want no fields", g.PGN, result)
+				}
+				return
+			}
+
+			want := make(map[string]bool)
+			for name, expected := range g.Fields {
+				want[name] = true
+				got, ok := result[name]
+				if !ok || got == nil {
+					t.Errorf("%s missing", name)
+					continue
+				}
+				if !goldenMatch(got, expected, g.Tolerance) {
+					t.Errorf("%s = %v, want %v (±%g)", name, got, expected, g.Tolerance)
+				}
+			}
+			for _, name := range g.Invalid {
+				want[name] = true
+				if got, ok := result[name]; !ok || got != nil {
+					t.Errorf("%s = %v, want not available", name, got)
+				}
+			}
+
+			var extra []string
+			for name := range result {
+				if !want[name] {
+					extra = append(extra, name)
+				}
+			}
+			if len(extra) > 0 {
+				sort.Strings(extra)
+				t.Errorf("unexpected fields %v", extra)
+			}
+		})
+	}
+}
+
+// goldenMatch compares a decoded value with its JSON expectation: numbers
+// of any type within tolerance, everything else exactly
+func goldenMatch(got, expected interface{}, tolerance float64) bool {
+	want, isNum := expected.(float64)
+	if !isNum {
+		return got == expected
+	}
+	var v float64
+	switch g := got.(type) {
+	case float64:
+		v = g
+	case uint8:
+		v = float64(g)
+	case uint16:
+		v = float64(g)
+	case uint32:
+		v = float64(g)
+	case int:
+		v = float64(g)
+	default:
+		return false
+	}
+	return math.Abs(v-want) <= tolerance
+}
+
+// TestDecoderRoundTrip encodes values across each field's range the way a
+// sender would and checks the critical decoders return them to within half
+// a resolution step
+func TestDecoderRoundTrip(t *testing.T) {
+	d := NewDecoder()
+
+	angles := []float64{-math.Pi, -1.2345, -0.0001, 0, 0.0001, 0.5, math.Pi}
+	for _, a := range angles {
+		data := []byte{0, 0, 0, 0, 0, 0, 0, 0xFF}
+		binary.LittleEndian.PutUint16(data[1:], uint16(int16(math.Round(a/0.0001))))
+		binary.LittleEndian.PutUint16(data[3:], uint16(int16(math.Round(-a/2/0.0001))))
+		binary.LittleEndian.PutUint16(data[5:], uint16(int16(math.Round(a/2/0.0001))))
+
+		result, _ := d.Decode(127257, data)
+		roundTripCheck(t, result, "yaw_rad", a, 0.0001)
+		roundTripCheck(t, result, "pitch_rad", -a/2, 0.0001)
+		roundTripCheck(t, result, "roll_rad", a/2, 0.0001)
+		roundTripCheck(t, result, "heel_angle", a/2*180/math.Pi, 0.0001*180/math.Pi)
+	}
+
+	for _, speed := range []float64{0, 0.01, 5.55, 25.7, 655.32} {
+		for _, angle := range []float64{0, 0.0001, 1, math.Pi, 2*math.Pi - 0.0001} {
+			data := []byte{0, 0, 0, 0, 0, 0x02, 0xFF, 0xFF}
+			binary.LittleEndian.PutUint16(data[1:], uint16(math.Round(speed/0.01)))
+			binary.LittleEndian.PutUint16(data[3:], uint16(math.Round(angle/0.0001)))
+
+			result, _ := d.Decode(130306, data)
+			roundTripCheck(t, result, "wind_speed_ms", speed, 0.01)
+			roundTripCheck(t, result, "wind_angle_rad", angle, 0.0001)
+
+			data = []byte{0, 0xFC, 0, 0, 0, 0, 0xFF, 0xFF}
+			binary.LittleEndian.PutUint16(data[2:], uint16(math.Round(angle/0.0001)))
+			binary.LittleEndian.PutUint16(data[4:], uint16(math.Round(speed/0.01)))
+
+			result, _ = d.Decode(129026, data)
+			roundTripCheck(t, result, "cog_rad", angle, 0.0001)
+			roundTripCheck(t, result, "sog_ms", speed, 0.01)
+			roundTripCheck(t, result, "sog_kts", speed*1.94384, 0.01*1.94384)
+		}
+	}
+}
+
+func roundTripCheck(t *testing.T, result map[string]interface{}, field string, want, resolution float64) {
+	t.Helper()
+	got, ok := result[field].(float64)
+	if !ok {
+		t.Errorf("%s missing from %v", field, result)
+		return
+	}
+	if math.Abs(got-want) > resolution/2+1e-9 {
+		t.Errorf("%s = %v, want %v (±%g)", field, got, want, resolution/2)
+	}
+}
+
+// TestDecodePGN129025Signed checks positions in every quadrant, since
+// latitude and longitude are signed and a south or west fix must keep its
+// sign
+func TestDecodePGN129025Signed(t *testing.T) {
+	d := NewDecoder()
+
+	tests := []struct {
+		name     string
+		lat, lon float64
+	}{
+		{"north east, Portsmouth harbour", 50.7950, 1.1072},
+		{"north west, Cowes", 50.7625, -1.2977},
+		{"south west, Rio de Janeiro", -22.9068, -43.1729},
+		{"south east, Sydney", -33.8568, 151.2153},
+		{"extremes", -90, -180},
+	}
+	for _, tt := range tests {
+		data := make([]byte, 8)
+		binary.LittleEndian.PutUint32(data[0:], uint32(int32(math.Round(tt.lat/1e-7))))
+		binary.LittleEndian.PutUint32(data[4:], uint32(int32(math.Round(tt.lon/1e-7))))
+
+		result, err := d.Decode(129025, data)
+		if err != nil {
+			t.Errorf("%s: %v", tt.name, err)
+			continue
+		}
+		roundTripCheck(t, result, "latitude", tt.lat, 1e-7)
+		roundTripCheck(t, result, "longitude", tt.lon, 1e-7)
+	}
+}
+
+func TestDecodePGN127505(t *testing.T) {
+	d := NewDecoder()
+
+	// Instance 2, black water (5), 62.5% of a 120.5 l tank
+	data := []byte{0x52, 0, 0, 0, 0, 0, 0, 0xFF}
+	binary.LittleEndian.PutUint16(data[1:], uint16(int16(62.5/0.004)))
+	binary.LittleEndian.PutUint32(data[3:], 1205)
+
+	result, err := d.Decode(127505, data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result["fluid_instance"] != uint8(2) || result["fluid_type"] != uint8(5) || result["fluid_type_name"] != "black_water" {
+		t.Errorf("instance/type = %v %v %v, want 2 5 black_water",
+			result["fluid_instance"], result["fluid_type"], result["fluid_type_name"])
+	}
+	roundTripCheck(t, result, "fluid_level_pct", 62.5, 0.004)
+	roundTripCheck(t, result, "tank_capacity_l", 120.5, 0.1)
+
+	// Water tank 0, level and capacity not available
+	result, err = d.Decode(127505, []byte{0x10, 0xFF, 0x7F, 0xFF, 0xFF, 0xFF, 0xFF, 0xFF})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := result["fluid_level_pct"]; ok {
+		t.Errorf("fluid_level_pct = %v for 0x7FFF, want it dropped", result["fluid_level_pct"])
+	}
+	if _, ok := result["tank_capacity_l"]; ok {
+		t.Errorf("tank_capacity_l = %v for 0xFFFFFFFF, want it dropped", result["tank_capacity_l"])
+	}
+	if result["fluid_type_name"] != "water" {
+		t.Errorf("fluid_type_name = %v, want water", result["fluid_type_name"])
+	}
+}
@@ -0,0 +1,115 @@
+package nmea
+
+import (
+	"sort"
+	"sync"
+	"time"
+)
+
+// DeviceInfo is what is known about one source address on the bus: the
+// product (126996) and configuration (126998) information it announced and
+// the PGNs it has been seen sending. Devices broadcast product information
+// at power-up or on request, so a device that was already running may be
+// listed with its PGNs only.
+type DeviceInfo struct {
+	Source uint8 `json:"source"`
+
+	// PGN 126996 Product Information
+	NMEA2000Version    float64 `json:"nmea2000_version,omitempty"`
+	ProductCode        uint16  `json:"product_code,omitempty"`
+	ModelID            string  `json:"model_id,omitempty"`
+	SoftwareVersion    string  `json:"software_version,omitempty"`
+	ModelVersion       string  `json:"model_version,omitempty"`
+	ModelSerial        string  `json:"model_serial,omitempty"`
+	CertificationLevel uint8   `json:"certification_level,omitempty"`
+	LoadEquivalency    uint8   `json:"load_equivalency,omitempty"` // multiples of 50 mA
+
+	// PGN 126998 Configuration Information
+	InstallationDescription1 string `json:"installation_description_1,omitempty"`
+	InstallationDescription2 string `json:"installation_description_2,omitempty"`
+	ManufacturerInformation  string `json:"manufacturer_information,omitempty"`
+
+	PGNs      []int      `json:"pgns"` // PGNs received from this address, ascending
+	FirstSeen time.Time  `json:"first_seen"`
+	LastSeen  time.Time  `json:"last_seen"`
+	InfoAt    *time.Time `json:"product_info_at,omitempty"` // last 126996
+}
+
+// DeviceRegistry tracks DeviceInfo by source address
+type DeviceRegistry struct {
+	mu      sync.RWMutex
+	devices map[uint8]*deviceEntry
+}
+
+type deviceEntry struct {
+	info DeviceInfo
+	pgns map[int]bool
+}
+
+func NewDeviceRegistry() *DeviceRegistry {
+	return &DeviceRegistry{devices: make(map[uint8]*deviceEntry)}
+}
+
+// Observe records a decoded message from the bus
+func (r *DeviceRegistry) Observe(msg DecodedMessage) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	entry, ok := r.devices[msg.Source]
+	if !ok {
+		entry = &deviceEntry{
+			info: DeviceInfo{Source: msg.Source, FirstSeen: msg.Timestamp},
+			pgns: make(map[int]bool),
+		}
+		r.devices[msg.Source] = entry
+	}
+	if msg.Timestamp.After(entry.info.LastSeen) {
+		entry.info.LastSeen = msg.Timestamp
+	}
+	entry.pgns[msg.PGN] = true
+
+	info := &entry.info
+	switch msg.PGN {
+	case 126996:
+		if len(msg.Fields) == 0 {
+			return
+		}
+		at := msg.Timestamp
+		info.InfoAt = &at
+		info.NMEA2000Version, _ = msg.Fields["nmea2000_version"].(float64)
+		info.ProductCode, _ = msg.Fields["product_code"].(uint16)
+		info.ModelID, _ = msg.Fields["model_id"].(string)
+		info.SoftwareVersion, _ = msg.Fields["software_version"].(string)
+		info.ModelVersion, _ = msg.Fields["model_version"].(string)
+		info.ModelSerial, _ = msg.Fields["model_serial"].(string)
+		info.CertificationLevel, _ = msg.Fields["certification_level"].(uint8)
+		info.LoadEquivalency, _ = msg.Fields["load_equivalency"].(uint8)
+	case 126998:
+		if len(msg.Fields) == 0 {
+			return
+		}
+		info.InstallationDescription1, _ = msg.Fields["installation_description_1"].(string)
+		info.InstallationDescription2, _ = msg.Fields["installation_description_2"].(string)
+		info.ManufacturerInformation, _ = msg.Fields["manufacturer_information"].(string)
+	}
+}
+
+// Devices lists every source address seen, ordered by address
+func (r *DeviceRegistry) Devices() []DeviceInfo {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	devices := make([]DeviceInfo, 0, len(r.devices))
+	for _, entry := range r.devices {
+		info := entry.info
+		info.PGNs = make([]int, 0, len(entry.pgns))
+		for pgn := range entry.pgns {
+			info.PGNs = append(info.PGNs, pgn)
+		}
+		sort.Ints(info.PGNs)
+		devices = append(devices, info)
+	}
+
+	sort.Slice(devices, func(i, j int) bool { return devices[i].Source < devices[j].Source })
+	return devices
+}
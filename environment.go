@@ -0,0 +1,166 @@
+package integration
+
+import (
+	"math"
+	"time"
+
+	"odysail-boat-viz/storage"
+)
+
+// Pressure tendency labels
+const (
+	PressureRising  = "rising"
+	PressureFalling = "falling"
+	PressureSteady  = "steady"
+)
+
+// Environment lookback and trend tuning
+const (
+	// environmentMaxAge is how far back to look for the latest reading of
+	// each quantity; senders of 130312/130313 often rotate instances
+	environmentMaxAge = 10 * time.Minute
+
+	// pressureSteadyHpaPerHour is the slope below which pressure counts as
+	// steady, about 1 hPa over the conventional 3 hour tendency
+	pressureSteadyHpaPerHour = 0.3
+
+	// pressureMinSpan is the shortest history a trend is reported from
+	pressureMinSpan = 10 * time.Minute
+)
+
+// N2K temperature (130312) and humidity (130313) source codes
+const (
+	temperatureSourceSea     = 0
+	temperatureSourceOutside = 1
+	humiditySourceOutside    = 1
+)
+
+// EnvironmentReading is one measured value and where it came from
+type EnvironmentReading struct {
+	Value     float64 `json:"value"`
+	PGN       int     `json:"pgn"`
+	Timestamp int64   `json:"timestamp"` // unix ms
+}
+
+// Environment is the latest weather picture from the environment PGNs.
+// Readings are nil when no recent source reported them.
+type Environment struct {
+	AirTemperature   *EnvironmentReading `json:"air_temperature_c"`
+	WaterTemperature *EnvironmentReading `json:"water_temperature_c"`
+	Humidity         *EnvironmentReading `json:"humidity_pct"`
+	Pressure         *EnvironmentReading `json:"pressure_hpa"`
+
+	// Least-squares pressure slope over PressureTrendWindow, and its label;
+	// empty when the window holds too little history
+	PressureRate  *float64 `json:"pressure_rate_hpa_h"`
+	PressureTrend string   `json:"pressure_trend,omitempty"`
+}
+
+// Environment gathers air and water temperature, humidity and barometric
+// pressure from PGNs 130310, 130312 and 130313, taking the newest reading of
+// each, and derives the pressure tendency from buffered 130310 history
+func (m *BoomSenseMapper) Environment() Environment {
+	now := time.Now()
+	since := now.Add(-environmentMaxAge)
+
+	var env Environment
+	for _, msg := range m.buffer.GetByPGNTimeRange(130310, since, now) {
+		newerReading(&env.AirTemperature, &msg, "air_temperature_c")
+		newerReading(&env.WaterTemperature, &msg, "water_temperature_c")
+		newerReading(&env.Humidity, &msg, "relative_humidity_pct")
+		newerReading(&env.Pressure, &msg, "atmospheric_pressure_hpa")
+	}
+	for _, msg := range m.buffer.GetByPGNTimeRange(130312, since, now) {
+		source, ok := enumField(msg.Fields, "temperature_source")
+		if !ok {
+			continue
+		}
+		switch source {
+		case temperatureSourceSea:
+			newerReading(&env.WaterTemperature, &msg, "actual_temperature_c")
+		case temperatureSourceOutside:
+			newerReading(&env.AirTemperature, &msg, "actual_temperature_c")
+		}
+	}
+	for _, msg := range m.buffer.GetByPGNTimeRange(130313, since, now) {
+		if source, ok := enumField(msg.Fields, "humidity_source"); ok && source == humiditySourceOutside {
+			newerReading(&env.Humidity, &msg, "actual_humidity_pct")
+		}
+	}
+
+	if rate, ok := m.pressureRate(now); ok {
+		env.PressureRate = &rate
+		switch {
+		case rate >= pressureSteadyHpaPerHour:
+			env.PressureTrend = PressureRising
+		case rate <= -pressureSteadyHpaPerHour:
+			env.PressureTrend = PressureFalling
+		default:
+			env.PressureTrend = PressureSteady
+		}
+	}
+
+	return env
+}
+
+// newerReading replaces *dst with msg's field when it is valid and newer
+func newerReading(dst **EnvironmentReading, msg *storage.DecodedMessage, field string) {
+	v, ok := msg.Fields[field].(float64)
+	if !ok || math.IsNaN(v) {
+		return
+	}
+	ts := msg.Timestamp.UnixMilli()
+	if *dst != nil && (*dst).Timestamp > ts {
+		return
+	}
+	*dst = &EnvironmentReading{Value: v, PGN: msg.PGN, Timestamp: ts}
+}
+
+// enumField reads a small integer field, which is uint8 from the decoder
+// but float64 after a buffer snapshot round trip
+func enumField(fields map[string]interface{}, key string) (int, bool) {
+	switch v := fields[key].(type) {
+	case uint8:
+		return int(v), true
+	case int:
+		return v, true
+	case float64:
+		return int(v), true
+	}
+	return 0, false
+}
+
+// pressureRate fits a line through the 130310 pressure samples in
+// PressureTrendWindow and returns its slope in hPa per hour
+func (m *BoomSenseMapper) pressureRate(now time.Time) (float64, bool) {
+	msgs := m.buffer.GetByPGNTimeRange(130310, now.Add(-m.PressureTrendWindow), now)
+
+	var n, sumT, sumP, sumTT, sumTP float64
+	var first, last time.Time
+	for _, msg := range msgs {
+		p, ok := msg.Fields["atmospheric_pressure_hpa"].(float64)
+		if !ok || math.IsNaN(p) {
+			continue
+		}
+		if n == 0 {
+			first = msg.Timestamp
+		}
+		last = msg.Timestamp
+
+		t := msg.Timestamp.Sub(now).Hours()
+		n++
+		sumT += t
+		sumP += p
+		sumTT += t * t
+		sumTP += t * p
+	}
+
+	if n < 2 || last.Sub(first) < pressureMinSpan {
+		return 0, false
+	}
+	den := n*sumTT - sumT*sumT
+	if den == 0 {
+		return 0, false
+	}
+	return (n*sumTP - sumT*sumP) / den, true
+}
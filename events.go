@@ -0,0 +1,144 @@
+package main
+
+import (
+	"math"
+	"net/http"
+	"strconv"
+	"sync"
+
+	"odysail-boat-viz/boomsense_sensor"
+)
+
+// eventSubscriberBuffer is how many events a slow stream client may lag
+// behind before further events are dropped for it
+const eventSubscriberBuffer = 16
+
+// streamEvent is the JSON form of a detected BoomSense event as pushed on
+// the SSE ("event: boom_event") and WebSocket streams
+type streamEvent struct {
+	Type      string   `json:"type"`
+	Timestamp int64    `json:"timestamp"` // unix ms
+	Score     float64  `json:"score"`
+	Direction string   `json:"direction,omitempty"`
+	GyroPeak  float64  `json:"gyro_peak"`
+	BoomDelta float64  `json:"boom_delta"`
+	RollDelta float64  `json:"roll_delta"`
+	Duration  float64  `json:"duration"`
+	Overshoot float64  `json:"overshoot"`
+	WindSpeed float64  `json:"wind_speed"`
+	WindAngle float64  `json:"wind_angle"`
+	Quality   *float64 `json:"quality,omitempty"` // Bayesian QA probability
+}
+
+// eventHub fans detector events (and alarms) out to every connected
+// stream client
+type eventHub[T any] struct {
+	mu   sync.Mutex
+	subs map[chan T]struct{}
+}
+
+func newEventHub[T any]() *eventHub[T] {
+	return &eventHub[T]{subs: make(map[chan T]struct{})}
+}
+
+// Subscribe returns a channel of events and a function that releases it
+func (h *eventHub[T]) Subscribe() (<-chan T, func()) {
+	ch := make(chan T, eventSubscriberBuffer)
+
+	h.mu.Lock()
+	h.subs[ch] = struct{}{}
+	h.mu.Unlock()
+
+	return ch, func() {
+		h.mu.Lock()
+		delete(h.subs, ch)
+		h.mu.Unlock()
+	}
+}
+
+// Publish delivers evt to every subscriber without blocking the detector
+func (h *eventHub[T]) Publish(evt T) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	for ch := range h.subs {
+		select {
+		case ch <- evt:
+		default:
+			// Client is not keeping up, drop rather than stall the detector
+		}
+	}
+}
+
+// newStreamEvent converts a detector event, already enriched with wind and
+// the QA probability at detection by Sensor.AddEventListener
+func newStreamEvent(evt boomsense_sensor.Event) streamEvent {
+	out := streamEvent{
+		Type:      evt.Type,
+		Timestamp: evt.Timestamp.UnixMilli(),
+		Score:     finiteOrZero(evt.Score),
+		Direction: evt.Direction,
+		GyroPeak:  finiteOrZero(evt.GyroPeak),
+		BoomDelta: finiteOrZero(evt.BoomDelta),
+		RollDelta: finiteOrZero(evt.RollDelta),
+		Duration:  finiteOrZero(evt.Duration),
+		Overshoot: finiteOrZero(evt.Overshoot),
+		WindSpeed: finiteOrZero(evt.WindSpeed),
+		WindAngle: finiteOrZero(evt.WindAngle),
+	}
+	if evt.Quality != nil {
+		quality := finiteOrZero(*evt.Quality)
+		out.Quality = &quality
+	}
+	return out
+}
+
+// eventHistoryDefault is how many events /api/boomsense/history returns
+// without ?n=
+const eventHistoryDefault = 10
+
+// handleBoomEventHistory returns the sensor's most recent detected events,
+// oldest first, in the same form as the stream. ?n= limits the count
+// (default 10, 0 for the whole history) and ?type= keeps one event type.
+func handleBoomEventHistory(w http.ResponseWriter, r *http.Request) {
+	if boomSensor == nil {
+		http.Error(w, "BoomSense sensor not running", http.StatusServiceUnavailable)
+		return
+	}
+
+	q := r.URL.Query()
+	n := eventHistoryDefault
+	if v := q.Get("n"); v != "" {
+		var err error
+		if n, err = strconv.Atoi(v); err != nil || n < 0 {
+			http.Error(w, "invalid n", http.StatusBadRequest)
+			return
+		}
+	}
+	eventType := q.Get("type")
+
+	events := make([]streamEvent, 0)
+	for _, evt := range boomSensor.GetRecentEvents(0) {
+		if eventType == "" || evt.Type == eventType {
+			events = append(events, newStreamEvent(evt))
+		}
+	}
+	if n > 0 && len(events) > n {
+		events = events[len(events)-n:]
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	encodeJSON(w, map[string]interface{}{
+		"count":  len(events),
+		"events": events,
+	})
+}
+
+// finiteOrZero keeps NaN/Inf (unset detector metrics) out of the JSON
+// encoder, which rejects them
+func finiteOrZero(v float64) float64 {
+	if math.IsNaN(v) || math.IsInf(v, 0) {
+		return 0
+	}
+	return v
+}
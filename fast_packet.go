@@ -0,0 +1,89 @@
+package nmea
+
+import "sync"
+
+// fastPacketPGNs are the decoded PGNs that NMEA 2000 sends as fast packets:
+// one message of up to 223 bytes split over several 8-byte CAN frames
+var fastPacketPGNs = map[int]bool{
+	126208: true, 126720: true, 126996: true, 126998: true,
+	127237: true, 127489: true, 127497: true, 127498: true,
+	127503: true, 127504: true, 127506: true, 128275: true,
+	129029: true, 129038: true, 129039: true, 129040: true,
+	129284: true, 129285: true, 129540: true, 129793: true,
+	129794: true, 129798: true, 129802: true, 129809: true,
+	129810: true, 130577: true, 130822: true,
+}
+
+// fastPacketKey identifies one sender's stream of a fast-packet PGN
+type fastPacketKey struct {
+	source uint8
+	pgn    int
+}
+
+// fastPacket is a message partway through reassembly
+type fastPacket struct {
+	seq    uint8 // sequence number shared by the message's frames
+	next   uint8 // frame counter expected next
+	length int   // message length from the first frame
+	data   []byte
+}
+
+// fastPacketAssembler rebuilds fast-packet messages from the single CAN
+// frames a YDWG-02 gateway forwards
+type fastPacketAssembler struct {
+	mu      sync.Mutex
+	pending map[fastPacketKey]*fastPacket
+}
+
+func newFastPacketAssembler() *fastPacketAssembler {
+	return &fastPacketAssembler{pending: make(map[fastPacketKey]*fastPacket)}
+}
+
+// add takes one CAN frame. Frames of other PGNs come straight back with
+// complete set; a fast-packet frame comes back as the whole message once
+// its last fragment arrives. dropped is set when a message in progress is
+// abandoned because a fragment was missed. Fragments whose first frame
+// was never seen (e.g. just after connecting) are ignored.
+func (a *fastPacketAssembler) add(frame RawFrame) (msg RawFrame, complete, dropped bool) {
+	if !fastPacketPGNs[frame.PGN] {
+		return frame, true, false
+	}
+	if len(frame.Data) < 2 {
+		return msg, false, false
+	}
+
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	key := fastPacketKey{source: frame.Source, pgn: frame.PGN}
+	seq := frame.Data[0] >> 5
+	counter := frame.Data[0] & 0x1F
+	p := a.pending[key]
+
+	if counter == 0 {
+		// A new message; one still pending lost its remaining frames
+		dropped = p != nil
+		p = &fastPacket{seq: seq, next: 1, length: int(frame.Data[1])}
+		p.data = append(p.data, frame.Data[2:]...)
+		a.pending[key] = p
+	} else {
+		if p == nil {
+			return msg, false, false
+		}
+		if p.seq != seq || p.next != counter {
+			delete(a.pending, key)
+			return msg, false, true
+		}
+		p.data = append(p.data, frame.Data[1:]...)
+		p.next++
+	}
+
+	if len(p.data) < p.length {
+		return msg, false, dropped
+	}
+	delete(a.pending, key)
+	msg = frame
+	msg.Data = p.data[:p.length]
+	msg.Length = p.length
+	return msg, true, dropped
+}
@@ -0,0 +1,117 @@
+package nmea
+
+import (
+	"bytes"
+	"testing"
+)
+
+// fastPacketFrames splits data into the CAN frames of one fast-packet
+// message with sequence number seq
+func fastPacketFrames(pgn int, source, seq uint8, data []byte) []RawFrame {
+	var frames []RawFrame
+	first := append([]byte{seq << 5, byte(len(data))}, data[:min(6, len(data))]...)
+	frames = append(frames, RawFrame{PGN: pgn, Source: source, Data: first})
+	for i, counter := 6, uint8(1); i < len(data); i, counter = i+7, counter+1 {
+		chunk := append([]byte{seq<<5 | counter}, data[i:min(i+7, len(data))]...)
+		for len(chunk) < 8 {
+			chunk = append(chunk, 0xFF)
+		}
+		frames = append(frames, RawFrame{PGN: pgn, Source: source, Data: chunk})
+	}
+	return frames
+}
+
+func TestFastPacketReassembly(t *testing.T) {
+	data := []byte{1, 2, 3, 4, 5, 6, 7, 8, 9, 10, 11, 12, 13, 14, 15, 16, 17, 18, 19, 20}
+	frames := fastPacketFrames(126996, 5, 2, data)
+	if len(frames) != 3 {
+		t.Fatalf("built %d frames, want 3", len(frames))
+	}
+
+	a := newFastPacketAssembler()
+	for i, frame := range frames {
+		msg, complete, dropped := a.add(frame)
+		if dropped {
+			t.Fatalf("frame %d dropped the message", i)
+		}
+		if complete != (i == len(frames)-1) {
+			t.Fatalf("frame %d: complete = %v", i, complete)
+		}
+		if complete && (!bytes.Equal(msg.Data, data) || msg.Length != len(data)) {
+			t.Errorf("reassembled %v (length %d), want %v", msg.Data, msg.Length, data)
+		}
+	}
+	if len(a.pending) != 0 {
+		t.Errorf("%d messages left pending", len(a.pending))
+	}
+}
+
+func TestFastPacketMissingFragment(t *testing.T) {
+	frames := fastPacketFrames(129029, 3, 1, make([]byte, 43))
+
+	a := newFastPacketAssembler()
+	a.add(frames[0])
+	if _, complete, dropped := a.add(frames[2]); complete || !dropped {
+		t.Errorf("skipped fragment: complete = %v, dropped = %v; want false, true", complete, dropped)
+	}
+
+	// A new first frame abandons the message in progress
+	a.add(frames[0])
+	a.add(frames[1])
+	next := fastPacketFrames(129029, 3, 2, make([]byte, 43))
+	if _, complete, dropped := a.add(next[0]); complete || !dropped {
+		t.Errorf("restarted message: complete = %v, dropped = %v; want false, true", complete, dropped)
+	}
+
+	// Fragments of a message whose start was never seen are ignored
+	b := newFastPacketAssembler()
+	if _, complete, dropped := b.add(frames[3]); complete || dropped {
+		t.Errorf("orphan fragment: complete = %v, dropped = %v; want false, false", complete, dropped)
+	}
+}
+
+func TestFastPacketSingleFramePGN(t *testing.T) {
+	frame := RawFrame{PGN: 127250, Data: []byte{0, 1, 2, 3, 4, 5, 6, 7}}
+	msg, complete, dropped := newFastPacketAssembler().add(frame)
+	if !complete || dropped || !bytes.Equal(msg.Data, frame.Data) {
+		t.Errorf("single-frame PGN: %v, %v, %v; want it passed through", msg.Data, complete, dropped)
+	}
+}
+
+func TestHandlePayloadYDWG(t *testing.T) {
+	cfg := DefaultConfig()
+	cfg.FrameFormat = FrameFormatYDWG
+	c := NewCollector(cfg, nil, nil)
+
+	// PGN 126996 from source 0x23: two fragments, then a line that is not
+	// a frame
+	c.handlePayload("n2k/raw", []byte(
+		"17:33:21.107 R 19F01423 40 0A 01 02 03 04 05 06\n"+
+			"17:33:21.108 R 19F01423 41 07 08 09 0A FF FF FF\n"+
+			"garbage\n"))
+
+	frame, ok := c.rawFrames.tryReceive()
+	if !ok {
+		t.Fatal("no frame queued for the reassembled message")
+	}
+	if want := []byte{1, 2, 3, 4, 5, 6, 7, 8, 9, 10}; frame.PGN != 126996 || !bytes.Equal(frame.Data, want) {
+		t.Errorf("queued PGN %d data %v, want 126996 %v", frame.PGN, frame.Data, want)
+	}
+	if _, ok := c.rawFrames.tryReceive(); ok {
+		t.Error("fragments queued alongside the reassembled message")
+	}
+	if got := c.stats.GetSnapshot()["text_frame_errors"].(int64); got != 1 {
+		t.Errorf("text_frame_errors = %d, want 1", got)
+	}
+
+	// JSON readings take the JSON path whatever the gateway format
+	sink := &countingSink{}
+	c.SetSensorSink(sink)
+	c.handlePayload("boats/x/imu", []byte(`{"ax":0.1,"ay":0,"az":1,"gx":2,"gy":0,"gz":3}`))
+	if sink.imu != 1 {
+		t.Errorf("IMU JSON under ydwg reached the sink %d times, want 1", sink.imu)
+	}
+	if got := c.stats.GetSnapshot()["text_frame_errors"].(int64); got != 1 {
+		t.Errorf("text_frame_errors = %d after JSON payload, want 1", got)
+	}
+}
@@ -0,0 +1,54 @@
+package main
+
+import (
+	"net/http"
+)
+
+// handleHealthz reports readiness for container and systemd watchdogs: 200
+// when the boat database is loaded and, if requireMQTT is set, the NMEA
+// collector is connected; 503 otherwise. The body lists each component.
+func handleHealthz(vs *VisualizationServer, requireMQTT bool) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		vs.mu.RLock()
+		boats := len(vs.boats)
+		vs.mu.RUnlock()
+
+		dbOK := boats > 0
+		ready := dbOK
+
+		collector := map[string]interface{}{"running": nmeaCollector != nil}
+		if nmeaCollector != nil {
+			for k, v := range nmeaCollector.Health() {
+				collector[k] = v
+			}
+		}
+		connected, _ := collector["connected"].(bool)
+		collector["required"] = requireMQTT
+		if requireMQTT && !connected {
+			ready = false
+		}
+
+		status, code := "ok", http.StatusOK
+		if !ready {
+			status, code = "unavailable", http.StatusServiceUnavailable
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		w.Header().Set("Cache-Control", "no-store")
+		w.WriteHeader(code)
+		encodeJSON(w, map[string]interface{}{
+			"status": status,
+			"components": map[string]interface{}{
+				"boat_db": map[string]interface{}{
+					"ok":    dbOK,
+					"boats": boats,
+				},
+				"collector": collector,
+				"sensor": map[string]interface{}{
+					"running": boomSensor != nil,
+				},
+				"influx": influxStatus(),
+			},
+		})
+	}
+}
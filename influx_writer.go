@@ -0,0 +1,300 @@
+package storage
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"log"
+	"math"
+	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// InfluxConfig describes an InfluxDB v2 write endpoint and batching
+type InfluxConfig struct {
+	URL    string // server base URL, e.g. http://localhost:8086
+	Org    string
+	Bucket string
+	Token  string
+
+	BatchSize     int           // lines per write request
+	FlushInterval time.Duration // longest a line waits before being sent
+	MaxBacklog    int           // lines held while the server is unreachable
+}
+
+// Influx batching defaults, used for zero InfluxConfig fields
+const (
+	influxDefaultBatchSize     = 500
+	influxDefaultFlushInterval = time.Second
+	influxDefaultMaxBacklog    = 100000
+)
+
+// InfluxWriter sends decoded messages to InfluxDB as line protocol: the
+// measurement is msg.Measurement, pgn and source are tags and every numeric,
+// boolean or string entry of msg.Fields is a field. Lines are batched and
+// retried while the server is down; once MaxBacklog lines are waiting,
+// further messages are dropped and counted. Frames and stats are not sent.
+type InfluxWriter struct {
+	config   InfluxConfig
+	writeURL string
+	client   *http.Client
+
+	mu      sync.Mutex
+	pending [][]byte
+
+	dropped atomic.Int64
+	flush   chan struct{}
+	done    chan struct{}
+	wg      sync.WaitGroup
+}
+
+func NewInfluxWriter(config InfluxConfig) *InfluxWriter {
+	if config.BatchSize <= 0 {
+		config.BatchSize = influxDefaultBatchSize
+	}
+	if config.FlushInterval <= 0 {
+		config.FlushInterval = influxDefaultFlushInterval
+	}
+	if config.MaxBacklog <= 0 {
+		config.MaxBacklog = influxDefaultMaxBacklog
+	}
+
+	q := url.Values{}
+	q.Set("org", config.Org)
+	q.Set("bucket", config.Bucket)
+	q.Set("precision", "ns")
+
+	w := &InfluxWriter{
+		config:   config,
+		writeURL: strings.TrimSuffix(config.URL, "/") + "/api/v2/write?" + q.Encode(),
+		client:   &http.Client{Timeout: 10 * time.Second},
+		flush:    make(chan struct{}, 1),
+		done:     make(chan struct{}),
+	}
+	w.wg.Add(1)
+	go w.flushLoop()
+	return w
+}
+
+// Dropped returns how many messages were discarded because the backlog was
+// full or the server rejected them
+func (w *InfluxWriter) Dropped() int64 {
+	return w.dropped.Load()
+}
+
+func (w *InfluxWriter) WriteFrame(frame RawFrame) {}
+
+func (w *InfluxWriter) WriteStats(snapshot map[string]interface{}) {}
+
+func (w *InfluxWriter) WriteDecoded(msg DecodedMessage) {
+	line := influxLine(msg)
+	if line == nil {
+		return
+	}
+
+	w.mu.Lock()
+	if len(w.pending) >= w.config.MaxBacklog {
+		w.mu.Unlock()
+		if w.dropped.Add(1)%1000 == 1 {
+			log.Printf("[Influx] Backlog full (%d lines), dropping messages (%d so far)",
+				w.config.MaxBacklog, w.dropped.Load())
+		}
+		return
+	}
+	w.pending = append(w.pending, line)
+	full := len(w.pending) >= w.config.BatchSize
+	w.mu.Unlock()
+
+	if full {
+		select {
+		case w.flush <- struct{}{}:
+		default:
+		}
+	}
+}
+
+func (w *InfluxWriter) Close() {
+	close(w.done)
+	w.wg.Wait()
+}
+
+// flushLoop sends pending lines every FlushInterval or as soon as a batch
+// fills, and makes a last attempt on Close
+func (w *InfluxWriter) flushLoop() {
+	defer w.wg.Done()
+
+	ticker := time.NewTicker(w.config.FlushInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+		case <-w.flush:
+		case <-w.done:
+			w.send()
+			return
+		}
+		w.send()
+	}
+}
+
+// send writes pending lines in batches, stopping at the first failure so
+// the rest are retried on the next flush
+func (w *InfluxWriter) send() {
+	for {
+		w.mu.Lock()
+		n := len(w.pending)
+		if n > w.config.BatchSize {
+			n = w.config.BatchSize
+		}
+		batch := w.pending[:n]
+		w.mu.Unlock()
+
+		if n == 0 {
+			return
+		}
+
+		err := w.post(bytes.Join(batch, []byte{'\n'}))
+		if err != nil {
+			if _, rejected := err.(influxRejected); !rejected {
+				log.Printf("[Influx] Write failed, will retry: %v", err)
+				return
+			}
+			// Retrying a malformed batch can never succeed
+			w.dropped.Add(int64(n))
+			log.Printf("[Influx] Dropping %d lines: %v", n, err)
+		}
+
+		w.mu.Lock()
+		w.pending = w.pending[n:]
+		w.mu.Unlock()
+	}
+}
+
+// influxRejected is a 4xx answer other than auth or rate limiting: the data
+// itself was refused
+type influxRejected struct{ error }
+
+func (w *InfluxWriter) post(body []byte) error {
+	req, err := http.NewRequest(http.MethodPost, w.writeURL, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "text/plain; charset=utf-8")
+	if w.config.Token != "" {
+		req.Header.Set("Authorization", "Token "+w.config.Token)
+	}
+
+	resp, err := w.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode/100 == 2 {
+		io.Copy(io.Discard, resp.Body)
+		return nil
+	}
+
+	msg, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+	err = fmt.Errorf("influx returned %s: %s", resp.Status, bytes.TrimSpace(msg))
+	switch resp.StatusCode {
+	case http.StatusBadRequest, http.StatusRequestEntityTooLarge, http.StatusUnprocessableEntity:
+		return influxRejected{err}
+	}
+	return err
+}
+
+// influxLine formats msg as one line of line protocol, or nil when it has
+// no field InfluxDB can store
+func influxLine(msg DecodedMessage) []byte {
+	keys := make([]string, 0, len(msg.Fields))
+	for k := range msg.Fields {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	var fields []string
+	for _, k := range keys {
+		if v, ok := influxFieldValue(msg.Fields[k]); ok {
+			fields = append(fields, influxEscape(k, ",= ")+"="+v)
+		}
+	}
+	if len(fields) == 0 {
+		return nil
+	}
+
+	measurement := msg.Measurement
+	if measurement == "" {
+		measurement = "nmea"
+	}
+
+	var b bytes.Buffer
+	b.WriteString(influxEscape(measurement, ", "))
+	fmt.Fprintf(&b, ",pgn=%d,source=%d ", msg.PGN, msg.Source)
+	b.WriteString(strings.Join(fields, ","))
+	b.WriteByte(' ')
+	b.WriteString(strconv.FormatInt(msg.Timestamp.UnixNano(), 10))
+	return b.Bytes()
+}
+
+// influxFieldValue renders a decoder value in line protocol syntax. Nil
+// ("not available") and non-finite values are skipped.
+func influxFieldValue(val interface{}) (string, bool) {
+	switch v := val.(type) {
+	case float64:
+		if math.IsNaN(v) || math.IsInf(v, 0) {
+			return "", false
+		}
+		return strconv.FormatFloat(v, 'g', -1, 64), true
+	case float32:
+		return influxFieldValue(float64(v))
+	case int:
+		return strconv.FormatInt(int64(v), 10) + "i", true
+	case int8:
+		return strconv.FormatInt(int64(v), 10) + "i", true
+	case int16:
+		return strconv.FormatInt(int64(v), 10) + "i", true
+	case int32:
+		return strconv.FormatInt(int64(v), 10) + "i", true
+	case int64:
+		return strconv.FormatInt(v, 10) + "i", true
+	case uint8:
+		return strconv.FormatUint(uint64(v), 10) + "i", true
+	case uint16:
+		return strconv.FormatUint(uint64(v), 10) + "i", true
+	case uint32:
+		return strconv.FormatUint(uint64(v), 10) + "i", true
+	case uint64:
+		if v > math.MaxInt64 {
+			return "", false
+		}
+		return strconv.FormatUint(v, 10) + "i", true
+	case bool:
+		return strconv.FormatBool(v), true
+	case string:
+		return `"` + strings.NewReplacer(`\`, `\\`, `"`, `\"`).Replace(v) + `"`, true
+	}
+	return "", false
+}
+
+// influxEscape backslash-escapes the given special characters
+func influxEscape(s, special string) string {
+	if !strings.ContainsAny(s, special) {
+		return s
+	}
+	var b strings.Builder
+	for _, r := range s {
+		if strings.ContainsRune(special, r) {
+			b.WriteByte('\\')
+		}
+		b.WriteRune(r)
+	}
+	return b.String()
+}
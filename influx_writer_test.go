@@ -0,0 +1,63 @@
+package storage
+
+import (
+	"math"
+	"testing"
+	"time"
+)
+
+func TestInfluxLineEscaping(t *testing.T) {
+	tests := []struct {
+		name string
+		msg  DecodedMessage
+		want string
+	}{
+		{
+			"plain",
+			DecodedMessage{PGN: 130306, Source: 3, Measurement: "wind",
+				Fields: map[string]interface{}{"wind_speed_kts": 12.5, "sid": uint8(1)}},
+			`wind,pgn=130306,source=3 sid=1i,wind_speed_kts=12.5 1000000005`,
+		},
+		{
+			"measurement with comma and space",
+			DecodedMessage{PGN: 127508, Source: 1, Measurement: "battery, house",
+				Fields: map[string]interface{}{"voltage_v": 12.6}},
+			`battery\,\ house,pgn=127508,source=1 voltage_v=12.6 1000000005`,
+		},
+		{
+			"field keys with comma, equals and space",
+			DecodedMessage{PGN: 126996, Source: 2, Measurement: "system",
+				Fields: map[string]interface{}{"model id": "x", "a=b,c": 1}},
+			`system,pgn=126996,source=2 a\=b\,c=1i,model\ id="x" 1000000005`,
+		},
+		{
+			"string field with quotes and backslashes",
+			DecodedMessage{PGN: 126996, Source: 2, Measurement: "system",
+				Fields: map[string]interface{}{"model": `GPS "200" C:\N2K`}},
+			`system,pgn=126996,source=2 model="GPS \"200\" C:\\N2K" 1000000005`,
+		},
+		{
+			"not available and non-finite fields skipped",
+			DecodedMessage{PGN: 129540, Source: 4, Measurement: "gnss",
+				Fields: map[string]interface{}{"snr": math.NaN(), "range": math.Inf(1), "x": nil, "sats": 7}},
+			`gnss,pgn=129540,source=4 sats=7i 1000000005`,
+		},
+		{
+			"empty measurement",
+			DecodedMessage{PGN: 65280, Source: 9, Fields: map[string]interface{}{"v": true}},
+			`nmea,pgn=65280,source=9 v=true 1000000005`,
+		},
+	}
+
+	for _, tt := range tests {
+		tt.msg.Timestamp = time.Unix(1, 5)
+		if got := string(influxLine(tt.msg)); got != tt.want {
+			t.Errorf("%s:\n got %s\nwant %s", tt.name, got, tt.want)
+		}
+	}
+
+	none := DecodedMessage{Measurement: "m", Fields: map[string]interface{}{"x": nil, "y": math.NaN()}}
+	if line := influxLine(none); line != nil {
+		t.Errorf("line %q for a message with no storable field, want none", line)
+	}
+}
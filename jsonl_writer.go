@@ -0,0 +1,76 @@
+package storage
+
+import (
+	"encoding/json"
+	"log"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// JSONLWriter logs decoded messages as JSON lines, one object per message
+// with its Fields map intact, so numeric types survive ingestion into
+// InfluxDB, Loki and the like. Raw frames and statistics are still written
+// as CSV: the frames log is what replay reads.
+type JSONLWriter struct {
+	*CSVWriter
+
+	decoded *csvFile
+}
+
+// decodedRecord is one line of the decoded JSONL log
+type decodedRecord struct {
+	Time        string                 `json:"time"`
+	TsMs        int64                  `json:"ts_ms"`
+	Measurement string                 `json:"measurement"`
+	PGN         int                    `json:"pgn"`
+	PGNName     string                 `json:"pgn_name"`
+	Source      uint8                  `json:"source"`
+	Fields      map[string]interface{} `json:"fields"`
+}
+
+func NewJSONLWriter(framesPath, decodedPath, statsPath string) *JSONLWriter {
+	os.MkdirAll(filepath.Dir(framesPath), 0755)
+	os.MkdirAll(filepath.Dir(decodedPath), 0755)
+
+	return &JSONLWriter{
+		CSVWriter: &CSVWriter{
+			frames: openCSVFile(framesPath, framesHeader),
+			stats:  openCSVFile(statsPath, statsHeader),
+		},
+		decoded: openCSVFile(decodedPath, nil),
+	}
+}
+
+func (w *JSONLWriter) WriteDecoded(msg DecodedMessage) {
+	if w.decoded == nil || len(msg.Fields) == 0 {
+		return
+	}
+
+	line, err := json.Marshal(decodedRecord{
+		Time:        msg.Timestamp.Format(time.RFC3339Nano),
+		TsMs:        msg.Timestamp.UnixMilli(),
+		Measurement: msg.Measurement,
+		PGN:         msg.PGN,
+		PGNName:     msg.PGNName,
+		Source:      msg.Source,
+		Fields:      finiteJSON(msg.Fields).(map[string]interface{}),
+	})
+	if err != nil {
+		log.Printf("[JSONL] Skipping PGN %d: %v", msg.PGN, err)
+		return
+	}
+	line = append(line, '\n')
+
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.decoded.writeData(line, w.MaxSizeBytes, w.RotateDaily)
+}
+
+func (w *JSONLWriter) Close() {
+	w.CSVWriter.Close()
+
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.decoded.close()
+}
@@ -0,0 +1,57 @@
+package storage
+
+import (
+	"bufio"
+	"encoding/json"
+	"math"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestJSONLWriteDecodedNonFinite(t *testing.T) {
+	dir := t.TempDir()
+	decodedPath := filepath.Join(dir, "decoded.jsonl")
+	w := NewJSONLWriter(filepath.Join(dir, "frames.csv"), decodedPath, filepath.Join(dir, "stats.csv"))
+
+	now := time.Now()
+	w.WriteDecoded(DecodedMessage{Timestamp: now, PGN: 130306, Measurement: "wind", Fields: map[string]interface{}{
+		"wind_speed_kts": 12.5,
+		"wind_angle_deg": math.NaN(),
+	}})
+	w.WriteDecoded(DecodedMessage{Timestamp: now, PGN: 129540, Measurement: "gnss", Fields: map[string]interface{}{
+		"satellites": []map[string]interface{}{{"prn": 3, "snr": math.Inf(1)}},
+	}})
+	w.Close()
+
+	f, err := os.Open(decodedPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+
+	var lines []string
+	var records []map[string]interface{}
+	sc := bufio.NewScanner(f)
+	for sc.Scan() {
+		var rec map[string]interface{}
+		if err := json.Unmarshal(sc.Bytes(), &rec); err != nil {
+			t.Fatalf("line %q: %v", sc.Text(), err)
+		}
+		lines = append(lines, sc.Text())
+		records = append(records, rec)
+	}
+	if len(records) != 2 {
+		t.Fatalf("%d lines written, want 2: %v", len(records), lines)
+	}
+
+	wind := records[0]["fields"].(map[string]interface{})
+	if v, ok := wind["wind_angle_deg"]; !ok || v != nil || wind["wind_speed_kts"] != 12.5 {
+		t.Errorf("wind fields = %v, want wind_angle_deg null beside wind_speed_kts 12.5", wind)
+	}
+	if !strings.Contains(lines[1], `"snr":null`) {
+		t.Errorf("satellites line = %s, want snr null", lines[1])
+	}
+}
@@ -1,16 +1,26 @@
 package main
 
 import (
+	"bytes"
+	"context"
 	"encoding/json"
+	"flag"
 	"fmt"
 	"io/ioutil"
 	"log"
 	"math"
+	"net"
 	"net/http"
 	"os"
+	"os/signal"
+	"sort"
+	"strconv"
 	"strings"
+	"sync"
+	"syscall"
 	"time"
 
+	"odysail-boat-viz/boomsense_sensor"
 	"odysail-boat-viz/integration"
 	"odysail-boat-viz/nmea"
 	"odysail-boat-viz/storage"
@@ -23,6 +33,20 @@ type Boat struct {
 	Polar      Polar      `json:"polar"`
 	Class      string     `json:"class"`
 	Metadata   Metadata   `json:"metadata"`
+
+	// SailPolars holds optional polars for specific sail configurations,
+	// keyed by name (e.g. "A2 kite", "code zero"); Polar is the default
+	SailPolars map[string]Polar `json:"sail_polars,omitempty"`
+}
+
+// SailNames lists the boat's sail-specific polars in name order
+func (b *Boat) SailNames() []string {
+	names := make([]string, 0, len(b.SailPolars))
+	for name := range b.SailPolars {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
 }
 
 type Dimensions struct {
@@ -42,6 +66,11 @@ type Polar struct {
 	WindSpeeds []float64   `json:"wind_speeds"`
 	WindAngles []float64   `json:"wind_angles"`
 	BoatSpeeds [][]float64 `json:"boat_speeds"`
+
+	// Symmetric marks a polar covering one side (TWA 0-180) that applies
+	// to both tacks, as opposed to a full-circle 0-360 one. Inferred from
+	// the largest TWA when unset; see IsSymmetric.
+	Symmetric *bool `json:"symmetric,omitempty"`
 }
 
 type Metadata struct {
@@ -90,13 +119,19 @@ type BoomSenseData struct {
 	Timestamp     int64   `json:"timestamp"`
 	WindSpeed     float64 `json:"wind_speed"`
 	WindAngle     float64 `json:"wind_angle"`
+	WindSide      string  `json:"wind_side,omitempty"` // "port" or "starboard"
 	BoatSpeed     float64 `json:"boat_speed"`
 }
 
-// Global NMEA collector and mapper
+// Global NMEA collector, mapper and optional in-process BoomSense sensor
 var (
 	nmeaCollector *nmea.Collector
 	boomMapper    *integration.BoomSenseMapper
+	boomSensor    *boomsense_sensor.Sensor
+	influxWriter  *storage.InfluxWriter // nil unless influx_url is set
+
+	// Detected BoomSense events for the SSE and WebSocket streams
+	boomEvents = newEventHub[streamEvent]()
 )
 
 // Helper function to convert interface{} to float64
@@ -122,26 +157,221 @@ func toFloat64(val interface{}) float64 {
 	}
 }
 
+// PolarStreak tracks how long the boat has been sailing at or above a
+// percentage of its polar target speed. Short dips below the threshold are
+// tolerated for up to Grace before the current streak resets. A gap of more
+// than MaxGap between samples is not counted as time above the threshold;
+// it counts as below, from the last sample.
+type PolarStreak struct {
+	Threshold float64       // percent of polar target speed
+	Grace     time.Duration // time allowed below threshold before reset
+	MaxGap    time.Duration // longest interval between samples counted
+
+	mu         sync.Mutex
+	current    time.Duration
+	best       time.Duration
+	lastUpdate time.Time
+	lastAbove  bool
+	belowSince time.Time
+}
+
+func NewPolarStreak(threshold float64, grace time.Duration) *PolarStreak {
+	return &PolarStreak{
+		Threshold: threshold,
+		Grace:     grace,
+		MaxGap:    5 * time.Second,
+	}
+}
+
+// Update feeds a polar percentage sample taken at time now
+func (ps *PolarStreak) Update(pct float64, now time.Time) {
+	ps.mu.Lock()
+	defer ps.mu.Unlock()
+
+	above := pct >= ps.Threshold
+	dt := time.Duration(0)
+	if !ps.lastUpdate.IsZero() && now.After(ps.lastUpdate) {
+		dt = now.Sub(ps.lastUpdate)
+	}
+	if dt > ps.MaxGap {
+		// No samples in between: nothing shows the boat stayed above target
+		dt = 0
+		ps.lastAbove = false
+		if ps.belowSince.IsZero() {
+			ps.belowSince = ps.lastUpdate
+		}
+	}
+	ps.lastUpdate = now
+
+	if !above && ps.belowSince.IsZero() {
+		ps.belowSince = now
+	}
+	if !ps.belowSince.IsZero() && now.Sub(ps.belowSince) > ps.Grace {
+		ps.current = 0
+	}
+
+	if above {
+		// Only time spent continuously above the threshold counts
+		if ps.lastAbove {
+			ps.current += dt
+		}
+		if ps.current > ps.best {
+			ps.best = ps.current
+		}
+		ps.belowSince = time.Time{}
+	}
+	ps.lastAbove = above
+}
+
+// Snapshot returns the live streak and session best in seconds
+func (ps *PolarStreak) Snapshot() map[string]interface{} {
+	ps.mu.Lock()
+	defer ps.mu.Unlock()
+
+	return map[string]interface{}{
+		"currentSeconds": ps.current.Seconds(),
+		"bestSeconds":    ps.best.Seconds(),
+		"thresholdPct":   ps.Threshold,
+		"active":         ps.lastAbove,
+	}
+}
+
+// PerformanceScale maps speed efficiency to the UI color levels. In
+// "absolute" mode the thresholds are polar percentages; in "relative" mode
+// they are percentage positions within the efficiency range achieved so far
+// this session, so a well-sailed boat still shows variation near the top.
+type PerformanceScale struct {
+	Mode       string  // "absolute" or "relative"
+	Optimal    float64 // at or above: optimal
+	Good       float64 // at or above: good
+	Suboptimal float64 // at or above: suboptimal, below: poor
+
+	mu         sync.Mutex
+	sessionMin float64
+	sessionMax float64
+	seen       bool
+}
+
+func NewPerformanceScale() *PerformanceScale {
+	return &PerformanceScale{
+		Mode:       "absolute",
+		Optimal:    95.0,
+		Good:       85.0,
+		Suboptimal: 70.0,
+	}
+}
+
+// Configure validates and applies a new mode and threshold set
+func (ps *PerformanceScale) Configure(mode string, optimal, good, suboptimal float64) error {
+	if mode != "absolute" && mode != "relative" {
+		return fmt.Errorf("invalid scale mode: %s", mode)
+	}
+	if !(optimal > good && good > suboptimal) || suboptimal < 0 || optimal > 100 {
+		return fmt.Errorf("thresholds must satisfy 100 >= optimal > good > suboptimal >= 0")
+	}
+
+	ps.mu.Lock()
+	defer ps.mu.Unlock()
+	ps.Mode = mode
+	ps.Optimal = optimal
+	ps.Good = good
+	ps.Suboptimal = suboptimal
+	return nil
+}
+
+// Settings returns the configured mode and (unscaled) thresholds
+func (ps *PerformanceScale) Settings() (mode string, optimal, good, suboptimal float64) {
+	ps.mu.Lock()
+	defer ps.mu.Unlock()
+	return ps.Mode, ps.Optimal, ps.Good, ps.Suboptimal
+}
+
+// Observe records an efficiency sample for the session range
+func (ps *PerformanceScale) Observe(eff float64) {
+	ps.mu.Lock()
+	defer ps.mu.Unlock()
+
+	if !ps.seen {
+		ps.sessionMin, ps.sessionMax = eff, eff
+		ps.seen = true
+		return
+	}
+	ps.sessionMin = math.Min(ps.sessionMin, eff)
+	ps.sessionMax = math.Max(ps.sessionMax, eff)
+}
+
+// activeThresholds returns the efficiency cut-offs currently in effect.
+// Caller must hold ps.mu.
+func (ps *PerformanceScale) activeThresholds() (optimal, good, suboptimal float64) {
+	if ps.Mode != "relative" || !ps.seen || ps.sessionMax <= ps.sessionMin {
+		return ps.Optimal, ps.Good, ps.Suboptimal
+	}
+
+	span := ps.sessionMax - ps.sessionMin
+	scale := func(pct float64) float64 {
+		return ps.sessionMin + span*pct/100.0
+	}
+	return scale(ps.Optimal), scale(ps.Good), scale(ps.Suboptimal)
+}
+
+// Level maps an efficiency value to "optimal", "good", "suboptimal" or "poor"
+func (ps *PerformanceScale) Level(eff float64) string {
+	ps.mu.Lock()
+	defer ps.mu.Unlock()
+
+	optimal, good, suboptimal := ps.activeThresholds()
+	if eff >= optimal {
+		return "optimal"
+	} else if eff >= good {
+		return "good"
+	} else if eff >= suboptimal {
+		return "suboptimal"
+	}
+	return "poor"
+}
+
+// Snapshot returns the mode and active thresholds for the scene payload
+func (ps *PerformanceScale) Snapshot() map[string]interface{} {
+	ps.mu.Lock()
+	defer ps.mu.Unlock()
+
+	optimal, good, suboptimal := ps.activeThresholds()
+	return map[string]interface{}{
+		"mode":       ps.Mode,
+		"optimal":    optimal,
+		"good":       good,
+		"suboptimal": suboptimal,
+	}
+}
+
 // Visualization server
 type VisualizationServer struct {
+	dbPath      string
+	polarStreak *PolarStreak
+	perfScale   *PerformanceScale
+
+	// mu guards the boat list, selection and live data, which HTTP
+	// handlers update (or /api/reload replaces) while scene requests read
+	// them
+	mu            sync.RWMutex
 	boats         []Boat
 	selectedBoat  *Boat
+	selectedSails string // key into selectedBoat.SailPolars, "" for the default polar
 	boomSenseData BoomSenseData
+	boomSenseAt   time.Time // when boomSenseData was last posted
 }
 
-func NewVisualizationServer(dbPath string) (*VisualizationServer, error) {
-	data, err := ioutil.ReadFile(dbPath)
+func NewVisualizationServer(cfg AppConfig) (*VisualizationServer, error) {
+	boats, err := loadBoatDB(cfg.DBPath)
 	if err != nil {
-		return nil, fmt.Errorf("failed to read database: %w", err)
-	}
-
-	var boats []Boat
-	if err := json.Unmarshal(data, &boats); err != nil {
-		return nil, fmt.Errorf("failed to parse database: %w", err)
+		return nil, err
 	}
 
 	return &VisualizationServer{
-		boats: boats,
+		dbPath:      cfg.DBPath,
+		boats:       boats,
+		polarStreak: NewPolarStreak(cfg.PolarStreakPct, cfg.PolarStreakGrace),
+		perfScale:   NewPerformanceScale(),
 		boomSenseData: BoomSenseData{
 			BoomAngle: 0,
 			EventType: "normal",
@@ -152,27 +382,157 @@ func NewVisualizationServer(dbPath string) (*VisualizationServer, error) {
 	}, nil
 }
 
-func (vs *VisualizationServer) SelectBoat(name string) error {
+// loadBoatDB reads the boat database JSON file
+func loadBoatDB(path string) ([]Boat, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read database: %w", err)
+	}
+
+	var boats []Boat
+	if err := json.Unmarshal(data, &boats); err != nil {
+		return nil, fmt.Errorf("failed to parse database: %w", err)
+	}
+	return boats, nil
+}
+
+// Reload re-reads the boat database and swaps it in, keeping the selected
+// boat (and sails, if it still has that polar) when it is still listed.
+// Polars uploaded through /api/polar are replaced by the file's. Returns
+// the new boat count; on error the current database stays in place.
+func (vs *VisualizationServer) Reload() (int, error) {
+	boats, err := loadBoatDB(vs.dbPath)
+	if err != nil {
+		return 0, err
+	}
+
+	vs.mu.Lock()
+	defer vs.mu.Unlock()
+
+	var selected *Boat
+	if vs.selectedBoat != nil {
+		for i := range boats {
+			if boats[i].Name == vs.selectedBoat.Name {
+				selected = &boats[i]
+				break
+			}
+		}
+	}
+	if selected == nil {
+		vs.selectedSails = ""
+	} else if _, ok := selected.SailPolars[vs.selectedSails]; !ok {
+		vs.selectedSails = ""
+	}
+	vs.boats = boats
+	vs.selectedBoat = selected
+	return len(boats), nil
+}
+
+// SelectBoat selects a boat and the sail configuration whose polar drives
+// the targets; an empty sails uses the boat's default polar
+func (vs *VisualizationServer) SelectBoat(name, sails string) error {
+	vs.mu.Lock()
+	defer vs.mu.Unlock()
+
 	for i := range vs.boats {
 		if vs.boats[i].Name == name {
+			if _, ok := vs.boats[i].SailPolars[sails]; sails != "" && !ok {
+				return fmt.Errorf("boat %s has no polar for sails %q", name, sails)
+			}
 			vs.selectedBoat = &vs.boats[i]
+			vs.selectedSails = sails
 			return nil
 		}
 	}
 	return fmt.Errorf("boat not found: %s", name)
 }
 
+// activePolar is the polar for the selected sails, or the selected boat's
+// default polar. Caller must hold vs.mu and have a boat selected.
+func (vs *VisualizationServer) activePolar() Polar {
+	if polar, ok := vs.selectedBoat.SailPolars[vs.selectedSails]; ok && vs.selectedSails != "" {
+		return polar
+	}
+	return vs.selectedBoat.Polar
+}
+
+// UpdateBoomSense stores live data. Wind angles in the 0-360 convention
+// are folded onto the 0-180 polar range, keeping the side they came from;
+// an angle already in range keeps the side the client sent.
 func (vs *VisualizationServer) UpdateBoomSense(data BoomSenseData) {
+	twa, side := integration.FoldWindAngle(data.WindAngle)
+	if data.WindSide == "" || twa != data.WindAngle {
+		data.WindSide = side
+	}
+	data.WindAngle = twa
+
+	vs.mu.Lock()
 	vs.boomSenseData = data
+	vs.boomSenseAt = time.Now()
+	vs.mu.Unlock()
+
+	vs.trackPolarStreak(time.Now())
+}
+
+// speedEfficiency returns boat speed as a percentage of the polar target,
+// capped at 100, and the uncapped ratio. ok is false without boat speed or
+// a polar target. Caller must hold vs.mu.
+func (vs *VisualizationServer) speedEfficiency() (pct, ratio float64, ok bool) {
+	targetSpeed := vs.getTargetSpeedFromPolar()
+	if targetSpeed <= 0 || vs.boomSenseData.BoatSpeed <= 0 {
+		return 0, 0, false
+	}
+	ratio = vs.boomSenseData.BoatSpeed / targetSpeed
+	return math.Min(ratio*100.0, 100), ratio, true
+}
+
+// trackPolarStreak feeds the polar streak one sample of the live data.
+// Stopped, no polar target and data older than the streak's MaxGap all
+// count as below the threshold.
+func (vs *VisualizationServer) trackPolarStreak(now time.Time) {
+	vs.mu.RLock()
+	pct, _, ok := vs.speedEfficiency()
+	if now.Sub(vs.boomSenseAt) > vs.polarStreak.MaxGap {
+		ok = false
+	}
+	vs.mu.RUnlock()
+
+	if !ok {
+		pct = 0
+	}
+	vs.polarStreak.Update(pct, now)
+}
+
+// startPolarStreak samples the polar streak every interval, so it keeps
+// time (and resets) when no new data is posted. Returns a stop function.
+func (vs *VisualizationServer) startPolarStreak(interval time.Duration) func() {
+	done := make(chan struct{})
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case now := <-ticker.C:
+				vs.trackPolarStreak(now)
+			case <-done:
+				return
+			}
+		}
+	}()
+	return func() { close(done) }
 }
 
 // Generate scene data
 func (vs *VisualizationServer) GenerateSceneData() map[string]interface{} {
+	vs.mu.RLock()
+	defer vs.mu.RUnlock()
+
 	if vs.selectedBoat == nil {
 		return map[string]interface{}{"error": "no boat selected"}
 	}
 
 	boat := vs.selectedBoat
+	polar := vs.activePolar()
 	dim := boat.Dimensions
 	meta := boat.Metadata
 
@@ -210,9 +570,14 @@ func (vs *VisualizationServer) GenerateSceneData() map[string]interface{} {
 			"isp": toFloat64(meta.ISP),
 		},
 		"polar": map[string]interface{}{
-			"windSpeeds": boat.Polar.WindSpeeds,
-			"windAngles": boat.Polar.WindAngles,
-			"boatSpeeds": boat.Polar.BoatSpeeds,
+			"windSpeeds": polar.WindSpeeds,
+			"windAngles": polar.WindAngles,
+			"boatSpeeds": polar.BoatSpeeds,
+			"symmetric":  polar.IsSymmetric(),
+		},
+		"sails": map[string]interface{}{
+			"selected":  vs.selectedSails,
+			"available": boat.SailNames(),
 		},
 		"boomSense": map[string]interface{}{
 			"angle":         vs.boomSenseData.BoomAngle,
@@ -225,12 +590,49 @@ func (vs *VisualizationServer) GenerateSceneData() map[string]interface{} {
 			"timestamp":     vs.boomSenseData.Timestamp,
 			"windSpeed":     vs.boomSenseData.WindSpeed,
 			"windAngle":     vs.boomSenseData.WindAngle,
+			"windSide":      vs.boomSenseData.WindSide,
 			"boatSpeed":     vs.boomSenseData.BoatSpeed,
 		},
 		"performance": vs.calculatePerformanceMetrics(),
+		"vmg":         vs.calculateVMGTargets(),
+		"navigation":  sceneNavigation(),
+	}
+}
+
+// sceneNavigation carries the leeway and current vectors and the sea state
+// for the viewer. Each is left out, not zeroed, while its inputs are missing so the UI
+// hides it instead of drawing a bogus zero current.
+func sceneNavigation() map[string]interface{} {
+	nav := map[string]interface{}{}
+	if boomMapper == nil {
+		return nav
+	}
+	if leeway := boomMapper.Leeway(); leeway != nil {
+		nav["leeway"] = map[string]interface{}{
+			"angle": leeway.AngleDeg,
+			"valid": leeway.Valid,
+		}
+	}
+	if current := boomMapper.Current(); current != nil {
+		nav["current"] = map[string]interface{}{
+			"set":   current.SetDeg,
+			"drift": current.DriftKts,
+			"valid": current.Valid,
+		}
+	}
+	if sea, ok := boomMapper.SeaState(); ok {
+		nav["seaState"] = map[string]interface{}{
+			"class":    sea.Class,
+			"rollRMS":  sea.RollRMSDeg,
+			"pitchRMS": sea.PitchRMSDeg,
+			"heaveRMS": sea.HeaveRMSM,
+		}
 	}
+	return nav
 }
 
+// calculatePerformanceMetrics and the helpers below read the selection and
+// live data; callers must hold vs.mu.
 func (vs *VisualizationServer) calculatePerformanceMetrics() map[string]interface{} {
 	if vs.selectedBoat == nil {
 		return map[string]interface{}{}
@@ -245,17 +647,19 @@ func (vs *VisualizationServer) calculatePerformanceMetrics() map[string]interfac
 	}
 
 	targetSpeed := vs.getTargetSpeedFromPolar()
-
-	// Calculate speed efficiency
-	speedEfficiency := 100.0
-	if targetSpeed > 0 && vs.boomSenseData.BoatSpeed > 0 {
-		speedEfficiency = (vs.boomSenseData.BoatSpeed / targetSpeed) * 100.0
-		if speedEfficiency > 100 {
-			speedEfficiency = 100
-		}
+	beat, beatOK, run, runOK := vs.activePolar().OptimalVMG(vs.boomSenseData.WindSpeed)
+
+	// Calculate speed efficiency, capped at 100. polar_ratio is not capped:
+	// sailing above the polar means conditions beat the VPP or the log
+	// under-reads, which is worth showing.
+	speedEfficiency, polarRatio, ok := vs.speedEfficiency()
+	if ok {
+		vs.perfScale.Observe(speedEfficiency)
+	} else {
+		speedEfficiency = 100.0
 	}
 
-	return map[string]interface{}{
+	metrics := map[string]interface{}{
 		"optimalBoomAngle": optimalAngle,
 		"deviation":        deviation,
 		"trimEfficiency":   trimEfficiency,
@@ -264,255 +668,1260 @@ func (vs *VisualizationServer) calculatePerformanceMetrics() map[string]interfac
 		"targetSpeed":      targetSpeed,
 		"windSpeed":        vs.boomSenseData.WindSpeed,
 		"windAngle":        vs.boomSenseData.WindAngle,
+		"windSide":         vs.boomSenseData.WindSide,
+		"polarStreak":      vs.polarStreak.Snapshot(),
+		"speedLevel":       vs.perfScale.Level(speedEfficiency),
+		"speedScale":       vs.perfScale.Snapshot(),
+		"beatAngle":        beat.Angle,
+		"beatVMG":          beat.VMG,
+		"beatTargetSpeed":  beat.BoatSpeed,
+		"runAngle":         run.Angle,
+		"runVMG":           run.VMG,
+		"runTargetSpeed":   run.BoatSpeed,
+	}
+	if polarRatio > 0 {
+		metrics["polar_ratio"] = polarRatio
+		metrics["polar_band"] = polarBandFor(polarRatio)
 	}
+	vs.addVMGCoaching(metrics, beat, beatOK, run, runOK)
+	return metrics
+}
+
+// polarOnBand is how far either side of the polar target, as a fraction,
+// boat speed counts as on target
+const polarOnBand = 0.03
+
+// polarBandFor classifies a boat speed / polar target ratio as "below",
+// "on" or "above"
+func polarBandFor(ratio float64) string {
+	switch {
+	case ratio < 1-polarOnBand:
+		return "below"
+	case ratio > 1+polarOnBand:
+		return "above"
+	}
+	return "on"
+}
+
+// vmgOnTargetDeg is how close to the optimal TWA counts as on target
+const vmgOnTargetDeg = 1.0
+
+// addVMGCoaching compares the current TWA with the optimal VMG angle for
+// the current board (upwind below 90°, downwind above): vmgCurrent and
+// vmgOptimal in kts (downwind as a positive magnitude), angleToOptimal in
+// degrees (negative = head up, positive = bear away) and optimalSide,
+// "above" when pointing higher than optimal, "below" when lower, "on"
+// within vmgOnTargetDeg.
+func (vs *VisualizationServer) addVMGCoaching(metrics map[string]interface{}, beat VMGTarget, beatOK bool, run VMGTarget, runOK bool) {
+	twa, _ := integration.FoldWindAngle(vs.boomSenseData.WindAngle)
+
+	target, ok := beat, beatOK
+	sign := 1.0
+	if twa > 90 {
+		target, ok = run, runOK
+		sign = -1.0
+	}
+	if !ok || vs.boomSenseData.BoatSpeed <= 0 {
+		return
+	}
+
+	delta := target.Angle - twa
+	side := "on"
+	switch {
+	case delta > vmgOnTargetDeg:
+		side = "above"
+	case delta < -vmgOnTargetDeg:
+		side = "below"
+	}
+
+	metrics["vmgCurrent"] = sign * vs.boomSenseData.BoatSpeed * math.Cos(twa*math.Pi/180.0)
+	metrics["vmgOptimal"] = target.VMG
+	metrics["angleToOptimal"] = delta
+	metrics["optimalSide"] = side
 }
 
 func (vs *VisualizationServer) getTargetSpeedFromPolar() float64 {
-	if vs.selectedBoat == nil || len(vs.selectedBoat.Polar.BoatSpeeds) == 0 {
+	if vs.selectedBoat == nil {
+		return 0.0
+	}
+	return vs.activePolar().TargetSpeedSide(vs.boomSenseData.WindSpeed, vs.boomSenseData.WindAngle, vs.boomSenseData.WindSide)
+}
+
+// TargetSpeed bilinearly interpolates the polar boat speed for the given
+// true wind speed and angle. Inputs outside the grid are clamped to its
+// edges; a single-row or single-column polar degrades to linear
+// interpolation along the other axis.
+func (p Polar) TargetSpeed(windSpeed, windAngle float64) float64 {
+	if len(p.WindSpeeds) == 0 || len(p.WindAngles) == 0 || len(p.BoatSpeeds) < len(p.WindSpeeds) {
 		return 0.0
 	}
 
-	polar := vs.selectedBoat.Polar
+	ws0, ws1, wsFrac := polarBracket(p.WindSpeeds, windSpeed)
+	wa0, wa1, waFrac := polarBracket(p.WindAngles, windAngle)
+
+	cell := func(ws, wa int) float64 {
+		if wa < len(p.BoatSpeeds[ws]) {
+			return p.BoatSpeeds[ws][wa]
+		}
+		return 0.0
+	}
+
+	low := cell(ws0, wa0) + (cell(ws0, wa1)-cell(ws0, wa0))*waFrac
+	high := cell(ws1, wa0) + (cell(ws1, wa1)-cell(ws1, wa0))*waFrac
+	return low + (high-low)*wsFrac
+}
+
+// VMGTarget is the optimal true wind angle for one point of sail together
+// with its polar boat speed and velocity made good (kts)
+type VMGTarget struct {
+	Angle     float64 `json:"angle"`
+	BoatSpeed float64 `json:"boatSpeed"`
+	VMG       float64 `json:"vmg"`
+}
+
+// OptimalVMG sweeps the polar in 1° steps at the given true wind speed and
+// returns the angles that maximise VMG to windward (TWA below 90°) and to
+// leeward (TWA above 90°). Downwind VMG is reported as a positive magnitude.
+// ok flags are false when the polar does not cover that side. A full-circle
+// polar is swept over its starboard half.
+func (p Polar) OptimalVMG(windSpeed float64) (beat VMGTarget, beatOK bool, run VMGTarget, runOK bool) {
+	if len(p.WindAngles) == 0 {
+		return
+	}
+
+	minAngle := math.Ceil(p.WindAngles[0])
+	maxAngle := math.Min(math.Floor(p.WindAngles[len(p.WindAngles)-1]), 180)
+	for twa := minAngle; twa <= maxAngle; twa++ {
+		bs := p.TargetSpeed(windSpeed, twa)
+		vmg := bs * math.Cos(twa*math.Pi/180.0)
+
+		switch {
+		case twa < 90 && vmg > beat.VMG:
+			beat = VMGTarget{Angle: twa, BoatSpeed: bs, VMG: vmg}
+			beatOK = true
+		case twa > 90 && -vmg > run.VMG:
+			run = VMGTarget{Angle: twa, BoatSpeed: bs, VMG: -vmg}
+			runOK = true
+		}
+	}
+	return
+}
+
+// polarBracket finds the grid indices surrounding v on an ascending axis and
+// the fractional position between them, clamping to the axis ends
+func polarBracket(axis []float64, v float64) (i0, i1 int, frac float64) {
+	last := len(axis) - 1
+	if v <= axis[0] {
+		return 0, 0, 0
+	}
+	if v >= axis[last] {
+		return last, last, 0
+	}
+
+	for i := 0; i < last; i++ {
+		if v <= axis[i+1] {
+			span := axis[i+1] - axis[i]
+			if span <= 0 {
+				return i + 1, i + 1, 0
+			}
+			return i, i + 1, (v - axis[i]) / span
+		}
+	}
+	return last, last, 0
+}
+
+// calculateVMGTargets reports the optimal beat and run angles for the
+// current true wind speed of the selected boat. Caller must hold vs.mu.
+func (vs *VisualizationServer) calculateVMGTargets() map[string]interface{} {
+	if vs.selectedBoat == nil {
+		return map[string]interface{}{}
+	}
+
 	windSpeed := vs.boomSenseData.WindSpeed
+	beat, beatOK, run, runOK := vs.activePolar().OptimalVMG(windSpeed)
+
+	targets := map[string]interface{}{
+		"windSpeed": windSpeed,
+	}
+	if beatOK {
+		targets["beat"] = beat
+	}
+	if runOK {
+		targets["run"] = run
+	}
+	return targets
+}
+
+func (vs *VisualizationServer) estimateOptimalBoomAngle() float64 {
 	windAngle := vs.boomSenseData.WindAngle
+	windSpeed := vs.boomSenseData.WindSpeed
+
+	var optimalAngle float64
+
+	if windAngle < 45 {
+		factor := 2.5 + (windSpeed / 30.0)
+		optimalAngle = windAngle / factor
+
+	} else if windAngle < 70 {
+		optimalAngle = windAngle * 0.35
+
+	} else if windAngle < 100 {
+		optimalAngle = windAngle * 0.60
 
-	// Find closest wind speed index
-	wsIdx := 0
-	minDiff := math.Abs(polar.WindSpeeds[0] - windSpeed)
-	for i, ws := range polar.WindSpeeds {
-		diff := math.Abs(ws - windSpeed)
-		if diff < minDiff {
-			minDiff = diff
-			wsIdx = i
+	} else if windAngle < 140 {
+		optimalAngle = windAngle * 0.60
+
+	} else {
+		optimalAngle = 80.0
+		if windSpeed < 6 {
+			optimalAngle = 75.0
 		}
 	}
 
-	// Find closest wind angle index
-	waIdx := 0
-	minDiff = math.Abs(polar.WindAngles[0] - windAngle)
-	for i, wa := range polar.WindAngles {
-		diff := math.Abs(wa - windAngle)
-		if diff < minDiff {
-			minDiff = diff
-			waIdx = i
+	if windSpeed < 8 {
+		optimalAngle *= 0.92
+	} else if windSpeed > 20 {
+		optimalAngle *= 1.05
+	}
+
+	if optimalAngle < -85 {
+		optimalAngle = -85
+	}
+	if optimalAngle > 85 {
+		optimalAngle = 85
+	}
+
+	return optimalAngle
+}
+
+func (vs *VisualizationServer) getAlertLevel(deviation float64) string {
+	if deviation < 5 {
+		return "optimal"
+	} else if deviation < 15 {
+		return "good"
+	} else if deviation < 30 {
+		return "suboptimal"
+	}
+	return "poor"
+}
+
+// HTTP Handlers
+func (vs *VisualizationServer) handleViewer(w http.ResponseWriter, r *http.Request) {
+	html := vs.generateHTML()
+	w.Header().Set("Content-Type", "text/html")
+	w.Write([]byte(html))
+}
+
+func (vs *VisualizationServer) handleSceneData(w http.ResponseWriter, r *http.Request) {
+	data := vs.GenerateSceneData()
+	w.Header().Set("Content-Type", "application/json")
+	encodeJSON(w, data)
+}
+
+func (vs *VisualizationServer) handleBoatList(w http.ResponseWriter, r *http.Request) {
+	searchQuery := strings.ToLower(r.URL.Query().Get("search"))
+	designer := strings.ToLower(r.URL.Query().Get("designer"))
+	builder := strings.ToLower(r.URL.Query().Get("builder"))
+
+	boats := make([]map[string]interface{}, 0)
+	designerSet := make(map[string]bool)
+	builderSet := make(map[string]bool)
+
+	vs.mu.RLock()
+	defer vs.mu.RUnlock()
+
+	for _, boat := range vs.boats {
+		if boat.Metadata.Designer != "" {
+			designerSet[boat.Metadata.Designer] = true
+		}
+		if boat.Metadata.Builder != "" {
+			builderSet[boat.Metadata.Builder] = true
 		}
+
+		if searchQuery != "" {
+			if !strings.Contains(strings.ToLower(boat.Name), searchQuery) &&
+				!strings.Contains(strings.ToLower(boat.Class), searchQuery) {
+				continue
+			}
+		}
+
+		if designer != "" && strings.ToLower(boat.Metadata.Designer) != designer {
+			continue
+		}
+
+		if builder != "" && strings.ToLower(boat.Metadata.Builder) != builder {
+			continue
+		}
+
+		boats = append(boats, map[string]interface{}{
+			"name":     boat.Name,
+			"class":    boat.Class,
+			"designer": boat.Metadata.Designer,
+			"builder":  boat.Metadata.Builder,
+			"length":   boat.Dimensions.LengthOverall,
+			"sails":    boat.SailNames(),
+		})
+	}
+
+	designers := make([]string, 0, len(designerSet))
+	for d := range designerSet {
+		if d != "" {
+			designers = append(designers, d)
+		}
+	}
+
+	builders := make([]string, 0, len(builderSet))
+	for b := range builderSet {
+		if b != "" {
+			builders = append(builders, b)
+		}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	encodeJSON(w, map[string]interface{}{
+		"boats":     boats,
+		"designers": designers,
+		"builders":  builders,
+	})
+}
+
+// handleCompare returns polars and key dimensions for several boats, looked
+// up by name without changing the selection, e.g.
+// /api/compare?boats=A,B,C&tws=12. With tws, each boat also carries its
+// interpolated speed at every polar angle of the first boat for overlaying.
+func (vs *VisualizationServer) handleCompare(w http.ResponseWriter, r *http.Request) {
+	var names []string
+	for _, name := range strings.Split(r.URL.Query().Get("boats"), ",") {
+		if name = strings.TrimSpace(name); name != "" {
+			names = append(names, name)
+		}
+	}
+	if len(names) == 0 {
+		http.Error(w, "boats is required, e.g. ?boats=A,B", http.StatusBadRequest)
+		return
+	}
+
+	tws := 0.0
+	if v := r.URL.Query().Get("tws"); v != "" {
+		f, err := strconv.ParseFloat(v, 64)
+		if err != nil || f <= 0 {
+			http.Error(w, fmt.Sprintf("invalid tws: %s", v), http.StatusBadRequest)
+			return
+		}
+		tws = f
+	}
+
+	// Polars can be replaced at runtime through /api/polar
+	vs.mu.RLock()
+	defer vs.mu.RUnlock()
+
+	found := make([]*Boat, 0, len(names))
+	var missing []string
+	for _, name := range names {
+		var match *Boat
+		for i := range vs.boats {
+			if vs.boats[i].Name == name {
+				match = &vs.boats[i]
+				break
+			}
+		}
+		if match == nil {
+			missing = append(missing, name)
+			continue
+		}
+		found = append(found, match)
+	}
+	if len(missing) > 0 {
+		http.Error(w, fmt.Sprintf("boats not found: %s", strings.Join(missing, ", ")), http.StatusNotFound)
+		return
+	}
+
+	boats := make([]map[string]interface{}, 0, len(found))
+	for _, boat := range found {
+		entry := map[string]interface{}{
+			"name":       boat.Name,
+			"class":      boat.Class,
+			"designer":   boat.Metadata.Designer,
+			"builder":    boat.Metadata.Builder,
+			"dimensions": boat.Dimensions,
+			"polar": map[string]interface{}{
+				"windSpeeds": boat.Polar.WindSpeeds,
+				"windAngles": boat.Polar.WindAngles,
+				"boatSpeeds": boat.Polar.BoatSpeeds,
+			},
+		}
+		if tws > 0 {
+			angles := found[0].Polar.WindAngles
+			speeds := make([]float64, len(angles))
+			for i, twa := range angles {
+				speeds[i] = boat.Polar.TargetSpeed(tws, twa)
+			}
+			entry["atTWS"] = map[string]interface{}{
+				"windAngles": angles,
+				"boatSpeeds": speeds,
+			}
+		}
+		boats = append(boats, entry)
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	encodeJSON(w, map[string]interface{}{
+		"tws":   tws,
+		"boats": boats,
+	})
+}
+
+// heatmapMaxSteps bounds each heatmap axis so a tiny step cannot request an
+// enormous grid
+const heatmapMaxSteps = 500
+
+// handlePolarHeatmap returns the selected boat's target speed on a regular
+// TWS×TWA grid, interpolated from the polar so it can be denser than the
+// raw table, e.g. /api/polar/heatmap?tws_step=1&twa_step=5&mode=efficiency.
+// mode=efficiency divides each cell by the best speed at the same TWS, so
+// it shows which angles the boat sails well in each wind. full=true draws
+// a symmetric polar over the whole 0-360 circle.
+func (vs *VisualizationServer) handlePolarHeatmap(w http.ResponseWriter, r *http.Request) {
+	q := r.URL.Query()
+	step := func(name string, def float64) (float64, bool) {
+		v := q.Get(name)
+		if v == "" {
+			return def, true
+		}
+		f, err := strconv.ParseFloat(v, 64)
+		if err != nil || f <= 0 {
+			http.Error(w, fmt.Sprintf("invalid %s: %s", name, v), http.StatusBadRequest)
+			return 0, false
+		}
+		return f, true
+	}
+	twsStep, ok := step("tws_step", 1)
+	if !ok {
+		return
+	}
+	twaStep, ok := step("twa_step", 5)
+	if !ok {
+		return
+	}
+	mode := q.Get("mode")
+	if mode == "" {
+		mode = "speed"
+	}
+	if mode != "speed" && mode != "efficiency" {
+		http.Error(w, fmt.Sprintf("mode must be \"speed\" or \"efficiency\", got %q", mode), http.StatusBadRequest)
+		return
+	}
+
+	vs.mu.RLock()
+	defer vs.mu.RUnlock()
+
+	if vs.selectedBoat == nil {
+		http.Error(w, "no boat selected", http.StatusNotFound)
+		return
+	}
+	polar := vs.activePolar()
+	if len(polar.WindSpeeds) == 0 || len(polar.WindAngles) == 0 {
+		http.Error(w, "selected boat has no polar", http.StatusNotFound)
+		return
+	}
+	if q.Get("full") == "true" {
+		polar = polar.ExpandSymmetric()
+	}
+
+	twsAxis, ok := heatmapAxis(polar.WindSpeeds, twsStep)
+	if !ok {
+		http.Error(w, "tws_step too small", http.StatusBadRequest)
+		return
+	}
+	twaAxis, ok := heatmapAxis(polar.WindAngles, twaStep)
+	if !ok {
+		http.Error(w, "twa_step too small", http.StatusBadRequest)
+		return
+	}
+
+	values := make([][]float64, len(twsAxis))
+	minV, maxV := math.Inf(1), math.Inf(-1)
+	for i, tws := range twsAxis {
+		row := make([]float64, len(twaAxis))
+		best := 0.0
+		for j, twa := range twaAxis {
+			row[j] = polar.TargetSpeed(tws, twa)
+			best = math.Max(best, row[j])
+		}
+		if mode == "efficiency" {
+			for j := range row {
+				if best > 0 {
+					row[j] /= best
+				}
+			}
+		}
+		for _, v := range row {
+			minV = math.Min(minV, v)
+			maxV = math.Max(maxV, v)
+		}
+		values[i] = row
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	encodeJSON(w, map[string]interface{}{
+		"boat":       vs.selectedBoat.Name,
+		"mode":       mode,
+		"windSpeeds": twsAxis,
+		"windAngles": twaAxis,
+		"values":     values, // [tws][twa]
+		"min":        minV,
+		"max":        maxV,
+	})
+}
+
+// heatmapAxis spans an ascending polar axis from its first to its last
+// value in steps, always ending on the last value
+func heatmapAxis(axis []float64, step float64) ([]float64, bool) {
+	lo, hi := axis[0], axis[len(axis)-1]
+	if (hi-lo)/step > heatmapMaxSteps {
+		return nil, false
+	}
+
+	var out []float64
+	for v := lo; v < hi-1e-9; v += step {
+		out = append(out, v)
+	}
+	return append(out, hi), true
+}
+
+// bestVMGResponse is the /api/polar/bestvmg body; beat or run is null when
+// the polar has no angles on that side of 90°
+type bestVMGResponse struct {
+	Boat      string     `json:"boat"`
+	WindSpeed float64    `json:"windSpeed"`
+	Beat      *VMGTarget `json:"beat"`
+	Run       *VMGTarget `json:"run"`
+}
+
+// handlePolarBestVMG returns the optimal beat and run angles, boat speeds
+// and VMGs of the selected boat's polar at a true wind speed, for use as
+// instrument targets, e.g. /api/polar/bestvmg?tws=12. A tws outside the
+// polar's wind speeds is refused: clamping would return the edge row's
+// targets as if they applied.
+func (vs *VisualizationServer) handlePolarBestVMG(w http.ResponseWriter, r *http.Request) {
+	v := r.URL.Query().Get("tws")
+	tws, err := strconv.ParseFloat(v, 64)
+	if err != nil || tws <= 0 {
+		http.Error(w, fmt.Sprintf("tws is required and must be positive, got %q", v), http.StatusBadRequest)
+		return
+	}
+
+	vs.mu.RLock()
+	defer vs.mu.RUnlock()
+
+	if vs.selectedBoat == nil {
+		http.Error(w, "no boat selected", http.StatusNotFound)
+		return
+	}
+	polar := vs.activePolar()
+	if len(polar.WindSpeeds) == 0 || len(polar.WindAngles) == 0 {
+		http.Error(w, "selected boat has no polar", http.StatusNotFound)
+		return
+	}
+	lo, hi := polar.WindSpeeds[0], polar.WindSpeeds[len(polar.WindSpeeds)-1]
+	if tws < lo || tws > hi {
+		http.Error(w, fmt.Sprintf("tws %g kts is outside the polar's %g-%g kts", tws, lo, hi), http.StatusBadRequest)
+		return
+	}
+
+	resp := bestVMGResponse{Boat: vs.selectedBoat.Name, WindSpeed: tws}
+	beat, beatOK, run, runOK := polar.OptimalVMG(tws)
+	if beatOK {
+		resp.Beat = &beat
+	}
+	if runOK {
+		resp.Run = &run
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	encodeJSON(w, resp)
+}
+
+// handleReload re-reads the boat database (POST) without a restart, so the
+// live NMEA buffer survives edits to the DB file
+func (vs *VisualizationServer) handleReload(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "POST required", http.StatusMethodNotAllowed)
+		return
+	}
+
+	n, err := vs.Reload()
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	vs.mu.RLock()
+	selected := ""
+	if vs.selectedBoat != nil {
+		selected = vs.selectedBoat.Name
+	}
+	vs.mu.RUnlock()
+
+	log.Printf("Reloaded %d boats from %s", n, vs.dbPath)
+	w.Header().Set("Content-Type", "application/json")
+	encodeJSON(w, map[string]interface{}{
+		"status":   "ok",
+		"boats":    n,
+		"selected": selected,
+	})
+}
+
+func (vs *VisualizationServer) handleSelectBoat(w http.ResponseWriter, r *http.Request) {
+	boatName := r.URL.Query().Get("name")
+	sails := r.URL.Query().Get("sails")
+	if err := vs.SelectBoat(boatName, sails); err != nil {
+		http.Error(w, err.Error(), http.StatusNotFound)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	encodeJSON(w, map[string]string{"status": "ok", "selected": boatName, "sails": sails})
+}
+
+func (vs *VisualizationServer) handleUpdateBoomSense(w http.ResponseWriter, r *http.Request) {
+	var data BoomSenseData
+	if err := json.NewDecoder(r.Body).Decode(&data); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	vs.UpdateBoomSense(data)
+	w.Header().Set("Content-Type", "application/json")
+	encodeJSON(w, map[string]string{"status": "ok"})
+}
+
+func (vs *VisualizationServer) handlePerformanceScale(w http.ResponseWriter, r *http.Request) {
+	if r.Method == http.MethodPost {
+		q := r.URL.Query()
+		mode, optimal, good, suboptimal := vs.perfScale.Settings()
+		if v := q.Get("mode"); v != "" {
+			mode = v
+		}
+		for key, dst := range map[string]*float64{
+			"optimal":    &optimal,
+			"good":       &good,
+			"suboptimal": &suboptimal,
+		} {
+			if v := q.Get(key); v != "" {
+				f, err := strconv.ParseFloat(v, 64)
+				if err != nil {
+					http.Error(w, fmt.Sprintf("invalid %s: %s", key, v), http.StatusBadRequest)
+					return
+				}
+				*dst = f
+			}
+		}
+
+		if err := vs.perfScale.Configure(mode, optimal, good, suboptimal); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	encodeJSON(w, vs.perfScale.Snapshot())
+}
+
+// handleBoomCalibration exposes the live boom axis value (GET) so a client
+// can capture the four calibration points, and commits them (POST)
+func handleBoomCalibration(w http.ResponseWriter, r *http.Request) {
+	if boomSensor == nil {
+		http.Error(w, "BoomSense sensor not running", http.StatusServiceUnavailable)
+		return
+	}
+
+	if r.Method == http.MethodPost {
+		var points struct {
+			Center0 float64 `json:"center0"`
+			StbMax  float64 `json:"stb_max"`
+			PortMax float64 `json:"port_max"`
+			Center1 float64 `json:"center1"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&points); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		cal, err := boomSensor.CalibrateFromPoints(points.Center0, points.StbMax, points.PortMax, points.Center1)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		encodeJSON(w, map[string]interface{}{
+			"status":   "ok",
+			"mid":      cal.Mid,
+			"span_pos": cal.SpanPos,
+			"span_neg": cal.SpanNeg,
+			"quality":  *cal.Quality,
+			"rating":   boomsense_sensor.CalibrationRating(*cal.Quality),
+		})
+		return
+	}
+
+	value, ready := boomSensor.GetAxisValue()
+	w.Header().Set("Content-Type", "application/json")
+	encodeJSON(w, map[string]interface{}{
+		"axis_value": value,
+		"ready":      ready,
+	})
+}
+
+// maxIMUUpload bounds a /api/boomsense/imu request body
+const maxIMUUpload = 1 << 20
+
+// imuSample is one reading posted to /api/boomsense/imu, in the keys of the
+// MQTT IMU payload; ts (or timestamp) is unix milliseconds, now when absent
+type imuSample struct {
+	TS        *float64 `json:"ts"`
+	Timestamp *float64 `json:"timestamp"`
+	AX        float64  `json:"ax"`
+	AY        float64  `json:"ay"`
+	AZ        float64  `json:"az"`
+	GX        float64  `json:"gx"`
+	GY        float64  `json:"gy"`
+	GZ        float64  `json:"gz"`
+	MX        float64  `json:"mx"`
+	MY        float64  `json:"my"`
+	MZ        float64  `json:"mz"`
+}
+
+func (s imuSample) reading() boomsense_sensor.IMUReading {
+	ts := time.Now()
+	if s.TS != nil {
+		ts = time.UnixMilli(int64(*s.TS))
+	} else if s.Timestamp != nil {
+		ts = time.UnixMilli(int64(*s.Timestamp))
+	}
+	return boomsense_sensor.IMUReading{
+		Timestamp: ts,
+		AccelX:    s.AX,
+		AccelY:    s.AY,
+		AccelZ:    s.AZ,
+		GyroX:     s.GX,
+		GyroY:     s.GY,
+		GyroZ:     s.GZ,
+		MagX:      s.MX,
+		MagY:      s.MY,
+		MagZ:      s.MZ,
+	}
+}
+
+// filteredJSON is the API view of one processed IMU sample; boom values
+// are null until the sensor is calibrated
+func filteredJSON(f boomsense_sensor.FilteredData) map[string]interface{} {
+	return map[string]interface{}{
+		"timestamp":           f.Timestamp.UnixMilli(),
+		"roll_deg":            f.RollDeg,
+		"pitch_deg":           f.PitchDeg,
+		"boom_rel_deg":        f.BoomRelDeg,
+		"boom_rel_deg_smooth": f.BoomRelDegSmooth,
+		"boom_norm":           f.BoomNorm,
+		"clipped":             f.Clipped,
+	}
+}
+
+// handleBoomIMU feeds posted IMU readings through the sensor as if they had
+// arrived over MQTT, so the filter, detector and calibration can be driven
+// from a script without hardware. The body is one reading or an array of
+// them, processed in order; the response mirrors it with the filtered data.
+// Readings must not go back in time, within the batch or against the live
+// feed: a batch starting before the last reading processed is refused with
+// 409 before any of it is applied.
+func handleBoomIMU(w http.ResponseWriter, r *http.Request) {
+	if boomSensor == nil {
+		http.Error(w, "BoomSense sensor not running", http.StatusServiceUnavailable)
+		return
+	}
+	if r.Method != http.MethodPost {
+		http.Error(w, "POST an IMU reading", http.StatusMethodNotAllowed)
+		return
+	}
+
+	body, err := ioutil.ReadAll(http.MaxBytesReader(w, r.Body, maxIMUUpload))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	body = bytes.TrimSpace(body)
+	batch := len(body) > 0 && body[0] == '['
+	var samples []imuSample
+	if batch {
+		err = json.Unmarshal(body, &samples)
+	} else {
+		samples = make([]imuSample, 1)
+		err = json.Unmarshal(body, &samples[0])
+	}
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	readings := make([]boomsense_sensor.IMUReading, len(samples))
+	for i, sample := range samples {
+		readings[i] = sample.reading()
+		if i > 0 && readings[i].Timestamp.Before(readings[i-1].Timestamp) {
+			http.Error(w, fmt.Sprintf("reading %d: timestamp goes backwards", i), http.StatusBadRequest)
+			return
+		}
+	}
+
+	filtered, err := boomSensor.ProcessIMUInOrder(readings)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusConflict)
+		return
+	}
+	results := make([]map[string]interface{}, len(filtered))
+	for i, f := range filtered {
+		results[i] = filteredJSON(f)
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if batch {
+		encodeJSON(w, results)
+	} else {
+		encodeJSON(w, results[0])
+	}
+}
+
+// handleDetectorConfig returns the event detector thresholds on GET. POST
+// takes a JSON object of thresholds to change, merged over the current
+// ones, and applies it only if every value is in range.
+func handleDetectorConfig(w http.ResponseWriter, r *http.Request) {
+	if boomSensor == nil {
+		http.Error(w, "BoomSense sensor not running", http.StatusServiceUnavailable)
+		return
+	}
+
+	switch r.Method {
+	case http.MethodGet:
+	case http.MethodPost:
+		cfg := boomSensor.DetectorConfig()
+		if err := json.NewDecoder(r.Body).Decode(&cfg); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		if err := boomSensor.UpdateDetectorConfig(cfg); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		log.Printf("[BoomSense] Detector thresholds updated via API")
+	default:
+		http.Error(w, "GET or POST required", http.StatusMethodNotAllowed)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	encodeJSON(w, boomSensor.DetectorConfig())
+}
+
+// NEW: NMEA API Handlers
+func handleNMEAStatus(w http.ResponseWriter, r *http.Request) {
+	if nmeaCollector == nil {
+		http.Error(w, "NMEA collector not running", http.StatusServiceUnavailable)
+		return
+	}
+
+	stats := nmeaCollector.Stats().GetSnapshot()
+	bufferStats := nmeaCollector.Buffer().GetStats()
+
+	w.Header().Set("Content-Type", "application/json")
+	encodeJSON(w, map[string]interface{}{
+		"collector": stats,
+		"buffer":    bufferStats,
+		"connected": nmeaCollector.IsConnected(),
+		"health":    nmeaCollector.Health(),
+		"influx":    influxStatus(),
+	})
+}
+
+// influxStatus reports whether decoded messages go to InfluxDB and how many
+// the writer has had to discard
+func influxStatus() map[string]interface{} {
+	if influxWriter == nil {
+		return map[string]interface{}{"enabled": false}
+	}
+	return map[string]interface{}{
+		"enabled": true,
+		"dropped": influxWriter.Dropped(),
+	}
+}
+
+// handleNMEADevices lists every source address on the bus with the model,
+// serial and software version from its product information, and the PGNs
+// it sends
+func handleNMEADevices(w http.ResponseWriter, r *http.Request) {
+	if nmeaCollector == nil {
+		http.Error(w, "NMEA collector not running", http.StatusServiceUnavailable)
+		return
 	}
 
-	if wsIdx < len(polar.BoatSpeeds) && waIdx < len(polar.BoatSpeeds[wsIdx]) {
-		return polar.BoatSpeeds[wsIdx][waIdx]
-	}
+	devices := nmeaCollector.Devices()
 
-	return 0.0
+	w.Header().Set("Content-Type", "application/json")
+	encodeJSON(w, map[string]interface{}{
+		"devices": devices,
+		"count":   len(devices),
+	})
 }
 
-func (vs *VisualizationServer) estimateOptimalBoomAngle() float64 {
-	windAngle := vs.boomSenseData.WindAngle
-	windSpeed := vs.boomSenseData.WindSpeed
+// handleNMEAPGNs lists the PGNs seen on the bus and which ones are decoded
+func handleNMEAPGNs(w http.ResponseWriter, r *http.Request) {
+	if nmeaCollector == nil {
+		http.Error(w, "NMEA collector not running", http.StatusServiceUnavailable)
+		return
+	}
 
-	var optimalAngle float64
+	pgns := nmeaCollector.PGNCoverage()
+	undecoded := 0
+	for _, info := range pgns {
+		if !info.Decoded {
+			undecoded++
+		}
+	}
 
-	if windAngle < 45 {
-		factor := 2.5 + (windSpeed / 30.0)
-		optimalAngle = windAngle / factor
+	w.Header().Set("Content-Type", "application/json")
+	encodeJSON(w, map[string]interface{}{
+		"pgns":      pgns,
+		"total":     len(pgns),
+		"undecoded": undecoded,
+	})
+}
 
-	} else if windAngle < 70 {
-		optimalAngle = windAngle * 0.35
+// handleEnvironment returns the latest air and water temperature, humidity
+// and barometric pressure with their source PGNs, plus the pressure trend
+func handleEnvironment(w http.ResponseWriter, r *http.Request) {
+	if boomMapper == nil {
+		http.Error(w, "BoomSense mapper not available", http.StatusServiceUnavailable)
+		return
+	}
 
-	} else if windAngle < 100 {
-		optimalAngle = windAngle * 0.60
+	w.Header().Set("Content-Type", "application/json")
+	encodeJSON(w, boomMapper.Environment())
+}
 
-	} else if windAngle < 140 {
-		optimalAngle = windAngle * 0.60
+func handleNMEALatest(w http.ResponseWriter, r *http.Request) {
+	if boomMapper == nil {
+		http.Error(w, "BoomSense mapper not available", http.StatusServiceUnavailable)
+		return
+	}
 
-	} else {
-		optimalAngle = 80.0
-		if windSpeed < 6 {
-			optimalAngle = 75.0
+	data := boomMapper.GetCurrentData()
+	aws, awaSigned := boomMapper.CalculateApparentWindSigned()
+	tws, twa := boomMapper.CalculateTrueWind()
+
+	navigation := map[string]interface{}{}
+	if radius, ok := boomMapper.TurningRadius(); ok {
+		direction := "starboard"
+		if radius < 0 {
+			direction = "port"
 		}
+		navigation["turning_radius_m"] = math.Abs(radius)
+		navigation["turn_direction"] = direction
 	}
-
-	if windSpeed < 8 {
-		optimalAngle *= 0.92
-	} else if windSpeed > 20 {
-		optimalAngle *= 1.05
+	if leeway, ok := boomMapper.EstimateLeeway(); ok {
+		navigation["leeway_deg"] = leeway
 	}
-
-	if optimalAngle < -85 {
-		optimalAngle = -85
+	if set, drift, ok := boomMapper.EstimateCurrent(); ok {
+		navigation["current_set_deg"] = set
+		navigation["current_drift_kts"] = drift
 	}
-	if optimalAngle > 85 {
-		optimalAngle = 85
+
+	// Null unless both water speed and SOG are fresh
+	var speedLog *integration.SpeedLogCheck
+	if check, ok := boomMapper.CheckSpeedLog(); ok {
+		speedLog = &check
 	}
 
-	return optimalAngle
-}
+	// Null until the window holds wind samples
+	var windStats *integration.WindStats
+	if stats, ok := boomMapper.WindStats(); ok {
+		windStats = &stats
+	}
 
-func (vs *VisualizationServer) getAlertLevel(deviation float64) string {
-	if deviation < 5 {
-		return "optimal"
-	} else if deviation < 15 {
-		return "good"
-	} else if deviation < 30 {
-		return "suboptimal"
+	// Null until the window holds enough attitude samples
+	var seaState *integration.SeaState
+	if state, ok := boomMapper.SeaState(); ok {
+		seaState = &state
 	}
-	return "poor"
-}
 
-// HTTP Handlers
-func (vs *VisualizationServer) handleViewer(w http.ResponseWriter, r *http.Request) {
-	html := vs.generateHTML()
-	w.Header().Set("Content-Type", "text/html")
-	w.Write([]byte(html))
+	w.Header().Set("Content-Type", "application/json")
+	encodeJSON(w, map[string]interface{}{
+		"boomsense": data,
+		"apparent_wind": map[string]float64{
+			"speed":        aws,
+			"angle":        math.Abs(awaSigned),
+			"angle_signed": awaSigned, // negative to port
+		},
+		"true_wind": map[string]float64{
+			"speed": tws,
+			"angle": twa,
+		},
+		"heel_angle": boomMapper.GetHeelAngle(),
+		"navigation": navigation,
+		"wind_stats": windStats,
+		"sea_state":  seaState,
+		"speed_log":  speedLog,
+	})
 }
 
-func (vs *VisualizationServer) handleSceneData(w http.ResponseWriter, r *http.Request) {
-	data := vs.GenerateSceneData()
-	w.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(w).Encode(data)
+// historyMaxBuckets bounds /api/nmea/history responses
+const historyMaxBuckets = 5000
+
+// historyBucket is one aggregated interval; the statistics are null when no
+// samples fell in it so charts can draw a gap
+type historyBucket struct {
+	Time  int64    `json:"t"` // bucket start, unix ms
+	Count int      `json:"count"`
+	Min   *float64 `json:"min"`
+	Mean  *float64 `json:"mean"`
+	Max   *float64 `json:"max"`
 }
 
-func (vs *VisualizationServer) handleBoatList(w http.ResponseWriter, r *http.Request) {
-	searchQuery := strings.ToLower(r.URL.Query().Get("search"))
-	designer := strings.ToLower(r.URL.Query().Get("designer"))
-	builder := strings.ToLower(r.URL.Query().Get("builder"))
+// handleNMEAHistory aggregates one numeric field of one PGN from the ring
+// buffer into min/mean/max per bucket, e.g.
+// /api/nmea/history?pgn=130306&field=wind_speed_kts&bucket=10s&span=1h
+func handleNMEAHistory(w http.ResponseWriter, r *http.Request) {
+	if nmeaCollector == nil {
+		http.Error(w, "NMEA collector not running", http.StatusServiceUnavailable)
+		return
+	}
 
-	boats := make([]map[string]interface{}, 0)
-	designerSet := make(map[string]bool)
-	builderSet := make(map[string]bool)
+	q := r.URL.Query()
+	pgn, err := strconv.Atoi(q.Get("pgn"))
+	if err != nil {
+		http.Error(w, "pgn is required", http.StatusBadRequest)
+		return
+	}
+	field := q.Get("field")
+	if field == "" {
+		http.Error(w, "field is required", http.StatusBadRequest)
+		return
+	}
 
-	for _, boat := range vs.boats {
-		if boat.Metadata.Designer != "" {
-			designerSet[boat.Metadata.Designer] = true
+	bucket, span := 10*time.Second, time.Hour
+	if v := q.Get("bucket"); v != "" {
+		if bucket, err = time.ParseDuration(v); err != nil || bucket <= 0 {
+			http.Error(w, "invalid bucket", http.StatusBadRequest)
+			return
 		}
-		if boat.Metadata.Builder != "" {
-			builderSet[boat.Metadata.Builder] = true
+	}
+	if v := q.Get("span"); v != "" {
+		if span, err = time.ParseDuration(v); err != nil || span <= 0 {
+			http.Error(w, "invalid span", http.StatusBadRequest)
+			return
 		}
+	}
+	n := int((span + bucket - 1) / bucket)
+	if n > historyMaxBuckets {
+		http.Error(w, fmt.Sprintf("span/bucket gives more than %d buckets", historyMaxBuckets), http.StatusBadRequest)
+		return
+	}
 
-		if searchQuery != "" {
-			if !strings.Contains(strings.ToLower(boat.Name), searchQuery) &&
-				!strings.Contains(strings.ToLower(boat.Class), searchQuery) {
-				continue
-			}
-		}
+	end := time.Now()
+	start := end.Add(-time.Duration(n) * bucket)
 
-		if designer != "" && strings.ToLower(boat.Metadata.Designer) != designer {
+	sums := make([]float64, n)
+	buckets := make([]historyBucket, n)
+	for i := range buckets {
+		buckets[i].Time = start.Add(time.Duration(i) * bucket).UnixMilli()
+	}
+
+	for _, msg := range nmeaCollector.Buffer().GetByPGNTimeRange(pgn, start, end) {
+		value, ok := numericField(msg.Fields[field])
+		if !ok {
 			continue
 		}
-
-		if builder != "" && strings.ToLower(boat.Metadata.Builder) != builder {
+		i := int(msg.Timestamp.Sub(start) / bucket)
+		if i < 0 || i >= n {
 			continue
 		}
-
-		boats = append(boats, map[string]interface{}{
-			"name":     boat.Name,
-			"class":    boat.Class,
-			"designer": boat.Metadata.Designer,
-			"builder":  boat.Metadata.Builder,
-			"length":   boat.Dimensions.LengthOverall,
-		})
-	}
-
-	designers := make([]string, 0, len(designerSet))
-	for d := range designerSet {
-		if d != "" {
-			designers = append(designers, d)
+		b := &buckets[i]
+		if b.Count == 0 {
+			b.Min, b.Max = new(float64), new(float64)
+			*b.Min, *b.Max = value, value
 		}
+		*b.Min = math.Min(*b.Min, value)
+		*b.Max = math.Max(*b.Max, value)
+		sums[i] += value
+		b.Count++
 	}
 
-	builders := make([]string, 0, len(builderSet))
-	for b := range builderSet {
-		if b != "" {
-			builders = append(builders, b)
+	for i := range buckets {
+		if buckets[i].Count > 0 {
+			mean := sums[i] / float64(buckets[i].Count)
+			buckets[i].Mean = &mean
 		}
 	}
 
 	w.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(w).Encode(map[string]interface{}{
-		"boats":     boats,
-		"designers": designers,
-		"builders":  builders,
+	encodeJSON(w, map[string]interface{}{
+		"pgn":            pgn,
+		"field":          field,
+		"bucket_seconds": bucket.Seconds(),
+		"start":          start.UnixMilli(),
+		"end":            end.UnixMilli(),
+		"buckets":        buckets,
 	})
 }
 
-func (vs *VisualizationServer) handleSelectBoat(w http.ResponseWriter, r *http.Request) {
-	boatName := r.URL.Query().Get("name")
-	if err := vs.SelectBoat(boatName); err != nil {
-		http.Error(w, err.Error(), http.StatusNotFound)
-		return
+// numericField accepts the numeric types decoders store in Fields; strings
+// and non-finite values are not chartable
+func numericField(val interface{}) (float64, bool) {
+	var f float64
+	switch v := val.(type) {
+	case float64:
+		f = v
+	case float32:
+		f = float64(v)
+	case int:
+		f = float64(v)
+	case int64:
+		f = float64(v)
+	case uint8:
+		f = float64(v)
+	case uint16:
+		f = float64(v)
+	case uint32:
+		f = float64(v)
+	default:
+		return 0, false
 	}
-	w.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(w).Encode(map[string]string{"status": "ok", "selected": boatName})
+	if math.IsNaN(f) || math.IsInf(f, 0) {
+		return 0, false
+	}
+	return f, true
 }
 
-func (vs *VisualizationServer) handleUpdateBoomSense(w http.ResponseWriter, r *http.Request) {
-	var data BoomSenseData
-	if err := json.NewDecoder(r.Body).Decode(&data); err != nil {
-		http.Error(w, err.Error(), http.StatusBadRequest)
-		return
-	}
-	vs.UpdateBoomSense(data)
-	w.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(w).Encode(map[string]string{"status": "ok"})
+// Stream rate limits for ?hz=. Fractional rates are allowed so a phone can
+// poll slower than once a second.
+const (
+	streamMinHz = 0.5
+	streamMaxHz = 20
+)
+
+// streamFieldGroups maps the ?fields= groups to the keys they send. The
+// timestamp is always included.
+var streamFieldGroups = map[string][]string{
+	"wind":  {"wind_speed", "wind_angle", "wind_side", "wind_angle_deg_corrected"},
+	"heel":  {"heel_angle"},
+	"speed": {"boat_speed", "boat_speed_source", "heading"},
+	"boom":  {"boom_angle", "roll_rate", "pitch_rate", "yaw_rate", "event_type"},
+	"loads": {"mainsheet_load", "vang_load"},
 }
 
-// NEW: NMEA API Handlers
-func handleNMEAStatus(w http.ResponseWriter, r *http.Request) {
-	if nmeaCollector == nil {
-		http.Error(w, "NMEA collector not running", http.StatusServiceUnavailable)
-		return
+// parseStreamOptions reads ?hz=N (clamped to streamMinHz..streamMaxHz,
+// default 1) and ?fields=wind,heel,... (default: the full BoomSenseData)
+func parseStreamOptions(r *http.Request) (time.Duration, []string, error) {
+	interval := time.Second
+	if v := r.URL.Query().Get("hz"); v != "" {
+		hz, err := strconv.ParseFloat(v, 64)
+		if err != nil || math.IsNaN(hz) {
+			return 0, nil, fmt.Errorf("invalid hz %q", v)
+		}
+		hz = math.Max(streamMinHz, math.Min(streamMaxHz, hz))
+		interval = time.Duration(float64(time.Second) / hz)
 	}
 
-	stats := nmeaCollector.Stats().GetSnapshot()
-	bufferStats := nmeaCollector.Buffer().GetStats()
-
-	w.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(w).Encode(map[string]interface{}{
-		"collector": stats,
-		"buffer":    bufferStats,
-		"connected": nmeaCollector.IsConnected(),
-	})
+	var groups []string
+	if v := r.URL.Query().Get("fields"); v != "" {
+		for _, g := range strings.Split(v, ",") {
+			g = strings.TrimSpace(g)
+			if _, ok := streamFieldGroups[g]; !ok {
+				return 0, nil, fmt.Errorf("unknown field group %q", g)
+			}
+			groups = append(groups, g)
+		}
+	}
+	return interval, groups, nil
 }
 
-func handleNMEALatest(w http.ResponseWriter, r *http.Request) {
-	if boomMapper == nil {
-		http.Error(w, "BoomSense mapper not available", http.StatusServiceUnavailable)
-		return
+// streamPayload returns the full data, or only the requested field groups
+func streamPayload(data integration.BoomSenseData, groups []string) interface{} {
+	if len(groups) == 0 {
+		return data
 	}
 
-	data := boomMapper.GetCurrentData()
-	aws, awa := boomMapper.CalculateApparentWind()
+	all := map[string]interface{}{
+		"boom_angle":               data.BoomAngle,
+		"roll_rate":                data.RollRate,
+		"pitch_rate":               data.PitchRate,
+		"yaw_rate":                 data.YawRate,
+		"heading":                  data.Heading,
+		"mainsheet_load":           data.MainsheetLoad,
+		"vang_load":                data.VangLoad,
+		"event_type":               data.EventType,
+		"wind_speed":               data.WindSpeed,
+		"wind_angle":               data.WindAngle,
+		"wind_side":                data.WindSide,
+		"wind_angle_deg_corrected": data.WindAngleCorrected,
+		"boat_speed":               data.BoatSpeed,
+		"boat_speed_source":        data.BoatSpeedSource,
+	}
 
-	w.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(w).Encode(map[string]interface{}{
-		"boomsense": data,
-		"apparent_wind": map[string]float64{
-			"speed": aws,
-			"angle": awa,
-		},
-		"heel_angle": boomMapper.GetHeelAngle(),
-	})
+	out := map[string]interface{}{"timestamp": data.Timestamp}
+	for _, g := range groups {
+		for _, key := range streamFieldGroups[g] {
+			if key == "heel_angle" {
+				out[key] = boomMapper.GetHeelAngle()
+				continue
+			}
+			out[key] = all[key]
+		}
+	}
+	return out
 }
 
 func handleNMEAStream(w http.ResponseWriter, r *http.Request) {
+	interval, groups, err := parseStreamOptions(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
 	w.Header().Set("Content-Type", "text/event-stream")
 	w.Header().Set("Cache-Control", "no-cache")
 	w.Header().Set("Connection", "keep-alive")
-	w.Header().Set("Access-Control-Allow-Origin", "*")
 
-	ticker := time.NewTicker(1 * time.Second)
+	ticker := time.NewTicker(interval)
 	defer ticker.Stop()
 
+	events, unsubscribe := boomEvents.Subscribe()
+	defer unsubscribe()
+	alarms, unsubscribeAlarms := alarmEvents.Subscribe()
+	defer unsubscribeAlarms()
+
 	for {
 		select {
 		case <-ticker.C:
 			if boomMapper != nil {
 				data := boomMapper.GetCurrentData()
-				jsonData, _ := json.Marshal(data)
+				jsonData, _ := marshalJSON(streamPayload(data, groups))
 				fmt.Fprintf(w, "data: %s\n\n", jsonData)
 				if flusher, ok := w.(http.Flusher); ok {
 					flusher.Flush()
 				}
 			}
+		case evt := <-events:
+			// Named event type so clients can listen separately from the
+			// periodic data messages
+			jsonData, _ := marshalJSON(evt)
+			fmt.Fprintf(w, "event: boom_event\ndata: %s\n\n", jsonData)
+			if flusher, ok := w.(http.Flusher); ok {
+				flusher.Flush()
+			}
+		case alarm := <-alarms:
+			jsonData, _ := marshalJSON(alarm)
+			fmt.Fprintf(w, "event: alarm\ndata: %s\n\n", jsonData)
+			if flusher, ok := w.(http.Flusher); ok {
+				flusher.Flush()
+			}
 		case <-r.Context().Done():
 			return
 		}
@@ -769,7 +2178,7 @@ func (vs *VisualizationServer) generateHTML() string {
                 </div>
                 <div>
                     <label class="filter-label">Wind Angle (°)</label>
-                    <input type="number" id="wind-angle" class="wind-input" value="45" min="0" max="180" step="1">
+                    <input type="number" id="wind-angle" class="wind-input" value="45" min="0" max="360" step="1">
                 </div>
             </div>
             
@@ -818,7 +2227,7 @@ func (vs *VisualizationServer) generateHTML() string {
                 <div class="metric-label">Actual Speed</div>
                 <div class="metric-value"><span id="actual-speed">0.0</span><span class="metric-unit">kts</span></div>
             </div>
-            <div class="metric">
+            <div class="metric" id="speed-metric">
                 <div class="metric-label">Speed Efficiency</div>
                 <div class="metric-value"><span id="speed-efficiency">0</span><span class="metric-unit">%</span></div>
             </div>
@@ -826,6 +2235,10 @@ func (vs *VisualizationServer) generateHTML() string {
                 <div class="metric-label">Wind Conditions</div>
                 <div class="metric-value" style="font-size: 16px;"><span id="wind-display">12kts @ 45°</span></div>
             </div>
+            <div class="metric">
+                <div class="metric-label">VMG</div>
+                <div class="metric-value" style="font-size: 16px;"><span id="vmg-display">--</span></div>
+            </div>
         </div>
     </div>
 
@@ -835,6 +2248,7 @@ func (vs *VisualizationServer) generateHTML() string {
         let designers = [];
         let builders = [];
         let isUpdating = false;
+        let windSide = '';  // side of the live wind angle, from the stream
         let selectedBoatName = null;
 
         function init() {
@@ -854,8 +2268,17 @@ func (vs *VisualizationServer) generateHTML() string {
             connectNMEAStream();
         }
 
+        // API token, when the server requires one, is taken from the page URL
+        // (/?token=...) and passed on as a query parameter
+        const apiToken = new URLSearchParams(window.location.search).get('token');
+
+        function withToken(url) {
+            if (!apiToken) return url;
+            return url + (url.includes('?') ? '&' : '?') + 'token=' + encodeURIComponent(apiToken);
+        }
+
         function connectNMEAStream() {
-            const stream = new EventSource('/api/nmea/stream');
+            const stream = new EventSource(withToken('/api/nmea/stream'));
             
             stream.onopen = () => {
                 console.log('[NMEA] Live data connected');
@@ -874,6 +2297,7 @@ func (vs *VisualizationServer) generateHTML() string {
                 }
                 if (data.wind_angle > 0) {
                     document.getElementById('wind-angle').value = data.wind_angle.toFixed(0);
+                    windSide = data.wind_side || '';
                 }
                 
                 // Auto-fill boat speed from live data
@@ -885,6 +2309,33 @@ func (vs *VisualizationServer) generateHTML() string {
                 updateWindConditions();
             };
             
+            stream.addEventListener('boom_event', (event) => {
+                const evt = JSON.parse(event.data);
+                const banner = document.getElementById('nmea-status');
+                let text = evt.type.replace('_', ' ').toUpperCase();
+                if (evt.score > 0) {
+                    text += ' — score ' + evt.score.toFixed(0);
+                }
+                banner.textContent = text;
+                banner.classList.add('active');
+                setTimeout(() => {
+                    banner.classList.remove('active');
+                    banner.textContent = 'NMEA Live Data Connected';
+                }, 4000);
+            });
+
+            stream.addEventListener('alarm', (event) => {
+                const alarm = JSON.parse(event.data);
+                const banner = document.getElementById('nmea-status');
+                banner.textContent = '⚠ ' + alarm.message;
+                banner.classList.toggle('active', alarm.state === 'raised');
+                if (alarm.state === 'cleared') {
+                    setTimeout(() => {
+                        banner.textContent = 'NMEA Live Data Connected';
+                    }, 4000);
+                }
+            });
+            
             stream.onerror = () => {
                 console.log('[NMEA] Connection lost, retrying in 5s...');
                 setTimeout(connectNMEAStream, 5000);
@@ -972,14 +2423,14 @@ func (vs *VisualizationServer) generateHTML() string {
 
         function selectBoat(boatName) {
             selectedBoatName = boatName;
-            fetch('/api/select?name=' + encodeURIComponent(boatName))
+            fetch(withToken('/api/select?name=' + encodeURIComponent(boatName)))
                 .then(r => r.json())
                 .then(() => loadSceneData())
                 .catch(err => console.error('Error:', err));
         }
 
         function loadSceneData() {
-            fetch('/api/scene')
+            fetch(withToken('/api/scene'))
                 .then(r => r.json())
                 .then(data => {
                     sceneData = data;
@@ -1092,11 +2543,38 @@ func (vs *VisualizationServer) generateHTML() string {
                 legendY += 20;
             });
 
+            // Draw optimal VMG angle rays
+            if (data.vmg) {
+                [data.vmg.beat, data.vmg.run].forEach(target => {
+                    if (!target) return;
+                    const radius = (target.boatSpeed / maxSpeed) * maxRadius;
+                    const rad = (target.angle - 90) * Math.PI / 180;
+
+                    ctx.strokeStyle = '#fbbf24';
+                    ctx.lineWidth = 2;
+                    ctx.setLineDash([6, 4]);
+                    ctx.beginPath();
+                    ctx.moveTo(centerX, centerY);
+                    ctx.lineTo(centerX + Math.cos(rad) * radius, centerY + Math.sin(rad) * radius);
+                    ctx.stroke();
+                    ctx.setLineDash([]);
+
+                    ctx.fillStyle = '#fbbf24';
+                    ctx.font = '11px sans-serif';
+                    ctx.fillText(target.angle.toFixed(0) + '° VMG ' + target.vmg.toFixed(1),
+                        centerX + Math.cos(rad) * radius + 6,
+                        centerY + Math.sin(rad) * radius
+                    );
+                });
+            }
+
             // Draw current condition marker
             if (data.boomSense && data.boomSense.windAngle && data.boomSense.windSpeed) {
                 const targetSpeed = data.performance.targetSpeed;
                 const radius = (targetSpeed / maxSpeed) * maxRadius;
-                const rad = (data.boomSense.windAngle - 90) * Math.PI / 180;
+                // Port wind is mirrored to the left of the symmetric polar
+                const twa = data.boomSense.windSide === 'port' ? -data.boomSense.windAngle : data.boomSense.windAngle;
+                const rad = (twa - 90) * Math.PI / 180;
                 
                 ctx.fillStyle = '#10b981';
                 ctx.beginPath();
@@ -1150,7 +2628,7 @@ func (vs *VisualizationServer) generateHTML() string {
             const windAngle = parseFloat(document.getElementById('wind-angle').value);
             const boatSpeed = parseFloat(document.getElementById('boat-speed').value);
 
-            fetch('/api/boomsense', {
+            fetch(withToken('/api/boomsense'), {
                 method: 'POST',
                 headers: { 'Content-Type': 'application/json' },
                 body: JSON.stringify({
@@ -1159,10 +2637,11 @@ func (vs *VisualizationServer) generateHTML() string {
                     timestamp: Date.now(),
                     wind_speed: windSpeed,
                     wind_angle: windAngle,
+                    wind_side: windAngle > 180 ? '' : windSide,
                     boat_speed: boatSpeed
                 })
             }).then(() => {
-                return fetch('/api/scene');
+                return fetch(withToken('/api/scene'));
             }).then(r => r.json())
             .then(data => {
                 updateTelemetry(data);
@@ -1199,8 +2678,21 @@ func (vs *VisualizationServer) generateHTML() string {
             document.getElementById('telem-optimal').textContent = perf.optimalBoomAngle.toFixed(1);
             document.getElementById('target-speed').textContent = perf.targetSpeed.toFixed(2);
             document.getElementById('actual-speed').textContent = bs.boatSpeed.toFixed(2);
-            document.getElementById('speed-efficiency').textContent = perf.speedEfficiency.toFixed(1);
-            document.getElementById('wind-display').textContent = perf.windSpeed.toFixed(1) + 'kts @ ' + perf.windAngle.toFixed(0) + '°';
+            // polar_ratio is unclamped, so sailing above the polar shows as >100
+            const speedPct = perf.polar_ratio !== undefined ? perf.polar_ratio * 100 : perf.speedEfficiency;
+            document.getElementById('speed-efficiency').textContent = speedPct.toFixed(1);
+            document.getElementById('speed-metric').className = 'metric alert-' + perf.speedLevel;
+            document.getElementById('wind-display').textContent = perf.windSpeed.toFixed(1) + 'kts @ ' + perf.windAngle.toFixed(0) + '°' + (perf.windSide ? ' ' + perf.windSide : '');
+
+            let vmgText = '--';
+            if (perf.vmgCurrent !== undefined) {
+                vmgText = perf.vmgCurrent.toFixed(2) + ' / ' + perf.vmgOptimal.toFixed(2) + 'kts';
+                if (perf.optimalSide !== 'on') {
+                    const turn = perf.angleToOptimal < 0 ? 'head up ' : 'bear away ';
+                    vmgText += ' · ' + turn + Math.abs(perf.angleToOptimal).toFixed(0) + '°';
+                }
+            }
+            document.getElementById('vmg-display').textContent = vmgText;
 
             const badge = document.getElementById('alert-badge');
             const metric = document.getElementById('trim-metric');
@@ -1216,28 +2708,116 @@ func (vs *VisualizationServer) generateHTML() string {
 }
 
 func main() {
-	dbPath := "orc_boat_db.json"
-	if len(os.Args) > 1 {
-		dbPath = os.Args[1]
+	if len(os.Args) > 1 && os.Args[1] == "decode" {
+		os.Exit(runDecodeCommand(os.Args[2:]))
+	}
+
+	configPath := flag.String("config", "", "JSON config file (default $ODYSAIL_CONFIG or "+DefaultAppConfigPath+")")
+	port := flag.Int("port", 0, "HTTP port (overrides http_addr)")
+	dbPath := flag.String("db", "", "boat database path")
+	mqttBroker := flag.String("mqtt-broker", "", "MQTT broker host")
+	mqttTopic := flag.String("mqtt-topic", "", "MQTT topics to subscribe to (comma-separated)")
+	apiToken := flag.String("api-token", "", "bearer token required by control and live-data endpoints")
+	flag.Parse()
+
+	// Flags win over the config file and environment
+	cfg, err := LoadAppConfig(*configPath, func(c *AppConfig) {
+		// Positional db path kept for compatibility
+		if flag.NArg() > 0 {
+			c.DBPath = flag.Arg(0)
+		}
+		if *dbPath != "" {
+			c.DBPath = *dbPath
+		}
+		if *port != 0 {
+			c.HTTPAddr = fmt.Sprintf(":%d", *port)
+		}
+		if *mqttBroker != "" {
+			c.NMEA.MQTTBroker = *mqttBroker
+		}
+		if *mqttTopic != "" {
+			c.NMEA.MQTTTopic = nmea.ParseTopicList(*mqttTopic)
+		}
+		if *apiToken != "" {
+			c.APIToken = *apiToken
+		}
+	})
+	if err != nil {
+		log.Fatalf("Failed to load config: %v", err)
 	}
 
-	server, err := NewVisualizationServer(dbPath)
+	server, err := NewVisualizationServer(cfg)
 	if err != nil {
 		log.Fatalf("Failed to initialize server: %v", err)
 	}
+	stopPolarStreak := server.startPolarStreak(time.Second)
+	defer stopPolarStreak()
 
 	// Initialize NMEA collector
 	log.Printf("[NMEA] Initializing collector...")
-	nmeaConfig := nmea.DefaultConfig()
-	buffer := storage.NewRingBuffer(nmeaConfig.BufferSize)
+	nmeaConfig := cfg.NMEA
+	buffer := storage.NewRingBuffer(nmeaConfig.BufferCapacity())
+	if nmeaConfig.BufferDuration > 0 {
+		log.Printf("[NMEA] Buffer sized for %s at %g msg/s: %d messages",
+			nmeaConfig.BufferDuration, nmeaConfig.BufferRateHz, buffer.Capacity())
+	}
+
+	// Restore the previous session's history; saved again on shutdown, after
+	// the collector has stopped and drained (defers run in reverse order)
+	if path := nmeaConfig.BufferSnapshotPath; path != "" {
+		if n, err := buffer.LoadSnapshot(path); err == nil {
+			log.Printf("[NMEA] Restored %d buffered messages from %s", n, path)
+		} else if !os.IsNotExist(err) {
+			log.Printf("[WARN] Failed to restore buffer snapshot: %v", err)
+		}
+		defer func() {
+			if err := buffer.SaveSnapshot(path); err != nil {
+				log.Printf("[WARN] Failed to save buffer snapshot: %v", err)
+			} else {
+				log.Printf("[NMEA] Saved buffer snapshot to %s", path)
+			}
+		}()
+	}
 
-	var csvWriter *storage.CSVWriter
-	if nmeaConfig.EnableCSV {
-		csvWriter = storage.NewCSVWriter(
+	// Left as a nil interface when disabled so the collector skips CSV output
+	var csvWriter nmea.CSVWriterInterface
+	if nmeaConfig.EnableCSV && nmeaConfig.OutputFormat == nmea.OutputFormatJSONL {
+		writer := storage.NewJSONLWriter(
+			nmeaConfig.CSVFramesPath,
+			nmeaConfig.JSONLDecodedPath,
+			nmeaConfig.CSVStatsPath,
+		)
+		writer.MaxSizeBytes = nmeaConfig.CSVMaxSizeBytes
+		writer.RotateDaily = nmeaConfig.CSVRotateDaily
+		csvWriter = writer
+	} else if nmeaConfig.EnableCSV {
+		writer := storage.NewCSVWriter(
 			nmeaConfig.CSVFramesPath,
 			nmeaConfig.CSVDecodedPath,
 			nmeaConfig.CSVStatsPath,
 		)
+		writer.MaxSizeBytes = nmeaConfig.CSVMaxSizeBytes
+		writer.RotateDaily = nmeaConfig.CSVRotateDaily
+		writer.Precision = nmeaConfig.CSVPrecision
+		writer.PrecisionByMeasurement = nmeaConfig.CSVPrecisionByMeasurement
+		writer.SkipComposite = nmeaConfig.CSVSkipComposite
+		writer.Fields = nmeaConfig.CSVFields
+		csvWriter = writer
+	}
+
+	if nmeaConfig.InfluxURL != "" {
+		influxWriter = storage.NewInfluxWriter(storage.InfluxConfig{
+			URL:    nmeaConfig.InfluxURL,
+			Org:    nmeaConfig.InfluxOrg,
+			Bucket: nmeaConfig.InfluxBucket,
+			Token:  nmeaConfig.InfluxToken,
+		})
+		if csvWriter == nil {
+			csvWriter = influxWriter
+		} else {
+			csvWriter = nmea.MultiWriter(csvWriter, influxWriter)
+		}
+		log.Printf("[NMEA] Writing decoded messages to InfluxDB %s (bucket %s)", nmeaConfig.InfluxURL, nmeaConfig.InfluxBucket)
 	}
 
 	nmeaCollector = nmea.NewCollector(nmeaConfig, buffer, csvWriter)
@@ -1252,30 +2832,151 @@ func main() {
 
 	// Initialize BoomSense mapper
 	boomMapper = integration.NewBoomSenseMapper(buffer)
+	boomMapper.HeelCorrection = cfg.WindHeelCorrection
+	boomMapper.LeewayK = cfg.LeewayK
+	boomMapper.WindStatsWindow = cfg.WindStatsWindow
+	boomMapper.MaxDataAge = cfg.BoatSpeedMaxAge
+	boomMapper.LogSuspectFactor = cfg.LogSuspectFactor
+	boomMapper.SensorHeading = cfg.SensorHeadingFallback
+	boomMapper.SeaStateWindow = cfg.SeaStateWindow
+	boomMapper.SeaStateSmoothing = cfg.SeaStateSmoothing
+	boomMapper.SeaModerateRMSDeg = cfg.SeaModerateRMSDeg
+	boomMapper.SeaRoughRMSDeg = cfg.SeaRoughRMSDeg
+	if path := cfg.WindCorrectionPath; path != "" {
+		table, err := integration.LoadWindCorrectionTable(path)
+		if err != nil {
+			log.Fatalf("Failed to load wind correction: %v", err)
+		}
+		boomMapper.WindCorrection = table
+		log.Printf("[Wind] Applying upwash correction from %s (%d TWA x %d TWS)", path, len(table.TWA), len(table.TWS))
+	}
+
+	stopAlarms := startAlarmMonitor(buffer)
+	defer stopAlarms()
+
+	// Initialize in-process BoomSense sensor
+	if cfg.SensorEnabled {
+		boomSensor = boomsense_sensor.NewSensor(cfg.Sensor)
+		if err := boomSensor.Start(); err != nil {
+			log.Printf("[WARN] BoomSense sensor failed to start: %v", err)
+			boomSensor = nil
+		} else {
+			defer boomSensor.Stop()
+			boomMapper.SetBoomSource(boomSensor)
+			boomSensor.AddOutputListener(integration.NewBoomBridge(buffer).Push)
+			nmeaCollector.SetSensorSink(boomSensor)
+			// Detach before the sensor stops (defers run in reverse order)
+			defer nmeaCollector.SetSensorSink(nil)
+
+			sensor := boomSensor
+			sensor.AddEventListener(func(evt boomsense_sensor.Event) {
+				boomEvents.Publish(newStreamEvent(evt))
+			})
+
+			stopSeaState := startSeaStateMonitor(sensor, map[string]float64{
+				integration.SeaModerate: cfg.SeaModerateGyroScale,
+				integration.SeaRough:    cfg.SeaRoughGyroScale,
+			})
+			defer stopSeaState()
+		}
+	}
+
+	// Setup HTTP routes. With an API token configured, endpoints that change
+	// state or expose live data require it; the viewer and boat database
+	// stay open.
+	auth := func(h http.HandlerFunc) http.HandlerFunc { return requireToken(cfg.APIToken, h) }
+
+	// Cross-origin frontends (e.g. a dev server on another port) must be
+	// listed in cors_origins; the WebSocket upgrade follows the same list
+	cors := newCORSPolicy(cfg.CORSOrigins)
+	wsUpgrader.CheckOrigin = cors.checkOrigin
 
-	// Setup HTTP routes
 	http.HandleFunc("/", server.handleViewer)
-	http.HandleFunc("/api/scene", server.handleSceneData)
+	http.HandleFunc("/api/scene", auth(server.handleSceneData))
 	http.HandleFunc("/api/boats", server.handleBoatList)
-	http.HandleFunc("/api/select", server.handleSelectBoat)
-	http.HandleFunc("/api/boomsense", server.handleUpdateBoomSense)
+	http.HandleFunc("/api/select", auth(server.handleSelectBoat))
+	http.HandleFunc("/api/reload", auth(server.handleReload))
+	http.HandleFunc("/api/compare", server.handleCompare)
+	http.HandleFunc("/api/boomsense", auth(server.handleUpdateBoomSense))
+	http.HandleFunc("/api/performance/scale", auth(server.handlePerformanceScale))
+	http.HandleFunc("/api/polar", auth(server.handlePolarUpload))
+	http.HandleFunc("/api/polar/heatmap", server.handlePolarHeatmap)
+	http.HandleFunc("/api/polar/bestvmg", server.handlePolarBestVMG)
+	http.HandleFunc("/api/boomsense/calibrate", auth(handleBoomCalibration))
+	http.HandleFunc("/api/boomsense/history", auth(handleBoomEventHistory))
+	http.HandleFunc("/api/boomsense/imu", auth(handleBoomIMU))
+	http.HandleFunc("/api/detector/config", auth(handleDetectorConfig))
 
 	// NMEA API endpoints
-	http.HandleFunc("/api/nmea/status", handleNMEAStatus)
-	http.HandleFunc("/api/nmea/latest", handleNMEALatest)
-	http.HandleFunc("/api/nmea/stream", handleNMEAStream)
+	http.HandleFunc("/api/nmea/status", auth(handleNMEAStatus))
+	http.HandleFunc("/api/nmea/latest", auth(handleNMEALatest))
+	http.HandleFunc("/api/nmea/pgns", auth(handleNMEAPGNs))
+	http.HandleFunc("/api/nmea/devices", auth(handleNMEADevices))
+	http.HandleFunc("/api/nmea/stream", auth(handleNMEAStream))
+	http.HandleFunc("/api/nmea/history", auth(handleNMEAHistory))
+	http.HandleFunc("/api/track", auth(handleTrack))
+	http.HandleFunc("/api/environment", auth(handleEnvironment))
+	http.HandleFunc("/api/alarms", auth(handleAlarms))
+	http.HandleFunc("/api/nmea/ws", auth(handleNMEAWebSocket))
+
+	// Session recording for offline replay
+	recordDir := cfg.NMEA.RecordDir
+	http.HandleFunc("/api/record/start", auth(func(w http.ResponseWriter, r *http.Request) {
+		handleRecordStart(w, r, recordDir)
+	}))
+	http.HandleFunc("/api/record/stop", auth(handleRecordStop))
+	http.HandleFunc("/api/record/status", auth(handleRecordStatus))
+
+	if cfg.MetricsEnabled {
+		http.HandleFunc("/metrics", auth(handleMetrics))
+	}
+
+	// Readiness for healthchecks, deliberately unauthenticated
+	http.HandleFunc("/healthz", handleHealthz(server, cfg.HealthRequireMQTT))
 
-	port := ":8080"
+	addr := cfg.HTTPAddr
 	fmt.Printf("🚢 OdySail Polar Analysis Server\n")
 	fmt.Printf("📡 BoomSense Integration Active\n")
-	fmt.Printf("🌐 Server running at http://localhost%s\n", port)
+	fmt.Printf("🌐 Server running at http://localhost%s\n", addr)
 	fmt.Printf("📊 Loaded %d boats from database\n", len(server.boats))
 	if nmeaCollector != nil && nmeaCollector.IsConnected() {
 		fmt.Printf("✅ NMEA2000 collector connected\n")
 	}
 	fmt.Println()
 
-	if err := http.ListenAndServe(port, nil); err != nil {
-		log.Fatalf("Server failed: %v", err)
+	// Request contexts derive from baseCtx so streaming handlers (SSE and
+	// WebSocket) return when shutdown begins
+	baseCtx, cancelStreams := context.WithCancel(context.Background())
+	httpServer := &http.Server{
+		Addr:        addr,
+		Handler:     corsHandler(cors, gzipHandler(http.DefaultServeMux)),
+		BaseContext: func(net.Listener) context.Context { return baseCtx },
+	}
+
+	serverErr := make(chan error, 1)
+	go func() {
+		serverErr <- httpServer.ListenAndServe()
+	}()
+
+	stop := make(chan os.Signal, 1)
+	signal.Notify(stop, syscall.SIGINT, syscall.SIGTERM)
+
+	select {
+	case sig := <-stop:
+		log.Printf("Received %s, shutting down...", sig)
+	case err := <-serverErr:
+		// Return rather than exit so the deferred collector/sensor stops run
+		log.Printf("Server failed: %v", err)
+		cancelStreams()
+		return
+	}
+
+	cancelStreams()
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if err := httpServer.Shutdown(ctx); err != nil {
+		log.Printf("[WARN] HTTP shutdown incomplete: %v", err)
 	}
+
+	// Deferred stops now flush CSV files and disconnect MQTT
 }
\ No newline at end of file
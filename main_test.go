@@ -0,0 +1,241 @@
+package main
+
+import (
+	"math"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"odysail-boat-viz/integration"
+	"odysail-boat-viz/storage"
+)
+
+func TestPolarStreak(t *testing.T) {
+	ps := NewPolarStreak(95, 5*time.Second)
+	t0 := time.Unix(1700000000, 0)
+	at := func(s int) time.Time { return t0.Add(time.Duration(s) * time.Second) }
+	check := func(step string, current, best float64) {
+		t.Helper()
+		snap := ps.Snapshot()
+		if snap["currentSeconds"] != current || snap["bestSeconds"] != best {
+			t.Errorf("%s: current %v best %v, want %v %v",
+				step, snap["currentSeconds"], snap["bestSeconds"], current, best)
+		}
+	}
+
+	for s := 0; s <= 10; s++ {
+		ps.Update(97, at(s))
+	}
+	check("10s above", 10, 10)
+
+	// A dip shorter than the grace period holds the streak, but the time
+	// below does not count
+	ps.Update(80, at(11))
+	ps.Update(97, at(13))
+	ps.Update(97, at(14))
+	check("after short dip", 11, 11)
+
+	// Below for longer than the grace period resets the streak, not the best
+	ps.Update(80, at(15))
+	ps.Update(80, at(21))
+	check("after long dip", 0, 11)
+
+	ps.Update(96, at(22))
+	ps.Update(96, at(25))
+	check("new streak", 3, 11)
+
+	// The boat stops while above target: stopped samples count as below,
+	// and the stop is not added when it gets going again
+	for s := 26; s <= 40; s++ {
+		ps.Update(0, at(s))
+	}
+	check("stopped", 0, 11)
+	ps.Update(97, at(41))
+	ps.Update(97, at(43))
+	check("resumed", 2, 11)
+
+	// No samples for longer than MaxGap is not time above target, and past
+	// the grace period it ends the streak
+	ps.Update(97, at(60))
+	check("after a gap", 0, 11)
+	ps.Update(97, at(61))
+	check("after a gap, sampling again", 1, 11)
+}
+
+func TestTrackPolarStreakStopped(t *testing.T) {
+	polar := Polar{WindSpeeds: []float64{10}, WindAngles: []float64{60, 120}, BoatSpeeds: [][]float64{{6, 6}}}
+	vs := &VisualizationServer{
+		boats:       []Boat{{Name: "a", Polar: polar}},
+		polarStreak: NewPolarStreak(95, 2*time.Second),
+		perfScale:   NewPerformanceScale(),
+	}
+	if err := vs.SelectBoat("a", ""); err != nil {
+		t.Fatal(err)
+	}
+
+	vs.UpdateBoomSense(BoomSenseData{WindSpeed: 10, WindAngle: 90, BoatSpeed: 6})
+	now := time.Now()
+	vs.trackPolarStreak(now.Add(time.Second))
+	if snap := vs.polarStreak.Snapshot(); snap["active"] != true || snap["currentSeconds"].(float64) <= 0 {
+		t.Fatalf("at target speed: %v", snap)
+	}
+
+	// Stopped: below target however long ago the streak started
+	vs.UpdateBoomSense(BoomSenseData{WindSpeed: 10, WindAngle: 90, BoatSpeed: 0})
+	for s := 2; s <= 5; s++ {
+		vs.trackPolarStreak(now.Add(time.Duration(s) * time.Second))
+	}
+	if snap := vs.polarStreak.Snapshot(); snap["active"] != false || snap["currentSeconds"] != 0.0 {
+		t.Errorf("stopped: %v", snap)
+	}
+
+	// Data that stopped arriving counts as below too
+	vs.UpdateBoomSense(BoomSenseData{WindSpeed: 10, WindAngle: 90, BoatSpeed: 6})
+	vs.trackPolarStreak(now.Add(time.Minute))
+	if snap := vs.polarStreak.Snapshot(); snap["active"] != false {
+		t.Errorf("stale data: %v", snap)
+	}
+}
+
+func TestPerformanceScale(t *testing.T) {
+	ps := NewPerformanceScale()
+	absolute := []struct {
+		eff  float64
+		want string
+	}{
+		{99, "optimal"}, {95, "optimal"}, {90, "good"}, {75, "suboptimal"}, {60, "poor"},
+	}
+	for _, tt := range absolute {
+		if got := ps.Level(tt.eff); got != tt.want {
+			t.Errorf("absolute Level(%v) = %s, want %s", tt.eff, got, tt.want)
+		}
+	}
+
+	if err := ps.Configure("relative", 75, 50, 25); err != nil {
+		t.Fatal(err)
+	}
+	// With nothing observed, relative mode falls back to the raw thresholds
+	if got := ps.Level(60); got != "good" {
+		t.Errorf("relative Level(60) before any sample = %s, want good", got)
+	}
+
+	// Session range 90-100: cut-offs at 97.5, 95 and 92.5
+	ps.Observe(90)
+	ps.Observe(100)
+	relative := []struct {
+		eff  float64
+		want string
+	}{
+		{98, "optimal"}, {96, "good"}, {93, "suboptimal"}, {91, "poor"},
+	}
+	for _, tt := range relative {
+		if got := ps.Level(tt.eff); got != tt.want {
+			t.Errorf("relative Level(%v) = %s, want %s", tt.eff, got, tt.want)
+		}
+	}
+	snap := ps.Snapshot()
+	if snap["mode"] != "relative" || snap["optimal"] != 97.5 || snap["suboptimal"] != 92.5 {
+		t.Errorf("snapshot = %v", snap)
+	}
+
+	if err := ps.Configure("relative", 50, 75, 25); err == nil {
+		t.Error("Configure accepted thresholds out of order")
+	}
+	if err := ps.Configure("stretch", 75, 50, 25); err == nil {
+		t.Error("Configure accepted an unknown mode")
+	}
+}
+
+// TestConcurrentSceneAccess drives the buffer, mapper and server from
+// several goroutines at once, as MQTT, the stream loop and HTTP handlers do.
+// It checks nothing itself; run it with -race.
+func TestConcurrentSceneAccess(t *testing.T) {
+	polar := Polar{
+		WindSpeeds: []float64{6, 10},
+		WindAngles: []float64{50, 90},
+		BoatSpeeds: [][]float64{{5, 6}, {6, 7}},
+	}
+	vs := &VisualizationServer{
+		boats:       []Boat{{Name: "a", Polar: polar}, {Name: "b", Polar: polar}},
+		polarStreak: NewPolarStreak(95, time.Second),
+		perfScale:   NewPerformanceScale(),
+	}
+	if err := vs.SelectBoat("a", ""); err != nil {
+		t.Fatal(err)
+	}
+	buffer := storage.NewRingBuffer(100)
+	mapper := integration.NewBoomSenseMapper(buffer)
+
+	const rounds = 200
+	var wg sync.WaitGroup
+	run := func(fn func(i int)) {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := 0; i < rounds; i++ {
+				fn(i)
+			}
+		}()
+	}
+
+	run(func(i int) {
+		now := time.Now()
+		buffer.Push(storage.DecodedMessage{Timestamp: now, PGN: 130306, Fields: map[string]interface{}{
+			"wind_speed_kts": float64(8 + i%6), "wind_angle_deg": float64(40 + i%90), "wind_reference": uint8(2),
+		}})
+		buffer.Push(storage.DecodedMessage{Timestamp: now, PGN: 128259, Fields: map[string]interface{}{
+			"water_speed_kts": float64(5 + i%3),
+		}})
+	})
+	for g := 0; g < 2; g++ {
+		run(func(int) {
+			data := mapper.GetCurrentData()
+			vs.UpdateBoomSense(BoomSenseData{WindSpeed: data.WindSpeed, WindAngle: data.WindAngle, BoatSpeed: data.BoatSpeed})
+		})
+		run(func(int) { mapper.CalculateTrueWind() })
+		run(func(i int) { vs.SelectBoat([]string{"a", "b"}[i%2], "") })
+		run(func(int) { vs.handleSceneData(httptest.NewRecorder(), httptest.NewRequest("GET", "/api/scene", nil)) })
+	}
+	wg.Wait()
+}
+
+func TestPolarTargetSpeed(t *testing.T) {
+	p := Polar{
+		WindSpeeds: []float64{6, 10, 14},
+		WindAngles: []float64{60, 90, 120},
+		BoatSpeeds: [][]float64{{4, 5, 6}, {6, 7, 8}, {7, 8, 9}},
+	}
+	tests := []struct {
+		name   string
+		ws, wa float64
+		want   float64
+	}{
+		{"grid point", 10, 90, 7},
+		{"cell midpoint", 8, 75, 5.5},
+		{"quarter point", 11, 97.5, 7.5},
+		{"along wind speed", 12, 90, 7.5},
+		{"along angle", 6, 105, 5.5},
+		{"clamped low", 2, 30, 4},
+		{"clamped high", 20, 200, 9},
+		{"clamped angle only", 12, 130, 8.5},
+	}
+	for _, tt := range tests {
+		if got := p.TargetSpeed(tt.ws, tt.wa); math.Abs(got-tt.want) > 1e-9 {
+			t.Errorf("%s: TargetSpeed(%v, %v) = %v, want %v", tt.name, tt.ws, tt.wa, got, tt.want)
+		}
+	}
+
+	// Degenerate polars interpolate along their one axis
+	row := Polar{WindSpeeds: []float64{10}, WindAngles: []float64{60, 120}, BoatSpeeds: [][]float64{{4, 6}}}
+	if got := row.TargetSpeed(20, 90); got != 5 {
+		t.Errorf("single row: TargetSpeed = %v, want 5", got)
+	}
+	col := Polar{WindSpeeds: []float64{6, 14}, WindAngles: []float64{90}, BoatSpeeds: [][]float64{{5}, {7}}}
+	if got := col.TargetSpeed(10, 45); got != 6 {
+		t.Errorf("single column: TargetSpeed = %v, want 6", got)
+	}
+	if got := (Polar{}).TargetSpeed(10, 90); got != 0 {
+		t.Errorf("empty polar: TargetSpeed = %v, want 0", got)
+	}
+}
@@ -1,122 +1,459 @@
-package storage
-
-import (
-	"sync"
-	"time"
-)
-
-// DecodedMessage local definition (already in csv_writer.go, shared in package)
-
-type RingBuffer struct {
-	data     []DecodedMessage
-	head     int
-	size     int
-	capacity int
-	mu       sync.RWMutex
-
-	latestByPGN map[int]*DecodedMessage
-	indexMu     sync.RWMutex
-}
-
-func NewRingBuffer(capacity int) *RingBuffer {
-	return &RingBuffer{
-		data:        make([]DecodedMessage, capacity),
-		capacity:    capacity,
-		latestByPGN: make(map[int]*DecodedMessage),
-	}
-}
-
-func (rb *RingBuffer) Push(msg DecodedMessage) {
-	rb.mu.Lock()
-	defer rb.mu.Unlock()
-
-	rb.data[rb.head] = msg
-	rb.head = (rb.head + 1) % rb.capacity
-
-	if rb.size < rb.capacity {
-		rb.size++
-	}
-
-	rb.indexMu.Lock()
-	rb.latestByPGN[msg.PGN] = &msg
-	rb.indexMu.Unlock()
-}
-
-func (rb *RingBuffer) GetRecent(n int) []DecodedMessage {
-	rb.mu.RLock()
-	defer rb.mu.RUnlock()
-
-	if n > rb.size {
-		n = rb.size
-	}
-
-	result := make([]DecodedMessage, n)
-	for i := 0; i < n; i++ {
-		idx := (rb.head - 1 - i + rb.capacity) % rb.capacity
-		result[i] = rb.data[idx]
-	}
-
-	return result
-}
-
-func (rb *RingBuffer) GetByTimeRange(start, end time.Time) []DecodedMessage {
-	rb.mu.RLock()
-	defer rb.mu.RUnlock()
-
-	result := make([]DecodedMessage, 0)
-
-	for i := 0; i < rb.size; i++ {
-		msg := rb.data[i]
-		if (msg.Timestamp.Equal(start) || msg.Timestamp.After(start)) &&
-			(msg.Timestamp.Equal(end) || msg.Timestamp.Before(end)) {
-			result = append(result, msg)
-		}
-	}
-
-	return result
-}
-
-func (rb *RingBuffer) GetLatestByPGN(pgn int) *DecodedMessage {
-	rb.indexMu.RLock()
-	defer rb.indexMu.RUnlock()
-
-	if msg, ok := rb.latestByPGN[pgn]; ok {
-		return msg
-	}
-	return nil
-}
-
-func (rb *RingBuffer) Size() int {
-	rb.mu.RLock()
-	defer rb.mu.RUnlock()
-	return rb.size
-}
-
-func (rb *RingBuffer) Capacity() int {
-	return rb.capacity
-}
-
-func (rb *RingBuffer) GetStats() map[string]interface{} {
-	rb.mu.RLock()
-	defer rb.mu.RUnlock()
-
-	oldest := time.Time{}
-	newest := time.Time{}
-
-	if rb.size > 0 {
-		oldestIdx := (rb.head - rb.size + rb.capacity) % rb.capacity
-		oldest = rb.data[oldestIdx].Timestamp
-
-		newestIdx := (rb.head - 1 + rb.capacity) % rb.capacity
-		newest = rb.data[newestIdx].Timestamp
-	}
-
-	return map[string]interface{}{
-		"size":              rb.size,
-		"capacity":          rb.capacity,
-		"utilization":       float64(rb.size) / float64(rb.capacity) * 100.0,
-		"oldest_timestamp":  oldest,
-		"newest_timestamp":  newest,
-		"time_span_seconds": newest.Sub(oldest).Seconds(),
-	}
-}
\ No newline at end of file
+package storage
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"math"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+	"unsafe"
+)
+
+// DecodedMessage local definition (already in csv_writer.go, shared in package)
+
+type RingBuffer struct {
+	data     []DecodedMessage
+	head     int
+	size     int
+	capacity int
+	mu       sync.RWMutex
+
+	latestByPGN      map[int]*DecodedMessage
+	latestBySource   map[pgnSourceKey]*DecodedMessage
+	latestByInstance map[pgnInstanceKey]*DecodedMessage
+	outOfOrder       int // pushes older than the latest for their PGN
+	futureStamped    int // pushes too far ahead of the clock to index
+	indexMu          sync.RWMutex
+}
+
+type pgnSourceKey struct {
+	pgn    int
+	source uint8
+}
+
+type pgnInstanceKey struct {
+	pgn      int
+	instance int
+}
+
+func NewRingBuffer(capacity int) *RingBuffer {
+	return &RingBuffer{
+		data:             make([]DecodedMessage, capacity),
+		capacity:         capacity,
+		latestByPGN:      make(map[int]*DecodedMessage),
+		latestBySource:   make(map[pgnSourceKey]*DecodedMessage),
+		latestByInstance: make(map[pgnInstanceKey]*DecodedMessage),
+	}
+}
+
+func (rb *RingBuffer) Push(msg DecodedMessage) {
+	rb.mu.Lock()
+	defer rb.mu.Unlock()
+
+	rb.data[rb.head] = msg
+	rb.head = (rb.head + 1) % rb.capacity
+
+	if rb.size < rb.capacity {
+		rb.size++
+	}
+
+	rb.indexMu.Lock()
+	switch {
+	case inFuture(msg.Timestamp, time.Now()):
+		rb.futureStamped++
+	case !rb.indexLocked(&msg):
+		rb.outOfOrder++
+	}
+	rb.indexMu.Unlock()
+}
+
+// MaxFutureSkew is how far ahead of the local clock a message timestamp may
+// be and still count as the latest; beyond it the sender's clock is wrong
+// and the message would hold "latest" until real time caught up
+const MaxFutureSkew = time.Minute
+
+// inFuture reports whether t is more than MaxFutureSkew ahead of now
+func inFuture(t, now time.Time) bool {
+	return t.After(now.Add(MaxFutureSkew))
+}
+
+// indexLocked makes msg the latest for its PGN, source and instance, except
+// where the held message is newer: a replayed, redelivered or reordered
+// frame is kept in the history but must not roll "latest" back in time. A
+// msg stamped beyond MaxFutureSkew is not indexed, and a held message that
+// is gives way to any arrival. Reports false when msg is not indexed for
+// its PGN. Callers hold rb.indexMu.
+func (rb *RingBuffer) indexLocked(msg *DecodedMessage) bool {
+	now := time.Now()
+	if inFuture(msg.Timestamp, now) {
+		return false
+	}
+	newer := func(cur *DecodedMessage, ok bool) bool {
+		return !ok || !msg.Timestamp.Before(cur.Timestamp) || inFuture(cur.Timestamp, now)
+	}
+
+	cur, ok := rb.latestByPGN[msg.PGN]
+	inOrder := newer(cur, ok)
+	if inOrder {
+		rb.latestByPGN[msg.PGN] = msg
+	}
+
+	sourceKey := pgnSourceKey{msg.PGN, msg.Source}
+	if cur, ok := rb.latestBySource[sourceKey]; newer(cur, ok) {
+		rb.latestBySource[sourceKey] = msg
+	}
+	if instance, ok := messageInstance(*msg); ok {
+		instanceKey := pgnInstanceKey{msg.PGN, instance}
+		if cur, ok := rb.latestByInstance[instanceKey]; newer(cur, ok) {
+			rb.latestByInstance[instanceKey] = msg
+		}
+	}
+	return inOrder
+}
+
+// instanceFields names the field holding the device instance for each
+// decoded PGN that has one
+var instanceFields = map[int]string{
+	127245: "rudder_instance",
+	127489: "engine_instance",
+	127493: "transmission_instance",
+	127505: "fluid_instance",
+	127508: "battery_instance",
+	130312: "temperature_instance",
+	130313: "humidity_instance",
+}
+
+// messageInstance returns the device instance carried by a message: the
+// field named in instanceFields, or for other PGNs the first "*_instance"
+// field in name order, so the choice is the same on every run
+func messageInstance(msg DecodedMessage) (int, bool) {
+	name, ok := instanceFields[msg.PGN]
+	if !ok {
+		var names []string
+		for field := range msg.Fields {
+			if strings.HasSuffix(field, "_instance") {
+				names = append(names, field)
+			}
+		}
+		if len(names) == 0 {
+			return 0, false
+		}
+		sort.Strings(names)
+		name = names[0]
+	}
+
+	switch v := msg.Fields[name].(type) {
+	case uint8:
+		return int(v), true
+	case int:
+		return v, true
+	case float64:
+		return int(v), true
+	}
+	return 0, false
+}
+
+func (rb *RingBuffer) GetRecent(n int) []DecodedMessage {
+	rb.mu.RLock()
+	defer rb.mu.RUnlock()
+
+	if n > rb.size {
+		n = rb.size
+	}
+
+	result := make([]DecodedMessage, n)
+	for i := 0; i < n; i++ {
+		idx := (rb.head - 1 - i + rb.capacity) % rb.capacity
+		result[i] = rb.data[idx]
+	}
+
+	return result
+}
+
+// GetByTimeRange returns messages with start <= Timestamp <= end, oldest first
+func (rb *RingBuffer) GetByTimeRange(start, end time.Time) []DecodedMessage {
+	return rb.collectRange(start, end, func(DecodedMessage) bool { return true })
+}
+
+// GetByPGNTimeRange returns messages for one PGN with start <= Timestamp <= end,
+// oldest first
+func (rb *RingBuffer) GetByPGNTimeRange(pgn int, start, end time.Time) []DecodedMessage {
+	return rb.collectRange(start, end, func(msg DecodedMessage) bool { return msg.PGN == pgn })
+}
+
+// collectRange walks the ring in chronological order (oldest at head-size)
+func (rb *RingBuffer) collectRange(start, end time.Time, keep func(DecodedMessage) bool) []DecodedMessage {
+	rb.mu.RLock()
+	defer rb.mu.RUnlock()
+
+	result := make([]DecodedMessage, 0)
+
+	for i := rb.size; i > 0; i-- {
+		msg := rb.data[(rb.head-i+rb.capacity)%rb.capacity]
+		if msg.Timestamp.Before(start) || msg.Timestamp.After(end) || !keep(msg) {
+			continue
+		}
+		result = append(result, msg)
+	}
+
+	return result
+}
+
+func (rb *RingBuffer) GetLatestByPGN(pgn int) *DecodedMessage {
+	rb.indexMu.RLock()
+	defer rb.indexMu.RUnlock()
+
+	if msg, ok := rb.latestByPGN[pgn]; ok {
+		return msg
+	}
+	return nil
+}
+
+// GetLatestByPGNSource returns the most recent message for a PGN from a
+// specific source address
+func (rb *RingBuffer) GetLatestByPGNSource(pgn int, source uint8) *DecodedMessage {
+	rb.indexMu.RLock()
+	defer rb.indexMu.RUnlock()
+
+	if msg, ok := rb.latestBySource[pgnSourceKey{pgn, source}]; ok {
+		return msg
+	}
+	return nil
+}
+
+// GetLatestByPGNInstance returns the most recent message for a PGN with the
+// given instance field (e.g. battery bank 0 vs 1 on 127508)
+func (rb *RingBuffer) GetLatestByPGNInstance(pgn, instance int) *DecodedMessage {
+	rb.indexMu.RLock()
+	defer rb.indexMu.RUnlock()
+
+	if msg, ok := rb.latestByInstance[pgnInstanceKey{pgn, instance}]; ok {
+		return msg
+	}
+	return nil
+}
+
+func (rb *RingBuffer) Size() int {
+	rb.mu.RLock()
+	defer rb.mu.RUnlock()
+	return rb.size
+}
+
+func (rb *RingBuffer) Capacity() int {
+	return rb.capacity
+}
+
+func (rb *RingBuffer) GetStats() map[string]interface{} {
+	rb.mu.RLock()
+	defer rb.mu.RUnlock()
+
+	oldest := time.Time{}
+	newest := time.Time{}
+
+	if rb.size > 0 {
+		oldestIdx := (rb.head - rb.size + rb.capacity) % rb.capacity
+		oldest = rb.data[oldestIdx].Timestamp
+
+		newestIdx := (rb.head - 1 + rb.capacity) % rb.capacity
+		newest = rb.data[newestIdx].Timestamp
+	}
+
+	perMessage := rb.messageBytesLocked()
+
+	rb.indexMu.RLock()
+	outOfOrder := rb.outOfOrder
+	futureStamped := rb.futureStamped
+	rb.indexMu.RUnlock()
+
+	return map[string]interface{}{
+		"size":              rb.size,
+		"capacity":          rb.capacity,
+		"utilization":       float64(rb.size) / float64(rb.capacity) * 100.0,
+		"oldest_timestamp":  oldest,
+		"newest_timestamp":  newest,
+		"time_span_seconds": newest.Sub(oldest).Seconds(),
+		"out_of_order":      outOfOrder,
+		"future_stamped":    futureStamped,
+
+		// Approximate heap use now and once every slot is filled with
+		// messages like the recent ones
+		"bytes_per_message":   perMessage,
+		"bytes_estimate":      rb.capacity*messageSlotBytes + rb.size*perMessage,
+		"bytes_estimate_full": rb.capacity * (messageSlotBytes + perMessage),
+	}
+}
+
+// Memory estimate inputs. Every slot holds a DecodedMessage whether used or
+// not; a message's fields map costs a header plus, per entry, the key, an
+// interface value boxing a float or small int, and a share of its bucket.
+const (
+	messageSlotBytes     = int(unsafe.Sizeof(DecodedMessage{}))
+	fieldsMapHeaderBytes = 48
+	fieldsMapEntryBytes  = 16 + 16 + 8 + 8
+	memorySampleMessages = 256
+)
+
+// messageBytesLocked averages the heap a message references beyond its
+// slot over the most recent messages. Callers hold rb.mu.
+func (rb *RingBuffer) messageBytesLocked() int {
+	n := rb.size
+	if n > memorySampleMessages {
+		n = memorySampleMessages
+	}
+	if n == 0 {
+		return 0
+	}
+
+	total := 0
+	for i := 0; i < n; i++ {
+		msg := &rb.data[(rb.head-1-i+rb.capacity)%rb.capacity]
+		total += cap(msg.Raw)
+		if msg.Fields == nil {
+			continue
+		}
+		total += fieldsMapHeaderBytes
+		for k, v := range msg.Fields {
+			total += fieldsMapEntryBytes + len(k)
+			if s, ok := v.(string); ok {
+				total += len(s)
+			}
+		}
+	}
+	return total / n
+}
+
+// snapshotVersion identifies the on-disk snapshot layout
+const snapshotVersion = 1
+
+// bufferSnapshot is the on-disk form of a RingBuffer. Field values go
+// through JSON, so numeric fields are restored as float64.
+type bufferSnapshot struct {
+	Version  int              `json:"version"`
+	SavedAt  time.Time        `json:"saved_at"`
+	Messages []DecodedMessage `json:"messages"` // oldest first
+	Latest   []DecodedMessage `json:"latest"`   // latest-by-PGN index
+}
+
+// SaveSnapshot writes the buffered messages (oldest first) and the
+// latest-by-PGN index to path. The file is replaced atomically.
+func (rb *RingBuffer) SaveSnapshot(path string) error {
+	rb.mu.RLock()
+	snap := bufferSnapshot{
+		Version:  snapshotVersion,
+		SavedAt:  time.Now(),
+		Messages: make([]DecodedMessage, 0, rb.size),
+	}
+	for i := rb.size; i > 0; i-- {
+		idx := (rb.head - i + rb.capacity) % rb.capacity
+		snap.Messages = append(snap.Messages, snapshotMessage(rb.data[idx]))
+	}
+	rb.mu.RUnlock()
+
+	rb.indexMu.RLock()
+	for _, msg := range rb.latestByPGN {
+		snap.Latest = append(snap.Latest, snapshotMessage(*msg))
+	}
+	rb.indexMu.RUnlock()
+
+	data, err := json.Marshal(snap)
+	if err != nil {
+		return fmt.Errorf("failed to encode buffer snapshot: %w", err)
+	}
+
+	os.MkdirAll(filepath.Dir(path), 0755)
+	tmp := path + ".tmp"
+	if err := ioutil.WriteFile(tmp, data, 0644); err != nil {
+		return fmt.Errorf("failed to write buffer snapshot: %w", err)
+	}
+	if err := os.Rename(tmp, path); err != nil {
+		return fmt.Errorf("failed to write buffer snapshot: %w", err)
+	}
+	return nil
+}
+
+// snapshotMessage copies msg with the NaN and ±Inf field values that JSON
+// cannot carry (a MarkInvalid field, a NaN SNR) replaced by null, so one
+// such value does not fail the whole snapshot
+func snapshotMessage(msg DecodedMessage) DecodedMessage {
+	if msg.Fields != nil {
+		msg.Fields = finiteJSON(msg.Fields).(map[string]interface{})
+	}
+	return msg
+}
+
+// finiteJSON returns v with non-finite floats replaced by nil, copying the
+// maps and slices it walks rather than changing them
+func finiteJSON(v interface{}) interface{} {
+	switch x := v.(type) {
+	case float64:
+		if math.IsNaN(x) || math.IsInf(x, 0) {
+			return nil
+		}
+	case float32:
+		if math.IsNaN(float64(x)) || math.IsInf(float64(x), 0) {
+			return nil
+		}
+	case map[string]interface{}:
+		out := make(map[string]interface{}, len(x))
+		for k, e := range x {
+			out[k] = finiteJSON(e)
+		}
+		return out
+	case []interface{}:
+		out := make([]interface{}, len(x))
+		for i, e := range x {
+			out[i] = finiteJSON(e)
+		}
+		return out
+	case []float64:
+		out := make([]interface{}, len(x))
+		for i, e := range x {
+			out[i] = finiteJSON(e)
+		}
+		return out
+	case []map[string]interface{}:
+		out := make([]map[string]interface{}, len(x))
+		for i, e := range x {
+			out[i] = finiteJSON(e).(map[string]interface{})
+		}
+		return out
+	}
+	return v
+}
+
+// LoadSnapshot pushes the messages from a snapshot written by SaveSnapshot
+// into the buffer, then restores latest-by-PGN entries for PGNs whose
+// messages had already rotated out. Entries stamped beyond MaxFutureSkew
+// are not restored as latest. Returns the number of messages loaded.
+func (rb *RingBuffer) LoadSnapshot(path string) (int, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return 0, err
+	}
+
+	var snap bufferSnapshot
+	if err := json.Unmarshal(data, &snap); err != nil {
+		return 0, fmt.Errorf("failed to parse buffer snapshot: %w", err)
+	}
+	if snap.Version != snapshotVersion {
+		return 0, fmt.Errorf("unsupported buffer snapshot version %d", snap.Version)
+	}
+
+	for _, msg := range snap.Messages {
+		rb.Push(msg)
+	}
+
+	rb.indexMu.Lock()
+	for i := range snap.Latest {
+		msg := snap.Latest[i]
+		rb.indexLocked(&msg)
+	}
+	rb.indexMu.Unlock()
+
+	return len(snap.Messages), nil
+}
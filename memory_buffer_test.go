@@ -0,0 +1,224 @@
+package storage
+
+import (
+	"encoding/json"
+	"math"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestLatestIgnoresFutureTimestamps(t *testing.T) {
+	rb := NewRingBuffer(10)
+	now := time.Now()
+
+	rb.Push(DecodedMessage{Timestamp: now.Add(time.Hour), PGN: 127250, Source: 1, Fields: map[string]interface{}{"heading_deg": 10.0}})
+	if msg := rb.GetLatestByPGN(127250); msg != nil {
+		t.Errorf("message an hour ahead became latest: %+v", msg)
+	}
+	if rb.Size() != 1 {
+		t.Errorf("size = %d, want the future message kept in history", rb.Size())
+	}
+	if got := rb.GetStats()["future_stamped"]; got != 1 {
+		t.Errorf("future_stamped = %v, want 1", got)
+	}
+
+	// Within the skew tolerance a slightly fast clock is still accepted
+	rb.Push(DecodedMessage{Timestamp: now.Add(MaxFutureSkew / 2), PGN: 127250, Source: 1, Fields: map[string]interface{}{"heading_deg": 20.0}})
+	if msg := rb.GetLatestByPGN(127250); msg == nil || msg.Fields["heading_deg"] != 20.0 {
+		t.Errorf("latest = %+v, want the message within tolerance", msg)
+	}
+}
+
+func TestLatestReplacesHeldFuture(t *testing.T) {
+	rb := NewRingBuffer(10)
+	now := time.Now()
+
+	// Held from before the local clock was corrected
+	future := DecodedMessage{Timestamp: now.Add(time.Hour), PGN: 127250, Source: 1}
+	rb.latestByPGN[future.PGN] = &future
+	rb.latestBySource[pgnSourceKey{future.PGN, future.Source}] = &future
+
+	rb.Push(DecodedMessage{Timestamp: now, PGN: 127250, Source: 1, Fields: map[string]interface{}{"heading_deg": 30.0}})
+	if msg := rb.GetLatestByPGN(127250); msg == nil || msg.Fields["heading_deg"] != 30.0 {
+		t.Errorf("latest = %+v, want the new arrival over a future one", msg)
+	}
+	if msg := rb.GetLatestByPGNSource(127250, 1); msg == nil || msg.Fields["heading_deg"] != 30.0 {
+		t.Errorf("latest by source = %+v, want the new arrival", msg)
+	}
+	if got := rb.GetStats()["out_of_order"]; got != 0 {
+		t.Errorf("out_of_order = %v, want 0", got)
+	}
+}
+
+func TestLoadSnapshotSkipsFutureLatest(t *testing.T) {
+	now := time.Now()
+	snap := bufferSnapshot{
+		Version: snapshotVersion,
+		SavedAt: now,
+		Latest: []DecodedMessage{
+			{Timestamp: now.Add(-time.Minute), PGN: 128267, Fields: map[string]interface{}{"depth_m": 5.0}},
+			{Timestamp: now.Add(24 * time.Hour), PGN: 129025, Fields: map[string]interface{}{"latitude": 50.0}},
+		},
+	}
+	data, err := json.Marshal(snap)
+	if err != nil {
+		t.Fatal(err)
+	}
+	path := filepath.Join(t.TempDir(), "buffer.json")
+	if err := os.WriteFile(path, data, 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	rb := NewRingBuffer(10)
+	if _, err := rb.LoadSnapshot(path); err != nil {
+		t.Fatal(err)
+	}
+	if rb.GetLatestByPGN(128267) == nil {
+		t.Error("past latest not restored from snapshot")
+	}
+	if msg := rb.GetLatestByPGN(129025); msg != nil {
+		t.Errorf("future latest restored from snapshot: %+v", msg)
+	}
+}
+
+func TestSnapshotNonFiniteValues(t *testing.T) {
+	rb := NewRingBuffer(10)
+	now := time.Now()
+	rb.Push(DecodedMessage{Timestamp: now, PGN: 129540, Fields: map[string]interface{}{
+		"sat_1_snr_dbhz": math.NaN(),
+		"sat_2_snr_dbhz": 41.5,
+	}})
+	rb.Push(DecodedMessage{Timestamp: now, PGN: 129285, Fields: map[string]interface{}{
+		"waypoints": []map[string]interface{}{{"latitude": math.Inf(1), "name": "A"}},
+	}})
+
+	path := filepath.Join(t.TempDir(), "buffer.json")
+	if err := rb.SaveSnapshot(path); err != nil {
+		t.Fatalf("SaveSnapshot with NaN and Inf fields: %v", err)
+	}
+	if !math.IsNaN(rb.GetLatestByPGN(129540).Fields["sat_1_snr_dbhz"].(float64)) {
+		t.Error("SaveSnapshot changed the buffered message")
+	}
+
+	restored := NewRingBuffer(10)
+	if n, err := restored.LoadSnapshot(path); err != nil || n != 2 {
+		t.Fatalf("LoadSnapshot = %d, %v; want 2 messages", n, err)
+	}
+	fields := restored.GetLatestByPGN(129540).Fields
+	if v, ok := fields["sat_1_snr_dbhz"]; !ok || v != nil {
+		t.Errorf("NaN restored as %v, want null", v)
+	}
+	if fields["sat_2_snr_dbhz"] != 41.5 {
+		t.Errorf("finite value restored as %v, want 41.5", fields["sat_2_snr_dbhz"])
+	}
+	waypoints := restored.GetLatestByPGN(129285).Fields["waypoints"].([]interface{})
+	if wp := waypoints[0].(map[string]interface{}); wp["latitude"] != nil || wp["name"] != "A" {
+		t.Errorf("waypoint restored as %v, want null latitude", wp)
+	}
+}
+
+func TestLatestByInstance(t *testing.T) {
+	rb := NewRingBuffer(10)
+	now := time.Now()
+
+	// Two battery banks on one source, interleaved
+	for i, volts := range []float64{12.1, 13.2, 12.3, 13.4} {
+		rb.Push(DecodedMessage{
+			Timestamp: now.Add(time.Duration(i) * time.Millisecond),
+			PGN:       127508,
+			Source:    7,
+			Fields:    map[string]interface{}{"battery_instance": uint8(i % 2), "voltage": volts},
+		})
+	}
+
+	for instance, want := range []float64{12.3, 13.4} {
+		msg := rb.GetLatestByPGNInstance(127508, instance)
+		if msg == nil || msg.Fields["voltage"] != want {
+			t.Errorf("instance %d latest = %+v, want voltage %v", instance, msg, want)
+		}
+	}
+	if msg := rb.GetLatestByPGNSource(127508, 7); msg == nil || msg.Fields["voltage"] != 13.4 {
+		t.Errorf("source 7 latest = %+v, want voltage 13.4", msg)
+	}
+	if msg := rb.GetLatestByPGN(127508); msg == nil || msg.Fields["voltage"] != 13.4 {
+		t.Errorf("latest = %+v, want voltage 13.4", msg)
+	}
+}
+
+func TestMessageInstance(t *testing.T) {
+	tests := []struct {
+		name   string
+		msg    DecodedMessage
+		want   int
+		wantOK bool
+	}{
+		{"battery", DecodedMessage{PGN: 127508, Fields: map[string]interface{}{"battery_instance": uint8(1)}}, 1, true},
+		// The PGN's own field wins over any other "_instance" field
+		{"explicit key", DecodedMessage{PGN: 130312, Fields: map[string]interface{}{
+			"source_instance": uint8(9), "temperature_instance": uint8(2), "zone_instance": uint8(5),
+		}}, 2, true},
+		// Unknown PGNs use the first "_instance" field by name
+		{"sorted fallback", DecodedMessage{PGN: 65280, Fields: map[string]interface{}{
+			"zone_instance": 5, "bank_instance": 3, "pump_instance": 4,
+		}}, 3, true},
+		{"float instance", DecodedMessage{PGN: 127505, Fields: map[string]interface{}{"fluid_instance": 4.0}}, 4, true},
+		{"none", DecodedMessage{PGN: 127250, Fields: map[string]interface{}{"heading_deg": 90.0}}, 0, false},
+		{"missing explicit key", DecodedMessage{PGN: 127508, Fields: map[string]interface{}{"voltage": 12.0}}, 0, false},
+	}
+	for _, tt := range tests {
+		// Repeat to catch an answer that depends on map iteration order
+		for i := 0; i < 20; i++ {
+			got, ok := messageInstance(tt.msg)
+			if got != tt.want || ok != tt.wantOK {
+				t.Errorf("%s: messageInstance = %d, %v; want %d, %v", tt.name, got, ok, tt.want, tt.wantOK)
+				break
+			}
+		}
+	}
+}
+
+func TestTimeRangeAfterWrap(t *testing.T) {
+	rb := NewRingBuffer(4)
+	t0 := time.Unix(1700000000, 0)
+	at := func(s int) time.Time { return t0.Add(time.Duration(s) * time.Second) }
+
+	// Seven messages through a ring of four; PGNs alternate 127257/127250
+	for i := 0; i < 7; i++ {
+		rb.Push(DecodedMessage{Timestamp: at(i), PGN: []int{127257, 127250}[i%2]})
+	}
+
+	seconds := func(msgs []DecodedMessage) []int64 {
+		var out []int64
+		for _, msg := range msgs {
+			out = append(out, msg.Timestamp.Unix()-t0.Unix())
+		}
+		return out
+	}
+	tests := []struct {
+		name string
+		got  []DecodedMessage
+		want []int64
+	}{
+		{"everything held", rb.GetByTimeRange(at(0), at(100)), []int64{3, 4, 5, 6}},
+		{"inclusive bounds", rb.GetByTimeRange(at(4), at(5)), []int64{4, 5}},
+		{"evicted range", rb.GetByTimeRange(at(0), at(2)), nil},
+		{"one PGN", rb.GetByPGNTimeRange(127257, at(0), at(100)), []int64{4, 6}},
+		{"one PGN in range", rb.GetByPGNTimeRange(127250, at(4), at(6)), []int64{5}},
+		{"unknown PGN", rb.GetByPGNTimeRange(130306, at(0), at(100)), nil},
+	}
+	for _, tt := range tests {
+		got := seconds(tt.got)
+		if len(got) != len(tt.want) {
+			t.Errorf("%s: got seconds %v, want %v", tt.name, got, tt.want)
+			continue
+		}
+		for i := range got {
+			if got[i] != tt.want[i] {
+				t.Errorf("%s: got seconds %v, want %v", tt.name, got, tt.want)
+				break
+			}
+		}
+	}
+}
@@ -0,0 +1,142 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"net/http"
+	"sort"
+	"strconv"
+)
+
+// metricsRegistry accumulates samples in the Prometheus text exposition
+// format, writing each family's HELP and TYPE lines once
+type metricsRegistry struct {
+	buf  bytes.Buffer
+	seen map[string]bool
+}
+
+func newMetricsRegistry() *metricsRegistry {
+	return &metricsRegistry{seen: make(map[string]bool)}
+}
+
+func (m *metricsRegistry) counter(name, help string, value interface{}, labels ...string) {
+	m.sample(name, "counter", help, value, labels)
+}
+
+func (m *metricsRegistry) gauge(name, help string, value interface{}, labels ...string) {
+	m.sample(name, "gauge", help, value, labels)
+}
+
+// sample writes one line; labels are name/value pairs. Values that are not
+// numbers (missing snapshot keys) are skipped.
+func (m *metricsRegistry) sample(name, kind, help string, value interface{}, labels []string) {
+	v, ok := metricValue(value)
+	if !ok {
+		return
+	}
+
+	if !m.seen[name] {
+		m.seen[name] = true
+		fmt.Fprintf(&m.buf, "# HELP %s %s\n# TYPE %s %s\n", name, help, name, kind)
+	}
+
+	m.buf.WriteString(name)
+	if len(labels) > 0 {
+		m.buf.WriteByte('{')
+		for i := 0; i+1 < len(labels); i += 2 {
+			if i > 0 {
+				m.buf.WriteByte(',')
+			}
+			fmt.Fprintf(&m.buf, "%s=%s", labels[i], strconv.Quote(labels[i+1]))
+		}
+		m.buf.WriteByte('}')
+	}
+	m.buf.WriteByte(' ')
+	m.buf.WriteString(strconv.FormatFloat(v, 'g', -1, 64))
+	m.buf.WriteByte('\n')
+}
+
+// metricValue converts the numeric types found in the snapshot maps
+func metricValue(value interface{}) (float64, bool) {
+	switch v := value.(type) {
+	case float64:
+		return v, true
+	case int:
+		return float64(v), true
+	case int64:
+		return float64(v), true
+	case bool:
+		if v {
+			return 1, true
+		}
+		return 0, true
+	}
+	return 0, false
+}
+
+// handleMetrics exposes collector and buffer statistics for Prometheus
+func handleMetrics(w http.ResponseWriter, r *http.Request) {
+	m := newMetricsRegistry()
+
+	m.gauge("odysail_collector_up", "Whether the NMEA collector is running.", nmeaCollector != nil)
+	if nmeaCollector != nil {
+		writeCollectorMetrics(m)
+	}
+	if influxWriter != nil {
+		m.counter("odysail_influx_dropped_total", "Decoded messages the InfluxDB writer discarded.", influxWriter.Dropped())
+	}
+
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+	w.Write(m.buf.Bytes())
+}
+
+func writeCollectorMetrics(m *metricsRegistry) {
+	stats := nmeaCollector.Stats().GetSnapshot()
+	m.counter("odysail_messages_processed_total", "Frames handed to the decoder.", stats["messages_processed"])
+	m.counter("odysail_decode_successes_total", "Frames decoded successfully.", stats["decode_successes"])
+	m.counter("odysail_decode_failures_total", "Frames that failed to decode.", stats["decode_failures"])
+	m.counter("odysail_empty_frames_total", "Keep-alive frames dropped before decoding.", stats["empty_frames"])
+	m.counter("odysail_frames_dropped_total", "Raw frames lost to a full decode queue.", stats["frames_dropped"])
+	m.counter("odysail_decoded_dropped_total", "Decoded messages lost to a full storage queue.", stats["decoded_dropped"])
+	m.counter("odysail_preferential_drops_total", "Queued items dropped to keep room for critical PGNs.", stats["preferential_drops"])
+	m.gauge("odysail_uptime_seconds", "Seconds since the collector started.", stats["uptime_seconds"])
+
+	if measurements, ok := stats["measurements"].(map[string]interface{}); ok {
+		names := make([]string, 0, len(measurements))
+		for name := range measurements {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		for _, name := range names {
+			m.counter("odysail_measurement_messages_total", "Decoded messages per measurement.",
+				measurements[name], "measurement", name)
+		}
+	}
+
+	// One loop per family: the text format wants each family's samples
+	// together under its HELP and TYPE lines
+	coverage := nmeaCollector.PGNCoverage()
+	for _, info := range coverage {
+		m.counter("odysail_pgn_messages_total", "Messages received per PGN.", info.Count, "pgn", strconv.Itoa(info.PGN))
+	}
+	for _, info := range coverage {
+		m.gauge("odysail_pgn_age_seconds", "Seconds since a PGN was last received.", info.AgeSeconds, "pgn", strconv.Itoa(info.PGN))
+	}
+
+	buffer := nmeaCollector.Buffer().GetStats()
+	m.gauge("odysail_buffer_messages", "Messages held in the ring buffer.", buffer["size"])
+	m.gauge("odysail_buffer_capacity", "Ring buffer capacity.", buffer["capacity"])
+	m.gauge("odysail_buffer_estimated_bytes", "Approximate memory held by the ring buffer.", buffer["bytes_estimate"])
+	if util, ok := buffer["utilization"].(float64); ok {
+		m.gauge("odysail_buffer_utilization_ratio", "Fraction of the ring buffer in use.", util/100)
+	}
+
+	health := nmeaCollector.Health()
+	m.gauge("odysail_mqtt_connected", "Whether the frame source is connected.", health["connected"])
+	m.gauge("odysail_collector_healthy", "Whether the collector is connected, subscribed and receiving data.", health["healthy"])
+	m.gauge("odysail_idle_seconds", "Seconds since the last frame arrived.", health["idle_seconds"])
+
+	raw, decoded := nmeaCollector.QueueDepth()
+	m.gauge("odysail_queue_depth", "Items waiting in the collector queues.", raw, "queue", "raw")
+	m.gauge("odysail_queue_depth", "Items waiting in the collector queues.", decoded, "queue", "decoded")
+}
@@ -0,0 +1,83 @@
+package main
+
+import (
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"odysail-boat-viz/nmea"
+	"odysail-boat-viz/storage"
+)
+
+func TestMetricsRegistry(t *testing.T) {
+	m := newMetricsRegistry()
+	m.counter("a_total", "A.", int64(3), "k", "v\"x")
+	m.counter("a_total", "A.", 4, "k", "w")
+	m.gauge("b", "B.", true)
+	m.gauge("c", "C.", nil)
+
+	want := "# HELP a_total A.\n# TYPE a_total counter\n" +
+		"a_total{k=\"v\\\"x\"} 3\na_total{k=\"w\"} 4\n" +
+		"# HELP b B.\n# TYPE b gauge\nb 1\n"
+	if got := m.buf.String(); got != want {
+		t.Errorf("registry wrote %q, want %q", got, want)
+	}
+}
+
+// TestMetricsFamiliesContiguous checks that each family's samples follow
+// its own HELP and TYPE lines without another family in between
+func TestMetricsFamiliesContiguous(t *testing.T) {
+	saved := nmeaCollector
+	defer func() { nmeaCollector = saved }()
+
+	nmeaCollector = nmea.NewCollector(nmea.DefaultConfig(), storage.NewRingBuffer(10), nil)
+	for _, pgn := range []int{127257, 129026, 130306} {
+		nmeaCollector.Stats().RecordMessage(pgn, nmea.GetMeasurementType(pgn), true)
+	}
+
+	rec := httptest.NewRecorder()
+	handleMetrics(rec, httptest.NewRequest("GET", "/metrics", nil))
+
+	finished := map[string]bool{}
+	current := ""
+	for _, line := range strings.Split(strings.TrimSpace(rec.Body.String()), "\n") {
+		var family string
+		if strings.HasPrefix(line, "# ") {
+			family = strings.Fields(line)[2]
+		} else {
+			family = strings.FieldsFunc(line, func(r rune) bool { return r == '{' || r == ' ' })[0]
+		}
+		if family == current {
+			continue
+		}
+		if finished[family] {
+			t.Errorf("family %s resumes after %s:\n%s", family, current, rec.Body.String())
+			return
+		}
+		finished[current] = true
+		current = family
+	}
+
+	for _, family := range []string{"odysail_pgn_messages_total", "odysail_pgn_age_seconds"} {
+		if n := strings.Count(rec.Body.String(), "\n"+family+"{"); n != 3 {
+			t.Errorf("%d %s samples, want 3", n, family)
+		}
+	}
+}
+
+func TestMetricsInfluxDropped(t *testing.T) {
+	saved := influxWriter
+	defer func() { influxWriter = saved }()
+
+	influxWriter = storage.NewInfluxWriter(storage.InfluxConfig{URL: "http://127.0.0.1:1", Bucket: "b"})
+	defer influxWriter.Close()
+
+	rec := httptest.NewRecorder()
+	handleMetrics(rec, httptest.NewRequest("GET", "/metrics", nil))
+	if !strings.Contains(rec.Body.String(), "\nodysail_influx_dropped_total 0\n") {
+		t.Errorf("no influx drop counter in:\n%s", rec.Body.String())
+	}
+	if status := influxStatus(); status["enabled"] != true || status["dropped"] != int64(0) {
+		t.Errorf("influxStatus = %v, want enabled with 0 dropped", status)
+	}
+}
@@ -0,0 +1,42 @@
+package integration
+
+// LeewayVector is EstimateLeeway with a validity flag for display
+type LeewayVector struct {
+	AngleDeg float64 `json:"angle_deg"` // positive: slipping to starboard of heading
+	Valid    bool    `json:"valid"`     // heel and water speed within MaxDataAge
+}
+
+// CurrentVector is EstimateCurrent with a validity flag for display
+type CurrentVector struct {
+	SetDeg   float64 `json:"set_deg"` // degrees true the water flows towards
+	DriftKts float64 `json:"drift_kts"`
+	Valid    bool    `json:"valid"` // COG/SOG, heading and water speed within MaxDataAge
+}
+
+// Leeway returns the leeway estimate, or nil when it cannot be made. An
+// estimate from inputs older than MaxDataAge is returned with Valid false,
+// so a display can grey it out rather than drop it on one late frame.
+func (m *BoomSenseMapper) Leeway() *LeewayVector {
+	angle, ok := m.EstimateLeeway()
+	if !ok {
+		return nil
+	}
+	return &LeewayVector{
+		AngleDeg: angle,
+		Valid:    m.fresh(127257) != nil && m.fresh(128259) != nil,
+	}
+}
+
+// Current returns the set and drift estimate, or nil when it cannot be
+// made; Valid as for Leeway
+func (m *BoomSenseMapper) Current() *CurrentVector {
+	set, drift, ok := m.EstimateCurrent()
+	if !ok {
+		return nil
+	}
+	return &CurrentVector{
+		SetDeg:   set,
+		DriftKts: drift,
+		Valid:    m.fresh(129026) != nil && m.fresh(127250) != nil && m.fresh(128259) != nil,
+	}
+}
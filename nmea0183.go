@@ -0,0 +1,552 @@
+package nmea
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"math"
+	"net"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// NMEA0183Source is the source address given to messages parsed from NMEA
+// 0183. It is the J1939 null address, which no bus device can claim, so
+// 0183 data never masquerades as an N2K sender.
+const NMEA0183Source = 254
+
+// ErrUnsupportedSentence is returned for well-formed sentences ParseNMEA0183
+// has no mapping for
+var ErrUnsupportedSentence = errors.New("unsupported NMEA 0183 sentence")
+
+// knotsToMS converts knots to m/s
+const knotsToMS = 1852.0 / 3600.0
+
+// ParseNMEA0183 parses one NMEA 0183 sentence into the PGN and field names
+// the N2K decoder would produce for the same data, so it can share the ring
+// buffer, mapper and logs with bus traffic:
+//
+//	RMC → 129026 (COG/SOG, plus latitude/longitude)
+//	GGA → 129025 (position, plus fix quality, satellites, HDOP, altitude)
+//	MWV → 130306 (wind, R = apparent, T = true boat-referenced)
+//	VHW → 128259 (speed through water)
+//	DPT → 128267 (depth and transducer offset)
+//	HDG → 127250 (magnetic heading, deviation, variation)
+//	VDM/VDO → 129038 / 129039 (single-fragment AIS position reports 1-3, 18)
+//
+// A checksum, when present, must match. Empty fields are nil, the way the
+// decoder marks "not available"; the collector then drops them (unless
+// MarkInvalidFields is set) and adds unit companions as for N2K data.
+func ParseNMEA0183(sentence string) (DecodedMessage, error) {
+	sentence = strings.TrimSpace(sentence)
+	if len(sentence) < 7 || (sentence[0] != '$' && sentence[0] != '!') {
+		return DecodedMessage{}, fmt.Errorf("not an NMEA 0183 sentence: %q", sentence)
+	}
+
+	body := sentence[1:]
+	if i := strings.IndexByte(body, '*'); i >= 0 {
+		want, err := strconv.ParseUint(body[i+1:], 16, 8)
+		if err != nil {
+			return DecodedMessage{}, fmt.Errorf("bad checksum in %q", sentence)
+		}
+		body = body[:i]
+		if got := nmeaChecksum(body); got != uint8(want) {
+			return DecodedMessage{}, fmt.Errorf("checksum mismatch in %q: got %02X", sentence, got)
+		}
+	}
+
+	f := strings.Split(body, ",")
+	if len(f[0]) < 5 {
+		return DecodedMessage{}, fmt.Errorf("bad address field in %q", sentence)
+	}
+
+	var (
+		pgn    int
+		result map[string]interface{}
+		err    error
+	)
+	switch f[0][len(f[0])-3:] {
+	case "RMC":
+		pgn, result, err = parseRMC(f)
+	case "GGA":
+		pgn, result, err = parseGGA(f)
+	case "MWV":
+		pgn, result, err = parseMWV(f)
+	case "VHW":
+		pgn, result, err = parseVHW(f)
+	case "DPT":
+		pgn, result, err = parseDPT(f)
+	case "HDG":
+		pgn, result, err = parseHDG(f)
+	case "VDM", "VDO":
+		pgn, result, err = parseVDM(f)
+	default:
+		return DecodedMessage{}, ErrUnsupportedSentence
+	}
+	if err != nil {
+		return DecodedMessage{}, fmt.Errorf("%s: %w", f[0], err)
+	}
+
+	return DecodedMessage{
+		Timestamp:   time.Now(),
+		PGN:         pgn,
+		PGNName:     GetPGNName(pgn),
+		Source:      NMEA0183Source,
+		Measurement: GetMeasurementType(pgn),
+		Fields:      result,
+		Dest:        0xFF,
+	}, nil
+}
+
+// nmeaChecksum is the XOR of every character between the start delimiter
+// and the '*'
+func nmeaChecksum(body string) uint8 {
+	var sum uint8
+	for i := 0; i < len(body); i++ {
+		sum ^= body[i]
+	}
+	return sum
+}
+
+// need checks a sentence has at least n fields after the address
+func need(f []string, n int) error {
+	if len(f) < n+1 {
+		return fmt.Errorf("expected %d fields, got %d", n, len(f)-1)
+	}
+	return nil
+}
+
+// num parses an optional numeric field; empty fields are not available
+func num(s string) (float64, bool) {
+	if s == "" {
+		return 0, false
+	}
+	v, err := strconv.ParseFloat(s, 64)
+	if err != nil || math.IsNaN(v) || math.IsInf(v, 0) {
+		return 0, false
+	}
+	return v, true
+}
+
+// setNum stores field s under key, or marks key not available
+func setNum(result map[string]interface{}, key, s string, scale float64) (float64, bool) {
+	v, ok := num(s)
+	if !ok {
+		invalid(result, key)
+		return 0, false
+	}
+	result[key] = v * scale
+	return v * scale, true
+}
+
+// setAngle stores an angle in degrees as key_deg and key_rad
+func setAngle(result map[string]interface{}, key, s string) {
+	if v, ok := num(s); ok {
+		result[key+"_deg"] = v
+		result[key+"_rad"] = v * math.Pi / 180.0
+	} else {
+		invalid(result, key+"_deg", key+"_rad")
+	}
+}
+
+// parseCoord converts "ddmm.mmmm" or "dddmm.mmmm" plus a hemisphere to
+// signed decimal degrees
+func parseCoord(value, hemi string, limit float64) (float64, bool) {
+	v, ok := num(value)
+	if !ok {
+		return 0, false
+	}
+	deg := math.Floor(v / 100)
+	coord := deg + (v-deg*100)/60
+	switch hemi {
+	case "S", "W":
+		coord = -coord
+	case "N", "E":
+	default:
+		return 0, false
+	}
+	if math.Abs(coord) > limit {
+		return 0, false
+	}
+	return coord, true
+}
+
+// setPosition stores latitude and longitude, or marks both not available
+func setPosition(result map[string]interface{}, lat, ns, lon, ew string, valid bool) {
+	la, okLat := parseCoord(lat, ns, 90)
+	lo, okLon := parseCoord(lon, ew, 180)
+	if valid && okLat && okLon {
+		result["latitude"] = la
+		result["longitude"] = lo
+	} else {
+		invalid(result, "latitude", "longitude")
+	}
+}
+
+// parseRMC: $--RMC,time,status,lat,N/S,lon,E/W,sog,cog,date,magvar,E/W
+func parseRMC(f []string) (int, map[string]interface{}, error) {
+	if err := need(f, 9); err != nil {
+		return 0, nil, err
+	}
+
+	result := make(map[string]interface{})
+	fix := f[2] == "A"
+	setPosition(result, f[3], f[4], f[5], f[6], fix)
+
+	if !fix {
+		invalid(result, "sog_ms", "cog_deg", "cog_rad")
+		return 129026, result, nil
+	}
+	setNum(result, "sog_ms", f[7], knotsToMS)
+	setAngle(result, "cog", f[8])
+
+	if len(f) > 11 {
+		if v, ok := num(f[10]); ok {
+			if f[11] == "W" {
+				v = -v
+			}
+			result["variation_deg"] = v
+		}
+	}
+	return 129026, result, nil
+}
+
+// parseGGA: $--GGA,time,lat,N/S,lon,E/W,quality,sats,hdop,alt,M,...
+func parseGGA(f []string) (int, map[string]interface{}, error) {
+	if err := need(f, 9); err != nil {
+		return 0, nil, err
+	}
+
+	result := make(map[string]interface{})
+	quality, _ := num(f[6])
+	result["gnss_fix_quality"] = uint8(quality)
+	setPosition(result, f[2], f[3], f[4], f[5], quality > 0)
+
+	if v, ok := num(f[7]); ok {
+		result["satellites"] = uint8(v)
+	}
+	setNum(result, "hdop", f[8], 1)
+	setNum(result, "altitude_m", f[9], 1)
+	return 129025, result, nil
+}
+
+// parseMWV: $--MWV,angle,R/T,speed,unit,status
+func parseMWV(f []string) (int, map[string]interface{}, error) {
+	if err := need(f, 5); err != nil {
+		return 0, nil, err
+	}
+
+	var ref uint8
+	switch f[2] {
+	case "R":
+		ref = 2 // apparent
+	case "T":
+		ref = 3 // true, boat referenced
+	default:
+		return 0, nil, fmt.Errorf("unknown wind reference %q", f[2])
+	}
+
+	result := make(map[string]interface{})
+	result["wind_reference"] = ref
+	result["wind_reference_str"] = EnumString(WindReferenceNames, ref)
+
+	if f[5] != "A" {
+		invalid(result, "wind_speed_ms", "wind_angle_deg", "wind_angle_rad")
+		return 130306, result, nil
+	}
+
+	setAngle(result, "wind_angle", f[1])
+
+	scale := 0.0
+	switch f[4] {
+	case "N":
+		scale = knotsToMS
+	case "M":
+		scale = 1
+	case "K":
+		scale = 1 / 3.6
+	case "S":
+		scale = 0.44704
+	}
+	if scale == 0 {
+		invalid(result, "wind_speed_ms")
+	} else {
+		setNum(result, "wind_speed_ms", f[3], scale)
+	}
+	return 130306, result, nil
+}
+
+// parseVHW: $--VHW,hdgT,T,hdgM,M,knots,N,kmh,K
+func parseVHW(f []string) (int, map[string]interface{}, error) {
+	if err := need(f, 8); err != nil {
+		return 0, nil, err
+	}
+
+	result := make(map[string]interface{})
+	if v, ok := num(f[5]); ok {
+		result["water_speed_ms"] = v * knotsToMS
+	} else if v, ok := num(f[7]); ok {
+		result["water_speed_ms"] = v / 3.6
+	} else {
+		invalid(result, "water_speed_ms")
+	}
+	return 128259, result, nil
+}
+
+// parseDPT: $--DPT,depth,offset[,range]. The offset follows the same sign
+// convention as PGN 128267: positive to the waterline, negative to the keel.
+func parseDPT(f []string) (int, map[string]interface{}, error) {
+	if err := need(f, 1); err != nil {
+		return 0, nil, err
+	}
+
+	result := make(map[string]interface{})
+	if depth, ok := setNum(result, "depth_m", f[1], 1); ok {
+		result["depth_transducer_m"] = depth
+	} else {
+		invalid(result, "depth_transducer_m")
+	}
+
+	if len(f) > 2 {
+		if offset, ok := setNum(result, "transducer_offset_m", f[2], 1); ok {
+			applyDepthOffset(result, offset)
+		}
+	}
+	return 128267, result, nil
+}
+
+// parseHDG: $--HDG,heading,deviation,E/W,variation,E/W (magnetic)
+func parseHDG(f []string) (int, map[string]interface{}, error) {
+	if err := need(f, 5); err != nil {
+		return 0, nil, err
+	}
+
+	result := make(map[string]interface{})
+	result["heading_reference"] = uint8(1)
+	result["heading_reference_str"] = EnumString(HeadingReferenceNames, 1)
+	setAngle(result, "heading", f[1])
+
+	for _, v := range []struct{ key, value, dir string }{
+		{"deviation", f[2], f[3]},
+		{"variation", f[4], f[5]},
+	} {
+		value := v.value
+		if v.dir == "W" && value != "" {
+			value = "-" + value
+		}
+		setAngle(result, v.key, value)
+	}
+	return 127250, result, nil
+}
+
+// parseVDM: !AIVDM,fragments,fragment,seq,channel,payload,fill. Only
+// single-fragment position reports (types 1-3 and 18) are decoded; the
+// fill bits padding the last character are not part of the message.
+func parseVDM(f []string) (int, map[string]interface{}, error) {
+	if err := need(f, 6); err != nil {
+		return 0, nil, err
+	}
+	if f[1] != "1" {
+		return 0, nil, ErrUnsupportedSentence
+	}
+
+	bits, err := aisBits(f[5])
+	if err != nil {
+		return 0, nil, err
+	}
+	fill, _ := strconv.Atoi(f[6])
+	if fill < 0 || fill > 5 || fill > len(bits) {
+		return 0, nil, fmt.Errorf("bad AIS fill bits %q", f[6])
+	}
+	bits = bits[:len(bits)-fill]
+
+	msgType := bits.unsigned(0, 6)
+	result := make(map[string]interface{})
+	result["message_id"] = uint8(msgType)
+
+	var pgn, sogAt, lonAt, latAt, cogAt, hdgAt int
+	switch msgType {
+	case 1, 2, 3:
+		pgn, sogAt, lonAt, latAt, cogAt, hdgAt = 129038, 50, 61, 89, 116, 128
+		if len(bits) < 168 {
+			return 0, nil, errors.New("short AIS position report")
+		}
+		result["nav_status"] = uint8(bits.unsigned(38, 4))
+	case 18:
+		pgn, sogAt, lonAt, latAt, cogAt, hdgAt = 129039, 46, 57, 85, 112, 124
+		if len(bits) < 168 {
+			return 0, nil, errors.New("short AIS position report")
+		}
+	default:
+		return 0, nil, ErrUnsupportedSentence
+	}
+
+	result["mmsi"] = uint32(bits.unsigned(8, 30))
+
+	if sog := bits.unsigned(sogAt, 10); sog != 1023 {
+		result["sog_ms"] = float64(sog) * 0.1 * knotsToMS
+	} else {
+		invalid(result, "sog_ms")
+	}
+
+	lon := float64(bits.signed(lonAt, 28)) / 600000.0
+	lat := float64(bits.signed(latAt, 27)) / 600000.0
+	if math.Abs(lon) <= 180 && math.Abs(lat) <= 90 {
+		result["latitude"] = lat
+		result["longitude"] = lon
+	} else {
+		invalid(result, "latitude", "longitude")
+	}
+
+	if cog := bits.unsigned(cogAt, 12); cog < 3600 {
+		result["cog_deg"] = float64(cog) * 0.1
+		result["cog_rad"] = float64(cog) * 0.1 * math.Pi / 180.0
+	} else {
+		invalid(result, "cog_deg", "cog_rad")
+	}
+
+	if hdg := bits.unsigned(hdgAt, 9); hdg < 360 {
+		result["heading_deg"] = float64(hdg)
+		result["heading_rad"] = float64(hdg) * math.Pi / 180.0
+	} else {
+		invalid(result, "heading_deg", "heading_rad")
+	}
+
+	return pgn, result, nil
+}
+
+// aisBitField is a de-armoured AIS payload, one bit per byte
+type aisBitField []byte
+
+// aisBits undoes the AIS 6-bit ASCII armouring
+func aisBits(payload string) (aisBitField, error) {
+	bits := make(aisBitField, 0, len(payload)*6)
+	for i := 0; i < len(payload); i++ {
+		c := payload[i]
+		if c < 48 || c > 119 || (c > 87 && c < 96) {
+			return nil, fmt.Errorf("bad AIS payload character %q", c)
+		}
+		v := c - 48
+		if v > 40 {
+			v -= 8
+		}
+		for b := 5; b >= 0; b-- {
+			bits = append(bits, (v>>uint(b))&1)
+		}
+	}
+	return bits, nil
+}
+
+// unsigned reads n bits starting at bit start, most significant first
+func (a aisBitField) unsigned(start, n int) uint64 {
+	var v uint64
+	for i := start; i < start+n && i < len(a); i++ {
+		v = v<<1 | uint64(a[i])
+	}
+	return v
+}
+
+// signed reads n bits as a two's complement value
+func (a aisBitField) signed(start, n int) int64 {
+	v := a.unsigned(start, n)
+	if v&(1<<uint(n-1)) != 0 {
+		return int64(v) - 1<<uint(n)
+	}
+	return int64(v)
+}
+
+// startNMEA0183 reads Config.NMEA0183Input, when set, until the collector
+// stops. A failure to open it is logged and leaves the N2K source running.
+func (c *Collector) startNMEA0183() {
+	input := c.config.NMEA0183Input
+	if input == "" {
+		return
+	}
+
+	var r io.ReadCloser
+	if addr, ok := strings.CutPrefix(input, "udp://"); ok {
+		conn, err := net.ListenPacket("udp", addr)
+		if err != nil {
+			log.Printf("[0183] Failed to listen on %s: %v", input, err)
+			return
+		}
+		r = udpLineReader{conn}
+	} else {
+		f, err := os.Open(input)
+		if err != nil {
+			log.Printf("[0183] Failed to open %s: %v", input, err)
+			return
+		}
+		r = f
+	}
+
+	// Closing the input unblocks the read loop on shutdown
+	go func() {
+		<-c.done
+		r.Close()
+	}()
+	go c.readNMEA0183(r, input)
+
+	log.Printf("[0183] Reading NMEA 0183 from %s", input)
+}
+
+// readNMEA0183 feeds every sentence read from r into the storage queue
+func (c *Collector) readNMEA0183(r io.Reader, name string) {
+	var failures int64
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" {
+			continue
+		}
+
+		msg, err := ParseNMEA0183(line)
+		if err != nil {
+			if !errors.Is(err, ErrUnsupportedSentence) {
+				if failures++; failures%100 == 1 {
+					log.Printf("[0183] %v (%d bad sentences so far)", err, failures)
+				}
+			}
+			continue
+		}
+
+		c.decoder.finish(msg.PGN, msg.Fields)
+		c.stats.RecordMessage(msg.PGN, msg.Measurement, len(msg.Fields) > 0)
+
+		select {
+		case <-c.done:
+			return
+		default:
+		}
+		c.sendDecoded(msg)
+	}
+
+	select {
+	case <-c.done:
+	default:
+		log.Printf("[0183] Input %s closed: %v", name, scanner.Err())
+	}
+}
+
+// udpLineReader presents UDP datagrams, each one or more sentences, as a
+// stream of lines
+type udpLineReader struct {
+	conn net.PacketConn
+}
+
+func (u udpLineReader) Read(p []byte) (int, error) {
+	n, _, err := u.conn.ReadFrom(p)
+	if n > 0 && p[n-1] != '\n' && n < len(p) {
+		p[n] = '\n'
+		n++
+	}
+	return n, err
+}
+
+func (u udpLineReader) Close() error {
+	return u.conn.Close()
+}
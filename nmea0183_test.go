@@ -0,0 +1,238 @@
+package nmea
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"testing"
+)
+
+// withChecksum appends the checksum to a sentence body ("$..." or "!...")
+func withChecksum(sentence string) string {
+	return fmt.Sprintf("%s*%02X", sentence, nmeaChecksum(sentence[1:]))
+}
+
+// aisArmour packs (value, width) fields into an armoured AIS payload
+func aisArmour(fields ...[2]int64) string {
+	var bits []byte
+	for _, field := range fields {
+		value, width := uint64(field[0]), int(field[1])
+		for b := width - 1; b >= 0; b-- {
+			bits = append(bits, byte(value>>uint(b))&1)
+		}
+	}
+	var payload []byte
+	for i := 0; i < len(bits); i += 6 {
+		var v byte
+		for j := i; j < i+6; j++ {
+			v <<= 1
+			if j < len(bits) {
+				v |= bits[j]
+			}
+		}
+		if v > 39 {
+			v += 8
+		}
+		payload = append(payload, v+48)
+	}
+	return string(payload)
+}
+
+// checkFields compares decoded fields; a nil want means not available
+func checkFields(t *testing.T, name string, got, want map[string]interface{}) {
+	t.Helper()
+	for key, w := range want {
+		g, ok := got[key]
+		switch w := w.(type) {
+		case nil:
+			if ok && g != nil {
+				t.Errorf("%s: %s = %v, want not available", name, key, g)
+			}
+		case float64:
+			if f, ok := g.(float64); !ok || math.Abs(f-w) > 1e-6 {
+				t.Errorf("%s: %s = %v, want %v", name, key, g, w)
+			}
+		default:
+			if g != w {
+				t.Errorf("%s: %s = %v (%T), want %v (%T)", name, key, g, g, w, w)
+			}
+		}
+	}
+}
+
+func TestParseNMEA0183(t *testing.T) {
+	tests := []struct {
+		name     string
+		sentence string
+		pgn      int
+		want     map[string]interface{}
+	}{
+		{
+			"RMC north east, west variation",
+			"$GPRMC,123519,A,4807.038,N,01131.000,E,022.4,084.4,230394,003.1,W*6A",
+			129026,
+			map[string]interface{}{
+				"latitude": 48.1173, "longitude": 11.516666667,
+				"sog_ms": 22.4 * knotsToMS, "cog_deg": 84.4, "variation_deg": -3.1,
+			},
+		},
+		{
+			"RMC south west",
+			withChecksum("$GPRMC,081836,A,3751.65,S,14507.36,W,000.0,360.0,130998,011.3,E"),
+			129026,
+			map[string]interface{}{
+				"latitude": -37.860833333, "longitude": -145.122666667,
+				"sog_ms": 0.0, "cog_deg": 360.0, "variation_deg": 11.3,
+			},
+		},
+		{
+			"RMC without a fix",
+			withChecksum("$GPRMC,123519,V,,,,,,,230394,,"),
+			129026,
+			map[string]interface{}{"latitude": nil, "longitude": nil, "sog_ms": nil, "cog_deg": nil},
+		},
+		{
+			"GGA south west",
+			"$GPGGA,123519,4807.038,S,01131.000,W,1,08,0.9,545.4,M,46.9,M,,*48",
+			129025,
+			map[string]interface{}{
+				"latitude": -48.1173, "longitude": -11.516666667, "gnss_fix_quality": uint8(1),
+				"satellites": uint8(8), "hdop": 0.9, "altitude_m": 545.4,
+			},
+		},
+		{
+			"GGA no fix, empty fields",
+			withChecksum("$GPGGA,123519,,,,,0,,,,M,,M,,"),
+			129025,
+			map[string]interface{}{"latitude": nil, "longitude": nil, "gnss_fix_quality": uint8(0), "hdop": nil, "altitude_m": nil},
+		},
+		{
+			"MWV apparent, knots",
+			"$IIMWV,210.0,R,10.0,N,A",
+			130306,
+			map[string]interface{}{"wind_reference": uint8(2), "wind_angle_deg": 210.0, "wind_speed_ms": 10 * knotsToMS},
+		},
+		{
+			"MWV true, km/h",
+			withChecksum("$WIMWV,45.5,T,36.0,K,A"),
+			130306,
+			map[string]interface{}{"wind_reference": uint8(3), "wind_angle_deg": 45.5, "wind_speed_ms": 10.0},
+		},
+		{
+			"MWV invalid status",
+			"$IIMWV,210.0,R,10.0,N,V",
+			130306,
+			map[string]interface{}{"wind_reference": uint8(2), "wind_angle_deg": nil, "wind_speed_ms": nil},
+		},
+		{
+			"VHW knots",
+			"$IIVHW,,T,,M,6.5,N,12.0,K",
+			128259,
+			map[string]interface{}{"water_speed_ms": 6.5 * knotsToMS},
+		},
+		{
+			"VHW km/h only",
+			"$IIVHW,,T,,M,,N,18.0,K",
+			128259,
+			map[string]interface{}{"water_speed_ms": 5.0},
+		},
+		{
+			"DPT keel offset",
+			"$SDDPT,10.5,-1.5",
+			128267,
+			map[string]interface{}{"depth_m": 10.5, "depth_transducer_m": 10.5, "depth_below_keel_m": 9.0},
+		},
+		{
+			"DPT no depth",
+			"$SDDPT,,0.0",
+			128267,
+			map[string]interface{}{"depth_m": nil, "depth_transducer_m": nil},
+		},
+		{
+			"HDG west variation, no deviation",
+			"$HCHDG,98.3,,,7.1,W",
+			127250,
+			map[string]interface{}{"heading_deg": 98.3, "deviation_deg": nil, "variation_deg": -7.1, "heading_reference": uint8(1)},
+		},
+		{
+			"HDG east deviation",
+			withChecksum("$HCHDG,271.0,2.5,E,1.0,E"),
+			127250,
+			map[string]interface{}{"heading_deg": 271.0, "deviation_deg": 2.5, "variation_deg": 1.0},
+		},
+		{
+			// Example from the gpsd AIVDM documentation
+			"AIS type 1",
+			"!AIVDM,1,1,,B,177KQJ5000G?tO`K>RA1wUbN0TKH,0*5C",
+			129038,
+			map[string]interface{}{
+				"message_id": uint8(1), "mmsi": uint32(477553000), "nav_status": uint8(5),
+				"sog_ms": 0.0, "latitude": 47.582833333, "longitude": -122.345833333,
+				"cog_deg": 51.0, "heading_deg": 181.0,
+			},
+		},
+		{
+			// Class B report from the pyais documentation; heading 511 is not available
+			"AIS type 18",
+			"!AIVDM,1,1,,A,B5NJ;PP005l4ot5Isbl03wsUkP06,0*76",
+			129039,
+			map[string]interface{}{
+				"message_id": uint8(18), "mmsi": uint32(367430530), "sog_ms": 0.0,
+				"latitude": 37.785035, "longitude": -122.26732, "cog_deg": 0.0, "heading_deg": nil,
+			},
+		},
+		{
+			"AIS type 1 under way, south west",
+			withChecksum("!AIVDO,1,1,,A," + aisArmour(
+				[2]int64{1, 6}, [2]int64{0, 2}, [2]int64{235009802, 30}, [2]int64{0, 4}, [2]int64{0, 8},
+				[2]int64{65, 10}, [2]int64{0, 1}, [2]int64{-5.5 * 600000, 28}, [2]int64{-33.25 * 600000, 27},
+				[2]int64{1234, 12}, [2]int64{120, 9}, [2]int64{0, 31},
+			) + ",0"),
+			129038,
+			map[string]interface{}{
+				"mmsi": uint32(235009802), "sog_ms": 6.5 * knotsToMS,
+				"latitude": -33.25, "longitude": -5.5, "cog_deg": 123.4, "heading_deg": 120.0,
+			},
+		},
+	}
+	for _, tt := range tests {
+		msg, err := ParseNMEA0183(tt.sentence)
+		if err != nil {
+			t.Errorf("%s: %v", tt.name, err)
+			continue
+		}
+		if msg.PGN != tt.pgn || msg.Source != NMEA0183Source {
+			t.Errorf("%s: PGN %d source %d, want %d %d", tt.name, msg.PGN, msg.Source, tt.pgn, NMEA0183Source)
+		}
+		checkFields(t, tt.name, msg.Fields, tt.want)
+	}
+}
+
+func TestParseNMEA0183Errors(t *testing.T) {
+	tests := []struct {
+		name        string
+		sentence    string
+		unsupported bool // ErrUnsupportedSentence rather than a parse error
+	}{
+		{"bad checksum", "$GPRMC,123519,A,4807.038,N,01131.000,E,022.4,084.4,230394,003.1,W*6B", false},
+		{"checksum not hex", "$GPRMC,123519,A,4807.038,N,01131.000,E,022.4,084.4,230394,003.1,W*ZZ", false},
+		{"no start delimiter", "GPRMC,123519,A", false},
+		{"too few fields", withChecksum("$GPRMC,123519,A,4807.038"), false},
+		{"bad wind reference", "$IIMWV,210.0,X,10.0,N,A", false},
+		{"bad AIS character", "!AIVDM,1,1,,B,177KQJ5000G?tO`K>RA1wUbN0TK~,0", false},
+		// The same 168-bit report with two fill bits is two bits short
+		{"AIS fill bits", withChecksum("!AIVDM,1,1,,B,177KQJ5000G?tO`K>RA1wUbN0TKH,2"), false},
+		{"unsupported sentence", "$GPGSV,1,1,00", true},
+		{"AIS multi-fragment", withChecksum("!AIVDM,2,1,3,B,55P5TL01VIaAL@7WKO@mBplU@<PDhh000000001S;AJ::4A80?4i@E53,0"), true},
+		{"AIS static data", withChecksum("!AIVDM,1,1,,A,H42O55i18tMET00000000000000,2"), true},
+	}
+	for _, tt := range tests {
+		_, err := ParseNMEA0183(tt.sentence)
+		switch {
+		case err == nil:
+			t.Errorf("%s: no error", tt.name)
+		case errors.Is(err, ErrUnsupportedSentence) != tt.unsupported:
+			t.Errorf("%s: error %v, want unsupported = %v", tt.name, err, tt.unsupported)
+		}
+	}
+}
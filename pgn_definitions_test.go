@@ -0,0 +1,35 @@
+package nmea
+
+import "testing"
+
+func TestParseCANID(t *testing.T) {
+	tests := []struct {
+		name                 string
+		id                   uint32
+		priority, dp, pf, ps uint8
+		src, dest            uint8
+		pgn                  int
+	}{
+		// PDU2 (PF >= 240): PS is part of the PGN, broadcast
+		{"129025 position", 0x09F80115, 2, 1, 0xF8, 0x01, 0x15, 0xFF, 129025},
+		{"127250 heading", 0x09F1127F, 2, 1, 0xF1, 0x12, 0x7F, 0xFF, 127250},
+		{"130312 priority 7", 0x1DFD08FE, 7, 1, 0xFD, 0x08, 0xFE, 0xFF, 130312},
+		// PDU1 (PF < 240): PS is the destination, not part of the PGN
+		{"59904 ISO request", 0x18EA2301, 6, 0, 0xEA, 0x23, 0x01, 0x23, 59904},
+		{"126208 group function", 0x0DED1005, 3, 1, 0xED, 0x10, 0x05, 0x10, 126208},
+		{"PDU1 global", 0x18EAFF01, 6, 0, 0xEA, 0xFF, 0x01, 0xFF, 59904},
+		// Bits above the 29-bit identifier (e.g. SocketCAN's EFF flag)
+		{"extended flag", 0x80000000 | 0x09F80115, 2, 1, 0xF8, 0x01, 0x15, 0xFF, 129025},
+	}
+	for _, tt := range tests {
+		priority, dp, pf, ps, src, dest, pgn := ParseCANID(tt.id)
+		if priority != tt.priority || dp != tt.dp || pf != tt.pf || ps != tt.ps {
+			t.Errorf("%s: priority %d dp %d pf %#x ps %#x, want %d %d %#x %#x",
+				tt.name, priority, dp, pf, ps, tt.priority, tt.dp, tt.pf, tt.ps)
+		}
+		if src != tt.src || dest != tt.dest || pgn != tt.pgn {
+			t.Errorf("%s: src %#x dest %#x pgn %d, want %#x %#x %d",
+				tt.name, src, dest, pgn, tt.src, tt.dest, tt.pgn)
+		}
+	}
+}
@@ -0,0 +1,180 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/csv"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+// maxPolarUpload bounds the size of an uploaded polar file
+const maxPolarUpload = 1 << 20
+
+// ParsePolarCSV reads a polar table in the Expedition/ORC CSV layout: the
+// first row is a blank (or label) cell followed by the TWS columns, each
+// following row is a TWA followed by one boat speed per TWS. Tab, semicolon
+// and comma delimiters are detected from the first line; lines starting
+// with '#' or '!' are comments. Both axes must be strictly ascending.
+func ParsePolarCSV(r io.Reader) (Polar, error) {
+	var polar Polar
+
+	data, err := ioutil.ReadAll(r)
+	if err != nil {
+		return polar, fmt.Errorf("failed to read polar: %w", err)
+	}
+
+	reader := csv.NewReader(bytes.NewReader(data))
+	reader.Comma = polarDelimiter(data)
+	reader.Comment = '#'
+	reader.FieldsPerRecord = -1
+
+	records, err := reader.ReadAll()
+	if err != nil {
+		return polar, fmt.Errorf("failed to parse polar: %w", err)
+	}
+
+	var rows [][]string
+	for _, record := range records {
+		if len(record) == 0 || strings.HasPrefix(strings.TrimSpace(record[0]), "!") {
+			continue
+		}
+		// Drop trailing empty cells left by a trailing delimiter
+		for len(record) > 0 && strings.TrimSpace(record[len(record)-1]) == "" {
+			record = record[:len(record)-1]
+		}
+		if len(record) > 0 {
+			rows = append(rows, record)
+		}
+	}
+	if len(rows) < 2 {
+		return polar, fmt.Errorf("polar needs a TWS header row and at least one TWA row")
+	}
+
+	// The corner cell is blank or a label such as "TWA/TWS"
+	for i, cell := range rows[0][1:] {
+		tws, err := strconv.ParseFloat(strings.TrimSpace(cell), 64)
+		if err != nil {
+			return polar, fmt.Errorf("header column %d: invalid TWS %q", i+2, cell)
+		}
+		polar.WindSpeeds = append(polar.WindSpeeds, tws)
+	}
+	if len(polar.WindSpeeds) == 0 {
+		return polar, fmt.Errorf("polar header has no TWS columns")
+	}
+
+	polar.BoatSpeeds = make([][]float64, len(polar.WindSpeeds))
+	for n, row := range rows[1:] {
+		line := n + 2
+		if len(row)-1 != len(polar.WindSpeeds) {
+			return polar, fmt.Errorf("row %d: expected %d boat speeds, got %d", line, len(polar.WindSpeeds), len(row)-1)
+		}
+		twa, err := strconv.ParseFloat(strings.TrimSpace(row[0]), 64)
+		if err != nil {
+			return polar, fmt.Errorf("row %d: invalid TWA %q", line, row[0])
+		}
+		polar.WindAngles = append(polar.WindAngles, twa)
+
+		// Stored as BoatSpeeds[tws][twa], the layout of the boat database
+		for i, cell := range row[1:] {
+			bs, err := strconv.ParseFloat(strings.TrimSpace(cell), 64)
+			if err != nil {
+				return polar, fmt.Errorf("row %d: invalid boat speed %q", line, cell)
+			}
+			polar.BoatSpeeds[i] = append(polar.BoatSpeeds[i], bs)
+		}
+	}
+
+	if err := checkAscending("TWS", polar.WindSpeeds); err != nil {
+		return polar, err
+	}
+	if err := checkAscending("TWA", polar.WindAngles); err != nil {
+		return polar, err
+	}
+
+	return polar, nil
+}
+
+// polarDelimiter picks the separator used on the first data line
+func polarDelimiter(data []byte) rune {
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	for scanner.Scan() {
+		line := scanner.Text()
+		if strings.TrimSpace(line) == "" || strings.HasPrefix(line, "#") || strings.HasPrefix(line, "!") {
+			continue
+		}
+		switch {
+		case strings.Contains(line, "\t"):
+			return '\t'
+		case strings.Contains(line, ";"):
+			return ';'
+		}
+		break
+	}
+	return ','
+}
+
+func checkAscending(name string, axis []float64) error {
+	for i := 1; i < len(axis); i++ {
+		if axis[i] <= axis[i-1] {
+			return fmt.Errorf("%s values must be ascending: %g after %g", name, axis[i], axis[i-1])
+		}
+	}
+	return nil
+}
+
+// handlePolarUpload replaces the polar of the selected boat, or of its
+// selected sails, with an uploaded Expedition/ORC CSV, sent either as the
+// raw request body or as the "file" field of a multipart form. The change
+// lasts until restart.
+func (vs *VisualizationServer) handlePolarUpload(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "POST a polar file", http.StatusMethodNotAllowed)
+		return
+	}
+	r.Body = http.MaxBytesReader(w, r.Body, maxPolarUpload)
+
+	var body io.Reader = r.Body
+	if strings.HasPrefix(r.Header.Get("Content-Type"), "multipart/form-data") {
+		file, _, err := r.FormFile("file")
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		defer file.Close()
+		body = file
+	}
+
+	polar, err := ParsePolarCSV(body)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	vs.mu.Lock()
+	boat, sails := vs.selectedBoat, vs.selectedSails
+	if boat != nil && sails != "" {
+		boat.SailPolars[sails] = polar
+	} else if boat != nil {
+		boat.Polar = polar
+	}
+	vs.mu.Unlock()
+
+	if boat == nil {
+		http.Error(w, "no boat selected", http.StatusBadRequest)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	encodeJSON(w, map[string]interface{}{
+		"status":      "ok",
+		"boat":        boat.Name,
+		"sails":       sails,
+		"wind_speeds": len(polar.WindSpeeds),
+		"wind_angles": len(polar.WindAngles),
+	})
+}
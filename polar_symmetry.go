@@ -0,0 +1,61 @@
+package main
+
+import "odysail-boat-viz/integration"
+
+// IsSymmetric reports whether the polar applies to both tacks from one
+// side's angles: Symmetric when set, otherwise true unless some TWA is
+// beyond 180°
+func (p Polar) IsSymmetric() bool {
+	if p.Symmetric != nil {
+		return *p.Symmetric
+	}
+	return len(p.WindAngles) == 0 || p.WindAngles[len(p.WindAngles)-1] <= 180
+}
+
+// TargetSpeedSide is TargetSpeed for a TWA folded to 0-180 and the side the
+// wind comes over. A symmetric polar serves both sides from the same
+// angles; a full-circle one reads port at 360 - twa.
+func (p Polar) TargetSpeedSide(windSpeed, twa float64, side string) float64 {
+	if side == integration.WindSidePort && !p.IsSymmetric() {
+		twa = 360 - twa
+	}
+	return p.TargetSpeed(windSpeed, twa)
+}
+
+// ExpandSymmetric returns a symmetric polar mirrored onto the full 0-360
+// circle, port angles at 360 - twa, for rendering. Full-circle polars, and
+// symmetric ones already listing angles past 180°, are returned as they are.
+func (p Polar) ExpandSymmetric() Polar {
+	if !p.IsSymmetric() || len(p.WindAngles) == 0 || p.WindAngles[len(p.WindAngles)-1] > 180 {
+		return p
+	}
+
+	// Mirror every angle short of 180°, walking back so the axis stays
+	// ascending; 0° becomes 360° and closes the circle
+	var mirrored []int
+	for i := len(p.WindAngles) - 1; i >= 0; i-- {
+		if p.WindAngles[i] < 180 {
+			mirrored = append(mirrored, i)
+		}
+	}
+
+	symmetric := false
+	full := Polar{
+		WindSpeeds: append([]float64(nil), p.WindSpeeds...),
+		WindAngles: append([]float64(nil), p.WindAngles...),
+		BoatSpeeds: make([][]float64, len(p.BoatSpeeds)),
+		Symmetric:  &symmetric,
+	}
+	for _, i := range mirrored {
+		full.WindAngles = append(full.WindAngles, 360-p.WindAngles[i])
+	}
+	for ws, row := range p.BoatSpeeds {
+		out := make([]float64, len(p.WindAngles), len(full.WindAngles))
+		copy(out, row)
+		for _, i := range mirrored {
+			out = append(out, out[i])
+		}
+		full.BoatSpeeds[ws] = out
+	}
+	return full
+}
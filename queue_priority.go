@@ -0,0 +1,183 @@
+package nmea
+
+// DefaultCriticalPGNs are kept flowing when the collector queues fill:
+// attitude, wind and COG/SOG drive the live display, and depth and position
+// feed the shallow-water and anchor alarms
+var DefaultCriticalPGNs = []int{127257, 130306, 129026, 128267, 129025, 129029}
+
+// criticalCANPriority is the CAN priority (0 highest) at or above which a
+// frame counts as critical even if its PGN is not listed, for frames whose
+// CAN ID was received
+const criticalCANPriority = 2
+
+// queueGate decides which items a full or nearly full queue keeps
+type queueGate struct {
+	critical  map[int]bool
+	highWater float64 // fraction of queue capacity, 0 = plain drop-newest
+}
+
+func newQueueGate(config Config) queueGate {
+	gate := queueGate{
+		critical:  make(map[int]bool, len(config.CriticalPGNs)),
+		highWater: config.QueueHighWater,
+	}
+	for _, pgn := range config.CriticalPGNs {
+		gate.critical[pgn] = true
+	}
+	return gate
+}
+
+// isCritical reports whether a PGN, or a frame's CAN priority when its CAN
+// ID is known, marks it as one to protect
+func (g queueGate) isCritical(pgn int, canID uint32, priority uint8) bool {
+	return g.critical[pgn] || (canID != 0 && priority <= criticalCANPriority)
+}
+
+// priorityQueue is a bounded queue holding critical items apart from the
+// rest. Receivers take critical items first; order is kept within each
+// class, so the frames of one PGN stay in sequence.
+type priorityQueue[T any] struct {
+	critical chan T
+	normal   chan T
+	size     int
+}
+
+func newPriorityQueue[T any](size int) *priorityQueue[T] {
+	return &priorityQueue[T]{
+		critical: make(chan T, size),
+		normal:   make(chan T, size),
+		size:     size,
+	}
+}
+
+// Len reports how many items are queued
+func (q *priorityQueue[T]) Len() int {
+	return len(q.critical) + len(q.normal)
+}
+
+// send queues item, waiting for room in its class rather than dropping
+// anything. It returns false if done is closed first; a nil done waits
+// indefinitely.
+func (q *priorityQueue[T]) send(item T, critical bool, done <-chan struct{}) bool {
+	ch := q.normal
+	if critical {
+		ch = q.critical
+	}
+	select {
+	case ch <- item:
+		return true
+	case <-done:
+		return false
+	}
+}
+
+// receive waits for the next item, critical first. ok is false once done
+// is closed.
+func (q *priorityQueue[T]) receive(done <-chan struct{}) (item T, ok bool) {
+	select {
+	case item = <-q.critical:
+		return item, true
+	default:
+	}
+	select {
+	case item = <-q.critical:
+		return item, true
+	case item = <-q.normal:
+		return item, true
+	case <-done:
+		return item, false
+	}
+}
+
+// tryReceive returns the next queued item, critical first, without waiting
+func (q *priorityQueue[T]) tryReceive() (item T, ok bool) {
+	select {
+	case item = <-q.critical:
+		return item, true
+	default:
+	}
+	select {
+	case item = <-q.normal:
+		return item, true
+	default:
+		return item, false
+	}
+}
+
+// Outcomes of queueGate offers
+const (
+	offerSent     = iota
+	offerDropped  // queue full
+	offerShed     // refused above the high-water mark to keep room for critical items
+	offerEvicting // sent after displacing the oldest queued normal item
+)
+
+// offer queues item without blocking. Past the high-water mark only
+// critical items are accepted. A critical item that finds the queue full
+// displaces the oldest queued normal item, as the freshest data is worth
+// most; queued critical items are never displaced, so with nothing else to
+// give way the new critical item is the one dropped.
+func offer[T any](g queueGate, q *priorityQueue[T], item T, critical bool) int {
+	if !critical {
+		if g.highWater > 0 && float64(q.Len()) >= g.highWater*float64(q.size) {
+			return offerShed
+		}
+		if q.Len() >= q.size {
+			return offerDropped
+		}
+		select {
+		case q.normal <- item:
+			return offerSent
+		default:
+			return offerDropped
+		}
+	}
+
+	select {
+	case q.critical <- item:
+	default:
+		// Every slot holds a critical item
+		return offerDropped
+	}
+	if q.Len() <= q.size {
+		return offerSent
+	}
+	select {
+	case <-q.normal:
+		return offerEvicting
+	default:
+		// Only over by items another sender is about to have taken
+		return offerSent
+	}
+}
+
+// sendDecoded hands a decoded message to the storage worker
+func (c *Collector) sendDecoded(msg DecodedMessage) {
+	critical := c.gate.isCritical(msg.PGN, msg.CANID, msg.Priority)
+
+	select {
+	case <-c.decodeDone:
+		// Draining for shutdown: nothing new is arriving and the storage
+		// worker is still running, so wait for room rather than drop
+		if !c.decodedData.send(msg, critical, c.storeDone) {
+			c.stats.RecordDecodedDropped()
+		}
+		return
+	default:
+	}
+
+	c.countOffer(offer(c.gate, c.decodedData, msg, critical), c.stats.RecordDecodedDropped)
+}
+
+// countOffer records an offer's losses against dropped, the queue's own
+// drop counter, and in the preferential drop count when the gate chose
+// what to lose
+func (c *Collector) countOffer(outcome int, dropped func()) {
+	switch outcome {
+	case offerDropped:
+		dropped()
+	case offerShed, offerEvicting:
+		dropped()
+		c.stats.RecordPreferentialDrop()
+	}
+}
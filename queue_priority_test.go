@@ -0,0 +1,84 @@
+package nmea
+
+import "testing"
+
+// TestOfferFullOfCritical checks that a queue holding only critical items
+// keeps them all, dropping the new critical item without counting it as a
+// preferential drop
+func TestOfferFullOfCritical(t *testing.T) {
+	g := newQueueGate(DefaultConfig())
+	q := newPriorityQueue[int](4)
+	for i := 0; i < 4; i++ {
+		if got := offer(g, q, i, true); got != offerSent {
+			t.Fatalf("offer critical %d = %d, want offerSent", i, got)
+		}
+	}
+	if got := offer(g, q, 4, true); got != offerDropped {
+		t.Errorf("offer critical to full queue = %d, want offerDropped", got)
+	}
+	for want := 0; want < 4; want++ {
+		if got, ok := q.tryReceive(); !ok || got != want {
+			t.Errorf("received %d, %v; want %d", got, ok, want)
+		}
+	}
+
+	c := NewCollector(DefaultConfig(), nil, nil)
+	c.rawFrames = newPriorityQueue[RawFrame](2)
+	for i := 0; i < 3; i++ {
+		c.enqueue(RawFrame{PGN: 127257})
+	}
+	snap := c.stats.GetSnapshot()
+	if snap["frames_dropped"].(int64) != 1 || snap["preferential_drops"].(int64) != 0 {
+		t.Errorf("frames_dropped = %v, preferential_drops = %v; want 1, 0",
+			snap["frames_dropped"], snap["preferential_drops"])
+	}
+}
+
+// TestOfferEvictsNormal checks that a critical item displaces the oldest
+// normal item, never a critical one, and is received first
+func TestOfferEvictsNormal(t *testing.T) {
+	g := newQueueGate(DefaultConfig())
+	g.highWater = 0
+	q := newPriorityQueue[int](3)
+	offer(g, q, 1, false)
+	offer(g, q, 10, true)
+	offer(g, q, 2, false)
+
+	if got := offer(g, q, 3, false); got != offerDropped {
+		t.Errorf("offer normal to full queue = %d, want offerDropped", got)
+	}
+	if got := offer(g, q, 11, true); got != offerEvicting {
+		t.Errorf("offer critical to full queue = %d, want offerEvicting", got)
+	}
+	if got := offer(g, q, 12, true); got != offerEvicting {
+		t.Errorf("offer critical to full queue = %d, want offerEvicting", got)
+	}
+	if got := offer(g, q, 13, true); got != offerDropped {
+		t.Errorf("offer critical to queue of critical items = %d, want offerDropped", got)
+	}
+
+	for _, want := range []int{10, 11, 12} {
+		if got, ok := q.tryReceive(); !ok || got != want {
+			t.Errorf("received %d, %v; want %d", got, ok, want)
+		}
+	}
+	if got, ok := q.tryReceive(); ok {
+		t.Errorf("received %d from emptied queue", got)
+	}
+}
+
+// TestOfferShed checks that normal items are refused above the high-water
+// mark while critical items are still accepted
+func TestOfferShed(t *testing.T) {
+	g := newQueueGate(DefaultConfig())
+	g.highWater = 0.5
+	q := newPriorityQueue[int](4)
+	offer(g, q, 1, false)
+	offer(g, q, 2, false)
+	if got := offer(g, q, 3, false); got != offerShed {
+		t.Errorf("offer normal above high water = %d, want offerShed", got)
+	}
+	if got := offer(g, q, 4, true); got != offerSent {
+		t.Errorf("offer critical above high water = %d, want offerSent", got)
+	}
+}
@@ -0,0 +1,173 @@
+package nmea
+
+import (
+	"encoding/csv"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// recordHeader is the frames CSV layout read by ReplaySource plus two
+// columns for BoomSense sensor payloads, so a session file replays the IMU
+// stream into the event detector alongside the N2K frames
+var recordHeader = []string{
+	"iso8601", "ts_ms", "can_id", "pgn", "priority",
+	"source", "dest", "length", "data_hex",
+	"topic", "sensor_json",
+}
+
+// recordFlushInterval bounds how much of a session is lost if the process
+// dies while recording
+const recordFlushInterval = time.Second
+
+// RecordingStatus describes the current or last finished recording
+type RecordingStatus struct {
+	Active   bool      `json:"active"`
+	Path     string    `json:"path,omitempty"`
+	Started  time.Time `json:"started,omitempty"`
+	Stopped  time.Time `json:"stopped,omitempty"`
+	Frames   int64     `json:"frames"`
+	Readings int64     `json:"readings"` // BoomSense sensor payloads
+}
+
+// recorder writes every incoming frame and sensor payload with its arrival
+// time, before any queueing or decoding, to a replayable session file
+type recorder struct {
+	mu        sync.Mutex
+	file      *os.File
+	writer    *csv.Writer
+	lastFlush time.Time
+	status    RecordingStatus
+}
+
+// StartRecording begins capturing the live input to path (a new file)
+func (c *Collector) StartRecording(path string) error {
+	c.rec.mu.Lock()
+	defer c.rec.mu.Unlock()
+
+	if c.rec.file != nil {
+		return fmt.Errorf("already recording to %s", c.rec.status.Path)
+	}
+
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return err
+	}
+	file, err := os.OpenFile(path, os.O_CREATE|os.O_EXCL|os.O_WRONLY, 0644)
+	if err != nil {
+		return err
+	}
+
+	writer := csv.NewWriter(file)
+	if err := writer.Write(recordHeader); err != nil {
+		file.Close()
+		return err
+	}
+
+	now := time.Now()
+	c.rec.file = file
+	c.rec.writer = writer
+	c.rec.lastFlush = now
+	c.rec.status = RecordingStatus{Active: true, Path: path, Started: now}
+	return nil
+}
+
+// StopRecording closes the session file and returns its summary
+func (c *Collector) StopRecording() (RecordingStatus, error) {
+	c.rec.mu.Lock()
+	defer c.rec.mu.Unlock()
+
+	if c.rec.file == nil {
+		return c.rec.status, fmt.Errorf("not recording")
+	}
+
+	c.rec.writer.Flush()
+	err := c.rec.writer.Error()
+	if closeErr := c.rec.file.Close(); err == nil {
+		err = closeErr
+	}
+
+	c.rec.file = nil
+	c.rec.writer = nil
+	c.rec.status.Active = false
+	c.rec.status.Stopped = time.Now()
+	return c.rec.status, err
+}
+
+// Recording reports the current or last recording
+func (c *Collector) Recording() RecordingStatus {
+	c.rec.mu.Lock()
+	defer c.rec.mu.Unlock()
+	return c.rec.status
+}
+
+// recordFrame stores a frame stamped with its arrival time, the clock
+// recordSensor uses, rather than a timestamp the gateway may have set from
+// its own clock; otherwise replay would pace frames and sensor readings
+// against different clocks
+func (c *Collector) recordFrame(frame RawFrame) {
+	c.rec.mu.Lock()
+	defer c.rec.mu.Unlock()
+
+	if c.rec.writer == nil {
+		return
+	}
+
+	c.rec.write(time.Now(), []string{
+		fmt.Sprintf("0x%08X", frame.ID),
+		strconv.Itoa(frame.PGN),
+		strconv.Itoa(int(frame.Priority)),
+		strconv.Itoa(int(frame.Source)),
+		strconv.Itoa(int(frame.Dest)),
+		strconv.Itoa(len(frame.Data)),
+		hex.EncodeToString(frame.Data),
+		frame.Topic,
+		"",
+	})
+	c.rec.status.Frames++
+}
+
+// recordSensor stores a sensor payload; payloads without their own
+// timestamp get the arrival time so replay keeps the sample spacing
+func (c *Collector) recordSensor(topic string, payload map[string]interface{}) {
+	c.rec.mu.Lock()
+	defer c.rec.mu.Unlock()
+
+	if c.rec.writer == nil {
+		return
+	}
+
+	now := time.Now()
+	if _, ok := payload["ts"]; !ok {
+		if _, ok := payload["timestamp"]; !ok {
+			stamped := make(map[string]interface{}, len(payload)+1)
+			for k, v := range payload {
+				stamped[k] = v
+			}
+			stamped["ts"] = float64(now.UnixMilli())
+			payload = stamped
+		}
+	}
+	data, err := json.Marshal(payload)
+	if err != nil {
+		return
+	}
+
+	c.rec.write(now, []string{"", "", "", "", "", "", "", topic, string(data)})
+	c.rec.status.Readings++
+}
+
+// write appends a row after the two timestamp columns. Caller holds r.mu.
+func (r *recorder) write(t time.Time, cols []string) {
+	row := append([]string{t.Format(time.RFC3339Nano), strconv.FormatInt(t.UnixMilli(), 10)}, cols...)
+	r.writer.Write(row)
+
+	if time.Since(r.lastFlush) >= recordFlushInterval {
+		r.writer.Flush()
+		r.lastFlush = time.Now()
+	}
+}
@@ -0,0 +1,89 @@
+package nmea
+
+import (
+	"encoding/csv"
+	"os"
+	"path/filepath"
+	"strconv"
+	"testing"
+	"time"
+)
+
+func TestRecordAndReplay(t *testing.T) {
+	c := NewCollector(DefaultConfig(), nil, nil)
+	path := filepath.Join(t.TempDir(), "s", "rec.csv")
+	if err := c.StartRecording(path); err != nil {
+		t.Fatal(err)
+	}
+	if err := c.StartRecording(path); err == nil {
+		t.Error("second StartRecording succeeded")
+	}
+
+	c.enqueue(RawFrame{Timestamp: time.Now(), ID: 0x09FD0203, PGN: 130306, Priority: 2, Source: 3, Dest: 255, Data: []byte{1, 2, 3}})
+	if !c.handleSensorPayload("boats/x/imu", map[string]interface{}{"ax": 0.1, "gx": 1.0}) {
+		t.Fatal("IMU payload not taken as a sensor reading")
+	}
+	status, err := c.StopRecording()
+	if err != nil || status.Frames != 1 || status.Readings != 1 || status.Active {
+		t.Fatalf("StopRecording = %+v, %v; want 1 frame, 1 reading", status, err)
+	}
+
+	rs, err := OpenReplaySource(path, 0, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var topics []string
+	var payload map[string]interface{}
+	rs.Sensor = func(topic string, p map[string]interface{}) { topics = append(topics, topic); payload = p }
+	out := make(chan RawFrame, 10)
+	if err := rs.Run(out, make(chan struct{})); err != nil {
+		t.Fatal(err)
+	}
+	if len(out) != 1 || len(topics) != 1 || topics[0] != "boats/x/imu" || payload["ts"] == nil {
+		t.Fatalf("replayed %d frames, sensor topics %v payload %v", len(out), topics, payload)
+	}
+	if f := <-out; f.PGN != 130306 || f.ID != 0x09FD0203 || len(f.Data) != 3 {
+		t.Errorf("replayed frame %+v", f)
+	}
+}
+
+// TestRecordArrivalClock checks that frames and sensor readings are both
+// recorded on the arrival clock, whatever time a gateway stamped a frame
+func TestRecordArrivalClock(t *testing.T) {
+	c := NewCollector(DefaultConfig(), nil, nil)
+	path := filepath.Join(t.TempDir(), "rec.csv")
+	if err := c.StartRecording(path); err != nil {
+		t.Fatal(err)
+	}
+
+	before := time.Now()
+	gatewayClock := time.Date(2001, 1, 1, 0, 0, 0, 0, time.UTC)
+	c.enqueue(RawFrame{Timestamp: gatewayClock, PGN: 130306, Data: []byte{1, 2, 3}})
+	c.handleSensorPayload("boats/x/imu", map[string]interface{}{"ax": 0.1, "ay": 0.0, "az": 1.0, "gx": 1.0, "gy": 0.0, "gz": 0.0})
+	if _, err := c.StopRecording(); err != nil {
+		t.Fatal(err)
+	}
+	after := time.Now()
+
+	f, err := os.Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	rows, err := csv.NewReader(f).ReadAll()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(rows) != 3 {
+		t.Fatalf("%d rows, want header, frame and reading", len(rows))
+	}
+	for _, row := range rows[1:] {
+		ms, err := strconv.ParseInt(row[1], 10, 64)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if ts := time.UnixMilli(ms); ts.Before(before.Truncate(time.Millisecond)) || ts.After(after) {
+			t.Errorf("row %v recorded at %v, want arrival between %v and %v", row[:3], ts, before, after)
+		}
+	}
+}
@@ -0,0 +1,77 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"path/filepath"
+	"regexp"
+	"time"
+)
+
+// recordNamePattern limits client-chosen session names to a plain file name
+var recordNamePattern = regexp.MustCompile(`^[A-Za-z0-9_.-]+$`)
+
+// handleRecordStart starts capturing the live frames and BoomSense readings
+// to a session file in dir. The file is named session_<UTC time>.csv unless
+// ?name= gives a plain file name. Replay it with source "replay" and
+// replay_path set to the file.
+func handleRecordStart(w http.ResponseWriter, r *http.Request, dir string) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "POST required", http.StatusMethodNotAllowed)
+		return
+	}
+	if nmeaCollector == nil {
+		http.Error(w, "NMEA collector not running", http.StatusServiceUnavailable)
+		return
+	}
+
+	name := r.URL.Query().Get("name")
+	if name == "" {
+		name = "session_" + time.Now().UTC().Format("20060102T150405Z")
+	}
+	if !recordNamePattern.MatchString(name) || name == "." || name == ".." {
+		http.Error(w, fmt.Sprintf("invalid name %q", name), http.StatusBadRequest)
+		return
+	}
+	if filepath.Ext(name) == "" {
+		name += ".csv"
+	}
+
+	if err := nmeaCollector.StartRecording(filepath.Join(dir, name)); err != nil {
+		http.Error(w, err.Error(), http.StatusConflict)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	encodeJSON(w, nmeaCollector.Recording())
+}
+
+func handleRecordStop(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "POST required", http.StatusMethodNotAllowed)
+		return
+	}
+	if nmeaCollector == nil {
+		http.Error(w, "NMEA collector not running", http.StatusServiceUnavailable)
+		return
+	}
+
+	status, err := nmeaCollector.StopRecording()
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusConflict)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	encodeJSON(w, status)
+}
+
+func handleRecordStatus(w http.ResponseWriter, r *http.Request) {
+	if nmeaCollector == nil {
+		http.Error(w, "NMEA collector not running", http.StatusServiceUnavailable)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	encodeJSON(w, nmeaCollector.Recording())
+}
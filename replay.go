@@ -0,0 +1,230 @@
+package nmea
+
+import (
+	"encoding/csv"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Frame sources selectable through Config.Source
+const (
+	SourceMQTT   = "mqtt"
+	SourceReplay = "replay"
+)
+
+// ReplaySource feeds RawFrames from a recorded frames CSV (as written by
+// storage.CSVWriter.WriteFrame or a Collector recording) into the decode
+// path. Frames are paced by their recorded timestamps divided by Speed;
+// Speed <= 0 replays as fast as the decoders accept them.
+type ReplaySource struct {
+	Path  string
+	Speed float64
+	Loop  bool
+
+	// Sensor receives the BoomSense payloads of a session recording (rows
+	// with sensor_json set); they are skipped when nil
+	Sensor func(topic string, payload map[string]interface{})
+
+	file   *os.File
+	reader *csv.Reader
+	cols   map[string]int
+}
+
+// OpenReplaySource opens the recording and validates its header
+func OpenReplaySource(path string, speed float64, loop bool) (*ReplaySource, error) {
+	rs := &ReplaySource{Path: path, Speed: speed, Loop: loop}
+
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open replay file: %w", err)
+	}
+	rs.file = file
+
+	if err := rs.readHeader(); err != nil {
+		file.Close()
+		return nil, err
+	}
+	return rs, nil
+}
+
+func (rs *ReplaySource) readHeader() error {
+	rs.reader = csv.NewReader(rs.file)
+	rs.reader.FieldsPerRecord = -1
+
+	header, err := rs.reader.Read()
+	if err != nil {
+		return fmt.Errorf("failed to read replay header: %w", err)
+	}
+
+	rs.cols = make(map[string]int)
+	for i, name := range header {
+		rs.cols[strings.TrimSpace(name)] = i
+	}
+
+	// Accept "data" as an alias for hand-made recordings
+	if _, ok := rs.cols["data_hex"]; !ok {
+		if i, ok := rs.cols["data"]; ok {
+			rs.cols["data_hex"] = i
+		}
+	}
+
+	for _, required := range []string{"iso8601", "pgn", "source", "data_hex"} {
+		if _, ok := rs.cols[required]; !ok {
+			return fmt.Errorf("replay file missing column %q", required)
+		}
+	}
+	return nil
+}
+
+// Run streams frames into out until the recording ends (or forever when
+// looping) or done is closed. The file is closed on return.
+func (rs *ReplaySource) Run(out chan<- RawFrame, done <-chan struct{}) error {
+	defer rs.file.Close()
+
+	log.Printf("[REPLAY] Replaying %s at %.1fx", rs.Path, rs.Speed)
+
+	var first time.Time
+	var start time.Time
+	count := 0
+
+	for {
+		record, err := rs.reader.Read()
+		if err == io.EOF {
+			if !rs.Loop {
+				log.Printf("[REPLAY] Finished %s: %d frames", rs.Path, count)
+				return nil
+			}
+			if _, err := rs.file.Seek(0, io.SeekStart); err != nil {
+				return fmt.Errorf("failed to rewind replay file: %w", err)
+			}
+			if err := rs.readHeader(); err != nil {
+				return err
+			}
+			first = time.Time{}
+			continue
+		}
+		if err != nil {
+			return fmt.Errorf("failed to read replay file: %w", err)
+		}
+
+		var recorded time.Time
+		var frame RawFrame
+		var sensor map[string]interface{}
+		if raw := rs.field(record, "sensor_json"); raw != "" {
+			recorded, err = rs.parseTime(record)
+			if err == nil {
+				err = json.Unmarshal([]byte(raw), &sensor)
+			}
+		} else {
+			recorded, frame, err = rs.parseRecord(record)
+		}
+		if err != nil {
+			log.Printf("[REPLAY] Skipping line: %v", err)
+			continue
+		}
+
+		// Honour inter-frame timing relative to the first frame
+		if first.IsZero() {
+			first = recorded
+			start = time.Now()
+		} else if rs.Speed > 0 {
+			offset := time.Duration(float64(recorded.Sub(first)) / rs.Speed)
+			if wait := time.Until(start.Add(offset)); wait > 0 {
+				select {
+				case <-time.After(wait):
+				case <-done:
+					return nil
+				}
+			}
+		}
+
+		if sensor != nil {
+			if rs.Sensor != nil {
+				rs.Sensor(rs.field(record, "topic"), sensor)
+			}
+			continue
+		}
+
+		// Frames are stamped at replay time, as live frames are on arrival
+		frame.Timestamp = time.Now()
+
+		select {
+		case out <- frame:
+			count++
+		case <-done:
+			return nil
+		}
+	}
+}
+
+// field returns the named column of a row, or "" when absent
+func (rs *ReplaySource) field(record []string, name string) string {
+	if i, ok := rs.cols[name]; ok && i < len(record) {
+		return strings.TrimSpace(record[i])
+	}
+	return ""
+}
+
+// parseTime reads a row's recorded time from iso8601, falling back to ts_ms
+func (rs *ReplaySource) parseTime(record []string) (time.Time, error) {
+	recorded, err := time.Parse(time.RFC3339Nano, rs.field(record, "iso8601"))
+	if err != nil {
+		ms, msErr := strconv.ParseInt(rs.field(record, "ts_ms"), 10, 64)
+		if msErr != nil {
+			return recorded, fmt.Errorf("bad timestamp %q", rs.field(record, "iso8601"))
+		}
+		recorded = time.UnixMilli(ms)
+	}
+	return recorded, nil
+}
+
+// parseRecord converts one CSV row into a RawFrame and its recorded time
+func (rs *ReplaySource) parseRecord(record []string) (time.Time, RawFrame, error) {
+	field := func(name string) string { return rs.field(record, name) }
+
+	var frame RawFrame
+
+	recorded, err := rs.parseTime(record)
+	if err != nil {
+		return recorded, frame, err
+	}
+
+	pgn, err := strconv.Atoi(field("pgn"))
+	if err != nil {
+		return recorded, frame, fmt.Errorf("bad pgn %q", field("pgn"))
+	}
+	source, err := strconv.ParseUint(field("source"), 10, 8)
+	if err != nil {
+		return recorded, frame, fmt.Errorf("bad source %q", field("source"))
+	}
+	data, err := hex.DecodeString(strings.ReplaceAll(field("data_hex"), " ", ""))
+	if err != nil {
+		return recorded, frame, fmt.Errorf("bad data %q", field("data_hex"))
+	}
+
+	frame.PGN = pgn
+	frame.Source = uint8(source)
+	frame.Data = data
+	frame.Length = len(data)
+	frame.Topic = "replay"
+	frame.Dest = 0xFF
+
+	if v, err := strconv.ParseUint(field("priority"), 10, 8); err == nil {
+		frame.Priority = uint8(v)
+	}
+	if v, err := strconv.ParseUint(field("dest"), 10, 8); err == nil {
+		frame.Dest = uint8(v)
+	}
+	if v, err := strconv.ParseUint(field("can_id"), 0, 32); err == nil {
+		frame.ID = uint32(v)
+	}
+
+	return recorded, frame, nil
+}
@@ -0,0 +1,182 @@
+package main
+
+import (
+	"encoding"
+	"encoding/json"
+	"errors"
+	"io"
+	"math"
+	"reflect"
+	"strconv"
+	"strings"
+)
+
+// marshalJSON is json.Marshal that tolerates NaN and ±Inf. encoding/json
+// refuses them outright, and one uncalibrated boom angle or invalid sensor
+// value would otherwise fail the whole response; such values are written as
+// null instead. Values that encode cleanly take the normal path.
+func marshalJSON(v interface{}) ([]byte, error) {
+	data, err := json.Marshal(v)
+	var unsupported *json.UnsupportedValueError
+	if err == nil || !errors.As(err, &unsupported) {
+		return data, err
+	}
+	return json.Marshal(jsonSafe(reflect.ValueOf(v)))
+}
+
+// encodeJSON writes v to w like json.NewEncoder(w).Encode, using
+// marshalJSON. Every handler encodes through it.
+func encodeJSON(w io.Writer, v interface{}) error {
+	data, err := marshalJSON(v)
+	if err != nil {
+		return err
+	}
+	_, err = w.Write(append(data, '\n'))
+	return err
+}
+
+var jsonMarshalerType = reflect.TypeOf((*json.Marshaler)(nil)).Elem()
+
+// jsonSafe rebuilds v as maps, slices and plain values that encode the same
+// way, with non-finite floats replaced by nil. Struct fields follow their
+// json tags; types with their own MarshalJSON are encoded as they are.
+func jsonSafe(v reflect.Value) interface{} {
+	if !v.IsValid() {
+		return nil
+	}
+	if v.Type().Implements(jsonMarshalerType) {
+		if (v.Kind() == reflect.Ptr || v.Kind() == reflect.Interface) && v.IsNil() {
+			return nil
+		}
+		if data, err := v.Interface().(json.Marshaler).MarshalJSON(); err == nil {
+			return json.RawMessage(data)
+		}
+		return nil
+	}
+
+	switch v.Kind() {
+	case reflect.Ptr, reflect.Interface:
+		if v.IsNil() {
+			return nil
+		}
+		return jsonSafe(v.Elem())
+
+	case reflect.Float32, reflect.Float64:
+		f := v.Float()
+		if math.IsNaN(f) || math.IsInf(f, 0) {
+			return nil
+		}
+		return f
+
+	case reflect.Struct:
+		out := make(map[string]interface{}, v.NumField())
+		jsonSafeFields(v, out)
+		return out
+
+	case reflect.Map:
+		if v.IsNil() {
+			return nil
+		}
+		out := make(map[string]interface{}, v.Len())
+		iter := v.MapRange()
+		for iter.Next() {
+			if name, ok := jsonMapKey(iter.Key()); ok {
+				out[name] = jsonSafe(iter.Value())
+			}
+		}
+		return out
+
+	case reflect.Slice, reflect.Array:
+		if v.Kind() == reflect.Slice {
+			if v.IsNil() {
+				return nil
+			}
+			if v.Type().Elem().Kind() == reflect.Uint8 {
+				return v.Interface() // base64, as encoding/json does
+			}
+		}
+		out := make([]interface{}, v.Len())
+		for i := range out {
+			out[i] = jsonSafe(v.Index(i))
+		}
+		return out
+	}
+
+	if v.CanInterface() {
+		return v.Interface()
+	}
+	return nil
+}
+
+// jsonSafeFields adds the exported fields of struct v to out under their
+// json names, flattening untagged embedded structs
+func jsonSafeFields(v reflect.Value, out map[string]interface{}) {
+	t := v.Type()
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		tag := field.Tag.Get("json")
+		if tag == "-" {
+			continue
+		}
+		name, opts, _ := strings.Cut(tag, ",")
+
+		fv := v.Field(i)
+		if field.Anonymous && name == "" {
+			ft := field.Type
+			if ft.Kind() == reflect.Ptr {
+				if fv.IsNil() {
+					continue
+				}
+				fv, ft = fv.Elem(), ft.Elem()
+			}
+			if ft.Kind() == reflect.Struct && !ft.Implements(jsonMarshalerType) {
+				jsonSafeFields(fv, out)
+				continue
+			}
+		}
+		if !field.IsExported() {
+			continue
+		}
+
+		if name == "" {
+			name = field.Name
+		}
+		if strings.Contains(","+opts+",", ",omitempty,") && isEmptyJSON(fv) {
+			continue
+		}
+		out[name] = jsonSafe(fv)
+	}
+}
+
+// jsonMapKey names a map key the way encoding/json does
+func jsonMapKey(k reflect.Value) (string, bool) {
+	if k.Kind() == reflect.String {
+		return k.String(), true
+	}
+	if tm, ok := k.Interface().(encoding.TextMarshaler); ok {
+		text, err := tm.MarshalText()
+		return string(text), err == nil
+	}
+	switch k.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return strconv.FormatInt(k.Int(), 10), true
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		return strconv.FormatUint(k.Uint(), 10), true
+	}
+	return "", false
+}
+
+// isEmptyJSON is encoding/json's omitempty test
+func isEmptyJSON(v reflect.Value) bool {
+	switch v.Kind() {
+	case reflect.Array, reflect.Map, reflect.Slice, reflect.String:
+		return v.Len() == 0
+	case reflect.Bool,
+		reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr,
+		reflect.Float32, reflect.Float64,
+		reflect.Interface, reflect.Ptr:
+		return v.IsZero()
+	}
+	return false
+}
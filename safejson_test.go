@@ -0,0 +1,75 @@
+package main
+
+import (
+	"bytes"
+	"math"
+	"testing"
+)
+
+type safeJSONInner struct {
+	X float64 `json:"x"`
+}
+
+type safeJSONOuter struct {
+	safeJSONInner
+	A    float64   `json:"a"`
+	P    *float64  `json:"p"`
+	O    *float64  `json:"o,omitempty"`
+	S    []float64 `json:"s"`
+	Skip float64   `json:"-"`
+	hid  float64
+}
+
+func TestMarshalJSONNonFinite(t *testing.T) {
+	nan, inf, ninf := math.NaN(), math.Inf(1), math.Inf(-1)
+
+	tests := []struct {
+		name string
+		v    interface{}
+		want string
+	}{
+		{"finite", map[string]float64{"a": 1.5}, `{"a":1.5}`},
+		{"NaN", nan, `null`},
+		{"+Inf", inf, `null`},
+		{"-Inf", ninf, `null`},
+		{"float32 NaN", float32(nan), `null`},
+		{"map", map[string]float64{"a": nan, "b": 2}, `{"a":null,"b":2}`},
+		{"int keys", map[int]float64{3: inf}, `{"3":null}`},
+		{"slice", []float64{1, nan, inf, ninf}, `[1,null,null,null]`},
+		{"array", [2]float64{ninf, 0}, `[null,0]`},
+		{"nested", map[string]interface{}{
+			"m": map[string]interface{}{"x": []interface{}{nan, map[string]float64{"y": ninf}}},
+			"n": "ok",
+		}, `{"m":{"x":[null,{"y":null}]},"n":"ok"}`},
+		{"struct", safeJSONOuter{
+			safeJSONInner: safeJSONInner{X: inf},
+			A:             nan,
+			P:             &ninf,
+			S:             []float64{nan, 1},
+			Skip:          nan,
+			hid:           nan,
+		}, `{"a":null,"p":null,"s":[null,1],"x":null}`},
+		{"struct pointer in slice", []*safeJSONOuter{{A: 1, S: []float64{}}, {A: nan}, nil},
+			`[{"a":1,"p":null,"s":[],"x":0},{"a":null,"p":null,"s":null,"x":0},null]`},
+	}
+	for _, tt := range tests {
+		got, err := marshalJSON(tt.v)
+		if err != nil {
+			t.Errorf("%s: %v", tt.name, err)
+			continue
+		}
+		if string(got) != tt.want {
+			t.Errorf("%s: got %s, want %s", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestEncodeJSONNonFinite(t *testing.T) {
+	var buf bytes.Buffer
+	if err := encodeJSON(&buf, map[string]interface{}{"boom_rel_deg": math.NaN()}); err != nil {
+		t.Fatal(err)
+	}
+	if got, want := buf.String(), "{\"boom_rel_deg\":null}\n"; got != want {
+		t.Errorf("encodeJSON wrote %q, want %q", got, want)
+	}
+}
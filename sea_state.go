@@ -0,0 +1,140 @@
+package integration
+
+import (
+	"math"
+	"sort"
+	"time"
+)
+
+// Sea state classes
+const (
+	SeaFlat     = "flat"
+	SeaModerate = "moderate"
+	SeaRough    = "rough"
+)
+
+// seaStateMinSamples is how many attitude samples the window needs before a
+// class is given, so one frame after startup does not read as flat water
+const seaStateMinSamples = 10
+
+// SeaState summarises boat motion over a rolling window. Roll and pitch RMS
+// are taken about a moving average over SeaStateSmoothing, so a steady heel
+// on a beat, or the change of heel through a tack, does not count as
+// motion.
+type SeaState struct {
+	WindowSeconds float64  `json:"window_seconds"`
+	Samples       int      `json:"samples"`
+	RollRMSDeg    float64  `json:"roll_rms_deg"`
+	PitchRMSDeg   float64  `json:"pitch_rms_deg"`
+	HeaveRMSM     *float64 `json:"heave_rms_m"` // null without PGN 127252
+	Class         string   `json:"class"`
+}
+
+// SeaState computes roll and pitch RMS from the PGN 127257 messages in the
+// last SeaStateWindow, and heave RMS from PGN 127252 when a heave sensor is
+// on the bus. The class comes from the larger of roll and pitch RMS against
+// SeaModerateRMSDeg and SeaRoughRMSDeg. ok is false until the window holds
+// seaStateMinSamples attitude samples.
+func (m *BoomSenseMapper) SeaState() (SeaState, bool) {
+	state := SeaState{WindowSeconds: m.SeaStateWindow.Seconds()}
+
+	end := time.Now()
+	start := end.Add(-m.SeaStateWindow)
+
+	msgs := m.buffer.GetByPGNTimeRange(127257, start, end)
+	sort.SliceStable(msgs, func(i, j int) bool { return msgs[i].Timestamp.Before(msgs[j].Timestamp) })
+
+	var times []time.Time
+	var rolls, pitches []float64
+	for _, msg := range msgs {
+		r, rollOK := msg.Fields["roll_deg"].(float64)
+		p, pitchOK := msg.Fields["pitch_deg"].(float64)
+		if !rollOK || !pitchOK || math.IsNaN(r) || math.IsNaN(p) {
+			continue
+		}
+		times = append(times, msg.Timestamp)
+		rolls = append(rolls, r)
+		pitches = append(pitches, p)
+	}
+
+	state.Samples = len(times)
+	if state.Samples < seaStateMinSamples {
+		return state, false
+	}
+	var roll, pitch rmsAccumulator
+	for _, v := range highPass(times, rolls, m.SeaStateSmoothing) {
+		roll.add(v)
+	}
+	for _, v := range highPass(times, pitches, m.SeaStateSmoothing) {
+		pitch.add(v)
+	}
+	state.RollRMSDeg = roll.rms()
+	state.PitchRMSDeg = pitch.rms()
+
+	var heave rmsAccumulator
+	for _, msg := range m.buffer.GetByPGNTimeRange(127252, start, end) {
+		if h, ok := msg.Fields["heave_m"].(float64); ok {
+			heave.add(h)
+		}
+	}
+	if heave.n >= seaStateMinSamples {
+		rms := heave.rms()
+		state.HeaveRMSM = &rms
+	}
+
+	motion := math.Max(state.RollRMSDeg, state.PitchRMSDeg)
+	switch {
+	case motion >= m.SeaRoughRMSDeg:
+		state.Class = SeaRough
+	case motion >= m.SeaModerateRMSDeg:
+		state.Class = SeaModerate
+	default:
+		state.Class = SeaFlat
+	}
+	return state, true
+}
+
+// highPass returns each value's deviation from the mean of the values within
+// half of span either side of it, removing swings slower than span (times
+// must be in order)
+func highPass(times []time.Time, values []float64, span time.Duration) []float64 {
+	half := span / 2
+	out := make([]float64, len(values))
+	lo, hi := 0, 0
+	sum := 0.0
+	for i, t := range times {
+		for hi < len(times) && !times[hi].After(t.Add(half)) {
+			sum += values[hi]
+			hi++
+		}
+		for times[lo].Before(t.Add(-half)) {
+			sum -= values[lo]
+			lo++
+		}
+		out[i] = values[i] - sum/float64(hi-lo)
+	}
+	return out
+}
+
+// rmsAccumulator gathers the RMS deviation from the mean of a series
+type rmsAccumulator struct {
+	n          int
+	sum, sumSq float64
+}
+
+func (a *rmsAccumulator) add(v float64) {
+	if math.IsNaN(v) {
+		return
+	}
+	a.n++
+	a.sum += v
+	a.sumSq += v * v
+}
+
+func (a *rmsAccumulator) rms() float64 {
+	if a.n == 0 {
+		return 0
+	}
+	mean := a.sum / float64(a.n)
+	return math.Sqrt(math.Max(0, a.sumSq/float64(a.n)-mean*mean))
+}
@@ -0,0 +1,54 @@
+package main
+
+import (
+	"log"
+	"time"
+
+	"odysail-boat-viz/boomsense_sensor"
+)
+
+// seaStateCheckInterval is how often the sea state is reclassified; the
+// window is a minute, so faster checks would only repeat the answer
+const seaStateCheckInterval = 5 * time.Second
+
+// startSeaStateMonitor reclassifies the sea state every
+// seaStateCheckInterval and sets sensor's detector gyro scale from
+// gyroScales by class (classes not listed, and no classification, run at
+// 1). The returned function stops it.
+func startSeaStateMonitor(sensor *boomsense_sensor.Sensor, gyroScales map[string]float64) func() {
+	done := make(chan struct{})
+	go func() {
+		ticker := time.NewTicker(seaStateCheckInterval)
+		defer ticker.Stop()
+
+		class := ""
+		for {
+			select {
+			case <-ticker.C:
+				state, ok := boomMapper.SeaState()
+				if !ok {
+					state.Class = ""
+				}
+				if state.Class == class {
+					continue
+				}
+				class = state.Class
+
+				scale, listed := gyroScales[class]
+				if !listed {
+					scale = 1
+				}
+				sensor.SetDetectorGyroScale(scale)
+				if ok {
+					log.Printf("[BoomSense] Sea state %s (roll RMS %.1f°, pitch RMS %.1f°): gyro thresholds x%.2f",
+						class, state.RollRMSDeg, state.PitchRMSDeg, scale)
+				} else {
+					log.Printf("[BoomSense] Sea state unknown: gyro thresholds x%.2f", scale)
+				}
+			case <-done:
+				return
+			}
+		}
+	}()
+	return func() { close(done) }
+}
@@ -0,0 +1,69 @@
+package integration
+
+import (
+	"math"
+	"testing"
+	"time"
+
+	"odysail-boat-viz/storage"
+)
+
+// pushAttitude fills buf with 59s of 10 Hz attitude, roll from roll(t) with
+// t in seconds from the start
+func pushAttitude(buf *storage.RingBuffer, end time.Time, roll func(t float64) float64) {
+	for i := 0; i < 590; i++ {
+		t := float64(i) / 10
+		buf.Push(storage.DecodedMessage{
+			Timestamp: end.Add(time.Duration((t - 59) * float64(time.Second))),
+			PGN:       127257,
+			Fields:    map[string]interface{}{"roll_deg": roll(t), "pitch_deg": 1.0},
+		})
+	}
+}
+
+func TestSeaStateClasses(t *testing.T) {
+	tests := []struct {
+		name string
+		roll func(t float64) float64
+		want string
+	}{
+		{"steady heel", func(t float64) float64 { return 18 }, SeaFlat},
+		{"tack", func(t float64) float64 {
+			// 15° to starboard, through the wind over 10s, 15° to port
+			switch {
+			case t < 25:
+				return 15
+			case t < 35:
+				return 15 - 3*(t-25)
+			default:
+				return -15
+			}
+		}, SeaFlat},
+		{"moderate waves", func(t float64) float64 { return 15 + 4*math.Sin(2*math.Pi*t/5) }, SeaModerate},
+		{"rough waves", func(t float64) float64 { return 15 + 10*math.Sin(2*math.Pi*t/5) }, SeaRough},
+	}
+
+	for _, tt := range tests {
+		buf := storage.NewRingBuffer(1000)
+		m := NewBoomSenseMapper(buf)
+		pushAttitude(buf, time.Now(), tt.roll)
+
+		state, ok := m.SeaState()
+		if !ok {
+			t.Errorf("%s: no sea state from %d samples", tt.name, state.Samples)
+			continue
+		}
+		if state.Class != tt.want {
+			t.Errorf("%s: class %s (roll RMS %.2f°), want %s", tt.name, state.Class, state.RollRMSDeg, tt.want)
+		}
+	}
+}
+
+func TestSeaStateTooFewSamples(t *testing.T) {
+	buf := storage.NewRingBuffer(100)
+	m := NewBoomSenseMapper(buf)
+	buf.Push(storage.DecodedMessage{Timestamp: time.Now(), PGN: 127257, Fields: map[string]interface{}{"roll_deg": 5.0, "pitch_deg": 1.0}})
+	if state, ok := m.SeaState(); ok {
+		t.Errorf("sea state %+v from one sample", state)
+	}
+}
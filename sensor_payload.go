@@ -0,0 +1,120 @@
+package nmea
+
+import (
+	"strings"
+	"time"
+
+	"odysail-boat-viz/boomsense_sensor"
+)
+
+// SensorSink receives the BoomSense readings that share the MQTT connection
+// with the N2K gateway. *boomsense_sensor.Sensor satisfies it.
+type SensorSink interface {
+	ProcessIMU(reading boomsense_sensor.IMUReading) boomsense_sensor.FilteredData
+	ProcessMeteo(reading boomsense_sensor.MeteoReading)
+	ProcessWind(reading boomsense_sensor.WindReading)
+}
+
+// Sensor payload kinds, named after the topic suffix they arrive on
+const (
+	sensorPayloadNone  = ""
+	sensorPayloadIMU   = "imu"
+	sensorPayloadMeteo = "meteo"
+	sensorPayloadWind  = "wind"
+)
+
+// sensorPayloadKind classifies a message. Payloads carrying N2K "pgn" or
+// "data" are frames whatever the topic; the rest are classified by topic
+// suffix (…/imu, …/meteo, …/wind), and on other topics count as IMU
+// readings when they carry accelerometer and gyro keys.
+func sensorPayloadKind(topic string, payload map[string]interface{}) string {
+	if _, ok := payload["data"]; ok {
+		return sensorPayloadNone
+	}
+	if _, ok := payload["pgn"]; ok {
+		return sensorPayloadNone
+	}
+
+	switch topic[strings.LastIndex(topic, "/")+1:] {
+	case sensorPayloadIMU:
+		return sensorPayloadIMU
+	case sensorPayloadMeteo:
+		return sensorPayloadMeteo
+	case sensorPayloadWind:
+		return sensorPayloadWind
+	}
+
+	_, hasAccel := payload["ax"].(float64)
+	_, hasGyro := payload["gx"].(float64)
+	if hasAccel && hasGyro {
+		return sensorPayloadIMU
+	}
+	return sensorPayloadNone
+}
+
+// imuAxes are the keys an IMU payload must carry; a missing axis would
+// reach the filter as a zero reading
+var imuAxes = []string{"ax", "ay", "az", "gx", "gy", "gz"}
+
+// routeSensorPayload hands a BoomSense reading to the sink, reporting false
+// for an IMU payload missing one of imuAxes
+func routeSensorPayload(sink SensorSink, kind string, payload map[string]interface{}) bool {
+	ts := payloadTime(payload)
+
+	switch kind {
+	case sensorPayloadIMU:
+		for _, axis := range imuAxes {
+			if _, ok := payload[axis].(float64); !ok {
+				return false
+			}
+		}
+		sink.ProcessIMU(boomsense_sensor.IMUReading{
+			Timestamp: ts,
+			AccelX:    payloadFloat(payload, "ax"),
+			AccelY:    payloadFloat(payload, "ay"),
+			AccelZ:    payloadFloat(payload, "az"),
+			GyroX:     payloadFloat(payload, "gx"),
+			GyroY:     payloadFloat(payload, "gy"),
+			GyroZ:     payloadFloat(payload, "gz"),
+			MagX:      payloadFloat(payload, "mx"),
+			MagY:      payloadFloat(payload, "my"),
+			MagZ:      payloadFloat(payload, "mz"),
+		})
+	case sensorPayloadMeteo:
+		sink.ProcessMeteo(boomsense_sensor.MeteoReading{
+			Timestamp:   ts,
+			TempC:       payloadFloat(payload, "temp_c", "temp"),
+			PressureHpa: payloadFloat(payload, "pressure_hpa", "pressure"),
+			HumidityPct: payloadFloat(payload, "humidity_pct", "humidity"),
+		})
+	case sensorPayloadWind:
+		sink.ProcessWind(boomsense_sensor.WindReading{
+			Timestamp: ts,
+			SpeedKts:  payloadFloat(payload, "speed_kts", "speed"),
+			AngleDeg:  payloadFloat(payload, "angle_deg", "angle"),
+		})
+	}
+	return true
+}
+
+// payloadTime reads the publisher's millisecond timestamp ("ts" or
+// "timestamp"), falling back to the arrival time
+func payloadTime(payload map[string]interface{}) time.Time {
+	if ts, ok := payload["ts"].(float64); ok {
+		return time.Unix(0, int64(ts)*1e6)
+	}
+	if ts, ok := payload["timestamp"].(float64); ok {
+		return time.Unix(0, int64(ts)*1e6)
+	}
+	return time.Now()
+}
+
+// payloadFloat returns the first of keys present as a number, or 0
+func payloadFloat(payload map[string]interface{}, keys ...string) float64 {
+	for _, key := range keys {
+		if v, ok := payload[key].(float64); ok {
+			return v
+		}
+	}
+	return 0
+}
@@ -0,0 +1,81 @@
+package nmea
+
+import (
+	"testing"
+
+	"odysail-boat-viz/boomsense_sensor"
+)
+
+// countingSink records what routeSensorPayload hands to the sensor
+type countingSink struct {
+	imu, meteo, wind int
+	last             boomsense_sensor.IMUReading
+}
+
+func (s *countingSink) ProcessIMU(r boomsense_sensor.IMUReading) boomsense_sensor.FilteredData {
+	s.imu++
+	s.last = r
+	return boomsense_sensor.FilteredData{}
+}
+
+func (s *countingSink) ProcessMeteo(boomsense_sensor.MeteoReading) { s.meteo++ }
+func (s *countingSink) ProcessWind(boomsense_sensor.WindReading)   { s.wind++ }
+
+var _ SensorSink = (*boomsense_sensor.Sensor)(nil)
+
+func TestSensorPayloadKind(t *testing.T) {
+	imu := map[string]interface{}{"ax": 0.1, "ay": 0.0, "az": 1.0, "gx": 2.0, "gy": 0.0, "gz": 3.0}
+	frame := map[string]interface{}{"pgn": 130306.0, "data": "00d002ae1efaffff"}
+
+	tests := []struct {
+		topic   string
+		payload map[string]interface{}
+		want    string
+	}{
+		{"boats/x/imu", imu, sensorPayloadIMU},
+		{"boats/x/raw", imu, sensorPayloadIMU},
+		{"boats/x/meteo", map[string]interface{}{"temp": 20.0}, sensorPayloadMeteo},
+		{"boats/x/wind", map[string]interface{}{"speed": 8.0}, sensorPayloadWind},
+		{"boats/x/n2k", frame, sensorPayloadNone},
+		// N2K frames keep their path whatever the topic suffix
+		{"boats/x/wind", frame, sensorPayloadNone},
+		{"boats/x/imu", map[string]interface{}{"data": "00"}, sensorPayloadNone},
+		{"boats/x/raw", map[string]interface{}{"ax": 0.1}, sensorPayloadNone},
+	}
+	for _, tt := range tests {
+		if got := sensorPayloadKind(tt.topic, tt.payload); got != tt.want {
+			t.Errorf("sensorPayloadKind(%q, %v) = %q, want %q", tt.topic, tt.payload, got, tt.want)
+		}
+	}
+}
+
+func TestRouteSensorPayload(t *testing.T) {
+	sink := &countingSink{}
+
+	full := map[string]interface{}{"ax": 0.1, "ay": 0.0, "az": 1.0, "gx": 2.0, "gy": 0.0, "gz": 3.0, "ts": 1000.0}
+	if !routeSensorPayload(sink, sensorPayloadIMU, full) {
+		t.Fatal("complete IMU payload rejected")
+	}
+	if sink.imu != 1 || sink.last.GyroZ != 3 || sink.last.Timestamp.UnixMilli() != 1000 {
+		t.Fatalf("IMU reading = %+v", sink.last)
+	}
+
+	for _, axis := range imuAxes {
+		partial := make(map[string]interface{}, len(full))
+		for k, v := range full {
+			if k != axis {
+				partial[k] = v
+			}
+		}
+		if routeSensorPayload(sink, sensorPayloadIMU, partial) {
+			t.Errorf("IMU payload without %s accepted", axis)
+		}
+	}
+	if sink.imu != 1 {
+		t.Errorf("incomplete payloads reached the sensor: %d readings", sink.imu)
+	}
+
+	if !routeSensorPayload(sink, sensorPayloadWind, map[string]interface{}{"speed": 3.0}) || sink.wind != 1 {
+		t.Error("wind reading not routed")
+	}
+}
@@ -0,0 +1,54 @@
+package integration
+
+import "fmt"
+
+// Speed log cross-check tuning
+const (
+	// logCheckMinSOGKts is the SOG below which the log is not judged;
+	// paddlewheels stall and GPS speed is noisy when barely moving
+	logCheckMinSOGKts = 2.0
+
+	// logCheckCurrentKts is the SOG excess put down to current before the
+	// log is blamed
+	logCheckCurrentKts = 1.0
+)
+
+// SpeedLogCheck compares speed through the water (PGN 128259) with speed
+// over ground (PGN 129026)
+type SpeedLogCheck struct {
+	WaterSpeed     float64 `json:"water_speed_kts"`
+	SOG            float64 `json:"sog_kts"`
+	DiscrepancyKts float64 `json:"discrepancy_kts"` // SOG minus water speed
+	Suspect        bool    `json:"log_suspect"`
+	Message        string  `json:"message,omitempty"`
+}
+
+// CheckSpeedLog flags a paddlewheel that is likely fouled or jammed: SOG of
+// at least logCheckMinSOGKts and more than LogSuspectFactor times the water
+// speed, by a margin current alone is not expected to explain. Boat speed
+// already prefers SOG, so a suspect log is reported rather than replaced.
+// ok is false unless both speeds are fresh.
+func (m *BoomSenseMapper) CheckSpeedLog() (SpeedLogCheck, bool) {
+	var check SpeedLogCheck
+
+	sogMsg, waterMsg := m.fresh(129026), m.fresh(128259)
+	if sogMsg == nil || waterMsg == nil {
+		return check, false
+	}
+	sog, sogOK := knots(sogMsg.Fields, "sog")
+	water, waterOK := knots(waterMsg.Fields, "water_speed")
+	if !sogOK || !waterOK {
+		return check, false
+	}
+
+	check.SOG = sog
+	check.WaterSpeed = water
+	check.DiscrepancyKts = sog - water
+
+	if m.LogSuspectFactor > 0 && sog >= logCheckMinSOGKts &&
+		sog-water > logCheckCurrentKts && sog > water*m.LogSuspectFactor {
+		check.Suspect = true
+		check.Message = fmt.Sprintf("Log suspect: water speed %.1f kts against SOG %.1f kts, using SOG", water, sog)
+	}
+	return check, true
+}
@@ -0,0 +1,149 @@
+package nmea
+
+import (
+	"bytes"
+	"encoding/hex"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Frame payload formats selectable through Config.FrameFormat
+const (
+	FrameFormatAuto      = "auto"      // detect per message
+	FrameFormatJSON      = "json"      // ESP32 JSON object
+	FrameFormatActisense = "actisense" // Actisense N2K ASCII lines
+	FrameFormatYDWG      = "ydwg"      // Yacht Devices YDWG-02 RAW lines
+)
+
+// detectFrameFormat guesses the format of one MQTT payload
+func detectFrameFormat(payload []byte) string {
+	trimmed := bytes.TrimSpace(payload)
+	switch {
+	case len(trimmed) == 0:
+		return FrameFormatJSON
+	case trimmed[0] == '{':
+		return FrameFormatJSON
+	case trimmed[0] == 'A' && len(trimmed) > 1 && trimmed[1] >= '0' && trimmed[1] <= '9':
+		return FrameFormatActisense
+	case len(trimmed) > 2 && trimmed[2] == ':':
+		return FrameFormatYDWG
+	}
+	return FrameFormatJSON
+}
+
+// isJSONPayload reports whether payload is a JSON object. BoomSense
+// readings are published as JSON whatever format the N2K gateway speaks.
+func isJSONPayload(payload []byte) bool {
+	trimmed := bytes.TrimSpace(payload)
+	return len(trimmed) > 0 && trimmed[0] == '{'
+}
+
+// parseTextFrames parses every line of a text payload in the given format.
+// Bad lines are skipped and counted in failed; the first error is returned
+// with the good frames. Both formats carry only a time of day, so frames
+// are stamped on arrival.
+func parseTextFrames(format, topic string, payload []byte, now time.Time) (frames []RawFrame, failed int, firstErr error) {
+
+	for _, line := range strings.Split(string(payload), "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" {
+			continue
+		}
+
+		var frame RawFrame
+		var err error
+		switch format {
+		case FrameFormatActisense:
+			frame, err = parseActisenseLine(line)
+		case FrameFormatYDWG:
+			frame, err = parseYDWGLine(line)
+		default:
+			err = fmt.Errorf("unsupported frame format %q", format)
+		}
+		if err != nil {
+			failed++
+			if firstErr == nil {
+				firstErr = err
+			}
+			continue
+		}
+
+		frame.Timestamp = now
+		frame.Topic = topic
+		frames = append(frames, frame)
+	}
+
+	return frames, failed, firstErr
+}
+
+// parseActisenseLine parses "A173321.107 23FF7 1F513 012F3070002F30709F":
+// time, then source/destination/priority packed as SSDDP, then the PGN and
+// the payload in hex.
+func parseActisenseLine(line string) (RawFrame, error) {
+	var frame RawFrame
+
+	parts := strings.Fields(line)
+	if len(parts) < 4 || !strings.HasPrefix(parts[0], "A") || len(parts[1]) != 5 {
+		return frame, fmt.Errorf("malformed actisense line %q", line)
+	}
+
+	addr, err := strconv.ParseUint(parts[1], 16, 32)
+	if err != nil {
+		return frame, fmt.Errorf("bad actisense address %q", parts[1])
+	}
+	pgn, err := strconv.ParseUint(parts[2], 16, 32)
+	if err != nil {
+		return frame, fmt.Errorf("bad actisense PGN %q", parts[2])
+	}
+	data, err := hex.DecodeString(strings.Join(parts[3:], ""))
+	if err != nil || len(data) == 0 {
+		return frame, fmt.Errorf("bad actisense data in %q", line)
+	}
+
+	frame.Source = uint8(addr >> 12)
+	frame.Dest = uint8(addr >> 4)
+	frame.Priority = uint8(addr & 0x0F)
+	frame.PGN = int(pgn)
+	frame.DP = uint8(pgn>>16) & 0x01
+	frame.PF = uint8(pgn >> 8)
+	frame.PS = uint8(pgn)
+	if frame.PF < 240 {
+		// PDU1: the destination travels in the PS byte of the CAN ID
+		frame.PS = frame.Dest
+	}
+	frame.ID = uint32(frame.Priority)<<26 | uint32(frame.DP)<<24 |
+		uint32(frame.PF)<<16 | uint32(frame.PS)<<8 | uint32(frame.Source)
+	frame.Data = data
+	frame.Length = len(data)
+	return frame, nil
+}
+
+// parseYDWGLine parses "17:33:21.107 R 19F51323 01 2F 30 70 00 2F 30 70":
+// time, direction, the 29-bit CAN ID and up to 8 data bytes. These are
+// single CAN frames; fast-packet PGNs arrive as their individual fragments,
+// for a fastPacketAssembler to put back together.
+func parseYDWGLine(line string) (RawFrame, error) {
+	var frame RawFrame
+
+	parts := strings.Fields(line)
+	if len(parts) < 4 || (parts[1] != "R" && parts[1] != "T") {
+		return frame, fmt.Errorf("malformed ydwg line %q", line)
+	}
+
+	id, err := strconv.ParseUint(parts[2], 16, 32)
+	if err != nil {
+		return frame, fmt.Errorf("bad ydwg CAN id %q", parts[2])
+	}
+	data, err := hex.DecodeString(strings.Join(parts[3:], ""))
+	if err != nil || len(data) == 0 || len(data) > 8 {
+		return frame, fmt.Errorf("bad ydwg data in %q", line)
+	}
+
+	frame.ID = uint32(id)
+	frame.Priority, frame.DP, frame.PF, frame.PS, frame.Source, frame.Dest, frame.PGN = ParseCANID(frame.ID)
+	frame.Data = data
+	frame.Length = len(data)
+	return frame, nil
+}
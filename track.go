@@ -0,0 +1,131 @@
+package main
+
+import (
+	"math"
+	"net/http"
+	"sort"
+	"strconv"
+	"time"
+
+	"odysail-boat-viz/integration"
+)
+
+// trackMaxSpan bounds /api/track requests; the ring buffer rarely holds
+// more than this anyway
+const trackMaxSpan = 24 * time.Hour
+
+// trackPoint is one vessel fix; precise marks PGN 129029 (GNSS Position
+// Data), which carries 1e-16° resolution against 1e-7° for 129025
+type trackPoint struct {
+	t        time.Time
+	lat, lon float64
+	precise  bool
+}
+
+// handleTrack returns the vessel track over ?span (default 1h) as a GeoJSON
+// LineString feature. Fixes are reduced to one per ?bucket (default 1s),
+// preferring 129029 over 129025, and ?min_dist_m drops fixes closer than
+// that to the previously kept one.
+func handleTrack(w http.ResponseWriter, r *http.Request) {
+	if nmeaCollector == nil {
+		http.Error(w, "NMEA collector not running", http.StatusServiceUnavailable)
+		return
+	}
+
+	q := r.URL.Query()
+	var err error
+	span, bucket, minDist := time.Hour, time.Second, 0.0
+	if v := q.Get("span"); v != "" {
+		if span, err = time.ParseDuration(v); err != nil || span <= 0 || span > trackMaxSpan {
+			http.Error(w, "invalid span", http.StatusBadRequest)
+			return
+		}
+	}
+	if v := q.Get("bucket"); v != "" {
+		if bucket, err = time.ParseDuration(v); err != nil || bucket <= 0 {
+			http.Error(w, "invalid bucket", http.StatusBadRequest)
+			return
+		}
+	}
+	if v := q.Get("min_dist_m"); v != "" {
+		if minDist, err = strconv.ParseFloat(v, 64); err != nil || minDist < 0 || math.IsNaN(minDist) {
+			http.Error(w, "invalid min_dist_m", http.StatusBadRequest)
+			return
+		}
+	}
+
+	end := time.Now()
+	start := end.Add(-span)
+	buf := nmeaCollector.Buffer()
+
+	buckets := make(map[int64]trackPoint)
+	for _, src := range []struct {
+		pgn     int
+		precise bool
+	}{{129029, true}, {129025, false}} {
+		for _, msg := range buf.GetByPGNTimeRange(src.pgn, start, end) {
+			lat, okLat := numericField(msg.Fields["latitude"])
+			lon, okLon := numericField(msg.Fields["longitude"])
+			if !okLat || !okLon || math.Abs(lat) > 90 || math.Abs(lon) > 180 {
+				continue
+			}
+
+			i := int64(msg.Timestamp.Sub(start) / bucket)
+			if prev, ok := buckets[i]; ok && prev.precise && !src.precise {
+				continue
+			}
+			buckets[i] = trackPoint{t: msg.Timestamp, lat: lat, lon: lon, precise: src.precise}
+		}
+	}
+
+	keys := make([]int64, 0, len(buckets))
+	for k := range buckets {
+		keys = append(keys, k)
+	}
+	sort.Slice(keys, func(a, b int) bool { return keys[a] < keys[b] })
+
+	points := make([]trackPoint, 0, len(keys))
+	for _, k := range keys {
+		points = append(points, buckets[k])
+	}
+	points = decimateTrack(points, minDist)
+
+	coords := make([][2]float64, len(points))
+	times := make([]int64, len(points))
+	for i, p := range points {
+		coords[i] = [2]float64{p.lon, p.lat} // GeoJSON order
+		times[i] = p.t.UnixMilli()
+	}
+
+	w.Header().Set("Content-Type", "application/geo+json")
+	encodeJSON(w, map[string]interface{}{
+		"type": "Feature",
+		"geometry": map[string]interface{}{
+			"type":        "LineString",
+			"coordinates": coords,
+		},
+		"properties": map[string]interface{}{
+			"start":  start.UnixMilli(),
+			"end":    end.UnixMilli(),
+			"points": len(points),
+			"times":  times,
+		},
+	})
+}
+
+// decimateTrack keeps the first and last fix and every fix at least minDist
+// metres from the last one kept
+func decimateTrack(points []trackPoint, minDist float64) []trackPoint {
+	if minDist <= 0 || len(points) < 3 {
+		return points
+	}
+
+	out := []trackPoint{points[0]}
+	for _, p := range points[1 : len(points)-1] {
+		last := out[len(out)-1]
+		if integration.DistanceM(last.lat, last.lon, p.lat, p.lon) >= minDist {
+			out = append(out, p)
+		}
+	}
+	return append(out, points[len(points)-1])
+}
@@ -1,135 +1,551 @@
-package nmea
-
-import (
-	"sync"
-	"time"
-)
-
-// RawFrame represents an unparsed NMEA2000 CAN frame
-type RawFrame struct {
-	Timestamp time.Time
-	Topic     string
-	ID        uint32
-	Priority  uint8
-	DP        uint8
-	PF        uint8
-	PS        uint8
-	Source    uint8
-	Dest      uint8
-	PGN       int
-	Length    int
-	Data      []byte
-}
-
-// DecodedMessage represents a fully decoded NMEA2000 message
-type DecodedMessage struct {
-	Timestamp   time.Time
-	PGN         int
-	PGNName     string
-	Source      uint8
-	Measurement string
-	Fields      map[string]interface{}
-	Raw         []byte
-}
-
-// Statistics tracks collector performance metrics
-type Statistics struct {
-	mu                sync.RWMutex
-	MessagesProcessed int64
-	DecodeSuccesses   int64
-	DecodeFailures    int64
-	PGNCounts         map[int]int64
-	MeasurementCounts map[string]int64
-	LastUpdate        time.Time
-	StartTime         time.Time
-}
-
-func NewStatistics() *Statistics {
-	return &Statistics{
-		PGNCounts:         make(map[int]int64),
-		MeasurementCounts: make(map[string]int64),
-		StartTime:         time.Now(),
-		LastUpdate:        time.Now(),
-	}
-}
-
-func (s *Statistics) RecordMessage(pgn int, measurement string, success bool) {
-	s.mu.Lock()
-	defer s.mu.Unlock()
-
-	s.MessagesProcessed++
-	if success {
-		s.DecodeSuccesses++
-	} else {
-		s.DecodeFailures++
-	}
-
-	s.PGNCounts[pgn]++
-	s.MeasurementCounts[measurement]++
-	s.LastUpdate = time.Now()
-}
-
-func (s *Statistics) GetSnapshot() map[string]interface{} {
-	s.mu.RLock()
-	defer s.mu.RUnlock()
-
-	successRate := 0.0
-	if s.MessagesProcessed > 0 {
-		successRate = float64(s.DecodeSuccesses) / float64(s.MessagesProcessed) * 100.0
-	}
-
-	uptime := time.Since(s.StartTime)
-	msgPerSec := 0.0
-	if uptime.Seconds() > 0 {
-		msgPerSec = float64(s.MessagesProcessed) / uptime.Seconds()
-	}
-
-	return map[string]interface{}{
-		"messages_processed": s.MessagesProcessed,
-		"decode_successes":   s.DecodeSuccesses,
-		"decode_failures":    s.DecodeFailures,
-		"success_rate":       successRate,
-		"uptime_seconds":     uptime.Seconds(),
-		"messages_per_sec":   msgPerSec,
-		"last_update":        s.LastUpdate,
-	}
-}
-
-// Config holds NMEA collector configuration
-type Config struct {
-	MQTTBroker       string
-	MQTTPort         int
-	MQTTUsername     string
-	MQTTPassword     string
-	MQTTTopic        string
-	UseTLS           bool
-	InsecureSkipTLS  bool
-	DeviceID         string
-	BufferSize       int
-	DecoderWorkers   int
-	QueueSize        int
-	EnableCSV        bool
-	CSVFramesPath    string
-	CSVDecodedPath   string
-	CSVStatsPath     string
-}
-
-func DefaultConfig() Config {
-	return Config{
-		MQTTBroker:      "02c55b5f93704f9eb9883f5c7bc98e8c.s1.eu.hivemq.cloud",
-		MQTTPort:        8883,
-		MQTTUsername:    "esp32",
-		MQTTPassword:    "Pourquoi312",
-		MQTTTopic:       "boats/esp32s3-dev01/#",
-		UseTLS:          true,
-		InsecureSkipTLS: false,
-		DeviceID:        "esp32s3-dev01",
-		BufferSize:      86400,
-		DecoderWorkers:  4,
-		QueueSize:       1000,
-		EnableCSV:       true,
-		CSVFramesPath:   "data/frames.csv",
-		CSVDecodedPath:  "data/decoded_long.csv",
-		CSVStatsPath:    "data/decode_stats.csv",
-	}
+package nmea
+
+import (
+	"encoding/json"
+	"math"
+	"runtime"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// RawFrame represents an unparsed NMEA2000 CAN frame
+type RawFrame struct {
+	Timestamp time.Time
+	Topic     string
+	ID        uint32
+	Priority  uint8
+	DP        uint8
+	PF        uint8
+	PS        uint8
+	Source    uint8
+	Dest      uint8
+	PGN       int
+	Length    int
+	Data      []byte
+}
+
+// DecodedMessage represents a fully decoded NMEA2000 message
+type DecodedMessage struct {
+	Timestamp   time.Time
+	PGN         int
+	PGNName     string
+	Source      uint8
+	Measurement string
+	Fields      map[string]interface{}
+	Raw         []byte
+
+	// Frame header, kept for the raw frame log
+	CANID    uint32
+	Priority uint8
+	Dest     uint8
+}
+
+// Statistics tracks collector performance metrics
+type Statistics struct {
+	mu                sync.RWMutex
+	MessagesProcessed int64
+	DecodeSuccesses   int64
+	DecodeFailures    int64
+	EmptyFrames       int64
+	FramesDropped     int64 // raw frames lost to a full decode queue
+	DecodedDropped    int64 // decoded messages lost to a full storage queue
+	PreferentialDrops int64 // of those, chosen to keep room for critical PGNs
+	SensorRejected    int64 // BoomSense IMU payloads missing an axis
+	TextFrameErrors   int64 // Actisense/YDWG lines that failed to parse
+	FastPacketDropped int64 // YDWG fast-packet messages lost to missing fragments
+	PGNCounts         map[int]int64
+	PGNFailures       map[int]int64 // decode failures per PGN, out of PGNCounts
+	PGNLastSeen       map[int]time.Time
+	MeasurementCounts map[string]int64
+	LastUpdate        time.Time
+	StartTime         time.Time
+
+	pgnRates map[int]*rateWindow
+}
+
+// PGNRateWindow is the sliding window used for per-PGN message rates
+const PGNRateWindow = 10 * time.Second
+
+// rateWindow counts events in one-second buckets over PGNRateWindow
+type rateWindow struct {
+	counts  [int(PGNRateWindow / time.Second)]int64
+	seconds [int(PGNRateWindow / time.Second)]int64
+}
+
+func (rw *rateWindow) add(t time.Time) {
+	sec := t.Unix()
+	i := int(sec % int64(len(rw.counts)))
+	if rw.seconds[i] != sec {
+		rw.seconds[i] = sec
+		rw.counts[i] = 0
+	}
+	rw.counts[i]++
+}
+
+// rate returns events per second over the window ending at now
+func (rw *rateWindow) rate(now time.Time) float64 {
+	sec := now.Unix()
+	n := int64(len(rw.counts))
+	var total int64
+	for i, s := range rw.seconds {
+		if s > sec-n && s <= sec {
+			total += rw.counts[i]
+		}
+	}
+	return float64(total) / float64(n)
+}
+
+func NewStatistics() *Statistics {
+	return &Statistics{
+		PGNCounts:         make(map[int]int64),
+		PGNFailures:       make(map[int]int64),
+		PGNLastSeen:       make(map[int]time.Time),
+		pgnRates:          make(map[int]*rateWindow),
+		MeasurementCounts: make(map[string]int64),
+		StartTime:         time.Now(),
+		LastUpdate:        time.Now(),
+	}
+}
+
+func (s *Statistics) RecordMessage(pgn int, measurement string, success bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.MessagesProcessed++
+	if success {
+		s.DecodeSuccesses++
+	} else {
+		s.DecodeFailures++
+		s.PGNFailures[pgn]++
+	}
+
+	now := time.Now()
+	s.PGNCounts[pgn]++
+	s.PGNLastSeen[pgn] = now
+	rw, ok := s.pgnRates[pgn]
+	if !ok {
+		rw = &rateWindow{}
+		s.pgnRates[pgn] = rw
+	}
+	rw.add(now)
+	s.MeasurementCounts[measurement]++
+	s.LastUpdate = now
+}
+
+// RecordEmptyFrame counts a keep-alive/all-sentinel frame that was dropped
+// before decoding. These are kept out of MessagesProcessed so they do not
+// skew the success rate.
+func (s *Statistics) RecordEmptyFrame() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.EmptyFrames++
+	s.LastUpdate = time.Now()
+}
+
+// RecordFrameDropped counts a raw frame discarded because the decode queue
+// was full
+func (s *Statistics) RecordFrameDropped() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.FramesDropped++
+}
+
+// RecordDecodedDropped counts a decoded message discarded because the
+// storage queue was full
+func (s *Statistics) RecordDecodedDropped() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.DecodedDropped++
+}
+
+// RecordPreferentialDrop counts a frame or message, already counted as
+// dropped, that was shed or evicted in favour of a critical PGN
+func (s *Statistics) RecordPreferentialDrop() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.PreferentialDrops++
+}
+
+// RecordSensorRejected counts a BoomSense payload that was not passed to
+// the sensor because it was incomplete
+func (s *Statistics) RecordSensorRejected() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.SensorRejected++
+}
+
+// RecordTextFrameErrors counts n Actisense or YDWG lines that could not
+// be parsed
+func (s *Statistics) RecordTextFrameErrors(n int) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.TextFrameErrors += int64(n)
+}
+
+// RecordFastPacketDropped counts a fast-packet message abandoned because a
+// fragment was missing or arrived out of order
+func (s *Statistics) RecordFastPacketDropped() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.FastPacketDropped++
+}
+
+func (s *Statistics) GetSnapshot() map[string]interface{} {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	successRate := 0.0
+	if s.MessagesProcessed > 0 {
+		successRate = float64(s.DecodeSuccesses) / float64(s.MessagesProcessed) * 100.0
+	}
+
+	uptime := time.Since(s.StartTime)
+	msgPerSec := 0.0
+	if uptime.Seconds() > 0 {
+		msgPerSec = float64(s.MessagesProcessed) / uptime.Seconds()
+	}
+
+	return map[string]interface{}{
+		"messages_processed":  s.MessagesProcessed,
+		"decode_successes":    s.DecodeSuccesses,
+		"decode_failures":     s.DecodeFailures,
+		"empty_frames":        s.EmptyFrames,
+		"frames_dropped":      s.FramesDropped,
+		"decoded_dropped":     s.DecodedDropped,
+		"preferential_drops":  s.PreferentialDrops,
+		"sensor_rejected":     s.SensorRejected,
+		"text_frame_errors":   s.TextFrameErrors,
+		"fast_packet_dropped": s.FastPacketDropped,
+		"success_rate":        successRate,
+		"uptime_seconds":      uptime.Seconds(),
+		"messages_per_sec":    msgPerSec,
+		"last_update":         s.LastUpdate,
+		"pgns":                s.pgnSnapshot(),
+		"pgn_success":         s.pgnSuccessSnapshot(),
+		"measurements":        s.measurementSnapshot(),
+	}
+}
+
+// measurementSnapshot copies the per-measurement message counts. Caller
+// must hold s.mu.
+func (s *Statistics) measurementSnapshot() map[string]interface{} {
+	counts := make(map[string]interface{}, len(s.MeasurementCounts))
+	for measurement, count := range s.MeasurementCounts {
+		counts[measurement] = count
+	}
+	return counts
+}
+
+// pgnSnapshot reports count, recent rate and last-seen time for every PGN
+// received, so a sensor that stops sending shows up as a growing age.
+// Caller must hold s.mu.
+func (s *Statistics) pgnSnapshot() map[int]interface{} {
+	now := time.Now()
+	pgns := make(map[int]interface{}, len(s.PGNCounts))
+	for pgn, count := range s.PGNCounts {
+		entry := map[string]interface{}{
+			"count":        count,
+			"rate_per_sec": 0.0,
+		}
+		if rw, ok := s.pgnRates[pgn]; ok {
+			entry["rate_per_sec"] = rw.rate(now)
+		}
+		if seen, ok := s.PGNLastSeen[pgn]; ok {
+			entry["last_seen"] = seen
+			entry["age_seconds"] = now.Sub(seen).Seconds()
+		}
+		pgns[pgn] = entry
+	}
+	return pgns
+}
+
+// pgnSuccessSnapshot breaks the decode success rate down by PGN, so a
+// single failing PGN (often an incomplete fast-packet) stands out from the
+// overall rate. Caller must hold s.mu.
+func (s *Statistics) pgnSuccessSnapshot() map[int]interface{} {
+	pgns := make(map[int]interface{}, len(s.PGNCounts))
+	for pgn, count := range s.PGNCounts {
+		fail := s.PGNFailures[pgn]
+		rate := 0.0
+		if count > 0 {
+			rate = float64(count-fail) / float64(count) * 100.0
+		}
+		pgns[pgn] = map[string]interface{}{
+			"success": count - fail,
+			"fail":    fail,
+			"rate":    rate,
+		}
+	}
+	return pgns
+}
+
+// PGNInfo describes one PGN seen on the bus
+type PGNInfo struct {
+	PGN         int       `json:"pgn"`
+	Name        string    `json:"name"`
+	Measurement string    `json:"measurement"`
+	Count       int64     `json:"count"`
+	RatePerSec  float64   `json:"rate_per_sec"`
+	LastSeen    time.Time `json:"last_seen"`
+	AgeSeconds  float64   `json:"age_seconds"`
+	Decoded     bool      `json:"decoded"` // a decoder is registered
+}
+
+// PGNActivity lists every PGN received so far, ordered by PGN. Decoded is
+// left for the caller, which owns the decoder.
+func (s *Statistics) PGNActivity() []PGNInfo {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	now := time.Now()
+	infos := make([]PGNInfo, 0, len(s.PGNCounts))
+	for pgn, count := range s.PGNCounts {
+		info := PGNInfo{
+			PGN:         pgn,
+			Name:        GetPGNName(pgn),
+			Measurement: GetMeasurementType(pgn),
+			Count:       count,
+		}
+		if rw, ok := s.pgnRates[pgn]; ok {
+			info.RatePerSec = rw.rate(now)
+		}
+		if seen, ok := s.PGNLastSeen[pgn]; ok {
+			info.LastSeen = seen
+			info.AgeSeconds = now.Sub(seen).Seconds()
+		}
+		infos = append(infos, info)
+	}
+
+	sort.Slice(infos, func(i, j int) bool { return infos[i].PGN < infos[j].PGN })
+	return infos
+}
+
+// TopicList is a set of MQTT topic filters. In JSON it accepts either a
+// single string (the original format) or an array of strings.
+type TopicList []string
+
+// ParseTopicList splits a comma-separated list, as used by the environment
+// and command line, dropping empty entries
+func ParseTopicList(s string) TopicList {
+	var topics TopicList
+	for _, t := range strings.Split(s, ",") {
+		if t = strings.TrimSpace(t); t != "" {
+			topics = append(topics, t)
+		}
+	}
+	return topics
+}
+
+func (t *TopicList) UnmarshalJSON(data []byte) error {
+	var single string
+	if err := json.Unmarshal(data, &single); err == nil {
+		*t = ParseTopicList(single)
+		return nil
+	}
+
+	var list []string
+	if err := json.Unmarshal(data, &list); err != nil {
+		return err
+	}
+	*t = ParseTopicList(strings.Join(list, ","))
+	return nil
+}
+
+func (t TopicList) String() string {
+	return strings.Join(t, ", ")
+}
+
+// Decoded log formats selectable through Config.OutputFormat
+const (
+	OutputFormatCSV   = "csv"
+	OutputFormatJSONL = "jsonl"
+)
+
+// Config holds NMEA collector configuration
+type Config struct {
+	Source          string     `json:"source"` // "mqtt" or "replay"
+	MQTTBroker      string     `json:"mqtt_broker"`
+	MQTTPort        int        `json:"mqtt_port"`
+	MQTTUsername    string     `json:"mqtt_username"`
+	MQTTPassword    string     `json:"mqtt_password"`
+	MQTTTopic       TopicList  `json:"mqtt_topic"` // one topic or a list
+	UseTLS          bool       `json:"use_tls"`
+	InsecureSkipTLS bool       `json:"insecure_skip_tls"`
+	DeviceID        string     `json:"device_id"`
+	BufferSize      int        `json:"buffer_size"`
+	DecoderWorkers  int        `json:"decoder_workers"` // 0 = one per CPU
+	QueueSize       int        `json:"queue_size"`
+	EnableCSV       bool       `json:"enable_csv"`
+	CSVFramesPath   string     `json:"csv_frames_path"`
+	CSVDecodedPath  string     `json:"csv_decoded_path"`
+	CSVStatsPath    string     `json:"csv_stats_path"`
+	Units           UnitConfig `json:"units"`
+
+	// Decoded log format: "csv" (one row per field) or "jsonl" (one object
+	// per message, written to JSONLDecodedPath). Frames and stats stay CSV.
+	OutputFormat     string `json:"output_format"`
+	JSONLDecodedPath string `json:"jsonl_decoded_path"`
+
+	// InfluxDB v2 output for decoded messages, alongside the CSV/JSONL logs
+	// (InfluxURL "" = off)
+	InfluxURL    string `json:"influx_url"`
+	InfluxOrg    string `json:"influx_org"`
+	InfluxBucket string `json:"influx_bucket"`
+	InfluxToken  string `json:"influx_token"`
+
+	// CSV rotation
+	CSVMaxSizeBytes int64 `json:"csv_max_size_bytes"` // rotate when a file would exceed this size (0 = unlimited)
+	CSVRotateDaily  bool  `json:"csv_rotate_daily"`   // rotate when the UTC date changes
+
+	// Decoded CSV values: float decimals (-1 = shortest exact form), per
+	// measurement overrides, dropping list/record fields instead of writing
+	// them as JSON, and an optional allowlist of field names to log
+	CSVPrecision              int            `json:"csv_precision"`
+	CSVPrecisionByMeasurement map[string]int `json:"csv_precision_by_measurement"`
+	CSVSkipComposite          bool           `json:"csv_skip_composite"`
+	CSVFields                 []string       `json:"csv_fields"`
+
+	// Emit fields the sender marked "not available" as null instead of
+	// omitting them
+	MarkInvalidFields bool `json:"mark_invalid_fields"`
+
+	// Transducer offset for depth sounders that do not report one (metres;
+	// positive = below waterline, negative = above keel, 0 = none)
+	DepthOffsetM float64 `json:"depth_offset_m"`
+
+	// MQTT payload format: "auto", "json", "actisense" or "ydwg"
+	FrameFormat string `json:"frame_format"`
+
+	// Directory for session recordings started through /api/record/start
+	RecordDir string `json:"record_dir"`
+
+	// Ring buffer sized by history instead of message count: when
+	// BufferDuration is set, the buffer holds BufferDuration at
+	// BufferRateHz decoded messages per second and BufferSize is ignored.
+	// Check buffer.bytes_estimate in /api/nmea/status against the RAM
+	// available before going large.
+	BufferDuration time.Duration `json:"buffer_duration_ns"`
+	BufferRateHz   float64       `json:"buffer_rate_hz"`
+
+	// Ring buffer persistence across restarts, off unless a path is set.
+	// The snapshot is loaded at startup and saved on graceful shutdown.
+	BufferSnapshotPath string `json:"buffer_snapshot_path"`
+
+	// Replay source (Source = "replay")
+	ReplayPath  string  `json:"replay_path"`  // frames CSV to replay
+	ReplaySpeed float64 `json:"replay_speed"` // pacing multiplier, 0 = as fast as possible
+	ReplayLoop  bool    `json:"replay_loop"`  // restart at end of file
+
+	// Additional NMEA 0183 input read alongside Source, e.g. an AIS
+	// receiver: "udp://:10110" listens for UDP datagrams, anything else is
+	// a serial device path whose line settings are already configured
+	// (e.g. stty -F /dev/ttyUSB0 38400 raw). "" = off.
+	NMEA0183Input string `json:"nmea0183_input"`
+
+	// MQTT reconnect backoff: the delay doubles from ReconnectMinInterval up
+	// to ReconnectMaxInterval, randomised by ±ReconnectJitter of itself so a
+	// flaky link is not hammered at a fixed rhythm
+	ReconnectMinInterval time.Duration `json:"reconnect_min_interval_ns"`
+	ReconnectMaxInterval time.Duration `json:"reconnect_max_interval_ns"`
+	ReconnectJitter      float64       `json:"reconnect_jitter"` // fraction, 0-1
+
+	// Keep retrying the first connection in the background instead of
+	// failing Start when the broker is not up yet
+	ConnectRetryForever bool `json:"connect_retry_forever"`
+
+	// Subscription health watchdog
+	DataTimeout          time.Duration `json:"data_timeout_ns"`        // no data for this long triggers re-subscribe
+	MaxSubscribeFailures int           `json:"max_subscribe_failures"` // consecutive failures before unhealthy
+
+	// Empty frame rejection (all-0xFF keep-alives from some gateways)
+	DropEmptyFrames bool  `json:"drop_empty_frames"` // drop empty frames for every PGN
+	DropEmptyPGNs   []int `json:"drop_empty_pgns"`   // drop empty frames only for these PGNs
+
+	// Queue protection under load: past QueueHighWater (a fraction of
+	// QueueSize, 0 = off) the decode and storage queues only accept
+	// CriticalPGNs and frames of CAN priority 0-2, and a critical item
+	// finding a queue full displaces the oldest queued one
+	CriticalPGNs   []int   `json:"critical_pgns"`
+	QueueHighWater float64 `json:"queue_high_water"`
+
+	// Route every frame from one source address to the same decoder worker,
+	// so a sender's frames are stored in arrival order and the buffer's
+	// latest for a PGN is never overtaken by an older frame decoded on
+	// another worker
+	DecoderPinSources bool `json:"decoder_pin_sources"`
+}
+
+// DecoderWorkerCount is the number of decode workers to run:
+// DecoderWorkers, or one per CPU when it is 0
+func (c Config) DecoderWorkerCount() int {
+	if c.DecoderWorkers > 0 {
+		return c.DecoderWorkers
+	}
+	return runtime.NumCPU()
+}
+
+// BufferCapacity is the number of messages the ring buffer should hold:
+// BufferDuration at BufferRateHz when a duration is set, else BufferSize
+func (c Config) BufferCapacity() int {
+	if c.BufferDuration > 0 && c.BufferRateHz > 0 {
+		return int(math.Ceil(c.BufferDuration.Seconds() * c.BufferRateHz))
+	}
+	return c.BufferSize
+}
+
+func DefaultConfig() Config {
+	return Config{
+		Source:          SourceMQTT,
+		MQTTBroker:      "localhost", // credentials come from config/env (MQTT_USERNAME, MQTT_PASSWORD)
+		MQTTPort:        1883,
+		MQTTTopic:       TopicList{"boats/esp32s3-dev01/#"},
+		UseTLS:          false,
+		InsecureSkipTLS: false,
+		DeviceID:        "esp32s3-dev01",
+		BufferSize:      86400,
+		DecoderWorkers:  0,
+		QueueSize:       1000,
+		EnableCSV:       true,
+		CSVFramesPath:   "data/frames.csv",
+		CSVDecodedPath:  "data/decoded_long.csv",
+		CSVStatsPath:    "data/decode_stats.csv",
+		Units:           DefaultUnitConfig(),
+		DropEmptyFrames: false,
+
+		OutputFormat:     OutputFormatCSV,
+		JSONLDecodedPath: "data/decoded.jsonl",
+
+		CSVMaxSizeBytes: 100 * 1024 * 1024,
+		CSVRotateDaily:  true,
+		CSVPrecision:    -1,
+
+		FrameFormat: FrameFormatAuto,
+
+		BufferRateHz: 50,
+		RecordDir:    "data/sessions",
+
+		ReplaySpeed: 1.0,
+
+		ReconnectMinInterval: 1 * time.Second,
+		ReconnectMaxInterval: 2 * time.Minute,
+		ReconnectJitter:      0.2,
+		ConnectRetryForever:  true,
+
+		DataTimeout:          30 * time.Second,
+		MaxSubscribeFailures: 3,
+
+		DecoderPinSources: true,
+
+		CriticalPGNs:   append([]int(nil), DefaultCriticalPGNs...),
+		QueueHighWater: 0.8,
+	}
 }
\ No newline at end of file
@@ -0,0 +1,200 @@
+package main
+
+import (
+	"encoding/json"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/gorilla/websocket"
+
+	"odysail-boat-viz/integration"
+)
+
+const (
+	wsDefaultHz  = 1.0
+	wsMaxHz      = 20.0
+	wsWriteWait  = 5 * time.Second
+	wsPongWait   = 60 * time.Second
+	wsPingPeriod = 50 * time.Second
+)
+
+var wsUpgrader = websocket.Upgrader{
+	ReadBufferSize:  1024,
+	WriteBufferSize: 4096,
+	// Replaced in main with the configured CORS policy
+	CheckOrigin: func(r *http.Request) bool { return true },
+}
+
+// wsControl is a client request on /api/nmea/ws, e.g.
+// {"subscribe":[130306,127257],"hz":5}. Omitted fields leave the current
+// setting unchanged; an empty subscribe list clears the subscription.
+type wsControl struct {
+	Subscribe *[]int   `json:"subscribe"`
+	Hz        *float64 `json:"hz"`
+}
+
+// wsPGNUpdate is pushed for each subscribed PGN whenever it has a newer
+// message than the last one sent
+type wsPGNUpdate struct {
+	PGN       int                    `json:"pgn"`
+	PGNName   string                 `json:"pgn_name"`
+	Source    uint8                  `json:"source"`
+	Timestamp int64                  `json:"timestamp"`
+	Fields    map[string]interface{} `json:"fields"`
+}
+
+// wsEventMessage wraps a detected BoomSense event
+type wsEventMessage struct {
+	Event streamEvent `json:"event"`
+}
+
+// wsAlarmMessage wraps a raised or cleared alarm
+type wsAlarmMessage struct {
+	Alarm integration.AlarmEvent `json:"alarm"`
+}
+
+// handleNMEAWebSocket pushes the same BoomSense payload as the SSE stream at
+// 1 Hz, plus detected BoomSense events and alarms as they happen. Clients
+// may send wsControl messages to change the rate and to also receive the
+// latest decoded messages for specific PGNs.
+func handleNMEAWebSocket(w http.ResponseWriter, r *http.Request) {
+	conn, err := wsUpgrader.Upgrade(w, r, nil)
+	if err != nil {
+		// Upgrade has already replied with an HTTP error
+		return
+	}
+	defer conn.Close()
+
+	controls := make(chan wsControl, 4)
+	closed := make(chan struct{})
+	done := make(chan struct{})
+	defer close(done)
+	go wsReadLoop(conn, controls, closed, done)
+
+	hz := wsDefaultHz
+	subscribed := map[int]time.Time{}
+
+	ticker := time.NewTicker(wsInterval(hz))
+	defer ticker.Stop()
+	pinger := time.NewTicker(wsPingPeriod)
+	defer pinger.Stop()
+
+	events, unsubscribe := boomEvents.Subscribe()
+	defer unsubscribe()
+	alarms, unsubscribeAlarms := alarmEvents.Subscribe()
+	defer unsubscribeAlarms()
+
+	for {
+		select {
+		case ctl := <-controls:
+			if ctl.Hz != nil && *ctl.Hz > 0 {
+				hz = *ctl.Hz
+				if hz > wsMaxHz {
+					hz = wsMaxHz
+				}
+				ticker.Reset(wsInterval(hz))
+			}
+			if ctl.Subscribe != nil {
+				subscribed = make(map[int]time.Time, len(*ctl.Subscribe))
+				for _, pgn := range *ctl.Subscribe {
+					subscribed[pgn] = time.Time{}
+				}
+			}
+
+		case <-ticker.C:
+			if boomMapper != nil {
+				if err := wsSend(conn, boomMapper.GetCurrentData()); err != nil {
+					return
+				}
+			}
+
+			if nmeaCollector == nil || len(subscribed) == 0 {
+				continue
+			}
+			for pgn, lastSent := range subscribed {
+				msg := nmeaCollector.Buffer().GetLatestByPGN(pgn)
+				if msg == nil || !msg.Timestamp.After(lastSent) {
+					continue
+				}
+				update := wsPGNUpdate{
+					PGN:       msg.PGN,
+					PGNName:   msg.PGNName,
+					Source:    msg.Source,
+					Timestamp: msg.Timestamp.UnixMilli(),
+					Fields:    msg.Fields,
+				}
+				if err := wsSend(conn, update); err != nil {
+					return
+				}
+				subscribed[pgn] = msg.Timestamp
+			}
+
+		case evt := <-events:
+			if err := wsSend(conn, wsEventMessage{Event: evt}); err != nil {
+				return
+			}
+
+		case alarm := <-alarms:
+			if err := wsSend(conn, wsAlarmMessage{Alarm: alarm}); err != nil {
+				return
+			}
+
+		case <-pinger.C:
+			if err := conn.WriteControl(websocket.PingMessage, nil, time.Now().Add(wsWriteWait)); err != nil {
+				return
+			}
+
+		case <-closed:
+			return
+		case <-r.Context().Done():
+			return
+		}
+	}
+}
+
+// wsReadLoop decodes client control messages until the connection closes.
+// Malformed messages are ignored so listen-only clients are unaffected.
+func wsReadLoop(conn *websocket.Conn, controls chan<- wsControl, closed chan<- struct{}, done <-chan struct{}) {
+	defer close(closed)
+
+	conn.SetReadLimit(4096)
+	conn.SetReadDeadline(time.Now().Add(wsPongWait))
+	conn.SetPongHandler(func(string) error {
+		return conn.SetReadDeadline(time.Now().Add(wsPongWait))
+	})
+
+	for {
+		_, data, err := conn.ReadMessage()
+		if err != nil {
+			if websocket.IsUnexpectedCloseError(err, websocket.CloseGoingAway, websocket.CloseNormalClosure) {
+				log.Printf("[WS] Read error: %v", err)
+			}
+			return
+		}
+		conn.SetReadDeadline(time.Now().Add(wsPongWait))
+
+		var ctl wsControl
+		if err := json.Unmarshal(data, &ctl); err != nil {
+			continue
+		}
+		select {
+		case controls <- ctl:
+		case <-done:
+			return
+		}
+	}
+}
+
+func wsSend(conn *websocket.Conn, v interface{}) error {
+	data, err := marshalJSON(v)
+	if err != nil {
+		return err
+	}
+	conn.SetWriteDeadline(time.Now().Add(wsWriteWait))
+	return conn.WriteMessage(websocket.TextMessage, data)
+}
+
+func wsInterval(hz float64) time.Duration {
+	return time.Duration(float64(time.Second) / hz)
+}
@@ -0,0 +1,110 @@
+package integration
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"math"
+	"sort"
+)
+
+// WindCorrectionTable holds the upwash calibration for a masthead wind
+// sensor: Correction[i][j] is the number of degrees to add to a measured
+// true wind angle of TWA[i] in TWS[j] knots. Angles are 0-180 off the bow
+// and apply to both tacks. Between breakpoints the correction is
+// interpolated bilinearly; outside the table the nearest edge is used.
+//
+//	{
+//	  "tws_kts": [6, 10, 16],
+//	  "twa_deg": [40, 90, 150],
+//	  "correction_deg": [[-3, -2.5, -2], [0, 0, 0], [1, 1.5, 2]]
+//	}
+type WindCorrectionTable struct {
+	TWS        []float64   `json:"tws_kts"`
+	TWA        []float64   `json:"twa_deg"`
+	Correction [][]float64 `json:"correction_deg"`
+}
+
+// LoadWindCorrectionTable reads and validates a correction table from a
+// JSON file
+func LoadWindCorrectionTable(path string) (*WindCorrectionTable, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read wind correction table: %w", err)
+	}
+
+	var t WindCorrectionTable
+	if err := json.Unmarshal(data, &t); err != nil {
+		return nil, fmt.Errorf("failed to parse wind correction table %s: %w", path, err)
+	}
+	if err := t.Validate(); err != nil {
+		return nil, fmt.Errorf("wind correction table %s: %w", path, err)
+	}
+	return &t, nil
+}
+
+// Validate checks the breakpoints are increasing and the grid matches them
+func (t *WindCorrectionTable) Validate() error {
+	if len(t.TWS) == 0 || len(t.TWA) == 0 {
+		return fmt.Errorf("tws_kts and twa_deg must not be empty")
+	}
+	if !strictlyIncreasing(t.TWS) {
+		return fmt.Errorf("tws_kts must be strictly increasing")
+	}
+	if !strictlyIncreasing(t.TWA) || t.TWA[0] < 0 || t.TWA[len(t.TWA)-1] > 180 {
+		return fmt.Errorf("twa_deg must be strictly increasing within 0-180")
+	}
+	if len(t.Correction) != len(t.TWA) {
+		return fmt.Errorf("correction_deg has %d rows, want one per twa_deg (%d)", len(t.Correction), len(t.TWA))
+	}
+	for i, row := range t.Correction {
+		if len(row) != len(t.TWS) {
+			return fmt.Errorf("correction_deg row %d has %d values, want one per tws_kts (%d)", i, len(row), len(t.TWS))
+		}
+		for _, c := range row {
+			if math.IsNaN(c) || math.Abs(c) > 45 {
+				return fmt.Errorf("correction_deg row %d: corrections must be within ±45 degrees", i)
+			}
+		}
+	}
+	return nil
+}
+
+func strictlyIncreasing(v []float64) bool {
+	for i := range v {
+		if math.IsNaN(v[i]) || (i > 0 && v[i] <= v[i-1]) {
+			return false
+		}
+	}
+	return true
+}
+
+// Correct returns the corrected true wind angle for a measured twa (0-180)
+// in tws knots, kept within 0-180. A nil table returns twa unchanged.
+func (t *WindCorrectionTable) Correct(tws, twa float64) float64 {
+	if t == nil {
+		return twa
+	}
+
+	i0, i1, fa := bracket(t.TWA, twa)
+	j0, j1, fs := bracket(t.TWS, tws)
+
+	lo := t.Correction[i0][j0]*(1-fs) + t.Correction[i0][j1]*fs
+	hi := t.Correction[i1][j0]*(1-fs) + t.Correction[i1][j1]*fs
+	return math.Max(0, math.Min(180, twa+lo*(1-fa)+hi*fa))
+}
+
+// bracket finds the breakpoints around x and x's fraction of the way from
+// the first to the second, clamped to the ends of the axis
+func bracket(axis []float64, x float64) (lo, hi int, frac float64) {
+	n := len(axis)
+	switch {
+	case n == 1 || x <= axis[0]:
+		return 0, 0, 0
+	case x >= axis[n-1]:
+		return n - 1, n - 1, 0
+	}
+	hi = sort.SearchFloat64s(axis, x)
+	lo = hi - 1
+	return lo, hi, (x - axis[lo]) / (axis[hi] - axis[lo])
+}
@@ -0,0 +1,55 @@
+package integration
+
+import (
+	"math"
+	"time"
+)
+
+// WindStats summarises true wind speed over a rolling window
+type WindStats struct {
+	WindowSeconds float64 `json:"window_seconds"`
+	Samples       int     `json:"samples"`
+	MeanTWS       float64 `json:"mean_tws_kts"`
+	StdDevTWS     float64 `json:"stddev_tws_kts"`
+	Gust          float64 `json:"gust_kts"` // max TWS in the window
+	Lull          float64 `json:"lull_kts"` // min TWS in the window
+}
+
+// WindStats computes gust, lull, mean and standard deviation of true wind
+// speed from the PGN 130306 messages in the last WindStatsWindow. Apparent
+// wind samples are converted with the current boat speed and heading, which
+// is a fair approximation over a window of a minute or so. ok is false when
+// the window holds no usable samples.
+func (m *BoomSenseMapper) WindStats() (WindStats, bool) {
+	stats := WindStats{WindowSeconds: m.WindStatsWindow.Seconds()}
+
+	end := time.Now()
+	msgs := m.buffer.GetByPGNTimeRange(130306, end.Add(-m.WindStatsWindow), end)
+
+	var sum, sumSq float64
+	for i := range msgs {
+		tws, _ := m.trueWind(m.windFromMessage(&msgs[i]))
+		if tws <= 0 || math.IsNaN(tws) {
+			continue
+		}
+
+		if stats.Samples == 0 || tws > stats.Gust {
+			stats.Gust = tws
+		}
+		if stats.Samples == 0 || tws < stats.Lull {
+			stats.Lull = tws
+		}
+		sum += tws
+		sumSq += tws * tws
+		stats.Samples++
+	}
+
+	if stats.Samples == 0 {
+		return stats, false
+	}
+
+	n := float64(stats.Samples)
+	stats.MeanTWS = sum / n
+	stats.StdDevTWS = math.Sqrt(math.Max(0, sumSq/n-stats.MeanTWS*stats.MeanTWS))
+	return stats, true
+}